@@ -19,7 +19,9 @@ import (
 	_ "github.com/go-chassis/go-chassis/v2/pkg/loadbalancing"
 
 	//protocols
+	_ "github.com/go-chassis/go-chassis/v2/client/highway"
 	_ "github.com/go-chassis/go-chassis/v2/client/rest"
+	_ "github.com/go-chassis/go-chassis/v2/server/highway"
 	_ "github.com/go-chassis/go-chassis/v2/server/restful"
 
 	"github.com/go-chassis/go-chassis/v2/core/common"
@@ -31,6 +33,12 @@ import (
 	//control panel
 	_ "github.com/go-chassis/go-chassis/v2/control/servicecomb"
 	// registry
+	_ "github.com/go-chassis/go-chassis/v2/core/registry/composite"
+	_ "github.com/go-chassis/go-chassis/v2/core/registry/consul"
+	_ "github.com/go-chassis/go-chassis/v2/core/registry/dns"
+	_ "github.com/go-chassis/go-chassis/v2/core/registry/etcd"
+	_ "github.com/go-chassis/go-chassis/v2/core/registry/file"
+	_ "github.com/go-chassis/go-chassis/v2/core/registry/gossip"
 	_ "github.com/go-chassis/go-chassis/v2/core/registry/servicecenter"
 	"github.com/go-chassis/go-chassis/v2/core/server"
 	// prometheus reporter for circuit breaker metrics
@@ -150,19 +158,19 @@ func waitingSignal() {
 func GracefulShutdown(s os.Signal) {
 	if !config.GetRegistratorDisable() {
 		registry.HBService.Stop()
+		// consumers stop sending new requests once they know the instance is DOWN
+		if err := server.MarkSelfInstanceDown(); err == nil {
+			server.WaitForPropagation(config.GetDrainPropagationDelay())
+		}
 		openlog.Info("unregister servers ...")
 		if err := server.UnRegistrySelfInstances(); err != nil {
 			openlog.Warn("servers failed to unregister: " + err.Error())
 		}
 	}
 
-	for name, s := range server.GetServers() {
-		openlog.Info("stopping server " + name + "...")
-		err := s.Stop()
-		if err != nil {
-			openlog.Warn("servers failed to stop: " + err.Error())
-		}
-		openlog.Info(name + " server stop success")
+	server.StopServers(config.GetDrainTimeout())
+	if err := registry.CloseServiceDiscovery(); err != nil {
+		openlog.Warn("close service discovery failed: " + err.Error())
 	}
 
 	openlog.Info("go chassis server gracefully shutdown")
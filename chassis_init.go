@@ -38,6 +38,7 @@ import (
 	"github.com/go-chassis/go-chassis/v2/core/registry"
 	"github.com/go-chassis/go-chassis/v2/core/router"
 	"github.com/go-chassis/go-chassis/v2/core/server"
+	"github.com/go-chassis/go-chassis/v2/health"
 	"github.com/go-chassis/go-chassis/v2/pkg/backends/quota"
 	"github.com/go-chassis/go-chassis/v2/pkg/metrics"
 	"github.com/go-chassis/go-chassis/v2/pkg/runtime"
@@ -139,6 +140,7 @@ func (c *chassis) initialize() error {
 		if err = loadbalancer.Enable(strategyName); err != nil {
 			return err
 		}
+		health.EnableActiveCheck()
 	}
 
 	err = configserver.Init()
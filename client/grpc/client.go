@@ -0,0 +1,146 @@
+// Package grpc is the gRPC protocol client plugin, it is used by transport handler
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/go-chassis/go-chassis/v2/core/client"
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/invocation"
+	"github.com/go-chassis/go-chassis/v2/core/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+// Name is the protocol name of gRPC client
+const Name = common.ProtocolGRPC
+
+// ErrInvalidReq means invocation can not be converted to a gRPC call
+var ErrInvalidReq = errors.New("gRPC invocation must have schema id as service name and operation id as method name")
+
+func init() {
+	client.InstallPlugin(Name, New)
+	status.Register(Name, map[string]int{
+		status.Unauthorized:        int(codes.Unauthenticated),
+		status.InternalServerError: int(codes.Internal),
+		status.ServiceUnavailable:  int(codes.Unavailable),
+	})
+}
+
+// Client is gRPC client plugin, it keeps one connection for each address
+type Client struct {
+	opts  client.Options
+	mu    sync.RWMutex
+	conns map[string]*grpc.ClientConn
+}
+
+// New returns a gRPC client
+func New(opts client.Options) (client.ProtocolClient, error) {
+	return &Client{
+		opts:  opts,
+		conns: make(map[string]*grpc.ClientConn),
+	}, nil
+}
+
+func (c *Client) getConn(addr string) (*grpc.ClientConn, error) {
+	c.mu.RLock()
+	conn, ok := c.conns[addr]
+	c.mu.RUnlock()
+	if ok {
+		return conn, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if conn, ok = c.conns[addr]; ok {
+		return conn, nil
+	}
+	creds := insecure.NewCredentials()
+	if c.opts.TLSConfig != nil {
+		creds = credentials.NewTLS(c.opts.TLSConfig)
+	}
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, client.TransportFailure{Message: err.Error()}
+	}
+	c.conns[addr] = conn
+	return conn, nil
+}
+
+// Call invokes "/{SchemaID}/{OperationID}" on addr, invocation headers are sent as gRPC metadata
+func (c *Client) Call(ctx context.Context, addr string, inv *invocation.Invocation, rsp interface{}) error {
+	if inv.SchemaID == "" || inv.OperationID == "" {
+		return ErrInvalidReq
+	}
+	conn, err := c.getConn(addr)
+	if err != nil {
+		return err
+	}
+	c.mu.RLock()
+	timeout := c.opts.Timeout
+	c.mu.RUnlock()
+	if _, ok := ctx.Deadline(); !ok && timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	ctx = metadata.NewOutgoingContext(ctx, metadata.New(common.FromContext(ctx)))
+	err = conn.Invoke(ctx, "/"+inv.SchemaID+"/"+inv.OperationID, inv.Args, rsp)
+	switch grpcstatus.Code(err) {
+	case codes.OK:
+		return nil
+	case codes.Canceled:
+		return client.ErrCanceled
+	case codes.Unavailable:
+		return client.TransportFailure{Message: err.Error()}
+	default:
+		return err
+	}
+}
+
+// Status returns gRPC OK code if response is set, gRPC errors are returned by Call
+func (c *Client) Status(rsp interface{}) (status int, err error) {
+	if rsp == nil {
+		return 0, fmt.Errorf("incompatible type: %s", reflect.TypeOf(rsp))
+	}
+	return int(codes.OK), nil
+}
+
+func (c *Client) String() string {
+	return "grpc_client"
+}
+
+// Close closes all connections
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var err error
+	for addr, conn := range c.conns {
+		if e := conn.Close(); e != nil {
+			err = e
+		}
+		delete(c.conns, addr)
+	}
+	return err
+}
+
+// ReloadConfigs reloads timeout and TLS config, TLS change takes effect on new connections
+func (c *Client) ReloadConfigs(opts client.Options) {
+	c.mu.Lock()
+	c.opts = client.EqualOpts(c.opts, opts)
+	c.mu.Unlock()
+}
+
+// GetOptions returns client options
+func (c *Client) GetOptions() client.Options {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.opts
+}
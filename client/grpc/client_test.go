@@ -0,0 +1,98 @@
+package grpc_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/go-archaius"
+	grpcclient "github.com/go-chassis/go-chassis/v2/client/grpc"
+	"github.com/go-chassis/go-chassis/v2/core/client"
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/config"
+	"github.com/go-chassis/go-chassis/v2/core/handler"
+	"github.com/go-chassis/go-chassis/v2/core/invocation"
+	"github.com/go-chassis/go-chassis/v2/core/lager"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/go-chassis/v2/core/server"
+	"github.com/go-chassis/go-chassis/v2/examples/schemas"
+	_ "github.com/go-chassis/go-chassis/v2/server/grpc"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func init() {
+	lager.Init(&lager.Options{
+		LoggerLevel: "INFO",
+	})
+	archaius.Init(archaius.WithMemorySource())
+	config.ReadGlobalConfigFromArchaius()
+}
+
+type headerHandler struct{}
+
+func (h *headerHandler) Handle(chain *handler.Chain, i *invocation.Invocation, cb invocation.ResponseCallBack) {
+	chain.Next(i, func(r *invocation.Response) {
+		if reply, ok := r.Result.(*wrapperspb.StringValue); ok {
+			reply.Value = reply.Value + " from " + i.SourceMicroService
+		}
+		cb(r)
+	})
+}
+
+func (h *headerHandler) Name() string {
+	return "grpc-test-header"
+}
+
+func TestClient_Call(t *testing.T) {
+	err := handler.RegisterHandler("grpc-test-header", func() handler.Handler { return &headerHandler{} })
+	assert.NoError(t, err)
+	err = handler.CreateChains(common.Provider, map[string]string{"grpc-client-test": "grpc-test-header"})
+	assert.NoError(t, err)
+	f, err := server.GetServerFunc("grpc")
+	assert.NoError(t, err)
+	s := f(server.Options{
+		Address:            "127.0.0.1:0",
+		ProtocolServerName: "grpc",
+		ChainName:          "grpc-client-test",
+	})
+	_, err = s.Register(&schemas.GrpcHello{}, server.WithRPCServiceDesc(&schemas.GreeterServiceDesc))
+	assert.NoError(t, err)
+	err = s.Start()
+	assert.NoError(t, err)
+	defer s.Stop()
+	addr := registry.InstanceEndpoints["grpc"]
+
+	c, err := grpcclient.New(client.Options{Timeout: 3 * time.Second})
+	assert.NoError(t, err)
+	defer c.Close()
+	assert.Equal(t, "grpc_client", c.String())
+
+	t.Run("call with headers, should success", func(t *testing.T) {
+		inv := invocation.New(common.NewContext(map[string]string{common.HeaderSourceName: "consumer"}))
+		inv.SchemaID = "helloworld.Greeter"
+		inv.OperationID = "SayHello"
+		inv.Args = wrapperspb.String("peter")
+		reply := &wrapperspb.StringValue{}
+		err := c.Call(inv.Ctx, addr, inv, reply)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello peter from consumer", reply.GetValue())
+		code, err := c.Status(reply)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, code)
+	})
+	t.Run("call without operation, should fail", func(t *testing.T) {
+		inv := invocation.New(context.Background())
+		inv.SchemaID = "helloworld.Greeter"
+		err := c.Call(inv.Ctx, addr, inv, &wrapperspb.StringValue{})
+		assert.Equal(t, grpcclient.ErrInvalidReq, err)
+	})
+	t.Run("call unknown method, should fail", func(t *testing.T) {
+		inv := invocation.New(context.Background())
+		inv.SchemaID = "helloworld.Greeter"
+		inv.OperationID = "SayBye"
+		inv.Args = wrapperspb.String("peter")
+		err := c.Call(inv.Ctx, addr, inv, &wrapperspb.StringValue{})
+		assert.Error(t, err)
+	})
+}
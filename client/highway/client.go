@@ -0,0 +1,291 @@
+// Package highway is the highway protocol client plugin,
+// one connection is kept for each provider address and requests are multiplexed on it
+package highway
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chassis/go-chassis/v2/core/client"
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/invocation"
+	"github.com/go-chassis/go-chassis/v2/pkg/highway"
+	"github.com/go-chassis/openlog"
+)
+
+// Name is the protocol name of highway client
+const Name = common.ProtocolHighway
+
+// DefaultDialTimeout is the timeout of establishing connection
+const DefaultDialTimeout = 10 * time.Second
+
+// ErrInvalidReq means invocation can not be converted to a highway request
+var ErrInvalidReq = errors.New("highway invocation must have schema id and operation id")
+
+func init() {
+	client.InstallPlugin(Name, New)
+}
+
+// Client is highway client plugin
+type Client struct {
+	opts  client.Options
+	mu    sync.RWMutex
+	conns map[string]*conn
+	dials map[string]*dial
+}
+
+// New returns a highway client
+func New(opts client.Options) (client.ProtocolClient, error) {
+	return &Client{
+		opts:  opts,
+		conns: make(map[string]*conn),
+		dials: make(map[string]*dial),
+	}, nil
+}
+
+type result struct {
+	header *highway.ResponseHeader
+	body   []byte
+	err    error
+}
+
+// conn is a multiplexed connection, responses are dispatched to callers by msg id
+type conn struct {
+	c       net.Conn
+	msgID   int64
+	wmu     sync.Mutex
+	mu      sync.Mutex
+	pending map[int64]chan *result
+	err     error
+}
+
+// dial is an in-flight connection establishment, callers of the same address wait for it
+type dial struct {
+	done chan struct{}
+	hc   *conn
+	err  error
+}
+
+// getConn returns the connection of addr, dials it if there is not one,
+// dialing is done without holding client lock, so that a slow address does not block calls to others
+func (c *Client) getConn(addr string) (*conn, error) {
+	c.mu.RLock()
+	hc, ok := c.conns[addr]
+	c.mu.RUnlock()
+	if ok {
+		return hc, nil
+	}
+	c.mu.Lock()
+	if hc, ok = c.conns[addr]; ok {
+		c.mu.Unlock()
+		return hc, nil
+	}
+	if d, ok := c.dials[addr]; ok {
+		c.mu.Unlock()
+		<-d.done
+		return d.hc, d.err
+	}
+	d := &dial{done: make(chan struct{})}
+	c.dials[addr] = d
+	tlsConfig := c.opts.TLSConfig
+	c.mu.Unlock()
+
+	d.hc, d.err = dialConn(addr, tlsConfig)
+	c.mu.Lock()
+	delete(c.dials, addr)
+	if d.err == nil {
+		c.conns[addr] = d.hc
+		go c.read(addr, d.hc)
+	}
+	c.mu.Unlock()
+	close(d.done)
+	return d.hc, d.err
+}
+
+func dialConn(addr string, tlsConfig *tls.Config) (*conn, error) {
+	dialer := &net.Dialer{Timeout: DefaultDialTimeout}
+	var nc net.Conn
+	var err error
+	if tlsConfig != nil {
+		nc, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		nc, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, client.TransportFailure{Message: err.Error()}
+	}
+	return &conn{c: nc, pending: make(map[int64]chan *result)}, nil
+}
+
+// read dispatches responses, once connection is broken, all pending calls fail
+// and the connection is removed so that next call will dial again
+func (c *Client) read(addr string, hc *conn) {
+	r := bufio.NewReader(hc.c)
+	var err error
+	for {
+		var f *highway.Frame
+		f, err = highway.ReadFrame(r, 0)
+		if err != nil {
+			break
+		}
+		h := &highway.ResponseHeader{}
+		res := &result{header: h, body: f.Body, err: h.Unmarshal(f.Header)}
+		hc.mu.Lock()
+		ch, ok := hc.pending[f.MsgID]
+		delete(hc.pending, f.MsgID)
+		hc.mu.Unlock()
+		if ok {
+			ch <- res
+		}
+	}
+	c.mu.Lock()
+	if c.conns[addr] == hc {
+		delete(c.conns, addr)
+	}
+	c.mu.Unlock()
+	hc.c.Close()
+	hc.mu.Lock()
+	hc.err = client.TransportFailure{Message: fmt.Sprintf("highway connection to %s is broken: %s", addr, err)}
+	for id, ch := range hc.pending {
+		ch <- &result{err: hc.err}
+		delete(hc.pending, id)
+	}
+	hc.mu.Unlock()
+}
+
+func (hc *conn) send(h *highway.RequestHeader, body []byte) (int64, chan *result, error) {
+	id := atomic.AddInt64(&hc.msgID, 1)
+	ch := make(chan *result, 1)
+	hc.mu.Lock()
+	if hc.err != nil {
+		hc.mu.Unlock()
+		return 0, nil, hc.err
+	}
+	hc.pending[id] = ch
+	hc.mu.Unlock()
+	hc.wmu.Lock()
+	err := highway.WriteFrame(hc.c, &highway.Frame{MsgID: id, Header: h.Marshal(), Body: body})
+	hc.wmu.Unlock()
+	if err != nil {
+		hc.cancel(id)
+		hc.c.Close()
+		return 0, nil, client.TransportFailure{Message: err.Error()}
+	}
+	return id, ch, nil
+}
+
+func (hc *conn) cancel(id int64) {
+	hc.mu.Lock()
+	delete(hc.pending, id)
+	hc.mu.Unlock()
+}
+
+// Call sends invocation to addr, invocation headers are carried in request context,
+// response body is decoded into rsp
+func (c *Client) Call(ctx context.Context, addr string, inv *invocation.Invocation, rsp interface{}) error {
+	if inv.SchemaID == "" || inv.OperationID == "" {
+		return ErrInvalidReq
+	}
+	body, err := highway.EncodeBody(inv.Args)
+	if err != nil {
+		return err
+	}
+	hc, err := c.getConn(addr)
+	if err != nil {
+		return err
+	}
+	h := &highway.RequestHeader{
+		MsgType:          highway.MsgTypeRequest,
+		DestMicroService: inv.MicroServiceName,
+		SchemaID:         inv.SchemaID,
+		OperationName:    inv.OperationID,
+		Context:          common.FromContext(ctx),
+	}
+	id, ch, err := hc.send(h, body)
+	if err != nil {
+		return err
+	}
+	c.mu.RLock()
+	d := c.opts.Timeout
+	c.mu.RUnlock()
+	var timeout <-chan time.Time
+	if _, ok := ctx.Deadline(); !ok && d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		timeout = t.C
+	}
+	select {
+	case <-ctx.Done():
+		hc.cancel(id)
+		return client.ErrCanceled
+	case <-timeout:
+		hc.cancel(id)
+		return client.TransportFailure{Message: fmt.Sprintf("highway call %s timeout after %s", addr, d)}
+	case res := <-ch:
+		return c.handleResult(inv, res, rsp)
+	}
+}
+
+func (c *Client) handleResult(inv *invocation.Invocation, res *result, rsp interface{}) error {
+	if res.err != nil {
+		return res.err
+	}
+	for k, v := range res.header.Context {
+		inv.SetHeader(k, v)
+	}
+	if res.header.StatusCode != http.StatusOK {
+		return highway.StatusError{Code: int(res.header.StatusCode), Message: res.header.ReasonPhrase}
+	}
+	if rsp == nil || len(res.body) == 0 {
+		return nil
+	}
+	return highway.DecodeBody(res.body, rsp)
+}
+
+// Status returns OK if response is set, highway error status is returned by Call as highway.StatusError
+func (c *Client) Status(rsp interface{}) (status int, err error) {
+	if rsp == nil {
+		return 0, fmt.Errorf("incompatible type: %s", reflect.TypeOf(rsp))
+	}
+	return http.StatusOK, nil
+}
+
+func (c *Client) String() string {
+	return "highway_client"
+}
+
+// Close closes all connections
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for addr, hc := range c.conns {
+		if err := hc.c.Close(); err != nil {
+			openlog.Warn("close highway connection failed: " + err.Error())
+		}
+		delete(c.conns, addr)
+	}
+	return nil
+}
+
+// ReloadConfigs reloads timeout and TLS config, TLS change takes effect on new connections
+func (c *Client) ReloadConfigs(opts client.Options) {
+	c.mu.Lock()
+	c.opts = client.EqualOpts(c.opts, opts)
+	c.mu.Unlock()
+}
+
+// GetOptions returns client options
+func (c *Client) GetOptions() client.Options {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.opts
+}
@@ -0,0 +1,165 @@
+package highway_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	hwclient "github.com/go-chassis/go-chassis/v2/client/highway"
+	"github.com/go-chassis/go-chassis/v2/core/client"
+	"github.com/go-chassis/go-chassis/v2/core/invocation"
+	"github.com/go-chassis/go-chassis/v2/pkg/highway"
+	"github.com/stretchr/testify/assert"
+)
+
+type message struct {
+	Name string
+}
+
+// fakeServer echoes request body and context of "Echo", never responds "Slow",
+// and closes the connection on "Close"
+func fakeServer(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					f, err := highway.ReadFrame(r, 0)
+					if err != nil {
+						return
+					}
+					h := &highway.RequestHeader{}
+					if err := h.Unmarshal(f.Header); err != nil {
+						return
+					}
+					switch h.OperationName {
+					case "Slow":
+						continue
+					case "Close":
+						return
+					case "Fail":
+						rh := &highway.ResponseHeader{StatusCode: http.StatusForbidden, ReasonPhrase: "denied"}
+						_ = highway.WriteFrame(conn, &highway.Frame{MsgID: f.MsgID, Header: rh.Marshal()})
+					default:
+						rh := &highway.ResponseHeader{StatusCode: http.StatusOK, Context: h.Context}
+						_ = highway.WriteFrame(conn, &highway.Frame{MsgID: f.MsgID, Header: rh.Marshal(), Body: f.Body})
+					}
+				}
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func newInv(operation string) *invocation.Invocation {
+	inv := invocation.New(context.Background())
+	inv.MicroServiceName = "Server"
+	inv.SchemaID = "Schema"
+	inv.OperationID = operation
+	inv.Args = &message{Name: "peter"}
+	return inv
+}
+
+func TestClient_Call(t *testing.T) {
+	addr := fakeServer(t)
+	c, err := hwclient.New(client.Options{Timeout: 200 * time.Millisecond})
+	assert.NoError(t, err)
+	defer c.Close()
+
+	t.Run("call without schema or operation, should fail", func(t *testing.T) {
+		inv := newInv("")
+		err := c.Call(inv.Ctx, addr, inv, &message{})
+		assert.Equal(t, hwclient.ErrInvalidReq, err)
+	})
+	t.Run("call, should decode body and carry headers", func(t *testing.T) {
+		inv := newInv("Echo")
+		inv.SetHeader("user", "peter")
+		reply := &message{}
+		err := c.Call(inv.Ctx, addr, inv, reply)
+		assert.NoError(t, err)
+		assert.Equal(t, "peter", reply.Name)
+		assert.Equal(t, "peter", inv.Header("user"))
+	})
+	t.Run("provider responds error status, should return status error", func(t *testing.T) {
+		inv := newInv("Fail")
+		err := c.Call(inv.Ctx, addr, inv, &message{})
+		var se highway.StatusError
+		assert.True(t, errors.As(err, &se))
+		assert.Equal(t, http.StatusForbidden, se.Code)
+	})
+	t.Run("no response in timeout, should get transport failure", func(t *testing.T) {
+		inv := newInv("Slow")
+		err := c.Call(inv.Ctx, addr, inv, &message{})
+		assert.True(t, errors.As(err, &client.TransportFailure{}))
+	})
+	t.Run("context canceled, should return canceled", func(t *testing.T) {
+		inv := newInv("Slow")
+		ctx, cancel := context.WithCancel(inv.Ctx)
+		time.AfterFunc(50*time.Millisecond, cancel)
+		err := c.Call(ctx, addr, inv, &message{})
+		assert.Equal(t, client.ErrCanceled, err)
+	})
+	t.Run("connection broken, pending call fails and next call dials again", func(t *testing.T) {
+		inv := newInv("Close")
+		err := c.Call(inv.Ctx, addr, inv, &message{})
+		assert.True(t, errors.As(err, &client.TransportFailure{}))
+
+		inv = newInv("Echo")
+		err = c.Call(inv.Ctx, addr, inv, &message{})
+		assert.NoError(t, err)
+	})
+}
+
+func TestClient_ReloadConfigs(t *testing.T) {
+	addr := fakeServer(t)
+	c, err := hwclient.New(client.Options{Timeout: time.Second})
+	assert.NoError(t, err)
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for n := 0; n < 10; n++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			inv := newInv("Echo")
+			assert.NoError(t, c.Call(inv.Ctx, addr, inv, &message{}))
+		}()
+		go func(n int) {
+			defer wg.Done()
+			c.ReloadConfigs(client.Options{Timeout: time.Duration(n+1) * time.Second})
+		}(n)
+	}
+	wg.Wait()
+	assert.NotZero(t, c.GetOptions().Timeout)
+}
+
+func TestClient_SlowDial(t *testing.T) {
+	addr := fakeServer(t)
+	c, err := hwclient.New(client.Options{Timeout: time.Second})
+	assert.NoError(t, err)
+	defer c.Close()
+
+	go func() {
+		// non routable address, dialing it hangs until dial timeout
+		inv := newInv("Echo")
+		_ = c.Call(inv.Ctx, "10.255.255.1:6000", inv, &message{})
+	}()
+	time.Sleep(50 * time.Millisecond)
+	start := time.Now()
+	inv := newInv("Echo")
+	assert.NoError(t, c.Call(inv.Ctx, addr, inv, &message{}))
+	assert.Less(t, time.Since(start), 500*time.Millisecond, "slow dial should not block other addresses")
+}
@@ -0,0 +1,243 @@
+// Package local is the in-process transport, it calls providers in the same binary without network,
+// the provider handler chain still runs, so that a modular monolith behaves same as split services
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/go-chassis/go-chassis/v2/core/client"
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/config"
+	"github.com/go-chassis/go-chassis/v2/core/handler"
+	"github.com/go-chassis/go-chassis/v2/core/invocation"
+	"github.com/go-chassis/go-chassis/v2/core/provider"
+	"github.com/go-chassis/go-chassis/v2/core/server"
+	"github.com/go-chassis/go-chassis/v2/pkg/runtime"
+	"github.com/go-chassis/go-chassis/v2/pkg/util"
+	"github.com/go-chassis/openlog"
+)
+
+// Name is the protocol name of local transport, it is also used as endpoint
+const Name = "local"
+
+// MDOriginProtocol is the invocation metadata key of the protocol before redirecting to local transport
+const MDOriginProtocol = "local-origin-protocol"
+
+// errors of local transport
+var (
+	ErrNoProvider   = errors.New("no local provider for invocation")
+	ErrInvalidReply = errors.New("reply type does not match provider response")
+)
+
+func init() {
+	client.InstallPlugin(Name, New)
+}
+
+// Redirect switches invocation to local transport if its provider is in this process and local transport is enabled,
+// load balancing is skipped because endpoint is decided
+func Redirect(inv *invocation.Invocation) bool {
+	if !config.GetLocalTransportEnabled() || inv.Endpoint != "" || !match(inv) {
+		return false
+	}
+	inv.SetMetadata(MDOriginProtocol, inv.Protocol)
+	inv.Protocol = Name
+	inv.Endpoint = Name
+	return true
+}
+
+func match(inv *invocation.Invocation) bool {
+	if inv.Protocol == common.ProtocolRest {
+		_, ok := restHandler(inv.MicroServiceName, inv.PortName)
+		return ok
+	}
+	p, err := provider.GetProvider(inv.MicroServiceName)
+	if err != nil {
+		return false
+	}
+	return p.Exist(inv.SchemaID, inv.OperationID)
+}
+
+// restHandler returns the running rest server of this service
+func restHandler(service, port string) (http.Handler, bool) {
+	if service != runtime.ServiceName {
+		return nil, false
+	}
+	s, err := server.GetServer(util.GenProtoEndPoint(common.ProtocolRest, port))
+	if err != nil {
+		return nil, false
+	}
+	h, ok := s.(http.Handler)
+	return h, ok
+}
+
+// Client is the local transport client
+type Client struct {
+	mu   sync.RWMutex
+	opts client.Options
+}
+
+// New returns a local client
+func New(opts client.Options) (client.ProtocolClient, error) {
+	return &Client{opts: opts}, nil
+}
+
+// Call serves rest request with the in-process rest server,
+// other protocols are dispatched to provider after provider chain
+func (c *Client) Call(ctx context.Context, addr string, inv *invocation.Invocation, rsp interface{}) error {
+	origin, _ := inv.Metadata[MDOriginProtocol].(string)
+	if origin == common.ProtocolRest {
+		return c.callRest(ctx, inv, rsp)
+	}
+	return c.callProvider(ctx, origin, inv, rsp)
+}
+
+func (c *Client) callRest(ctx context.Context, inv *invocation.Invocation, rsp interface{}) error {
+	req, ok := inv.Args.(*http.Request)
+	if !ok {
+		return fmt.Errorf("incompatible request type: %s", reflect.TypeOf(inv.Args))
+	}
+	resp, ok := rsp.(*http.Response)
+	if !ok {
+		return fmt.Errorf("incompatible response type: %s", reflect.TypeOf(rsp))
+	}
+	h, ok := restHandler(inv.MicroServiceName, inv.PortName)
+	if !ok {
+		return ErrNoProvider
+	}
+	req = req.WithContext(ctx)
+	for k, v := range common.FromContext(ctx) {
+		req.Header.Set(k, v)
+	}
+	if len(req.Header.Get("Content-Type")) == 0 {
+		req.Header.Set("Content-Type", common.JSON)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	*resp = *w.Result()
+	if client.GetFailureMap(common.ProtocolRest)["http_"+strconv.Itoa(resp.StatusCode)] {
+		return fmt.Errorf("http error status [%d], local call", resp.StatusCode)
+	}
+	return nil
+}
+
+func (c *Client) callProvider(ctx context.Context, protocol string, inv *invocation.Invocation, rsp interface{}) error {
+	p, err := provider.GetProvider(inv.MicroServiceName)
+	if err != nil || !p.Exist(inv.SchemaID, inv.OperationID) {
+		return ErrNoProvider
+	}
+	// headers are copied, so that provider chain does not change consumer's headers
+	m := make(map[string]string, len(common.FromContext(ctx)))
+	for k, v := range common.FromContext(ctx) {
+		m[k] = v
+	}
+	pi := invocation.New(context.WithValue(ctx, common.ContextHeaderKey{}, m))
+	pi.MicroServiceName = inv.MicroServiceName
+	pi.SourceMicroService = m[common.HeaderSourceName]
+	pi.Protocol = protocol
+	pi.SchemaID = inv.SchemaID
+	pi.OperationID = inv.OperationID
+	pi.Args = inv.Args
+	pi.SetMetadata(common.RestRoutePath, inv.SchemaID+"/"+inv.OperationID)
+
+	chain := providerChain(protocol)
+	chain.AddHandler(&invokeHandler{p: p})
+	var result interface{}
+	chain.Next(pi, func(ir *invocation.Response) {
+		result = ir.Result
+		err = ir.Err
+	})
+	if err != nil {
+		return err
+	}
+	return setReply(rsp, result)
+}
+
+// providerChain returns the provider chain of protocol server, if it does not exist, use default chain
+func providerChain(protocol string) handler.Chain {
+	c, err := handler.GetChain(common.Provider, protocol)
+	if err != nil {
+		c, err = handler.GetChain(common.Provider, common.DefaultChainName)
+		if err != nil {
+			openlog.Debug("no provider chain for local call: " + err.Error())
+			return handler.Chain{ServiceType: common.Provider, Name: Name}
+		}
+	}
+	return c.Clone()
+}
+
+// setReply sets provider response to consumer reply, reply must be a pointer
+func setReply(rsp, result interface{}) error {
+	if rsp == nil || result == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(rsp)
+	res := reflect.ValueOf(result)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return ErrInvalidReply
+	}
+	if res.Kind() == reflect.Ptr {
+		if res.IsNil() {
+			return nil
+		}
+		if res.Type() == rv.Type() {
+			rv.Elem().Set(res.Elem())
+			return nil
+		}
+	}
+	if res.Type().AssignableTo(rv.Elem().Type()) {
+		rv.Elem().Set(res)
+		return nil
+	}
+	return ErrInvalidReply
+}
+
+// Status returns http status of rest response, other protocols are always OK if Call returns no error
+func (c *Client) Status(rsp interface{}) (status int, err error) {
+	if resp, ok := rsp.(*http.Response); ok {
+		return resp.StatusCode, nil
+	}
+	return http.StatusOK, nil
+}
+
+func (c *Client) String() string {
+	return "local_client"
+}
+
+// Close does nothing, there is no connection
+func (c *Client) Close() error {
+	return nil
+}
+
+// ReloadConfigs reloads options
+func (c *Client) ReloadConfigs(opts client.Options) {
+	c.mu.Lock()
+	c.opts = client.EqualOpts(c.opts, opts)
+	c.mu.Unlock()
+}
+
+// GetOptions returns client options
+func (c *Client) GetOptions() client.Options {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.opts
+}
+
+type invokeHandler struct {
+	p provider.Provider
+}
+
+func (ih *invokeHandler) Handle(chain *handler.Chain, i *invocation.Invocation, cb invocation.ResponseCallBack) {
+	result, err := ih.p.Invoke(i)
+	cb(&invocation.Response{Result: result, Err: err})
+}
+
+func (ih *invokeHandler) Name() string {
+	return "local-invoke"
+}
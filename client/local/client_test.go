@@ -0,0 +1,166 @@
+package local_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-chassis/go-chassis/v2/client/local"
+	"github.com/go-chassis/go-chassis/v2/client/rest"
+	"github.com/go-chassis/go-chassis/v2/core/client"
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/config"
+	"github.com/go-chassis/go-chassis/v2/core/config/model"
+	"github.com/go-chassis/go-chassis/v2/core/handler"
+	"github.com/go-chassis/go-chassis/v2/core/invocation"
+	"github.com/go-chassis/go-chassis/v2/core/lager"
+	"github.com/go-chassis/go-chassis/v2/core/provider"
+	"github.com/go-chassis/go-chassis/v2/core/server"
+	"github.com/go-chassis/go-chassis/v2/examples/schemas"
+	"github.com/go-chassis/go-chassis/v2/examples/schemas/helloworld"
+	"github.com/go-chassis/go-chassis/v2/pkg/runtime"
+	_ "github.com/go-chassis/go-chassis/v2/server/restful"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	lager.Init(&lager.Options{
+		LoggerLevel: "INFO",
+	})
+	archaius.Init(archaius.WithMemorySource())
+	archaius.Set("servicecomb.noRefreshSchema", true)
+	config.ReadGlobalConfigFromArchaius()
+}
+
+var providerCalls int
+
+type countHandler struct{}
+
+func (h *countHandler) Handle(chain *handler.Chain, i *invocation.Invocation, cb invocation.ResponseCallBack) {
+	providerCalls++
+	chain.Next(i, cb)
+}
+
+func (h *countHandler) Name() string {
+	return "local-test-count"
+}
+
+func TestRedirect(t *testing.T) {
+	provider.RegisterProvider(common.DefaultProvider, "LocalServer")
+	_, err := provider.RegisterSchema("LocalServer", &schemas.HelloServer{})
+	assert.NoError(t, err)
+	newInv := func() *invocation.Invocation {
+		inv := invocation.New(context.Background())
+		inv.MicroServiceName = "LocalServer"
+		inv.SchemaID = "HelloServer"
+		inv.OperationID = "SayHello"
+		return inv
+	}
+
+	t.Run("local transport is disabled, should not redirect", func(t *testing.T) {
+		archaius.Set("servicecomb.transport.local.enabled", false)
+		assert.False(t, local.Redirect(newInv()))
+	})
+	archaius.Set("servicecomb.transport.local.enabled", true)
+	defer archaius.Set("servicecomb.transport.local.enabled", false)
+	t.Run("provider exists, should redirect", func(t *testing.T) {
+		inv := newInv()
+		assert.True(t, local.Redirect(inv))
+		assert.Equal(t, local.Name, inv.Protocol)
+		assert.Equal(t, local.Name, inv.Endpoint)
+	})
+	t.Run("operation does not exist, should not redirect", func(t *testing.T) {
+		inv := newInv()
+		inv.OperationID = "SayBye"
+		assert.False(t, local.Redirect(inv))
+	})
+	t.Run("service is remote, should not redirect", func(t *testing.T) {
+		inv := newInv()
+		inv.MicroServiceName = "RemoteServer"
+		assert.False(t, local.Redirect(inv))
+	})
+}
+
+func TestClient_Call(t *testing.T) {
+	archaius.Set("servicecomb.transport.local.enabled", true)
+	defer archaius.Set("servicecomb.transport.local.enabled", false)
+	err := handler.RegisterHandler("local-test-count", func() handler.Handler { return &countHandler{} })
+	assert.NoError(t, err)
+	err = handler.CreateChains(common.Provider, map[string]string{common.DefaultChainName: "local-test-count"})
+	assert.NoError(t, err)
+	c, err := local.New(client.Options{})
+	assert.NoError(t, err)
+
+	t.Run("call local provider, provider chain should run", func(t *testing.T) {
+		provider.RegisterProvider(common.DefaultProvider, "LocalCallServer")
+		_, err := provider.RegisterSchema("LocalCallServer", &schemas.HelloServer{})
+		assert.NoError(t, err)
+		inv := invocation.New(context.Background())
+		inv.MicroServiceName = "LocalCallServer"
+		inv.SchemaID = "HelloServer"
+		inv.OperationID = "SayHello"
+		inv.Args = &helloworld.HelloRequest{Name: "peter"}
+		assert.True(t, local.Redirect(inv))
+		calls := providerCalls
+		reply := &helloworld.HelloReply{}
+		err = c.Call(inv.Ctx, inv.Endpoint, inv, reply)
+		assert.NoError(t, err)
+		assert.Equal(t, "Go Hello  peter", reply.Message)
+		assert.Equal(t, calls+1, providerCalls)
+	})
+	t.Run("call local rest server, should skip network", func(t *testing.T) {
+		runtime.ServiceName = "LocalRest"
+		config.GlobalDefinition.ServiceComb.Protocols = map[string]model.Protocol{
+			"rest": {Listen: "127.0.0.1:0"},
+		}
+		assert.NoError(t, server.Init())
+		s, err := server.GetServer("rest")
+		assert.NoError(t, err)
+		_, err = s.Register(&schemas.RestFulHello{})
+		assert.NoError(t, err)
+		assert.NoError(t, s.Start())
+		defer s.Stop()
+
+		req, err := rest.NewRequest(http.MethodPost, "http://LocalRest/sayjson", []byte(`{"Name":"peter"}`))
+		assert.NoError(t, err)
+		inv := invocation.New(context.Background())
+		inv.MicroServiceName = "LocalRest"
+		inv.Protocol = common.ProtocolRest
+		inv.Args = req
+		resp := rest.NewResponse()
+		assert.True(t, local.Redirect(inv))
+		calls := providerCalls
+		err = c.Call(inv.Ctx, inv.Endpoint, inv, resp)
+		assert.NoError(t, err)
+		status, err := c.Status(resp)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, status)
+		body, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.True(t, strings.Contains(string(body), "hello peter"))
+		assert.Equal(t, calls+1, providerCalls)
+	})
+}
+
+func TestClient_ReloadConfigs(t *testing.T) {
+	c, err := local.New(client.Options{Timeout: time.Second})
+	assert.NoError(t, err)
+	var wg sync.WaitGroup
+	for n := 0; n < 10; n++ {
+		wg.Add(2)
+		go func(n int) {
+			defer wg.Done()
+			c.ReloadConfigs(client.Options{Timeout: time.Duration(n+1) * time.Second})
+		}(n)
+		go func() {
+			defer wg.Done()
+			assert.NotZero(t, c.GetOptions().Timeout)
+		}()
+	}
+	wg.Wait()
+}
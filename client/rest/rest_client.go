@@ -12,7 +12,9 @@ import (
 
 	"github.com/go-chassis/go-chassis/v2/core/client"
 	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/config"
 	"github.com/go-chassis/go-chassis/v2/core/invocation"
+	"github.com/go-chassis/go-chassis/v2/pkg/compression"
 	"github.com/go-chassis/go-chassis/v2/pkg/util/httputil"
 )
 
@@ -124,6 +126,14 @@ func (c *Client) Call(ctx context.Context, addr string, inv *invocation.Invocati
 	}
 
 	c.contextToHeader(ctx, reqSend)
+	// net/http transparently decodes gzip only if it sets Accept-Encoding by itself
+	decode := false
+	if config.GetCompressionEnabled(inv.MicroServiceName) && reqSend.Header.Get(compression.HeaderAcceptEncoding) == "" {
+		reqSend.Header.Set(compression.HeaderAcceptEncoding, compression.AcceptEncoding(config.GetCompressionEncodings()))
+		decode = true
+	}
+	// abort the request once invocation context is canceled
+	reqSend = reqSend.WithContext(ctx)
 
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -139,16 +149,28 @@ func (c *Client) Call(ctx context.Context, addr string, inv *invocation.Invocati
 	var temp *http.Response
 	errChan := make(chan error, 1)
 	go func() {
-		temp, err = c.c.Do(reqSend)
-		errChan <- err
+		var doErr error
+		temp, doErr = c.c.Do(reqSend)
+		errChan <- doErr
 	}()
 
 	select {
 	case <-ctx.Done():
 		err = client.ErrCanceled
 	case err = <-errChan:
+		if err != nil && ctx.Err() != nil {
+			err = client.ErrCanceled
+		}
 		if err == nil {
+			if decode {
+				err = compression.DecodeResponse(temp)
+			}
 			*resp = *temp
+			if err != nil {
+				// body can not be decoded, release the connection, status and headers are still returned
+				temp.Body.Close()
+				resp.Body = http.NoBody
+			}
 		}
 	}
 
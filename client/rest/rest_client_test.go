@@ -3,8 +3,11 @@ package rest_test
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -21,6 +24,7 @@ import (
 	"github.com/go-chassis/go-chassis/v2/core/lager"
 	"github.com/go-chassis/go-chassis/v2/core/server"
 	"github.com/go-chassis/go-chassis/v2/examples/schemas"
+	"github.com/go-chassis/go-chassis/v2/pkg/compression"
 	"github.com/go-chassis/go-chassis/v2/pkg/runtime"
 	"github.com/go-chassis/go-chassis/v2/pkg/util/httputil"
 	"github.com/go-chassis/go-chassis/v2/server/restful"
@@ -265,6 +269,64 @@ func TestNewRestClient_Call_Error_Scenarios(t *testing.T) {
 
 }
 
+func TestNewRestClient_Compression(t *testing.T) {
+	large := strings.Repeat("hello world ", 200)
+	ts := httptest.NewServer(compression.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Accept-Encoding", r.Header.Get("Accept-Encoding"))
+		io.WriteString(w, large)
+	}), func(r *http.Request) *compression.Options {
+		return &compression.Options{Encodings: []string{"deflate", "gzip"}, MinSize: 1024}
+	}))
+	defer ts.Close()
+	archaius.Init(archaius.WithMemorySource())
+	archaius.Set("servicecomb.transport.compression.enabled", true)
+	archaius.Set("servicecomb.transport.compression.encodings", "deflate,gzip")
+	defer archaius.Delete("servicecomb.transport.compression.enabled")
+	defer archaius.Delete("servicecomb.transport.compression.encodings")
+
+	c, err := rest.NewRestClient(client.Options{})
+	assert.NoError(t, err)
+	arg, _ := rest.NewRequest("GET", "http://Server/", nil)
+	inv := &invocation.Invocation{MicroServiceName: "Server", Args: arg}
+	reply := rest.NewResponse()
+	err = c.Call(context.TODO(), strings.TrimPrefix(ts.URL, "http://"), inv, reply)
+	assert.NoError(t, err)
+	assert.Equal(t, "deflate, gzip", reply.Header.Get("X-Accept-Encoding"))
+	assert.Equal(t, "", reply.Header.Get("Content-Encoding"))
+	b, err := io.ReadAll(reply.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, large, string(b))
+
+	t.Run("disable compression for a service", func(t *testing.T) {
+		archaius.Set("servicecomb.transport.compression.Server.enabled", false)
+		defer archaius.Delete("servicecomb.transport.compression.Server.enabled")
+		arg, _ := rest.NewRequest("GET", "http://Server/", nil)
+		inv := &invocation.Invocation{MicroServiceName: "Server", Args: arg}
+		reply := rest.NewResponse()
+		err = c.Call(context.TODO(), strings.TrimPrefix(ts.URL, "http://"), inv, reply)
+		assert.NoError(t, err)
+		// net/http asks for gzip and decodes it by itself
+		assert.Equal(t, "gzip", reply.Header.Get("X-Accept-Encoding"))
+		b, err := io.ReadAll(reply.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, large, string(b))
+	})
+	t.Run("invalid compressed body, should return error", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", "gzip")
+			io.WriteString(w, "not gzip")
+		}))
+		defer ts.Close()
+		arg, _ := rest.NewRequest("GET", "http://Server/", nil)
+		inv := &invocation.Invocation{MicroServiceName: "Server", Args: arg}
+		reply := rest.NewResponse()
+		err = c.Call(context.TODO(), strings.TrimPrefix(ts.URL, "http://"), inv, reply)
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusOK, reply.StatusCode)
+		assert.Equal(t, http.NoBody, reply.Body, "raw body is closed")
+	})
+}
+
 type TestSchema struct {
 }
 
@@ -0,0 +1,23 @@
+package servicecomb
+
+import (
+	"fmt"
+
+	"github.com/go-chassis/go-archaius/event"
+	"github.com/go-chassis/go-chassis/v2/core/config"
+	"github.com/go-chassis/openlog"
+)
+
+// EgressEventListener reloads egress rules once egress config changes
+type EgressEventListener struct{}
+
+// Event is a method used to handle a egress event
+func (e *EgressEventListener) Event(evt *event.Event) {
+	openlog.Debug(fmt.Sprintf("egress event, key: %s, type: %s", evt.Key, evt.EventType))
+	c, err := config.ReadEgressFromArchaius()
+	if err != nil {
+		openlog.Error("can not unmarshal new egress config: " + err.Error())
+		return
+	}
+	SaveToEgressCache(c)
+}
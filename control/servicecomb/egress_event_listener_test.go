@@ -0,0 +1,39 @@
+package servicecomb_test
+
+import (
+	"testing"
+
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-chassis/go-archaius/event"
+	"github.com/go-chassis/go-chassis/v2/control"
+	"github.com/go-chassis/go-chassis/v2/control/servicecomb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEgressEventListener(t *testing.T) {
+	p := &servicecomb.Panel{}
+	l := &servicecomb.EgressEventListener{}
+	archaius.Set("servicecomb.egress.rules.search.hosts", []interface{}{"*.google.com"})
+	archaius.Set("servicecomb.egress.rules.api.hosts", []interface{}{"api.example.com"})
+	archaius.Set("servicecomb.egress.rules.api.ports", []interface{}{
+		map[string]interface{}{"port": 443, "protocol": "HTTPS"},
+	})
+	l.Event(&event.Event{Key: "servicecomb.egress.rules.api.hosts"})
+	rules := p.GetEgressRule()
+	assert.Equal(t, 2, len(rules))
+	assert.Equal(t, []string{"api.example.com"}, rules[0].Hosts)
+	assert.Equal(t, []*control.EgressPort{{Port: 443, Protocol: "HTTPS"}}, rules[0].Ports)
+	assert.Equal(t, []string{"*.google.com"}, rules[1].Hosts)
+	assert.Empty(t, rules[1].Ports)
+
+	archaius.Delete("servicecomb.egress.rules.search.hosts")
+	l.Event(&event.Event{Key: "servicecomb.egress.rules.search.hosts"})
+	rules = p.GetEgressRule()
+	assert.Equal(t, 1, len(rules))
+	assert.Equal(t, []string{"api.example.com"}, rules[0].Hosts)
+
+	archaius.Delete("servicecomb.egress.rules.api.hosts")
+	archaius.Delete("servicecomb.egress.rules.api.ports")
+	l.Event(&event.Event{Key: "servicecomb.egress.rules.api.hosts"})
+	assert.Empty(t, p.GetEgressRule())
+}
@@ -3,6 +3,7 @@ package servicecomb
 import (
 	"github.com/go-chassis/go-archaius"
 	"github.com/go-chassis/go-archaius/event"
+	"github.com/go-chassis/go-chassis/v2/core/config"
 	"github.com/go-chassis/openlog"
 )
 
@@ -24,5 +25,6 @@ func Init() {
 	RegisterKeys(circuitBreakerEventListener, ConsumerFallbackKey, ConsumerFallbackPolicyKey, ConsumerIsolationKey, ConsumerCircuitBreakerKey)
 	RegisterKeys(lbEventListener, LoadBalanceKey)
 	RegisterKeys(&LagerEventListener{}, LagerLevelKey)
+	RegisterKeys(&EgressEventListener{}, config.EgressKey)
 
 }
@@ -1,6 +1,8 @@
 package servicecomb
 
 import (
+	"sort"
+
 	"github.com/go-chassis/go-archaius"
 	"github.com/go-chassis/go-chassis/v2/control"
 	"github.com/go-chassis/go-chassis/v2/core/common"
@@ -19,6 +21,9 @@ func newPanel(options control.Options) control.Panel {
 	Init()
 	SaveToLBCache(config.GetLoadBalancing())
 	SaveToCBCache(config.GetHystrixConfig())
+	if egress, err := config.ReadEgressFromArchaius(); err == nil {
+		SaveToEgressCache(egress)
+	}
 	return &Panel{}
 }
 
@@ -76,7 +81,17 @@ func (p *Panel) GetFaultInjection(inv invocation.Invocation) model.Fault {
 
 // GetEgressRule get egress config
 func (p *Panel) GetEgressRule() []control.EgressConfig {
-	return []control.EgressConfig{}
+	items := EgressConfigCache.Items()
+	names := make([]string, 0, len(items))
+	for name := range items {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	rules := make([]control.EgressConfig, 0, len(names))
+	for _, name := range names {
+		rules = append(rules, items[name].Object.(control.EgressConfig))
+	}
+	return rules
 }
 
 func init() {
@@ -6,6 +6,7 @@ import (
 	"github.com/go-chassis/go-chassis/v2/resilience/retry"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/go-chassis/go-chassis/v2/control"
 	"github.com/go-chassis/go-chassis/v2/core/client"
@@ -48,6 +49,7 @@ func saveDefaultLB(raw *model.LoadBalancing) string { // return updated key
 		SessionTimeoutInSeconds: raw.SessionStickinessRule.SessionTimeoutInSeconds,
 		SuccessiveFailedTimes:   raw.SessionStickinessRule.SuccessiveFailedTimes,
 	}
+	setHedging(&c, raw.Hedging)
 	setDefaultLBValue(&c)
 	LBConfigCache.Set("", c, 0)
 	return ""
@@ -78,6 +80,7 @@ func saveEachLB(k string, raw model.LoadBalancingSpec) string { // return update
 		SessionTimeoutInSeconds: raw.SessionStickinessRule.SessionTimeoutInSeconds,
 		SuccessiveFailedTimes:   raw.SessionStickinessRule.SuccessiveFailedTimes,
 	}
+	setHedging(&c, raw.Hedging)
 	openlog.Info(fmt.Sprintf("save lb config [%s] [%v]", k, raw))
 	setDefaultLBValue(&c)
 	LBConfigCache.Set(k, c, 0)
@@ -91,6 +94,60 @@ func setDefaultLBValue(c *control.LoadBalancingConfig) {
 	if c.BackOffKind == "" {
 		c.BackOffKind = retry.DefaultBackOffKind
 	}
+	if c.HedgingDelay <= 0 {
+		c.HedgingDelay = config.DefaultHedgingDelay
+	}
+	if c.HedgingMaxAttempts < 2 {
+		c.HedgingMaxAttempts = config.DefaultHedgingMaxAttempts
+	}
+	if c.HedgingMaxOutstanding <= 0 {
+		c.HedgingMaxOutstanding = config.DefaultHedgingMaxOutstanding
+	}
+}
+
+func setHedging(c *control.LoadBalancingConfig, raw model.HedgingSpec) {
+	c.HedgingEnabled = raw.Enabled
+	c.HedgingPercentile = raw.Percentile
+	c.HedgingMaxAttempts = raw.MaxAttempts
+	c.HedgingMaxOutstanding = raw.MaxOutstanding
+	c.HedgingOperations = raw.Operations
+	if c.HedgingEnabled && c.RetryEnabled {
+		openlog.Warn("both hedging and retry are enabled, retry does not apply to operations with hedging")
+	}
+	if raw.Delay != "" {
+		d, err := time.ParseDuration(raw.Delay)
+		if err != nil {
+			openlog.Warn(fmt.Sprintf("invalid hedging delay [%s]: %s", raw.Delay, err))
+			return
+		}
+		c.HedgingDelay = d
+	}
+}
+
+// SaveToEgressCache save egress rules, key is rule name
+func SaveToEgressCache(raw *model.EgressStruct) {
+	openlog.Debug("Loading egress config from archaius into cache")
+	oldKeys := EgressConfigCache.Items()
+	newKeys := make(map[string]bool)
+	if raw != nil {
+		for name, r := range raw.Rules {
+			c := control.EgressConfig{Hosts: r.Hosts}
+			for _, p := range r.Ports {
+				if p == nil {
+					continue
+				}
+				c.Ports = append(c.Ports, &control.EgressPort{Port: p.Port, Protocol: p.Protocol})
+			}
+			EgressConfigCache.Set(name, c, 0)
+			newKeys[name] = true
+		}
+	}
+	// remove outdated keys
+	for old := range oldKeys {
+		if _, ok := newKeys[old]; !ok {
+			EgressConfigCache.Delete(old)
+		}
+	}
 }
 
 // SaveToCBCache save configs
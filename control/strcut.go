@@ -1,5 +1,7 @@
 package control
 
+import "time"
+
 // LoadBalancingConfig is a standardized model
 type LoadBalancingConfig struct {
 	Strategy     string
@@ -13,6 +15,13 @@ type LoadBalancingConfig struct {
 
 	SessionTimeoutInSeconds int
 	SuccessiveFailedTimes   int
+
+	HedgingEnabled        bool
+	HedgingDelay          time.Duration
+	HedgingPercentile     float64
+	HedgingMaxAttempts    int
+	HedgingMaxOutstanding int
+	HedgingOperations     []string
 }
 
 // RateLimitingConfig is a standardized model
@@ -11,6 +11,9 @@ import (
 // ErrCanceled means Request is canceled by context management
 var ErrCanceled = errors.New("request cancelled")
 
+// ErrDeadlineExceeded means the deadline of invocation context is reached before request is sent
+var ErrDeadlineExceeded = errors.New("request deadline exceeded")
+
 // TransportFailure is caused by client call failure
 // for example:  resp, err = client.Do(req)
 // if err is not nil then should wrap original error with TransportFailure
@@ -4,7 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/go-chassis/go-chassis/v2/core/common"
@@ -33,6 +36,16 @@ func errNotNil(err error, cb invocation.ResponseCallBack) {
 
 // Handle is to handle transport related things
 func (th *TransportHandler) Handle(chain *handler.Chain, i *invocation.Invocation, cb invocation.ResponseCallBack) {
+	// deliver remaining time budget to provider, and fail fast if caller has given up
+	common.SetTimeoutHeader(i.Ctx)
+	if i.Ctx.Err() != nil {
+		err := ErrDeadlineExceeded
+		if errors.Is(i.Ctx.Err(), context.Canceled) {
+			err = ErrCanceled
+		}
+		cb(&invocation.Response{Err: err})
+		return
+	}
 
 	c, err := GetClient(i)
 	if err != nil {
@@ -44,7 +57,9 @@ func (th *TransportHandler) Handle(chain *handler.Chain, i *invocation.Invocatio
 
 	//taking the time elapsed to check for latency aware strategy
 	timeBefore := time.Now()
+	done := startRequest(i)
 	err = c.Call(i.Ctx, i.Endpoint, i, i.Reply)
+	done()
 	if err != nil {
 		r.Err = err
 		if !errors.Is(err, ErrCanceled) {
@@ -55,6 +70,11 @@ func (th *TransportHandler) Handle(chain *handler.Chain, i *invocation.Invocatio
 			ProcessSuccessiveFailure(i)
 		}
 		r.Status, _ = c.Status(i.Reply)
+		if !errors.Is(err, ErrCanceled) {
+			outcome := Outcome(r.Status, err)
+			loadbalancer.RecordOutcome(i.MicroServiceName, i.Endpoint, outcome)
+			recordLatency(i, outcome, time.Since(timeBefore))
+		}
 		cb(r)
 		return
 	}
@@ -64,8 +84,11 @@ func (th *TransportHandler) Handle(chain *handler.Chain, i *invocation.Invocatio
 		cb(r)
 		return
 	}
-	if i.Strategy == loadbalancer.StrategyLatency {
-		timeAfter := time.Since(timeBefore)
+	outcome := Outcome(r.Status, nil)
+	loadbalancer.RecordOutcome(i.MicroServiceName, i.Endpoint, outcome)
+	timeAfter := time.Since(timeBefore)
+	recordLatency(i, outcome, timeAfter)
+	if i.Strategy == loadbalancer.StrategyLatency || i.Metadata[loadbalancer.MDLatencyStats] == true {
 		loadbalancer.SetLatency(timeAfter, i.Endpoint, i.MicroServiceName, i.RouteTags, i.Protocol)
 	}
 
@@ -77,6 +100,60 @@ func (th *TransportHandler) Handle(chain *handler.Chain, i *invocation.Invocatio
 	cb(r)
 }
 
+// startRequest records in-flight request for LeastRequest and P2CEWMA strategy,
+// only endpoints picked from discovery cache are recorded
+func startRequest(i *invocation.Invocation) func() {
+	if i.Metadata[loadbalancer.MDLoadStats] != true {
+		return func() {}
+	}
+	return loadbalancer.StartRequest(i.Endpoint)
+}
+
+// recordLatency records latency of a call for P2CEWMA strategy, a failed call is penalized
+func recordLatency(i *invocation.Invocation, outcome loadbalancer.Outcome, latency time.Duration) {
+	if i.Metadata[loadbalancer.MDLoadStats] != true {
+		return
+	}
+	if outcome == loadbalancer.OutcomeSuccess {
+		loadbalancer.RecordLatency(i.Endpoint, latency)
+		return
+	}
+	loadbalancer.RecordFailure(i.Endpoint, latency)
+}
+
+// Outcome classifies the result of a call for outlier detection,
+// errors without 5xx status are gateway errors unless connection can not be established
+func Outcome(status int, err error) loadbalancer.Outcome {
+	switch {
+	case status >= http.StatusInternalServerError:
+		if status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout {
+			return loadbalancer.OutcomeGatewayError
+		}
+		return loadbalancer.OutcomeServerError
+	case err == nil:
+		return loadbalancer.OutcomeSuccess
+	case status > 0:
+		// the instance responds, error is caused by the response, like a status in failure list
+		return loadbalancer.OutcomeSuccess
+	case isConnectFailure(err):
+		return loadbalancer.OutcomeConnectFailure
+	}
+	return loadbalancer.OutcomeGatewayError
+}
+
+func isConnectFailure(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	// some protocol clients only keep the message of the original error
+	var tf TransportFailure
+	return errors.As(err, &tf) && (strings.Contains(tf.Message, "connection refused") || strings.Contains(tf.Message, "dial"))
+}
+
 // ProcessSpecialProtocol handles special logic for protocol
 func ProcessSpecialProtocol(inv *invocation.Invocation) {
 	switch inv.Protocol {
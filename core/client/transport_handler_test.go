@@ -2,11 +2,14 @@ package client_test
 
 import (
 	"context"
+	"errors"
 	"io"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/go-chassis/go-archaius"
 	"github.com/go-chassis/go-chassis/v2/client/rest"
@@ -14,6 +17,7 @@ import (
 	"github.com/go-chassis/go-chassis/v2/core/common"
 	"github.com/go-chassis/go-chassis/v2/core/config/model"
 	"github.com/go-chassis/go-chassis/v2/core/lager"
+	"github.com/go-chassis/go-chassis/v2/core/loadbalancer"
 	"github.com/go-chassis/go-chassis/v2/pkg/runtime"
 	"github.com/go-chassis/go-chassis/v2/pkg/util/fileutil"
 
@@ -88,6 +92,7 @@ servicecomb:
 
 	i.Endpoint = "127.0.0.1:9992"
 	i.Protocol = "rest"
+	i.SetMetadata(loadbalancer.MDLoadStats, true)
 
 	h := &client.TransportHandler{}
 	c.Handlers = append(c.Handlers, h)
@@ -98,5 +103,38 @@ servicecomb:
 		t.Logf("%#v", r.Err)
 		assert.Equal(t, nil, r.Result)
 	})
+	assert.InDelta(t, float64(loadbalancer.FailurePenalty), float64(loadbalancer.EWMALatency(i.Endpoint)), float64(time.Second),
+		"connection failure costs the penalty")
 
+	t.Run("endpoint is not picked from discovery, should not record", func(t *testing.T) {
+		i.Endpoint = "127.0.0.1:9993"
+		i.Metadata = nil
+		i.HandlerIndex = 0
+		c.Next(i, func(r *invocation.Response) {})
+		assert.Zero(t, loadbalancer.EWMALatency(i.Endpoint))
+	})
+}
+
+func TestTransportHandler_Deadline(t *testing.T) {
+	c := &handler.Chain{}
+	c.Handlers = append(c.Handlers, &client.TransportHandler{})
+	ctx, cancel := context.WithTimeout(common.NewContext(nil), -time.Second)
+	defer cancel()
+	i := invocation.New(ctx)
+	i.Protocol = "rest"
+	i.Endpoint = "127.0.0.1:9992"
+	c.Next(i, func(r *invocation.Response) {
+		assert.Equal(t, client.ErrDeadlineExceeded, r.Err)
+	})
+	assert.Equal(t, "0", i.Header(common.HeaderTimeout))
+}
+
+func TestOutcome(t *testing.T) {
+	assert.Equal(t, loadbalancer.OutcomeSuccess, client.Outcome(http.StatusOK, nil))
+	assert.Equal(t, loadbalancer.OutcomeSuccess, client.Outcome(http.StatusNotFound, errors.New("not found")))
+	assert.Equal(t, loadbalancer.OutcomeServerError, client.Outcome(http.StatusInternalServerError, nil))
+	assert.Equal(t, loadbalancer.OutcomeGatewayError, client.Outcome(http.StatusServiceUnavailable, nil))
+	assert.Equal(t, loadbalancer.OutcomeGatewayError, client.Outcome(0, errors.New("timeout")))
+	assert.Equal(t, loadbalancer.OutcomeConnectFailure, client.Outcome(0, &net.OpError{Op: "dial", Err: errors.New("refused")}))
+	assert.Equal(t, loadbalancer.OutcomeConnectFailure, client.Outcome(0, client.TransportFailure{Message: "dial tcp: connection refused"}))
 }
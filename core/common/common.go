@@ -5,6 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chassis/go-archaius/source/remote"
 	"github.com/go-chassis/openlog"
@@ -67,6 +70,7 @@ const (
 const (
 	ProtocolRest    = "rest"
 	ProtocolHighway = "highway"
+	ProtocolGRPC    = "grpc"
 	LBSessionID     = "go-chassisLB"
 )
 
@@ -106,6 +110,9 @@ const (
 	HeaderXCseContent = "x-cse-context"
 
 	HeaderMark = "X-Mark"
+	// HeaderTimeout is the remaining time budget of a request in milliseconds,
+	// consumer sets it from the deadline of invocation context, provider turns it back to a context deadline
+	HeaderTimeout = "x-cse-timeout"
 )
 
 // Rest metadata key for restful protocol
@@ -233,3 +240,60 @@ func SetXCSEContext(vm map[string]string, r *http.Request) {
 	}
 	r.Header.Set(HeaderXCseContent, string(b))
 }
+
+// TimeoutFromHeaders return the remaining time budget in headers, header name is case-insensitive
+func TimeoutFromHeaders(m map[string]string) (time.Duration, bool) {
+	v, ok := m[HeaderTimeout]
+	if !ok {
+		for k := range m {
+			if strings.EqualFold(k, HeaderTimeout) {
+				v, ok = m[k], true
+				break
+			}
+		}
+	}
+	if !ok {
+		return 0, false
+	}
+	ms, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		openlog.Debug(fmt.Sprintf("invalid timeout header [%s]: %s", v, err))
+		return 0, false
+	}
+	return time.Duration(ms) * time.Millisecond, true
+}
+
+// SetTimeoutHeader set the remaining time of ctx deadline into ctx headers, timeout headers in other cases,
+// like the one received by provider, are removed so that only the fresh one is sent.
+// it returns false if ctx has no deadline or no headers
+func SetTimeoutHeader(ctx context.Context) bool {
+	d, ok := ctx.Deadline()
+	if !ok {
+		return false
+	}
+	m, ok := ctx.Value(ContextHeaderKey{}).(map[string]string)
+	if !ok {
+		return false
+	}
+	ms := time.Until(d).Milliseconds()
+	if ms < 0 {
+		ms = 0
+	}
+	for k := range m {
+		if strings.EqualFold(k, HeaderTimeout) {
+			delete(m, k)
+		}
+	}
+	m[HeaderTimeout] = strconv.FormatInt(ms, 10)
+	return true
+}
+
+// WithHeaderDeadline returns a copy of ctx with the deadline decided by timeout header,
+// if there is no timeout header, ctx is returned and cancel does nothing
+func WithHeaderDeadline(ctx context.Context, m map[string]string) (context.Context, context.CancelFunc) {
+	timeout, ok := TimeoutFromHeaders(m)
+	if !ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
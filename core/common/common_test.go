@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/go-chassis/go-chassis/v2/core/common"
 	"github.com/stretchr/testify/assert"
@@ -98,3 +99,53 @@ func TestXCseHeader(t *testing.T) {
 		assert.Equal(t, s, "test5")
 	})
 }
+
+func TestTimeoutHeader(t *testing.T) {
+	ctx := common.NewContext(nil)
+	assert.False(t, common.SetTimeoutHeader(ctx))
+	_, ok := common.TimeoutFromHeaders(common.FromContext(ctx))
+	assert.False(t, ok)
+
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	assert.True(t, common.SetTimeoutHeader(ctx))
+	timeout, ok := common.TimeoutFromHeaders(common.FromContext(ctx))
+	assert.True(t, ok)
+	assert.True(t, timeout > 59*time.Second && timeout <= time.Minute)
+
+	t.Run("ctx without headers, should not report success", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		assert.False(t, common.SetTimeoutHeader(ctx))
+	})
+
+	t.Run("inbound timeout header, should be replaced", func(t *testing.T) {
+		m := map[string]string{"X-Cse-Timeout": "999999"}
+		ctx, cancel := context.WithTimeout(common.NewContext(m), time.Second)
+		defer cancel()
+		assert.True(t, common.SetTimeoutHeader(ctx))
+		assert.Equal(t, 1, len(m))
+		timeout, ok := common.TimeoutFromHeaders(m)
+		assert.True(t, ok)
+		assert.True(t, timeout <= time.Second)
+	})
+	t.Run("header name is case-insensitive", func(t *testing.T) {
+		timeout, ok := common.TimeoutFromHeaders(map[string]string{"X-Cse-Timeout": "1500"})
+		assert.True(t, ok)
+		assert.Equal(t, 1500*time.Millisecond, timeout)
+		_, ok = common.TimeoutFromHeaders(map[string]string{"X-Cse-Timeout": "abc"})
+		assert.False(t, ok)
+	})
+	t.Run("turn header back to deadline", func(t *testing.T) {
+		ctx, cancel := common.WithHeaderDeadline(context.Background(), map[string]string{"X-Cse-Timeout": "1500"})
+		defer cancel()
+		d, ok := ctx.Deadline()
+		assert.True(t, ok)
+		assert.True(t, time.Until(d) <= 1500*time.Millisecond)
+
+		ctx, cancel = common.WithHeaderDeadline(context.Background(), nil)
+		defer cancel()
+		_, ok = ctx.Deadline()
+		assert.False(t, ok)
+	})
+}
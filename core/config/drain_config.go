@@ -0,0 +1,38 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-chassis/openlog"
+)
+
+const (
+	//DefaultDrainTimeout is default value for the time in-flight requests have to complete during shutdown
+	DefaultDrainTimeout = 30 * time.Second
+)
+
+// GetDrainPropagationDelay returns the time to wait after instance is marked DOWN,
+// so that consumers are able to know it before servers stop
+func GetDrainPropagationDelay() time.Duration {
+	return getDuration("servicecomb.drain.propagationDelay", 0)
+}
+
+// GetDrainTimeout returns the time in-flight requests have to complete, after that servers are force closed
+func GetDrainTimeout() time.Duration {
+	return getDuration("servicecomb.drain.timeout", DefaultDrainTimeout)
+}
+
+func getDuration(key string, def time.Duration) time.Duration {
+	s := archaius.GetString(key, "")
+	if s == "" {
+		return def
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		openlog.Warn(fmt.Sprintf("invalid duration [%s] of [%s], use default value %s", s, key, def))
+		return def
+	}
+	return d
+}
@@ -0,0 +1,18 @@
+package config
+
+import (
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-chassis/go-chassis/v2/core/config/model"
+)
+
+// EdgeKey is the key prefix of edge service config
+const EdgeKey = "^servicecomb\\.edge\\."
+
+// ReadEdgeFromArchaius unmarshal edge service config from archaius
+func ReadEdgeFromArchaius() (*model.EdgeStruct, error) {
+	w := &model.EdgeWrapper{}
+	if err := archaius.UnmarshalConfig(w); err != nil {
+		return nil, err
+	}
+	return &w.ServiceComb.Edge, nil
+}
@@ -0,0 +1,18 @@
+package config
+
+import (
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-chassis/go-chassis/v2/core/config/model"
+)
+
+// EgressKey is the key prefix of egress config
+const EgressKey = "^servicecomb\\.egress\\."
+
+// ReadEgressFromArchaius unmarshal egress rules from archaius
+func ReadEgressFromArchaius() (*model.EgressStruct, error) {
+	w := &model.EgressWrapper{}
+	if err := archaius.UnmarshalConfig(w); err != nil {
+		return nil, err
+	}
+	return &w.ServiceComb.Egress, nil
+}
@@ -2,9 +2,11 @@ package config
 
 import (
 	"github.com/go-chassis/go-archaius"
+	"github.com/go-chassis/go-chassis/v2/core/common"
 	"github.com/go-chassis/go-chassis/v2/resilience/retry"
 	"strings"
 	"sync"
+	"time"
 )
 
 const (
@@ -23,6 +25,12 @@ const (
 	DefaultSessionTimeout = 30
 	//DefaultFailedTimes is default value for failed times
 	DefaultFailedTimes = 5
+	//DefaultHedgingDelay is default delay before a hedged attempt is sent
+	DefaultHedgingDelay = 100 * time.Millisecond
+	//DefaultHedgingMaxAttempts is default value for attempts, include the first one
+	DefaultHedgingMaxAttempts = 2
+	//DefaultHedgingMaxOutstanding is default value for in flight hedged attempts of one service
+	DefaultHedgingMaxOutstanding = 10
 )
 
 var lbMutex = sync.RWMutex{}
@@ -129,3 +137,290 @@ func BackOffMaxMs(source, service string) int {
 	ms := archaius.GetInt(genKey(lbPrefix, service, propertyBackoffMaxMs), global)
 	return ms
 }
+
+// constant for slow start
+const (
+	//SlowStartLinear ramps up weight of a new instance linearly
+	SlowStartLinear = "linear"
+	//SlowStartExponential ramps up weight of a new instance exponentially, it stays low longer than linear
+	SlowStartExponential = "exponential"
+	//DefaultSlowStartMinWeight is default weight of an instance which just appears
+	DefaultSlowStartMinWeight = 0.1
+
+	propertySlowStartWindow    = "slowStart.window"
+	propertySlowStartCurve     = "slowStart.curve"
+	propertySlowStartMinWeight = "slowStart.minWeight"
+)
+
+// GetSlowStartWindow returns how long a new instance of a service warms up, 0 means slow start is disabled
+func GetSlowStartWindow(service string) time.Duration {
+	return getDuration(genKey(lbPrefix, service, propertySlowStartWindow),
+		getDuration(genKey(lbPrefix, propertySlowStartWindow), 0))
+}
+
+// GetSlowStartCurve returns how weight of a new instance ramps up, linear or exponential
+func GetSlowStartCurve(service string) string {
+	return archaius.GetString(genKey(lbPrefix, service, propertySlowStartCurve),
+		archaius.GetString(genKey(lbPrefix, propertySlowStartCurve), SlowStartLinear))
+}
+
+// GetSlowStartMinWeight returns weight of an instance which just appears, in range (0,1]
+func GetSlowStartMinWeight(service string) float64 {
+	w := archaius.GetFloat64(genKey(lbPrefix, service, propertySlowStartMinWeight),
+		archaius.GetFloat64(genKey(lbPrefix, propertySlowStartMinWeight), DefaultSlowStartMinWeight))
+	if w <= 0 || w > 1 {
+		return DefaultSlowStartMinWeight
+	}
+	return w
+}
+
+// constant for outlier detection
+const (
+	//DefaultOutlierConsecutive5xx is default number of consecutive 5xx, gateway errors and connect failures before ejection
+	DefaultOutlierConsecutive5xx = 5
+	//DefaultOutlierConsecutiveGatewayErrors is default number of consecutive gateway errors and connect failures before ejection
+	DefaultOutlierConsecutiveGatewayErrors = 5
+	//DefaultOutlierConsecutiveConnectFailures is default number of consecutive connect failures before ejection
+	DefaultOutlierConsecutiveConnectFailures = 5
+	//DefaultOutlierBaseEjectionTime is default ejection time, it is multiplied by times an instance is ejected
+	DefaultOutlierBaseEjectionTime = 30 * time.Second
+	//DefaultOutlierMaxEjectionTime is default max ejection time
+	DefaultOutlierMaxEjectionTime = 300 * time.Second
+	//DefaultOutlierMaxEjectionPercent is default max percent of instances of a service which can be ejected
+	DefaultOutlierMaxEjectionPercent = 10
+
+	propertyOutlierPrefix = "outlierDetection"
+)
+
+func getOutlierInt(service, property string, def int) int {
+	return archaius.GetInt(genKey(lbPrefix, service, propertyOutlierPrefix, property),
+		archaius.GetInt(genKey(lbPrefix, propertyOutlierPrefix, property), def))
+}
+
+func getOutlierDuration(service, property string, def time.Duration) time.Duration {
+	return getDuration(genKey(lbPrefix, service, propertyOutlierPrefix, property),
+		getDuration(genKey(lbPrefix, propertyOutlierPrefix, property), def))
+}
+
+// GetOutlierDetectionEnabled returns if instances of a service are ejected once they fail, default is true
+func GetOutlierDetectionEnabled(service string) bool {
+	return archaius.GetBool(genKey(lbPrefix, service, propertyOutlierPrefix, "enabled"),
+		archaius.GetBool(genKey(lbPrefix, propertyOutlierPrefix, "enabled"), true))
+}
+
+// GetOutlierConsecutive5xx returns number of consecutive 5xx, gateway errors and connect failures before ejection,
+// 0 means never
+func GetOutlierConsecutive5xx(service string) int {
+	return getOutlierInt(service, "consecutive5xx", DefaultOutlierConsecutive5xx)
+}
+
+// GetOutlierConsecutiveGatewayErrors returns number of consecutive 502, 503, 504, timeouts and connect failures
+// before ejection, 0 means never
+func GetOutlierConsecutiveGatewayErrors(service string) int {
+	return getOutlierInt(service, "consecutiveGatewayErrors", DefaultOutlierConsecutiveGatewayErrors)
+}
+
+// GetOutlierConsecutiveConnectFailures returns number of consecutive connect failures before ejection, 0 means never
+func GetOutlierConsecutiveConnectFailures(service string) int {
+	return getOutlierInt(service, "consecutiveConnectFailures", DefaultOutlierConsecutiveConnectFailures)
+}
+
+// GetOutlierBaseEjectionTime returns ejection time, it is multiplied by times an instance is ejected
+func GetOutlierBaseEjectionTime(service string) time.Duration {
+	return getOutlierDuration(service, "baseEjectionTime", DefaultOutlierBaseEjectionTime)
+}
+
+// GetOutlierMaxEjectionTime returns max ejection time
+func GetOutlierMaxEjectionTime(service string) time.Duration {
+	return getOutlierDuration(service, "maxEjectionTime", DefaultOutlierMaxEjectionTime)
+}
+
+// GetOutlierMaxEjectionPercent returns max percent of instances of a service which can be ejected
+func GetOutlierMaxEjectionPercent(service string) int {
+	return getOutlierInt(service, "maxEjectionPercent", DefaultOutlierMaxEjectionPercent)
+}
+
+// constant for active health check
+const (
+	//HealthCheckHTTP probes an instance with http GET, 2xx and 3xx status means healthy
+	HealthCheckHTTP = "http"
+	//HealthCheckTCP probes an instance by establishing tcp connection
+	HealthCheckTCP = "tcp"
+	//HealthCheckGRPC probes an instance with grpc health checking protocol
+	HealthCheckGRPC = "grpc"
+	//DefaultHealthCheckPath is default path of http health check
+	DefaultHealthCheckPath = "/health"
+	//DefaultHealthCheckInterval is default interval between two probes
+	DefaultHealthCheckInterval = 10 * time.Second
+	//DefaultHealthCheckTimeout is default timeout of a probe
+	DefaultHealthCheckTimeout = 3 * time.Second
+	//DefaultHealthCheckHealthyThreshold is default number of consecutive successes before an instance is healthy again
+	DefaultHealthCheckHealthyThreshold = 2
+	//DefaultHealthCheckUnhealthyThreshold is default number of consecutive failures before an instance is unhealthy
+	DefaultHealthCheckUnhealthyThreshold = 3
+
+	propertyHealthCheckPrefix = "healthCheck"
+)
+
+// HealthCheckKey matches keys of active health check config, global or of a service
+const HealthCheckKey = "^cse\\.loadbalance\\.(.+\\.)?healthCheck\\."
+
+func getHealthCheckString(service, property, def string) string {
+	return archaius.GetString(genKey(lbPrefix, service, propertyHealthCheckPrefix, property),
+		archaius.GetString(genKey(lbPrefix, propertyHealthCheckPrefix, property), def))
+}
+
+func getHealthCheckInt(service, property string, def int) int {
+	n := archaius.GetInt(genKey(lbPrefix, service, propertyHealthCheckPrefix, property),
+		archaius.GetInt(genKey(lbPrefix, propertyHealthCheckPrefix, property), def))
+	if n <= 0 {
+		return def
+	}
+	return n
+}
+
+func getHealthCheckDuration(service, property string, def time.Duration) time.Duration {
+	d := getDuration(genKey(lbPrefix, service, propertyHealthCheckPrefix, property),
+		getDuration(genKey(lbPrefix, propertyHealthCheckPrefix, property), def))
+	if d <= 0 {
+		return def
+	}
+	return d
+}
+
+// GetHealthCheckType returns how instances of a service are probed, http, tcp or grpc,
+// empty means active health check is disabled
+func GetHealthCheckType(service string) string {
+	return getHealthCheckString(service, "type", "")
+}
+
+// GetHealthCheckPath returns path of http health check, or service name of grpc health check
+func GetHealthCheckPath(service string) string {
+	def := DefaultHealthCheckPath
+	if GetHealthCheckType(service) == HealthCheckGRPC {
+		def = ""
+	}
+	return getHealthCheckString(service, "path", def)
+}
+
+// GetHealthCheckProtocol returns protocol of the endpoint which is probed,
+// it is grpc for grpc health check and rest for others by default
+func GetHealthCheckProtocol(service string) string {
+	def := common.ProtocolRest
+	if GetHealthCheckType(service) == HealthCheckGRPC {
+		def = common.ProtocolGRPC
+	}
+	return getHealthCheckString(service, "protocol", def)
+}
+
+// GetHealthCheckInterval returns interval between two probes of an instance
+func GetHealthCheckInterval(service string) time.Duration {
+	return getHealthCheckDuration(service, "interval", DefaultHealthCheckInterval)
+}
+
+// GetHealthCheckTimeout returns timeout of a probe
+func GetHealthCheckTimeout(service string) time.Duration {
+	return getHealthCheckDuration(service, "timeout", DefaultHealthCheckTimeout)
+}
+
+// GetHealthCheckHealthyThreshold returns number of consecutive successes before an unhealthy instance is healthy again
+func GetHealthCheckHealthyThreshold(service string) int {
+	return getHealthCheckInt(service, "healthyThreshold", DefaultHealthCheckHealthyThreshold)
+}
+
+// GetHealthCheckUnhealthyThreshold returns number of consecutive failures before an instance is unhealthy
+func GetHealthCheckUnhealthyThreshold(service string) int {
+	return getHealthCheckInt(service, "unhealthyThreshold", DefaultHealthCheckUnhealthyThreshold)
+}
+
+// constant for consistent hash
+const (
+	//DefaultConsistentHashVirtualNodes is default number of points an instance has on hash ring
+	DefaultConsistentHashVirtualNodes = 160
+	//DefaultConsistentHashLoadFactor is default max load of an instance relative to average load
+	DefaultConsistentHashLoadFactor = 1.25
+
+	propertyConsistentHashPrefix = "consistentHash"
+)
+
+// GetConsistentHashKey returns where hash key of a request comes from, it is like header:X-User-Id,
+// query:user, cookie:session or field:SourceMicroService
+func GetConsistentHashKey(service string) string {
+	return archaius.GetString(genKey(lbPrefix, service, propertyConsistentHashPrefix, "hashKey"),
+		archaius.GetString(genKey(lbPrefix, propertyConsistentHashPrefix, "hashKey"), ""))
+}
+
+// GetConsistentHashVirtualNodes returns number of points an instance has on hash ring
+func GetConsistentHashVirtualNodes(service string) int {
+	n := archaius.GetInt(genKey(lbPrefix, service, propertyConsistentHashPrefix, "virtualNodes"),
+		archaius.GetInt(genKey(lbPrefix, propertyConsistentHashPrefix, "virtualNodes"), DefaultConsistentHashVirtualNodes))
+	if n <= 0 {
+		return DefaultConsistentHashVirtualNodes
+	}
+	return n
+}
+
+// GetConsistentHashLoadFactor returns max in-flight requests of an instance relative to average,
+// requests spill over to next instance on hash ring once it is exceeded, a value no more than 1 disables bounded load
+func GetConsistentHashLoadFactor(service string) float64 {
+	return archaius.GetFloat64(genKey(lbPrefix, service, propertyConsistentHashPrefix, "loadFactor"),
+		archaius.GetFloat64(genKey(lbPrefix, propertyConsistentHashPrefix, "loadFactor"), DefaultConsistentHashLoadFactor))
+}
+
+// constant for weighted round robin
+const (
+	//DefaultWeightKey is default metadata key of instance weight
+	DefaultWeightKey = "weight"
+	//DefaultInstanceWeight is default weight of an instance without valid weight
+	DefaultInstanceWeight = 1
+
+	propertyWeightedRoundRobinPrefix = "weightedRoundRobin"
+)
+
+// GetWeightKey returns metadata key of instance weight
+func GetWeightKey(service string) string {
+	return archaius.GetString(genKey(lbPrefix, service, propertyWeightedRoundRobinPrefix, "weightKey"),
+		archaius.GetString(genKey(lbPrefix, propertyWeightedRoundRobinPrefix, "weightKey"), DefaultWeightKey))
+}
+
+// GetDefaultInstanceWeight returns weight of an instance whose weight is missing or invalid
+func GetDefaultInstanceWeight(service string) int {
+	w := archaius.GetInt(genKey(lbPrefix, service, propertyWeightedRoundRobinPrefix, "defaultWeight"),
+		archaius.GetInt(genKey(lbPrefix, propertyWeightedRoundRobinPrefix, "defaultWeight"), DefaultInstanceWeight))
+	if w <= 0 {
+		return DefaultInstanceWeight
+	}
+	return w
+}
+
+// constant for locality failover
+const (
+	//DefaultLocalityMinHealthyPercent is default percent of healthy instances a locality tier needs to take all its traffic
+	DefaultLocalityMinHealthyPercent = 70
+
+	propertyLocalityPrefix = "locality"
+)
+
+// GetLocalityMinHealthyPercent returns percent of healthy instances a locality tier needs to take all its traffic,
+// tier is zone, region, failover or remote. once a tier has less healthy instances, its traffic spills over in proportion
+func GetLocalityMinHealthyPercent(tier string) int {
+	p := archaius.GetInt(genKey(lbPrefix, propertyLocalityPrefix, tier, "minHealthyPercent"),
+		archaius.GetInt(genKey(lbPrefix, propertyLocalityPrefix, "minHealthyPercent"), DefaultLocalityMinHealthyPercent))
+	if p <= 0 || p > 100 {
+		return DefaultLocalityMinHealthyPercent
+	}
+	return p
+}
+
+// GetLocalityFailoverRegions returns regions which take traffic in order after local region,
+// before other regions
+func GetLocalityFailoverRegions() []string {
+	s := archaius.GetString(genKey(lbPrefix, propertyLocalityPrefix, "failoverRegions"), "")
+	regions := make([]string, 0)
+	for _, r := range strings.Split(s, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			regions = append(regions, r)
+		}
+	}
+	return regions
+}
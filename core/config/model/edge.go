@@ -0,0 +1,28 @@
+package model
+
+// EdgeWrapper is the edge service config
+type EdgeWrapper struct {
+	ServiceComb EdgeServiceComb `yaml:"servicecomb"`
+}
+
+// EdgeServiceComb is the servicecomb section of edge service config
+type EdgeServiceComb struct {
+	Edge EdgeStruct `yaml:"edge"`
+}
+
+// EdgeStruct maps incoming path prefixes to micro services, key of routes is route name
+type EdgeStruct struct {
+	Routes map[string]EdgeRoute `yaml:"routes"`
+}
+
+// EdgeRoute forwards requests matching the path prefix to a micro service
+type EdgeRoute struct {
+	Prefix  string `yaml:"prefix"`
+	Service string `yaml:"service"`
+	// Rewrite replaces the matched prefix, use "/" to strip the prefix
+	Rewrite string `yaml:"rewrite"`
+	// StripHeaders are request headers which are not forwarded
+	StripHeaders []string `yaml:"stripHeaders"`
+	// Chain is the consumer handler chain name, it is "default" if empty
+	Chain string `yaml:"chain"`
+}
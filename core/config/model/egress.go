@@ -0,0 +1,30 @@
+package model
+
+// EgressWrapper is the egress config
+type EgressWrapper struct {
+	ServiceComb EgressServiceComb `yaml:"servicecomb"`
+}
+
+// EgressServiceComb is the servicecomb section of egress config
+type EgressServiceComb struct {
+	Egress EgressStruct `yaml:"egress"`
+}
+
+// EgressStruct holds egress rules, key of rules is rule name
+type EgressStruct struct {
+	Rules map[string]EgressRule `yaml:"rules"`
+}
+
+// EgressRule allows consumer to call hosts on ports
+type EgressRule struct {
+	// Hosts supports wildcard, like *.example.com and *
+	Hosts []string `yaml:"hosts"`
+	// Ports is optional, all ports are allowed if it is empty
+	Ports []*EgressPort `yaml:"ports"`
+}
+
+// EgressPort is a port with protocol, protocol is optional
+type EgressPort struct {
+	Port     int32  `yaml:"port"`
+	Protocol string `yaml:"protocol"`
+}
@@ -19,6 +19,7 @@ type LoadBalancing struct {
 	Filters               string                       `yaml:"serverListFilters"`
 	Backoff               BackoffStrategy              `yaml:"backoff"`
 	SessionStickinessRule SessionStickinessRule        `yaml:"SessionStickinessRule"`
+	Hedging               HedgingSpec                  `yaml:"hedging"`
 	AnyService            map[string]LoadBalancingSpec `yaml:",inline"`
 }
 
@@ -30,6 +31,7 @@ type LoadBalancingSpec struct {
 	RetryOnNext           int                   `yaml:"retryOnNext"`
 	RetryOnSame           int                   `yaml:"retryOnSame"`
 	Backoff               BackoffStrategy       `yaml:"backoff"`
+	Hedging               HedgingSpec           `yaml:"hedging"`
 }
 
 // SessionStickinessRule loadbalancing structure
@@ -44,3 +46,14 @@ type BackoffStrategy struct {
 	MinMs int    `yaml:"minMs"`
 	MaxMs int    `yaml:"maxMs"`
 }
+
+// HedgingSpec hedging strategy, send parallel attempts to other instances
+// if the first one is slow, the first successful response wins
+type HedgingSpec struct {
+	Enabled        bool     `yaml:"enabled"`
+	Delay          string   `yaml:"delay"`
+	Percentile     float64  `yaml:"percentile"`
+	MaxAttempts    int      `yaml:"maxAttempts"`
+	MaxOutstanding int      `yaml:"maxOutstanding"`
+	Operations     []string `yaml:"operations"`
+}
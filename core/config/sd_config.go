@@ -1,6 +1,13 @@
 package config
 
-import "github.com/go-chassis/go-archaius"
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-chassis/go-chassis/v2/pkg/util/fileutil"
+)
 
 // GetServiceDiscoveryType returns the Type of SD registry
 func GetServiceDiscoveryType() string {
@@ -52,3 +59,124 @@ func GetServiceDiscoveryConfigPath() string {
 	}
 	return DefaultConfigPath
 }
+
+// GetServiceDiscoveryFilePath returns the file path of file registry,
+// default is registry.yaml in conf dir
+func GetServiceDiscoveryFilePath() string {
+	return archaius.GetString("servicecomb.registry.file.path", filepath.Join(fileutil.GetConfDir(), "registry.yaml"))
+}
+
+// GetServiceDiscoveryKubernetesNamespace returns the namespace kubernetes registry watches, default is "default"
+func GetServiceDiscoveryKubernetesNamespace() string {
+	return archaius.GetString("servicecomb.registry.kubernetes.namespace", "default")
+}
+
+// GetServiceDiscoveryKubernetesEndpointSlices returns if kubernetes registry reads endpoint slices instead of endpoints
+func GetServiceDiscoveryKubernetesEndpointSlices() bool {
+	return archaius.GetBool("servicecomb.registry.kubernetes.endpointSlices", false)
+}
+
+// constant for dns registry
+const (
+	//DefaultDNSSRVName is the default srv name of dns registry, {service} is replaced by service name
+	DefaultDNSSRVName = "_{service}._tcp"
+	//DefaultDNSPort is the default port of instances resolved by A/AAAA records
+	DefaultDNSPort = 80
+	//DefaultDNSMinTTL is the default min interval of resolving
+	DefaultDNSMinTTL = 5 * time.Second
+	//DefaultDNSMaxTTL is the default max interval of resolving
+	DefaultDNSMaxTTL = 5 * time.Minute
+)
+
+// GetServiceDiscoveryDNSDomain returns the domain appended to service names by dns registry
+func GetServiceDiscoveryDNSDomain() string {
+	return archaius.GetString("servicecomb.registry.dns.domain", "")
+}
+
+// GetServiceDiscoveryDNSSRVName returns the srv name of dns registry, srv query is disabled if it is empty
+func GetServiceDiscoveryDNSSRVName() string {
+	return archaius.GetString("servicecomb.registry.dns.srv", DefaultDNSSRVName)
+}
+
+// GetServiceDiscoveryDNSPort returns the port of instances resolved by A/AAAA records
+func GetServiceDiscoveryDNSPort() int {
+	return archaius.GetInt("servicecomb.registry.dns.port", DefaultDNSPort)
+}
+
+// GetServiceDiscoveryDNSMinTTL returns the min interval of resolving, ttl of records shorter than it is not respected
+func GetServiceDiscoveryDNSMinTTL() time.Duration {
+	return getDuration("servicecomb.registry.dns.minTTL", DefaultDNSMinTTL)
+}
+
+// GetServiceDiscoveryDNSMaxTTL returns the max interval of resolving
+func GetServiceDiscoveryDNSMaxTTL() time.Duration {
+	return getDuration("servicecomb.registry.dns.maxTTL", DefaultDNSMaxTTL)
+}
+
+// constant for composite discovery
+const (
+	//DefaultCompositePolicy is the default merge policy of composite discovery
+	DefaultCompositePolicy = "union"
+	//DefaultCompositeStaleAfter is the default time instances of a failing backend are still used
+	DefaultCompositeStaleAfter = 10 * time.Minute
+)
+
+// GetServiceDiscoveryCompositeBackends returns discovery plugins composite discovery wraps, in order
+func GetServiceDiscoveryCompositeBackends() []string {
+	backends := make([]string, 0)
+	for _, b := range strings.Split(archaius.GetString("servicecomb.registry.composite.backends", ""), ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			backends = append(backends, b)
+		}
+	}
+	return backends
+}
+
+// GetServiceDiscoveryCompositePolicy returns how composite discovery merges instances of backends
+func GetServiceDiscoveryCompositePolicy() string {
+	return archaius.GetString("servicecomb.registry.composite.policy", DefaultCompositePolicy)
+}
+
+// GetServiceDiscoveryCompositeAddress returns comma separated addresses of a backend of composite discovery
+func GetServiceDiscoveryCompositeAddress(backend string) string {
+	return archaius.GetString("servicecomb.registry.composite."+backend+".address", "")
+}
+
+// GetServiceDiscoveryCompositeStaleAfter returns how long instances of a failing backend are still used
+func GetServiceDiscoveryCompositeStaleAfter() time.Duration {
+	return getDuration("servicecomb.registry.composite.staleAfter", DefaultCompositeStaleAfter)
+}
+
+// constant for snapshot of discovery cache
+const (
+	//DefaultSnapshotInterval is the default interval of saving discovery cache snapshot
+	DefaultSnapshotInterval = 30 * time.Second
+	//DefaultSnapshotMaxAge is the default max age of a snapshot which can be loaded
+	DefaultSnapshotMaxAge = 24 * time.Hour
+)
+
+// GetServiceDiscoverySnapshotEnabled returns if discovery cache is saved into a snapshot file
+func GetServiceDiscoverySnapshotEnabled() bool {
+	return archaius.GetBool("servicecomb.registry.snapshot.enabled", false)
+}
+
+// GetServiceDiscoverySnapshotPath returns the snapshot file path,
+// default is registry-snapshot.json in chassis home dir
+func GetServiceDiscoverySnapshotPath() string {
+	return archaius.GetString("servicecomb.registry.snapshot.path", filepath.Join(fileutil.ChassisHomeDir(), "registry-snapshot.json"))
+}
+
+// GetServiceDiscoverySnapshotInterval returns the interval of saving snapshot
+func GetServiceDiscoverySnapshotInterval() time.Duration {
+	return getDuration("servicecomb.registry.snapshot.interval", DefaultSnapshotInterval)
+}
+
+// GetServiceDiscoverySnapshotMaxAge returns the max age of a snapshot which can be loaded, 0 means no limit
+func GetServiceDiscoverySnapshotMaxAge() time.Duration {
+	return getDuration("servicecomb.registry.snapshot.maxAge", DefaultSnapshotMaxAge)
+}
+
+// GetServiceDiscoveryKubernetesNodeTopology returns if kubernetes registry reads topology of Endpoints from nodes
+func GetServiceDiscoveryKubernetesNodeTopology() bool {
+	return archaius.GetBool("servicecomb.registry.kubernetes.nodeTopology", false)
+}
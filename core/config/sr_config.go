@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+	"time"
+
 	"github.com/go-chassis/cari/rbac"
 	"github.com/go-chassis/go-archaius"
 	"github.com/go-chassis/go-chassis/v2/security/cipher"
@@ -64,3 +66,98 @@ func GetRegistratorRbacAccount() *rbac.AuthUser {
 	}
 	return &auth
 }
+
+// constant for etcd registry
+const (
+	//DefaultEtcdPrefix is the default key prefix of etcd registry
+	DefaultEtcdPrefix = "/servicecomb/registry"
+	//DefaultEtcdTTL is the default lease ttl of instances in etcd registry
+	DefaultEtcdTTL = 90 * time.Second
+)
+
+// GetRegistratorEtcdPrefix returns the key prefix of etcd registry
+func GetRegistratorEtcdPrefix() string {
+	return archaius.GetString("servicecomb.registry.etcd.prefix", DefaultEtcdPrefix)
+}
+
+// GetRegistratorEtcdTTL returns the lease ttl of instances in etcd registry, it must be longer than heartbeat interval
+func GetRegistratorEtcdTTL() time.Duration {
+	return getDuration("servicecomb.registry.etcd.ttl", DefaultEtcdTTL)
+}
+
+// constant for consul registry
+const (
+	//DefaultConsulPrefix is the default kv prefix of consul registry
+	DefaultConsulPrefix = "servicecomb/registry"
+	//DefaultConsulTTL is the default ttl of instance checks in consul registry
+	DefaultConsulTTL = 90 * time.Second
+	//DefaultConsulDeregisterAfter is the default time after which critical instances are deregistered by consul
+	DefaultConsulDeregisterAfter = 10 * time.Minute
+	//DefaultConsulWaitTime is the default wait time of consul blocking queries
+	DefaultConsulWaitTime = 5 * time.Minute
+)
+
+// GetRegistratorConsulPrefix returns the kv prefix of consul registry, services and schemas are stored under it
+func GetRegistratorConsulPrefix() string {
+	return archaius.GetString("servicecomb.registry.consul.prefix", DefaultConsulPrefix)
+}
+
+// GetRegistratorConsulToken returns the acl token of consul
+func GetRegistratorConsulToken() string {
+	return archaius.GetString("servicecomb.registry.consul.token", "")
+}
+
+// GetRegistratorConsulTTL returns the ttl of instance checks in consul registry, it must be longer than heartbeat interval
+func GetRegistratorConsulTTL() time.Duration {
+	return getDuration("servicecomb.registry.consul.ttl", DefaultConsulTTL)
+}
+
+// GetRegistratorConsulDeregisterAfter returns the time after which critical instances are deregistered by consul
+func GetRegistratorConsulDeregisterAfter() time.Duration {
+	return getDuration("servicecomb.registry.consul.deregisterAfter", DefaultConsulDeregisterAfter)
+}
+
+// GetServiceDiscoveryConsulWaitTime returns the wait time of consul blocking queries
+func GetServiceDiscoveryConsulWaitTime() time.Duration {
+	return getDuration("servicecomb.registry.consul.waitTime", DefaultConsulWaitTime)
+}
+
+const (
+	//DefaultGossipBind is the default address gossip registry listens on
+	DefaultGossipBind = "0.0.0.0:7946"
+	//DefaultGossipInterval is the default probe interval of gossip registry
+	DefaultGossipInterval = time.Second
+	//DefaultGossipSuspectTimeout is the default time after which a suspected member is declared dead
+	DefaultGossipSuspectTimeout = 5 * time.Second
+)
+
+// GetRegistratorGossipBind returns the udp address gossip registry listens on
+func GetRegistratorGossipBind() string {
+	return archaius.GetString("servicecomb.registry.gossip.bind", DefaultGossipBind)
+}
+
+// GetRegistratorGossipAdvertise returns the address other members reach this member at,
+// it is the bind address, or the local ip with bind port if bind ip is unspecified
+func GetRegistratorGossipAdvertise() string {
+	return archaius.GetString("servicecomb.registry.gossip.advertise", "")
+}
+
+// GetRegistratorGossipInterval returns the probe interval of gossip registry
+func GetRegistratorGossipInterval() time.Duration {
+	return getDuration("servicecomb.registry.gossip.interval", DefaultGossipInterval)
+}
+
+// GetRegistratorGossipSuspectTimeout returns the time after which a suspected member is declared dead
+func GetRegistratorGossipSuspectTimeout() time.Duration {
+	return getDuration("servicecomb.registry.gossip.suspectTimeout", DefaultGossipSuspectTimeout)
+}
+
+// GetRegistratorCompositeType returns the plugin which registers this service when registry type is composite,
+// it is the first backend of composite discovery by default
+func GetRegistratorCompositeType() string {
+	t := archaius.GetString("servicecomb.registry.composite.registrator", "")
+	if backends := GetServiceDiscoveryCompositeBackends(); t == "" && len(backends) != 0 {
+		t = backends[0]
+	}
+	return t
+}
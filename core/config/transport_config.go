@@ -0,0 +1,47 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/go-chassis/go-archaius"
+)
+
+const (
+	compressionPrefix = "servicecomb.transport.compression"
+	//DefaultCompressionEncodings is default value for supported encodings
+	DefaultCompressionEncodings = "gzip,deflate"
+	//DefaultCompressionMinSize is default value for body size threshold
+	DefaultCompressionMinSize = 1024
+)
+
+// GetLocalTransportEnabled returns if calls to providers in the same process should skip network
+func GetLocalTransportEnabled() bool {
+	return archaius.GetBool("servicecomb.transport.local.enabled", false)
+}
+
+// GetCompressionEnabled returns if http response compression is enabled for traffic with the service,
+// service level config overrides the global one
+func GetCompressionEnabled(service string) bool {
+	global := archaius.GetBool(genKey(compressionPrefix, "enabled"), false)
+	if service == "" {
+		return global
+	}
+	return archaius.GetBool(genKey(compressionPrefix, service, "enabled"), global)
+}
+
+// GetCompressionEncodings returns supported encodings in order of preference
+func GetCompressionEncodings() []string {
+	encodings := archaius.GetString(genKey(compressionPrefix, "encodings"), DefaultCompressionEncodings)
+	s := make([]string, 0)
+	for _, e := range strings.Split(encodings, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			s = append(s, e)
+		}
+	}
+	return s
+}
+
+// GetCompressionMinSize returns body size threshold, smaller body will not be compressed
+func GetCompressionMinSize() int {
+	return archaius.GetInt(genKey(compressionPrefix, "minSize"), DefaultCompressionMinSize)
+}
@@ -69,6 +69,7 @@ func (lb *LBHandler) getEndpoint(i *invocation.Invocation, lbConfig control.Load
 		openlog.Error(lbErr.Error())
 		return nil, lbErr
 	}
+	i.SetMetadata(loadbalancer.MDLoadStats, true)
 	return ep, nil
 }
 
@@ -79,6 +80,10 @@ func (lb *LBHandler) Handle(chain *Chain, i *invocation.Invocation, cb invocatio
 		return
 	}
 	lbConfig := control.DefaultPanel.GetLoadBalancing(*i)
+	if hedgingEnabled(i, lbConfig) {
+		lb.handleWithHedging(chain, i, lbConfig, cb)
+		return
+	}
 	if !lbConfig.RetryEnabled {
 		lb.handleWithNoRetry(chain, i, lbConfig, cb)
 	} else {
@@ -0,0 +1,248 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chassis/go-chassis/v2/control"
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/invocation"
+	"github.com/go-chassis/go-chassis/v2/core/loadbalancer"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/go-chassis/v2/core/status"
+	"github.com/go-chassis/openlog"
+)
+
+// hedgeOutstanding saves the number of in flight hedged attempts of each service
+var hedgeOutstanding sync.Map
+
+type hedgeResult struct {
+	inv  *invocation.Invocation
+	resp *invocation.Response
+}
+
+func hedgingEnabled(i *invocation.Invocation, lbConfig control.LoadBalancingConfig) bool {
+	if !lbConfig.HedgingEnabled {
+		return false
+	}
+	if len(lbConfig.HedgingOperations) == 0 {
+		return true
+	}
+	for _, op := range lbConfig.HedgingOperations {
+		if op == i.OperationID || op == i.SchemaID+"."+i.OperationID || op == i.URLPath {
+			return true
+		}
+	}
+	return false
+}
+
+// hedgeDelay use the latency percentile of the service if there is stats, otherwise use the fixed delay
+func hedgeDelay(i *invocation.Invocation, lbConfig control.LoadBalancingConfig) time.Duration {
+	if lbConfig.HedgingPercentile > 0 {
+		d, ok := loadbalancer.LatencyPercentile(lbConfig.HedgingPercentile, i.MicroServiceName, i.RouteTags, i.Protocol)
+		if ok && d > 0 {
+			return d
+		}
+	}
+	return lbConfig.HedgingDelay
+}
+
+func acquireHedge(service string, max int) bool {
+	v, _ := hedgeOutstanding.LoadOrStore(service, new(int32))
+	counter := v.(*int32)
+	if atomic.AddInt32(counter, 1) > int32(max) {
+		atomic.AddInt32(counter, -1)
+		return false
+	}
+	return true
+}
+
+func releaseHedge(service string) {
+	if v, ok := hedgeOutstanding.Load(service); ok {
+		atomic.AddInt32(v.(*int32), -1)
+	}
+}
+
+// handleWithHedging sends the request to one instance, if there is no response after hedge delay,
+// it sends the same request to a different instance. the first successful response wins,
+// and the other attempts are canceled by their invocation context
+func (lb *LBHandler) handleWithHedging(chain *Chain, i *invocation.Invocation, lbConfig control.LoadBalancingConfig, cb invocation.ResponseCallBack) {
+	ep, err := lb.getEndpoint(i, lbConfig)
+	if err != nil {
+		WriteBackErr(err, status.Status(i.Protocol, status.ServiceUnavailable), cb)
+		return
+	}
+	var reqBytes []byte
+	if req, ok := i.Args.(*http.Request); ok && req != nil && req.Body != nil {
+		reqBytes, _ = io.ReadAll(req.Body)
+	}
+	i.SetMetadata(loadbalancer.MDLatencyStats, true)
+	delay := hedgeDelay(i, lbConfig)
+
+	results := make(chan hedgeResult, lbConfig.HedgingMaxAttempts)
+	cancels := make(map[*invocation.Invocation]context.CancelFunc, lbConfig.HedgingMaxAttempts)
+	used := map[string]bool{ep.Address: true}
+	launch := func(ep *registry.Endpoint, hedge bool) {
+		a, cancel := cloneForAttempt(i, ep, reqBytes)
+		cancels[a] = cancel
+		go func() {
+			if hedge {
+				defer releaseHedge(i.MicroServiceName)
+			}
+			var resp *invocation.Response
+			chain.Next(a, func(r *invocation.Response) {
+				resp = r
+			})
+			if resp == nil {
+				resp = &invocation.Response{}
+			}
+			results <- hedgeResult{inv: a, resp: resp}
+		}()
+	}
+	launch(ep, false)
+	launched, inflight := 1, 1
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	var last hedgeResult
+	for inflight > 0 {
+		select {
+		case r := <-results:
+			inflight--
+			if r.resp.Err == nil {
+				delete(cancels, r.inv)
+				for _, cancel := range cancels {
+					cancel()
+				}
+				go drainHedges(results, inflight)
+				applyAttempt(i, r.inv)
+				r.resp.Result = i.Reply
+				cb(r.resp)
+				return
+			}
+			if last.inv != nil {
+				closeReply(last.inv)
+			}
+			last = r
+			// do not wait for the delay, the failed attempt will not respond anymore
+			if launched < lbConfig.HedgingMaxAttempts && lb.launchHedge(i, lbConfig, used, launch) {
+				launched++
+				inflight++
+			}
+		case <-timer.C:
+			if launched < lbConfig.HedgingMaxAttempts && lb.launchHedge(i, lbConfig, used, launch) {
+				launched++
+				inflight++
+			}
+			if launched < lbConfig.HedgingMaxAttempts {
+				timer.Reset(delay)
+			}
+		}
+	}
+	for _, cancel := range cancels {
+		cancel()
+	}
+	openlog.Error(fmt.Sprintf("all hedged attempts of [%s] failed: %v", i.MicroServiceName, last.resp.Err))
+	applyAttempt(i, last.inv)
+	last.resp.Result = i.Reply
+	cb(last.resp)
+}
+
+// launchHedge picks a different instance and launches a hedged attempt,
+// it returns false if there is no other instance or outstanding hedges reach the limit
+func (lb *LBHandler) launchHedge(i *invocation.Invocation, lbConfig control.LoadBalancingConfig,
+	used map[string]bool, launch func(*registry.Endpoint, bool)) bool {
+	var ep *registry.Endpoint
+	for n := 0; n <= len(used); n++ {
+		e, err := lb.getEndpoint(i, lbConfig)
+		if err != nil {
+			return false
+		}
+		if !used[e.Address] {
+			ep = e
+			break
+		}
+	}
+	if ep == nil {
+		openlog.Debug("no other instance for hedging: " + i.MicroServiceName)
+		return false
+	}
+	if !acquireHedge(i.MicroServiceName, lbConfig.HedgingMaxOutstanding) {
+		openlog.Warn("outstanding hedged attempts reach the limit: " + i.MicroServiceName)
+		return false
+	}
+	used[ep.Address] = true
+	launch(ep, true)
+	return true
+}
+
+// cloneForAttempt copy invocation so that each attempt has its own context, headers, request and reply
+func cloneForAttempt(i *invocation.Invocation, ep *registry.Endpoint, reqBytes []byte) (*invocation.Invocation, context.CancelFunc) {
+	a := *i
+	a.Endpoint = ep.Address
+	a.SSLEnable = ep.IsSSLEnable()
+	headers := make(map[string]string)
+	for k, v := range i.Headers() {
+		headers[k] = v
+	}
+	ctx, cancel := context.WithCancel(context.WithValue(i.Ctx, common.ContextHeaderKey{}, headers))
+	a.Ctx = ctx
+	a.Metadata = make(map[string]interface{}, len(i.Metadata))
+	for k, v := range i.Metadata {
+		a.Metadata[k] = v
+	}
+	if req, ok := i.Args.(*http.Request); ok && req != nil {
+		r := req.Clone(ctx)
+		if req.Body != nil {
+			r.Body = io.NopCloser(bytes.NewReader(reqBytes))
+		}
+		a.Args = r
+	}
+	if _, ok := i.Reply.(*http.Response); ok {
+		a.Reply = &http.Response{}
+	} else if v := reflect.ValueOf(i.Reply); v.Kind() == reflect.Ptr && !v.IsNil() {
+		a.Reply = reflect.New(v.Type().Elem()).Interface()
+	}
+	return &a, cancel
+}
+
+// applyAttempt write the result of an attempt back to the original invocation
+func applyAttempt(i, a *invocation.Invocation) {
+	i.Endpoint = a.Endpoint
+	i.SSLEnable = a.SSLEnable
+	headers := i.Headers()
+	for k, v := range a.Headers() {
+		headers[k] = v
+	}
+	if resp, ok := i.Reply.(*http.Response); ok && resp != nil {
+		*resp = *a.Reply.(*http.Response)
+		return
+	}
+	v := reflect.ValueOf(i.Reply)
+	if v.Kind() != reflect.Ptr || v.IsNil() || a.Reply == i.Reply {
+		return
+	}
+	v.Elem().Set(reflect.ValueOf(a.Reply).Elem())
+}
+
+// drainHedges wait for the canceled attempts and release their responses
+func drainHedges(results chan hedgeResult, n int) {
+	for ; n > 0; n-- {
+		r := <-results
+		closeReply(r.inv)
+	}
+}
+
+// closeReply closes body of http response of an attempt which is not written back
+func closeReply(a *invocation.Invocation) {
+	if resp, ok := a.Reply.(*http.Response); ok && resp.Body != nil {
+		resp.Body.Close()
+	}
+}
@@ -0,0 +1,206 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-chassis/go-chassis/v2/control"
+	"github.com/go-chassis/go-chassis/v2/core/handler"
+	"github.com/go-chassis/go-chassis/v2/core/invocation"
+	"github.com/go-chassis/go-chassis/v2/core/loadbalancer"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	mk "github.com/go-chassis/go-chassis/v2/core/registry/mock"
+	utiltags "github.com/go-chassis/go-chassis/v2/pkg/util/tags"
+	"github.com/stretchr/testify/assert"
+)
+
+type hedgingPanel struct {
+	control.Panel
+	lb control.LoadBalancingConfig
+}
+
+func (p *hedgingPanel) GetLoadBalancing(inv invocation.Invocation) control.LoadBalancingConfig {
+	return p.lb
+}
+
+type hedgingTransport struct {
+	f func(i *invocation.Invocation) *invocation.Response
+}
+
+func (h *hedgingTransport) Name() string {
+	return "hedging-transport"
+}
+
+func (h *hedgingTransport) Handle(chain *handler.Chain, i *invocation.Invocation, cb invocation.ResponseCallBack) {
+	cb(h.f(i))
+}
+
+type trackedBody struct {
+	closed int32
+}
+
+func (b *trackedBody) Read(p []byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (b *trackedBody) Close() error {
+	atomic.StoreInt32(&b.closed, 1)
+	return nil
+}
+
+func (b *trackedBody) isClosed() bool {
+	return atomic.LoadInt32(&b.closed) == 1
+}
+
+func prepareHedging(t *testing.T, service string, lb control.LoadBalancingConfig) {
+	old := control.DefaultPanel
+	t.Cleanup(func() {
+		control.DefaultPanel = old
+	})
+	control.DefaultPanel = &hedgingPanel{lb: lb}
+	assert.NoError(t, archaius.Init(archaius.WithMemorySource()))
+	loadbalancer.Enable(loadbalancer.StrategyRoundRobin)
+	mss := []*registry.MicroServiceInstance{
+		{InstanceID: "ins1", EndpointsMap: map[string]*registry.Endpoint{"rest": {Address: "127.0.0.1:8001"}}},
+		{InstanceID: "ins2", EndpointsMap: map[string]*registry.Endpoint{"rest": {Address: "127.0.0.1:8002"}}},
+	}
+	testRegistryObj := new(mk.DiscoveryMock)
+	registry.DefaultServiceDiscoveryService = testRegistryObj
+	testRegistryObj.On("FindMicroServiceInstances", "selfServiceID", "appID", service, "1.0", "").
+		Return(mss, nil)
+}
+
+func newHedgingInvocation(service string) *invocation.Invocation {
+	i := invocation.New(context.Background())
+	i.MicroServiceName = service
+	i.SchemaID = "schema1"
+	i.OperationID = "SayHello"
+	i.SourceServiceID = "selfServiceID"
+	i.RouteTags = utiltags.NewDefaultTag("1.0", "appID")
+	i.Reply = &http.Response{}
+	return i
+}
+
+func TestLBHandler_Hedging(t *testing.T) {
+	lb := control.LoadBalancingConfig{
+		Strategy:              loadbalancer.StrategyRoundRobin,
+		HedgingEnabled:        true,
+		HedgingDelay:          20 * time.Millisecond,
+		HedgingMaxAttempts:    2,
+		HedgingMaxOutstanding: 10,
+	}
+	t.Run("slow attempt is canceled, first success wins", func(t *testing.T) {
+		prepareHedging(t, "hedging1", lb)
+		var calls int32
+		canceled := make(chan string, 1)
+		c := handler.Chain{}
+		c.AddHandler(&handler.LBHandler{})
+		c.AddHandler(&hedgingTransport{f: func(i *invocation.Invocation) *invocation.Response {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				<-i.Ctx.Done()
+				canceled <- i.Endpoint
+				return &invocation.Response{Err: i.Ctx.Err()}
+			}
+			i.Reply.(*http.Response).StatusCode = http.StatusOK
+			i.SetHeader("from", i.Endpoint)
+			return &invocation.Response{Status: http.StatusOK}
+		}})
+		i := newHedgingInvocation("hedging1")
+		var r *invocation.Response
+		c.Next(i, func(resp *invocation.Response) {
+			r = resp
+		})
+		assert.NoError(t, r.Err)
+		assert.Equal(t, http.StatusOK, i.Reply.(*http.Response).StatusCode)
+		assert.Equal(t, i.Endpoint, i.Header("from"))
+		select {
+		case ep := <-canceled:
+			assert.NotEqual(t, i.Endpoint, ep)
+		case <-time.After(time.Second):
+			t.Fatal("slow attempt is not canceled")
+		}
+	})
+	t.Run("all attempts failed", func(t *testing.T) {
+		prepareHedging(t, "hedging2", lb)
+		var mu sync.Mutex
+		endpoints := map[string]bool{}
+		var bodies []*trackedBody
+		c := handler.Chain{}
+		c.AddHandler(&handler.LBHandler{})
+		c.AddHandler(&hedgingTransport{f: func(i *invocation.Invocation) *invocation.Response {
+			b := &trackedBody{}
+			i.Reply.(*http.Response).Body = b
+			mu.Lock()
+			endpoints[i.Endpoint] = true
+			bodies = append(bodies, b)
+			mu.Unlock()
+			return &invocation.Response{Err: errors.New("fake error")}
+		}})
+		i := newHedgingInvocation("hedging2")
+		var r *invocation.Response
+		c.Next(i, func(resp *invocation.Response) {
+			r = resp
+		})
+		assert.Error(t, r.Err)
+		assert.Equal(t, 2, len(endpoints))
+		// body of the response written back is left to caller, others are closed
+		for _, b := range bodies {
+			assert.Equal(t, b != i.Reply.(*http.Response).Body, b.isClosed())
+		}
+	})
+	t.Run("operation is not configured", func(t *testing.T) {
+		cfg := lb
+		cfg.HedgingOperations = []string{"schema1.SayBye"}
+		prepareHedging(t, "hedging3", cfg)
+		var calls int32
+		c := handler.Chain{}
+		c.AddHandler(&handler.LBHandler{})
+		c.AddHandler(&hedgingTransport{f: func(i *invocation.Invocation) *invocation.Response {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(50 * time.Millisecond)
+			return &invocation.Response{}
+		}})
+		i := newHedgingInvocation("hedging3")
+		c.Next(i, func(resp *invocation.Response) {
+			assert.NoError(t, resp.Err)
+		})
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+	t.Run("outstanding hedges are limited", func(t *testing.T) {
+		cfg := lb
+		cfg.HedgingMaxOutstanding = 1
+		prepareHedging(t, "hedging4", cfg)
+		var calls int32
+		release := make(chan struct{})
+		c := handler.Chain{}
+		c.AddHandler(&handler.LBHandler{})
+		c.AddHandler(&hedgingTransport{f: func(i *invocation.Invocation) *invocation.Response {
+			atomic.AddInt32(&calls, 1)
+			select {
+			case <-release:
+			case <-i.Ctx.Done():
+			}
+			return &invocation.Response{}
+		}})
+		var wg sync.WaitGroup
+		for n := 0; n < 3; n++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				i := newHedgingInvocation("hedging4")
+				c.Next(i, func(resp *invocation.Response) {})
+			}()
+		}
+		time.Sleep(200 * time.Millisecond)
+		assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+		close(release)
+		wg.Wait()
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/go-chassis/go-chassis/v2/client/local"
 	"github.com/go-chassis/go-chassis/v2/core/common"
 	"github.com/go-chassis/go-chassis/v2/core/handler"
 	"github.com/go-chassis/go-chassis/v2/core/invocation"
@@ -38,6 +39,10 @@ func (ri *abstractInvoker) invoke(i *invocation.Invocation) error {
 
 	// add self service name into remote context, this value used in provider rate limiter
 	i.Ctx = common.WithContext(i.Ctx, common.HeaderSourceName, runtime.ServiceName)
+	// provider in same process is called without network
+	if local.Redirect(i) {
+		openlog.Debug("call local provider " + i.MicroServiceName)
+	}
 
 	c, err := handler.GetChain(common.Consumer, ri.opts.ChainName)
 	if err != nil {
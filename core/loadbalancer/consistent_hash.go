@@ -0,0 +1,231 @@
+package loadbalancer
+
+import (
+	"hash/fnv"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/config"
+	"github.com/go-chassis/go-chassis/v2/core/invocation"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/go-chassis/v2/pkg/util"
+)
+
+// sources of consistent hash key
+const (
+	HashKeyHeader = "header"
+	HashKeyQuery  = "query"
+	HashKeyCookie = "cookie"
+	HashKeyField  = "field"
+)
+
+// rings caches hash ring of every service key, a ring is rebuilt only when instances change
+var rings sync.Map
+
+// hashRing places every instance on the ring many times, a key belongs to the first point after its hash,
+// so that only keys of added or removed instances are remapped
+type hashRing struct {
+	points    []uint64
+	owners    []int // index of instance of a point
+	instances []*registry.MicroServiceInstance
+	addresses []string
+	// members is instance id to address, it decides if the ring is still valid
+	members      map[string]string
+	virtualNodes int
+}
+
+func newHashRing(instances []*registry.MicroServiceInstance, addresses []string, virtualNodes int) *hashRing {
+	r := &hashRing{
+		points:       make([]uint64, 0, len(instances)*virtualNodes),
+		owners:       make([]int, 0, len(instances)*virtualNodes),
+		instances:    instances,
+		addresses:    addresses,
+		members:      make(map[string]string, len(instances)),
+		virtualNodes: virtualNodes,
+	}
+	type point struct {
+		hash  uint64
+		owner int
+	}
+	points := make([]point, 0, len(instances)*virtualNodes)
+	for i, ins := range instances {
+		r.members[ins.InstanceID] = addresses[i]
+		for n := 0; n < virtualNodes; n++ {
+			points = append(points, point{hash: hashOf(ins.InstanceID + "#" + strconv.Itoa(n)), owner: i})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].hash < points[j].hash })
+	for _, p := range points {
+		r.points = append(r.points, p.hash)
+		r.owners = append(r.owners, p.owner)
+	}
+	return r
+}
+
+// matches reports if the ring is built from the same instances
+func (r *hashRing) matches(instances []*registry.MicroServiceInstance, addresses []string, virtualNodes int) bool {
+	if r.virtualNodes != virtualNodes || len(r.members) != len(instances) {
+		return false
+	}
+	for i, ins := range instances {
+		if addr, ok := r.members[ins.InstanceID]; !ok || addr != addresses[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// hashOf is fnv-1a finalized by splitmix64, so that similar strings spread over the ring
+func hashOf(s string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// ConsistentHashStrategy sends requests with the same hash key to the same instance,
+// with bounded load, a request spills over to next instance on ring if the instance is overloaded.
+// requests without hash key are sent in round robin
+type ConsistentHashStrategy struct {
+	ring       *hashRing
+	serviceKey string
+	key        string
+	hasKey     bool
+	loadFactor float64
+}
+
+func newConsistentHashStrategy() Strategy {
+	return &ConsistentHashStrategy{}
+}
+
+// ReceiveData receive data
+func (s *ConsistentHashStrategy) ReceiveData(inv *invocation.Invocation, instances []*registry.MicroServiceInstance, serviceKey string) {
+	service := serviceOf(serviceKey)
+	s.serviceKey = serviceKey
+	s.key, s.hasKey = HashKey(inv, config.GetConsistentHashKey(service))
+	s.loadFactor = config.GetConsistentHashLoadFactor(service)
+
+	addresses := make([]string, len(instances))
+	for i, ins := range instances {
+		addresses[i] = addressOf(inv, ins)
+	}
+	virtualNodes := config.GetConsistentHashVirtualNodes(service)
+	if v, ok := rings.Load(serviceKey); ok && v.(*hashRing).matches(instances, addresses, virtualNodes) {
+		s.ring = v.(*hashRing)
+		return
+	}
+	s.ring = newHashRing(instances, addresses, virtualNodes)
+	rings.Store(serviceKey, s.ring)
+}
+
+// Pick return instance
+func (s *ConsistentHashStrategy) Pick() (*registry.MicroServiceInstance, error) {
+	if s.ring == nil || len(s.ring.instances) == 0 {
+		return nil, ErrNoneAvailableInstance
+	}
+	r := s.ring
+	if !s.hasKey {
+		return r.instances[pick(s.serviceKey)%len(r.instances)], nil
+	}
+	h := hashOf(s.key)
+	start := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if start == len(r.points) {
+		start = 0
+	}
+	if s.loadFactor <= 1 || len(r.instances) == 1 {
+		return r.instances[r.owners[start]], nil
+	}
+	// bounded load: an instance takes no more than loadFactor times of average in-flight requests
+	loads := make([]int64, len(r.instances))
+	var total int64
+	for i, addr := range r.addresses {
+		loads[i] = Outstanding(addr)
+		total += loads[i]
+	}
+	capacity := int64(math.Ceil(s.loadFactor * float64(total+1) / float64(len(r.instances))))
+	visited := make(map[int]struct{}, len(r.instances))
+	for n := 0; n < len(r.points) && len(visited) < len(r.instances); n++ {
+		owner := r.owners[(start+n)%len(r.points)]
+		if _, ok := visited[owner]; ok {
+			continue
+		}
+		if loads[owner] < capacity {
+			return r.instances[owner], nil
+		}
+		visited[owner] = struct{}{}
+	}
+	return r.instances[r.owners[start]], nil
+}
+
+// HashKey returns hash key of an invocation, source is like header:X-User-Id, query:user, cookie:session,
+// or field:SourceMicroService. it returns false if the invocation has no such key
+func HashKey(inv *invocation.Invocation, source string) (string, bool) {
+	kind, name, ok := strings.Cut(source, ":")
+	if !ok || name == "" {
+		return "", false
+	}
+	req, _ := inv.Args.(*http.Request)
+	var v string
+	switch kind {
+	case HashKeyHeader:
+		if headers, ok := inv.Ctx.Value(common.ContextHeaderKey{}).(map[string]string); ok {
+			v = headers[name]
+		}
+		if v == "" && req != nil {
+			v = req.Header.Get(name)
+		}
+	case HashKeyQuery:
+		if req != nil && req.URL != nil {
+			v = req.URL.Query().Get(name)
+		}
+	case HashKeyCookie:
+		if req != nil {
+			if c, err := req.Cookie(name); err == nil {
+				v = c.Value
+			}
+		}
+	case HashKeyField:
+		v = fieldOf(inv, name)
+	}
+	return v, v != ""
+}
+
+func fieldOf(inv *invocation.Invocation, name string) string {
+	switch name {
+	case "SourceMicroService":
+		return inv.SourceMicroService
+	case "SourceServiceID":
+		return inv.SourceServiceID
+	case "URLPath":
+		return inv.URLPath
+	case "SchemaID":
+		return inv.SchemaID
+	case "OperationID":
+		return inv.OperationID
+	}
+	return ""
+}
+
+// addressOf returns address of the endpoint which is called, like load balance handler decides
+func addressOf(inv *invocation.Invocation, ins *registry.MicroServiceInstance) string {
+	ep, ok := ins.EndpointsMap[util.GenProtoEndPoint(inv.Protocol, inv.PortName)]
+	if !ok && inv.Protocol == "" {
+		for _, ep = range ins.EndpointsMap {
+			break
+		}
+	}
+	if ep == nil {
+		return ""
+	}
+	return ep.Address
+}
@@ -0,0 +1,119 @@
+package loadbalancer_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-chassis/go-chassis/v2/core/invocation"
+	"github.com/go-chassis/go-chassis/v2/core/loadbalancer"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+func hashInstances(n int) []*registry.MicroServiceInstance {
+	instances := make([]*registry.MicroServiceInstance, 0, n)
+	for i := 0; i < n; i++ {
+		instances = append(instances, &registry.MicroServiceInstance{
+			InstanceID:   fmt.Sprintf("ins-%d", i),
+			EndpointsMap: map[string]*registry.Endpoint{"rest": {Address: fmt.Sprintf("10.0.3.%d:8080", i)}},
+		})
+	}
+	return instances
+}
+
+func TestConsistentHashStrategy(t *testing.T) {
+	f := func() loadbalancer.Strategy { return &loadbalancer.ConsistentHashStrategy{} }
+	archaius.Set("cse.loadbalance.sharded.consistentHash.hashKey", "header:X-User")
+	archaius.Set("cse.loadbalance.sharded.consistentHash.loadFactor", "0")
+	instances := hashInstances(10)
+
+	pickFor := func(instances []*registry.MicroServiceInstance, user string) *registry.MicroServiceInstance {
+		inv := invocation.New(context.Background())
+		inv.MicroServiceName, inv.Protocol = "sharded", "rest"
+		inv.SetHeader("X-User", user)
+		s := f()
+		s.ReceiveData(inv, instances, "sharded|")
+		ins, err := s.Pick()
+		assert.NoError(t, err)
+		return ins
+	}
+	before := make(map[string]string)
+	used := make(map[string]struct{})
+	for i := 0; i < 1000; i++ {
+		user := fmt.Sprintf("user-%d", i)
+		before[user] = pickFor(instances, user).InstanceID
+		used[before[user]] = struct{}{}
+		assert.Equal(t, before[user], pickFor(instances, user).InstanceID)
+	}
+	assert.Equal(t, 10, len(used), "keys spread over instances")
+
+	t.Run("minimal remap", func(t *testing.T) {
+		removed := instances[3].InstanceID
+		fewer := append(append([]*registry.MicroServiceInstance{}, instances[:3]...), instances[4:]...)
+		for user, id := range before {
+			got := pickFor(fewer, user).InstanceID
+			if id != removed {
+				assert.Equal(t, id, got, "key of remaining instance is not remapped")
+			}
+			assert.NotEqual(t, removed, got)
+		}
+		for user, id := range before {
+			assert.Equal(t, id, pickFor(instances, user).InstanceID, "keys move back once instance is added again")
+		}
+	})
+	t.Run("bounded load", func(t *testing.T) {
+		archaius.Set("cse.loadbalance.sharded.consistentHash.loadFactor", "1.25")
+		hot := pickFor(instances, "hot")
+		done := make([]func(), 0)
+		for i := 0; i < 5; i++ {
+			done = append(done, loadbalancer.StartRequest(hot.EndpointsMap["rest"].Address))
+		}
+		assert.Equal(t, int64(5), loadbalancer.Outstanding(hot.EndpointsMap["rest"].Address))
+		assert.NotEqual(t, hot, pickFor(instances, "hot"), "hot key spills over")
+		for _, f := range done {
+			f()
+			f()
+		}
+		assert.Equal(t, int64(0), loadbalancer.Outstanding(hot.EndpointsMap["rest"].Address))
+		assert.Equal(t, hot, pickFor(instances, "hot"))
+	})
+	t.Run("no key", func(t *testing.T) {
+		inv := invocation.New(context.Background())
+		inv.MicroServiceName = "sharded"
+		s := f()
+		s.ReceiveData(inv, instances, "sharded|")
+		picked := make(map[string]struct{})
+		for i := 0; i < 10; i++ {
+			ins, err := s.Pick()
+			assert.NoError(t, err)
+			picked[ins.InstanceID] = struct{}{}
+		}
+		assert.Equal(t, 10, len(picked), "requests without key are sent in round robin")
+	})
+}
+
+func TestHashKey(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "http://orders/v1?user=q", nil)
+	req.Header.Set("X-User", "h")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "c"})
+	inv := invocation.New(context.Background())
+	inv.Args = req
+	inv.SourceMicroService = "gateway"
+	for source, expected := range map[string]string{
+		"header:X-User":            "h",
+		"query:user":               "q",
+		"cookie:session":           "c",
+		"field:SourceMicroService": "gateway",
+	} {
+		k, ok := loadbalancer.HashKey(inv, source)
+		assert.True(t, ok, source)
+		assert.Equal(t, expected, k, source)
+	}
+	for _, source := range []string{"", "header", "header:X-Other", "field:Unknown", "body:user"} {
+		_, ok := loadbalancer.HashKey(inv, source)
+		assert.False(t, ok, source)
+	}
+}
@@ -0,0 +1,80 @@
+package loadbalancer
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chassis/go-chassis/v2/core/invocation"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+)
+
+// rands avoids lock contention of global rand source under high QPS
+var rands = sync.Pool{
+	New: func() interface{} {
+		return rand.New(rand.NewSource(time.Now().UnixNano() + rand.Int63()))
+	},
+}
+
+func randIntn(n int) int {
+	r := rands.Get().(*rand.Rand)
+	i := r.Intn(n)
+	rands.Put(r)
+	return i
+}
+
+// loadOf returns stats of instances, stats is nil if instance has no endpoint of the invocation
+func loadOf(inv *invocation.Invocation, instances []*registry.MicroServiceInstance) []*endpointStats {
+	stats := make([]*endpointStats, len(instances))
+	for i, ins := range instances {
+		if addr := addressOf(inv, ins); addr != "" {
+			stats[i] = statsOf(addr)
+		}
+	}
+	return stats
+}
+
+func inflightOf(s *endpointStats) float64 {
+	if s == nil {
+		return 0
+	}
+	return float64(atomic.LoadInt64(&s.inflight))
+}
+
+// LeastRequestStrategy picks the instance which has least in-flight requests, ties are broken randomly
+type LeastRequestStrategy struct {
+	instances []*registry.MicroServiceInstance
+	stats     []*endpointStats
+	slowStart SlowStart
+}
+
+func newLeastRequestStrategy() Strategy {
+	return &LeastRequestStrategy{}
+}
+
+// ReceiveData receive data
+func (r *LeastRequestStrategy) ReceiveData(inv *invocation.Invocation, instances []*registry.MicroServiceInstance, serviceKey string) {
+	r.instances = instances
+	r.stats = loadOf(inv, instances)
+	r.slowStart = NewSlowStart(serviceOf(serviceKey))
+}
+
+// Pick return instance
+func (r *LeastRequestStrategy) Pick() (*registry.MicroServiceInstance, error) {
+	n := len(r.instances)
+	if n == 0 {
+		return nil, ErrNoneAvailableInstance
+	}
+	start := randIntn(n)
+	best, bestCost := start, -1.0
+	for k := 0; k < n; k++ {
+		i := (start + k) % n
+		// an instance warming up looks busier in proportion to its weight
+		cost := (inflightOf(r.stats[i]) + 1) / r.slowStart.Weight(r.instances[i])
+		if bestCost < 0 || cost < bestCost {
+			best, bestCost = i, cost
+		}
+	}
+	return r.instances[best], nil
+}
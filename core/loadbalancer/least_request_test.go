@@ -0,0 +1,120 @@
+package loadbalancer_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/go-chassis/v2/core/invocation"
+	"github.com/go-chassis/go-chassis/v2/core/loadbalancer"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	_ "github.com/go-chassis/go-chassis/v2/pkg/loadbalancing"
+	"github.com/stretchr/testify/assert"
+)
+
+func loadInstances(prefix string, n int) []*registry.MicroServiceInstance {
+	instances := make([]*registry.MicroServiceInstance, 0, n)
+	for i := 0; i < n; i++ {
+		instances = append(instances, &registry.MicroServiceInstance{
+			InstanceID:   fmt.Sprintf("%s-%d", prefix, i),
+			EndpointsMap: map[string]*registry.Endpoint{"rest": {Address: fmt.Sprintf("%s-%d:8080", prefix, i)}},
+		})
+	}
+	return instances
+}
+
+func pickMany(t testing.TB, s loadbalancer.Strategy, instances []*registry.MicroServiceInstance, n int) map[string]int {
+	inv := &invocation.Invocation{MicroServiceName: "loaded", Protocol: "rest", Ctx: context.Background()}
+	picked := make(map[string]int)
+	for i := 0; i < n; i++ {
+		s.ReceiveData(inv, instances, "loaded|")
+		ins, err := s.Pick()
+		assert.NoError(t, err)
+		picked[ins.InstanceID]++
+	}
+	return picked
+}
+
+func TestLeastRequestStrategy(t *testing.T) {
+	instances := loadInstances("lr", 3)
+	s := &loadbalancer.LeastRequestStrategy{}
+	_, err := s.Pick()
+	assert.Equal(t, loadbalancer.ErrNoneAvailableInstance, err)
+
+	picked := pickMany(t, s, instances, 300)
+	assert.Equal(t, 3, len(picked), "ties are broken randomly")
+
+	done := loadbalancer.StartRequest("lr-0:8080")
+	loadbalancer.StartRequest("lr-1:8080")
+	picked = pickMany(t, s, instances, 100)
+	assert.Equal(t, 100, picked["lr-2"])
+	done()
+	picked = pickMany(t, s, instances, 100)
+	assert.Equal(t, 0, picked["lr-1"])
+}
+
+func TestP2CEWMAStrategy(t *testing.T) {
+	instances := loadInstances("p2c", 2)
+	s := &loadbalancer.P2CEWMAStrategy{}
+	_, err := s.Pick()
+	assert.Equal(t, loadbalancer.ErrNoneAvailableInstance, err)
+	assert.Equal(t, 1, len(pickMany(t, s, instances[:1], 10)))
+
+	loadbalancer.RecordLatency("p2c-0:8080", 100*time.Millisecond)
+	loadbalancer.RecordLatency("p2c-1:8080", 10*time.Millisecond)
+	assert.InDelta(t, float64(100*time.Millisecond), float64(loadbalancer.EWMALatency("p2c-0:8080")), float64(time.Millisecond))
+	assert.Equal(t, 100, pickMany(t, s, instances, 100)["p2c-1"], "faster instance is picked")
+
+	// in-flight requests make the faster instance cost more
+	for i := 0; i < 20; i++ {
+		loadbalancer.StartRequest("p2c-1:8080")
+	}
+	assert.Equal(t, 100, pickMany(t, s, instances, 100)["p2c-0"])
+
+	t.Run("peak", func(t *testing.T) {
+		loadbalancer.RecordLatency("p2c-2:8080", 10*time.Millisecond)
+		loadbalancer.RecordLatency("p2c-2:8080", 50*time.Millisecond)
+		assert.InDelta(t, float64(50*time.Millisecond), float64(loadbalancer.EWMALatency("p2c-2:8080")), float64(time.Millisecond),
+			"higher latency replaces average at once")
+		loadbalancer.RecordLatency("p2c-2:8080", 10*time.Millisecond)
+		assert.Greater(t, loadbalancer.EWMALatency("p2c-2:8080"), 40*time.Millisecond, "lower latency is averaged slowly")
+	})
+	t.Run("failure", func(t *testing.T) {
+		loadbalancer.RecordFailure("p2c-3:8080", time.Millisecond)
+		assert.InDelta(t, float64(loadbalancer.FailurePenalty), float64(loadbalancer.EWMALatency("p2c-3:8080")), float64(time.Millisecond),
+			"fast failure costs the penalty")
+		loadbalancer.RecordFailure("p2c-3:8080", 2*loadbalancer.FailurePenalty)
+		assert.InDelta(t, float64(2*loadbalancer.FailurePenalty), float64(loadbalancer.EWMALatency("p2c-3:8080")), float64(time.Millisecond))
+	})
+}
+
+func BenchmarkStrategies(b *testing.B) {
+	assert.NoError(b, loadbalancer.Enable(loadbalancer.StrategyRoundRobin))
+	instances := loadInstances("bench", 20)
+	for _, name := range []string{
+		loadbalancer.StrategyRoundRobin,
+		loadbalancer.StrategyRandom,
+		loadbalancer.StrategyLatency,
+		loadbalancer.StrategyWeightedRR,
+		loadbalancer.StrategyLeastRequest,
+		loadbalancer.StrategyP2CEWMA,
+	} {
+		f, err := loadbalancer.GetStrategyPlugin(name)
+		assert.NoError(b, err)
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				inv := &invocation.Invocation{MicroServiceName: "bench", Protocol: "rest", Ctx: context.Background()}
+				for pb.Next() {
+					// lb handler builds a strategy for every request
+					s := f()
+					s.ReceiveData(inv, instances, "bench|")
+					ins, _ := s.Pick()
+					done := loadbalancer.StartRequest(ins.EndpointsMap["rest"].Address)
+					done()
+				}
+			})
+		})
+	}
+}
@@ -3,10 +3,13 @@ package loadbalancer
 
 import (
 	"fmt"
+	"math"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/go-chassis/go-chassis/v2/core/config"
 	"github.com/go-chassis/go-chassis/v2/core/invocation"
 	"github.com/go-chassis/go-chassis/v2/core/registry"
 	"github.com/go-chassis/go-chassis/v2/pkg/util/tags"
@@ -21,11 +24,24 @@ const (
 // StrategyLatency is name
 const StrategyLatency = "WeightedResponse"
 
+// MDLatencyStats is invocation metadata key, if it is true,
+// transport handler saves latency of the call, even strategy is not StrategyLatency
+const MDLatencyStats = "lb-latency-stats"
+
+// MDLoadStats is invocation metadata key, it is true if endpoint is picked from discovery cache,
+// transport handler saves in-flight requests and latency of the call only then,
+// stats of an instance are dropped once it is removed from cache, so addresses outside of cache are not saved
+const MDLoadStats = "lb-load-stats"
+
 // constant strings for load balance variables
 const (
 	StrategyRoundRobin        = "RoundRobin"
 	StrategyRandom            = "Random"
 	StrategySessionStickiness = "SessionStickiness"
+	StrategyConsistentHash    = "ConsistentHash"
+	StrategyLeastRequest      = "LeastRequest"
+	StrategyP2CEWMA           = "P2CEWMA"
+	StrategyWeightedRR        = "WeightedRoundRobin"
 
 	OperatorEqual   = "="
 	OperatorGreater = ">"
@@ -70,6 +86,7 @@ func BuildStrategy(i *invocation.Invocation,
 		openlog.Error(fmt.Sprintf("Lb err: %s", err))
 		return nil, lbErr
 	}
+	instances = applyInstanceFilters(i.MicroServiceName, instances)
 
 	if isFilterExist {
 		filterFuncs := make([]Filter, 0)
@@ -85,6 +102,9 @@ func BuildStrategy(i *invocation.Invocation,
 			instances = filter(instances, nil)
 		}
 	}
+	if !hasFilter(i.Filters, OutlierDetection) && config.GetOutlierDetectionEnabled(i.MicroServiceName) {
+		instances = FilterOutliers(instances, nil)
+	}
 
 	if len(instances) == 0 {
 		lbErr := LBError{fmt.Sprintf("No available instance, key: %s(%v)", i.MicroServiceName, i.RouteTags)}
@@ -97,6 +117,15 @@ func BuildStrategy(i *invocation.Invocation,
 	return s, nil
 }
 
+func hasFilter(filters []string, name string) bool {
+	for _, f := range filters {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
 // Strategy is load balancer algorithm , call Pick to return one instance
 type Strategy interface {
 	ReceiveData(inv *invocation.Invocation, instances []*registry.MicroServiceInstance, serviceKey string)
@@ -119,6 +148,12 @@ func Enable(strategyName string) error {
 	InstallStrategy(StrategyRandom, newRandomStrategy)
 	InstallStrategy(StrategyRoundRobin, newRoundRobinStrategy)
 	InstallStrategy(StrategySessionStickiness, newSessionStickinessStrategy)
+	InstallStrategy(StrategyConsistentHash, newConsistentHashStrategy)
+	InstallStrategy(StrategyLeastRequest, newLeastRequestStrategy)
+	InstallStrategy(StrategyP2CEWMA, newP2CEWMAStrategy)
+	InstallStrategy(StrategyWeightedRR, newWeightedRoundRobinStrategy)
+	InstallFilter(OutlierDetection, FilterOutliers)
+	watchInstances()
 
 	if strategyName == "" {
 		openlog.Info("Empty strategy configuration, use RoundRobin as default")
@@ -138,6 +173,31 @@ func InstallFilter(name string, f Filter) {
 	openlog.Info("Installed filter plugin: " + name)
 }
 
+// InstanceFilter removes instances of a service which should not be selected, like unhealthy instances
+type InstanceFilter func(service string, instances []*registry.MicroServiceInstance) []*registry.MicroServiceInstance
+
+var (
+	instanceFiltersMu sync.RWMutex
+	instanceFilters   []InstanceFilter
+)
+
+// InstallInstanceFilter installs a filter which is applied on instances of every invocation,
+// before filters in config, instances stay in discovery cache
+func InstallInstanceFilter(f InstanceFilter) {
+	instanceFiltersMu.Lock()
+	defer instanceFiltersMu.Unlock()
+	instanceFilters = append(instanceFilters, f)
+}
+
+func applyInstanceFilters(service string, instances []*registry.MicroServiceInstance) []*registry.MicroServiceInstance {
+	instanceFiltersMu.RLock()
+	defer instanceFiltersMu.RUnlock()
+	for _, f := range instanceFilters {
+		instances = f(service, instances)
+	}
+	return instances
+}
+
 // variables for latency map, rest and highway requests count
 var (
 	//ProtocolStatsMap saves all stats for all service's protocol, one protocol has a lot of instances
@@ -181,3 +241,29 @@ func SetLatency(latency time.Duration, addr, microServiceName string, tags utilt
 	ProtocolStatsMap[key] = stats
 	LatencyMapRWMutex.Unlock()
 }
+
+// LatencyPercentile return the latency percentile of all instances of a service's protocol,
+// p is in range (0,100], it returns false if there is no stats yet
+func LatencyPercentile(p float64, microServiceName string, tags utiltags.Tags, protocol string) (time.Duration, bool) {
+	if p <= 0 || p > 100 {
+		return 0, false
+	}
+	key := BuildKey(microServiceName, tags.String(), protocol)
+	latencies := make([]time.Duration, 0)
+	LatencyMapRWMutex.RLock()
+	for _, v := range ProtocolStatsMap[key] {
+		latencies = append(latencies, v.Latency...)
+	}
+	LatencyMapRWMutex.RUnlock()
+	if len(latencies) == 0 {
+		return 0, false
+	}
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+	index := int(math.Ceil(p/100*float64(len(latencies)))) - 1
+	if index < 0 {
+		index = 0
+	}
+	return latencies[index], true
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/go-chassis/go-chassis/v2/core/lager"
 	"github.com/go-chassis/go-chassis/v2/core/loadbalancer"
 	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/go-chassis/v2/core/registry/mock"
 	_ "github.com/go-chassis/go-chassis/v2/core/registry/servicecenter"
 	"github.com/go-chassis/go-chassis/v2/pkg/runtime"
 	utiltags "github.com/go-chassis/go-chassis/v2/pkg/util/tags"
@@ -141,6 +142,22 @@ func TestBuildStrategy(t *testing.T) {
 		"127.0.0.1", "service",
 		utiltags.NewDefaultTag("1.0", "app"), "rest")
 }
+func TestLatencyPercentile(t *testing.T) {
+	tags := utiltags.NewDefaultTag("1.0", "app")
+	_, ok := loadbalancer.LatencyPercentile(90, "percentile", tags, "rest")
+	assert.False(t, ok)
+	for n := 1; n <= 10; n++ {
+		loadbalancer.SetLatency(time.Duration(n)*time.Millisecond, "127.0.0.1:1", "percentile", tags, "rest")
+		loadbalancer.SetLatency(time.Duration(n+10)*time.Millisecond, "127.0.0.1:2", "percentile", tags, "rest")
+	}
+	d, ok := loadbalancer.LatencyPercentile(90, "percentile", tags, "rest")
+	assert.True(t, ok)
+	assert.Equal(t, 18*time.Millisecond, d)
+	d, _ = loadbalancer.LatencyPercentile(100, "percentile", tags, "rest")
+	assert.Equal(t, 20*time.Millisecond, d)
+	_, ok = loadbalancer.LatencyPercentile(0, "percentile", tags, "rest")
+	assert.False(t, ok)
+}
 func BenchmarkDefaultSelector_Select(b *testing.B) {
 	p := os.Getenv("GOPATH")
 	os.Setenv("CHASSIS_HOME", filepath.Join(p, "src", "github.com", "go-chassis", "go-chassis", "examples", "discovery", "client"))
@@ -193,3 +210,28 @@ func BenchmarkDefaultSelector_Select(b *testing.B) {
 	}
 
 }
+
+func TestInstallInstanceFilter(t *testing.T) {
+	old := registry.DefaultServiceDiscoveryService
+	defer func() { registry.DefaultServiceDiscoveryService = old }()
+	d := &mock.DiscoveryMock{}
+	registry.DefaultServiceDiscoveryService = d
+	up := &registry.MicroServiceInstance{InstanceID: "up"}
+	down := &registry.MicroServiceInstance{InstanceID: "down"}
+	d.On("FindMicroServiceInstances", "", "", "filtered", "", "").Return([]*registry.MicroServiceInstance{up, down}, nil)
+	loadbalancer.InstallInstanceFilter(func(service string, instances []*registry.MicroServiceInstance) []*registry.MicroServiceInstance {
+		if service != "filtered" {
+			return instances
+		}
+		return []*registry.MicroServiceInstance{instances[0]}
+	})
+
+	inv := &invocation.Invocation{MicroServiceName: "filtered"}
+	s, err := loadbalancer.BuildStrategy(inv, &loadbalancer.RoundRobinStrategy{})
+	assert.NoError(t, err)
+	for n := 0; n < 3; n++ {
+		ins, err := s.Pick()
+		assert.NoError(t, err)
+		assert.Equal(t, "up", ins.InstanceID)
+	}
+}
@@ -0,0 +1,281 @@
+package loadbalancer
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-chassis/go-chassis/v2/core/config"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/go-chassis/v2/pkg/metrics"
+	"github.com/go-chassis/openlog"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// OutlierDetection is the name of outlier detection filter, it is applied by default
+const OutlierDetection = "outlierDetection"
+
+// metrics of outlier detection
+const (
+	MetricsOutlierEjections = "scb_lb_outlier_ejections_total"
+	MetricsOutlierEjected   = "scb_lb_outlier_ejected"
+)
+
+// Outcome is the result of a call to an instance
+type Outcome int
+
+// outcomes of a call
+const (
+	OutcomeSuccess Outcome = iota
+	// OutcomeServerError is a 5xx response except gateway errors
+	OutcomeServerError
+	// OutcomeGatewayError is a 502, 503, 504 response, or a timeout
+	OutcomeGatewayError
+	// OutcomeConnectFailure means connection to the instance can not be established
+	OutcomeConnectFailure
+)
+
+// reasons of ejection
+const (
+	ReasonConsecutive5xx             = "consecutive5xx"
+	ReasonConsecutiveGatewayErrors   = "consecutiveGatewayErrors"
+	ReasonConsecutiveConnectFailures = "consecutiveConnectFailures"
+)
+
+// types of outlier event
+const (
+	EventEject   = "eject"
+	EventUneject = "uneject"
+)
+
+// OutlierEvent is emitted once an instance is ejected or brought back
+type OutlierEvent struct {
+	Type    string
+	Service string
+	Address string
+	// Reason is the threshold which is crossed, it is empty in uneject event
+	Reason string
+	// Ejections is how many times the instance has been ejected recently
+	Ejections int
+	Duration  time.Duration
+}
+
+var (
+	ejectionsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: MetricsOutlierEjections,
+		Help: "times instances of a service are ejected by outlier detection",
+	}, []string{"service", "reason"})
+	ejectedGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: MetricsOutlierEjected,
+		Help: "1 if an instance is ejected by outlier detection, otherwise 0",
+	}, []string{"service", "address"})
+)
+
+// host is the outlier state of an instance address
+type host struct {
+	service            string
+	consecutive5xx     int
+	consecutiveGateway int
+	consecutiveConnect int
+	ejections          int
+	ejectedAt          time.Time
+	ejectedUntil       time.Time
+}
+
+var (
+	outlierMu       sync.Mutex
+	hosts           = make(map[string]*host) // key is address
+	outlierListener []func(OutlierEvent)
+)
+
+// RegisterOutlierListener registers a func which receives outlier events, it must not block
+func RegisterOutlierListener(f func(OutlierEvent)) {
+	outlierMu.Lock()
+	defer outlierMu.Unlock()
+	outlierListener = append(outlierListener, f)
+}
+
+// emit must be called with outlierMu locked
+func emit(e OutlierEvent) {
+	switch e.Type {
+	case EventEject:
+		openlog.Warn(fmt.Sprintf("instance [%s] of [%s] is ejected for %s because of %s, ejections: %d",
+			e.Address, e.Service, e.Duration, e.Reason, e.Ejections))
+		ejectionsCounter.WithLabelValues(e.Service, e.Reason).Inc()
+		ejectedGauge.WithLabelValues(e.Service, e.Address).Set(1)
+	case EventUneject:
+		openlog.Info(fmt.Sprintf("instance [%s] of [%s] is brought back", e.Address, e.Service))
+		ejectedGauge.WithLabelValues(e.Service, e.Address).Set(0)
+	}
+	for _, f := range outlierListener {
+		f(e)
+	}
+}
+
+// RecordOutcome records the result of a call to an instance address,
+// the instance is ejected once consecutive failures cross a threshold
+func RecordOutcome(service, address string, o Outcome) {
+	if address == "" || !config.GetOutlierDetectionEnabled(service) {
+		return
+	}
+	outlierMu.Lock()
+	defer outlierMu.Unlock()
+	h, ok := hosts[address]
+	if !ok {
+		if o == OutcomeSuccess {
+			return
+		}
+		h = &host{}
+		hosts[address] = h
+	}
+	h.service = service
+	switch o {
+	case OutcomeSuccess:
+		h.consecutive5xx, h.consecutiveGateway, h.consecutiveConnect = 0, 0, 0
+		return
+	case OutcomeConnectFailure:
+		h.consecutiveConnect++
+		h.consecutiveGateway++
+		h.consecutive5xx++
+	case OutcomeGatewayError:
+		h.consecutiveConnect = 0
+		h.consecutiveGateway++
+		h.consecutive5xx++
+	case OutcomeServerError:
+		h.consecutiveConnect, h.consecutiveGateway = 0, 0
+		h.consecutive5xx++
+	}
+	now := time.Now()
+	if now.Before(h.ejectedUntil) {
+		return
+	}
+	var reason string
+	switch {
+	case crossed(h.consecutiveConnect, config.GetOutlierConsecutiveConnectFailures(service)):
+		reason = ReasonConsecutiveConnectFailures
+	case crossed(h.consecutiveGateway, config.GetOutlierConsecutiveGatewayErrors(service)):
+		reason = ReasonConsecutiveGatewayErrors
+	case crossed(h.consecutive5xx, config.GetOutlierConsecutive5xx(service)):
+		reason = ReasonConsecutive5xx
+	default:
+		return
+	}
+	eject(h, address, reason, now)
+}
+
+func crossed(n, threshold int) bool {
+	return threshold > 0 && n >= threshold
+}
+
+// eject ejects an instance for base ejection time multiplied by times it is ejected,
+// the multiplier decreases by one for every base ejection time the instance stays healthy
+func eject(h *host, address, reason string, now time.Time) {
+	base := config.GetOutlierBaseEjectionTime(h.service)
+	if base <= 0 {
+		base = config.DefaultOutlierBaseEjectionTime
+	}
+	if h.ejections > 0 {
+		h.ejections -= int(now.Sub(h.ejectedUntil) / base)
+		if h.ejections < 0 {
+			h.ejections = 0
+		}
+	}
+	h.ejections++
+	d := base * time.Duration(h.ejections)
+	if limit := config.GetOutlierMaxEjectionTime(h.service); limit > 0 && d > limit {
+		d = limit
+	}
+	h.ejectedAt, h.ejectedUntil = now, now.Add(d)
+	h.consecutive5xx, h.consecutiveGateway, h.consecutiveConnect = 0, 0, 0
+	emit(OutlierEvent{Type: EventEject, Service: h.service, Address: address, Reason: reason, Ejections: h.ejections, Duration: d})
+	until := h.ejectedUntil
+	time.AfterFunc(d, func() {
+		outlierMu.Lock()
+		defer outlierMu.Unlock()
+		// the instance may be removed or ejected again
+		if hosts[address] == h && h.ejectedUntil.Equal(until) {
+			emit(OutlierEvent{Type: EventUneject, Service: h.service, Address: address, Ejections: h.ejections})
+		}
+	})
+}
+
+// Ejected reports if an instance address is ejected
+func Ejected(address string) bool {
+	outlierMu.Lock()
+	defer outlierMu.Unlock()
+	h, ok := hosts[address]
+	return ok && time.Now().Before(h.ejectedUntil)
+}
+
+// FilterOutliers removes ejected instances, an instance is ejected if any of its endpoints is.
+// instances ejected earlier are removed first, no more than max ejection percent of instances are removed,
+// at least one instance is kept
+func FilterOutliers(instances []*registry.MicroServiceInstance, _ []*Criteria) []*registry.MicroServiceInstance {
+	type ejected struct {
+		index int
+		at    time.Time
+	}
+	var service string
+	outs := make([]ejected, 0)
+	now := time.Now()
+	outlierMu.Lock()
+	for i, ins := range instances {
+		for _, ep := range ins.EndpointsMap {
+			if ep == nil {
+				continue
+			}
+			if h, ok := hosts[ep.Address]; ok && now.Before(h.ejectedUntil) {
+				service = h.service
+				outs = append(outs, ejected{index: i, at: h.ejectedAt})
+				break
+			}
+		}
+	}
+	outlierMu.Unlock()
+	if len(outs) == 0 {
+		return instances
+	}
+	percent := config.GetOutlierMaxEjectionPercent(service)
+	limit := len(instances) * percent / 100
+	if limit == 0 && percent > 0 {
+		limit = 1
+	}
+	if limit >= len(instances) {
+		limit = len(instances) - 1
+	}
+	if len(outs) > limit {
+		sort.Slice(outs, func(i, j int) bool { return outs[i].at.Before(outs[j].at) })
+		outs = outs[:limit]
+	}
+	removed := make(map[int]struct{}, len(outs))
+	for _, o := range outs {
+		removed[o.index] = struct{}{}
+	}
+	result := make([]*registry.MicroServiceInstance, 0, len(instances)-len(removed))
+	for i, ins := range instances {
+		if _, ok := removed[i]; !ok {
+			result = append(result, ins)
+		}
+	}
+	return result
+}
+
+// forgetRemovedInstances drops outlier state of instances which are removed from discovery cache
+func forgetRemovedInstances(ins *registry.MicroServiceInstance) {
+	outlierMu.Lock()
+	defer outlierMu.Unlock()
+	for _, ep := range ins.EndpointsMap {
+		if ep == nil {
+			continue
+		}
+		if h, ok := hosts[ep.Address]; ok {
+			ejectedGauge.DeleteLabelValues(h.service, ep.Address)
+			delete(hosts, ep.Address)
+		}
+	}
+}
+
+func init() {
+	metrics.GetSystemPrometheusRegistry().MustRegister(ejectionsCounter, ejectedGauge)
+}
@@ -0,0 +1,91 @@
+package loadbalancer_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-chassis/go-chassis/v2/core/loadbalancer"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutlierDetection(t *testing.T) {
+	var mu sync.Mutex
+	events := make([]loadbalancer.OutlierEvent, 0)
+	loadbalancer.RegisterOutlierListener(func(e loadbalancer.OutlierEvent) {
+		mu.Lock()
+		events = append(events, e)
+		mu.Unlock()
+	})
+	instances := make([]*registry.MicroServiceInstance, 0)
+	for _, addr := range []string{"10.0.0.1:8080", "10.0.0.2:8080", "10.0.0.3:8080"} {
+		instances = append(instances, &registry.MicroServiceInstance{
+			InstanceID:   addr,
+			EndpointsMap: map[string]*registry.Endpoint{"rest": {Address: addr}},
+		})
+	}
+
+	for i := 0; i < 4; i++ {
+		loadbalancer.RecordOutcome("outlier", "10.0.0.1:8080", loadbalancer.OutcomeServerError)
+	}
+	loadbalancer.RecordOutcome("outlier", "10.0.0.1:8080", loadbalancer.OutcomeSuccess)
+	loadbalancer.RecordOutcome("outlier", "10.0.0.1:8080", loadbalancer.OutcomeServerError)
+	assert.False(t, loadbalancer.Ejected("10.0.0.1:8080"), "success resets consecutive errors")
+	for i := 0; i < 4; i++ {
+		loadbalancer.RecordOutcome("outlier", "10.0.0.1:8080", loadbalancer.OutcomeServerError)
+	}
+	assert.True(t, loadbalancer.Ejected("10.0.0.1:8080"))
+	assert.Equal(t, 2, len(loadbalancer.FilterOutliers(instances, nil)))
+
+	mu.Lock()
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, loadbalancer.EventEject, events[0].Type)
+	assert.Equal(t, loadbalancer.ReasonConsecutive5xx, events[0].Reason)
+	assert.Equal(t, 30*time.Second, events[0].Duration)
+	mu.Unlock()
+
+	t.Run("max ejection percent", func(t *testing.T) {
+		archaius.Set("cse.loadbalance.outlier.outlierDetection.consecutiveConnectFailures", "1")
+		loadbalancer.RecordOutcome("outlier", "10.0.0.2:8080", loadbalancer.OutcomeConnectFailure)
+		loadbalancer.RecordOutcome("outlier", "10.0.0.3:8080", loadbalancer.OutcomeConnectFailure)
+		assert.True(t, loadbalancer.Ejected("10.0.0.3:8080"))
+		result := loadbalancer.FilterOutliers(instances, nil)
+		assert.Equal(t, 2, len(result), "at least one instance is ejected")
+		assert.NotContains(t, result, instances[0], "instance ejected earliest is removed")
+
+		archaius.Set("cse.loadbalance.outlier.outlierDetection.maxEjectionPercent", "100")
+		assert.Equal(t, 1, len(loadbalancer.FilterOutliers(instances, nil)), "one instance is kept")
+	})
+	t.Run("uneject", func(t *testing.T) {
+		archaius.Set("cse.loadbalance.another.outlierDetection.baseEjectionTime", "50ms")
+		archaius.Set("cse.loadbalance.another.outlierDetection.consecutiveGatewayErrors", "2")
+		loadbalancer.RecordOutcome("another", "10.0.1.1:8080", loadbalancer.OutcomeGatewayError)
+		loadbalancer.RecordOutcome("another", "10.0.1.1:8080", loadbalancer.OutcomeGatewayError)
+		assert.True(t, loadbalancer.Ejected("10.0.1.1:8080"))
+		assert.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			e := events[len(events)-1]
+			return e.Type == loadbalancer.EventUneject && e.Address == "10.0.1.1:8080"
+		}, time.Second, 5*time.Millisecond)
+		assert.False(t, loadbalancer.Ejected("10.0.1.1:8080"))
+
+		loadbalancer.RecordOutcome("another", "10.0.1.1:8080", loadbalancer.OutcomeGatewayError)
+		loadbalancer.RecordOutcome("another", "10.0.1.1:8080", loadbalancer.OutcomeGatewayError)
+		mu.Lock()
+		e := events[len(events)-1]
+		mu.Unlock()
+		assert.Equal(t, loadbalancer.ReasonConsecutiveGatewayErrors, e.Reason)
+		assert.Equal(t, 2, e.Ejections)
+		assert.Equal(t, 100*time.Millisecond, e.Duration, "ejection time grows")
+	})
+	t.Run("disabled", func(t *testing.T) {
+		archaius.Set("cse.loadbalance.disabled.outlierDetection.enabled", "false")
+		for i := 0; i < 10; i++ {
+			loadbalancer.RecordOutcome("disabled", "10.0.2.1:8080", loadbalancer.OutcomeServerError)
+		}
+		assert.False(t, loadbalancer.Ejected("10.0.2.1:8080"))
+	})
+}
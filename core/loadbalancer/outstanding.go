@@ -0,0 +1,125 @@
+package loadbalancer
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+)
+
+// ewmaDecayTime is how fast latency of an instance decays, an idle instance gets traffic again after it decays
+const ewmaDecayTime = 10 * time.Second
+
+// FailurePenalty is the least latency recorded for a failed request,
+// so that an instance which fails fast does not look cheapest
+const FailurePenalty = 5 * time.Second
+
+// endpointStats is the load of an instance address, fields are updated atomically without lock
+type endpointStats struct {
+	inflight int64
+	// ewma is bits of float64, peak EWMA latency in nanoseconds
+	ewma uint64
+	// stamp is unix nano time of last latency update
+	stamp int64
+}
+
+// endpoints saves load of instance addresses, value is *endpointStats
+var endpoints sync.Map
+
+func statsOf(address string) *endpointStats {
+	v, ok := endpoints.Load(address)
+	if !ok {
+		v, _ = endpoints.LoadOrStore(address, &endpointStats{})
+	}
+	return v.(*endpointStats)
+}
+
+// StartRequest records a request to an instance address is in flight,
+// the returned func must be called once the request completes
+func StartRequest(address string) func() {
+	if address == "" {
+		return func() {}
+	}
+	s := statsOf(address)
+	atomic.AddInt64(&s.inflight, 1)
+	var once sync.Once
+	return func() {
+		once.Do(func() { atomic.AddInt64(&s.inflight, -1) })
+	}
+}
+
+// Outstanding returns number of in-flight requests of an instance address
+func Outstanding(address string) int64 {
+	v, ok := endpoints.Load(address)
+	if !ok {
+		return 0
+	}
+	return atomic.LoadInt64(&v.(*endpointStats).inflight)
+}
+
+// RecordLatency updates peak EWMA latency of an instance address,
+// a latency higher than average replaces it at once, a lower one is averaged by time passed since last update
+func RecordLatency(address string, latency time.Duration) {
+	if address == "" {
+		return
+	}
+	s := statsOf(address)
+	rtt := float64(latency)
+	now := time.Now().UnixNano()
+	for {
+		old := atomic.LoadUint64(&s.ewma)
+		v := math.Float64frombits(old)
+		if rtt > v {
+			v = rtt
+		} else {
+			w := math.Exp(-float64(now-atomic.LoadInt64(&s.stamp)) / float64(ewmaDecayTime))
+			v = v*w + rtt*(1-w)
+		}
+		if atomic.CompareAndSwapUint64(&s.ewma, old, math.Float64bits(v)) {
+			atomic.StoreInt64(&s.stamp, now)
+			return
+		}
+	}
+}
+
+// RecordFailure updates peak EWMA latency of an instance address with a failed request,
+// the latency is raised to FailurePenalty if it is shorter
+func RecordFailure(address string, latency time.Duration) {
+	if latency < FailurePenalty {
+		latency = FailurePenalty
+	}
+	RecordLatency(address, latency)
+}
+
+// EWMALatency returns peak EWMA latency of an instance address, it decays to 0 if no request completes
+func EWMALatency(address string) time.Duration {
+	v, ok := endpoints.Load(address)
+	if !ok {
+		return 0
+	}
+	return time.Duration(v.(*endpointStats).latency(time.Now().UnixNano()))
+}
+
+func (s *endpointStats) latency(now int64) float64 {
+	v := math.Float64frombits(atomic.LoadUint64(&s.ewma))
+	if v == 0 {
+		return 0
+	}
+	elapsed := now - atomic.LoadInt64(&s.stamp)
+	if elapsed <= 0 {
+		return v
+	}
+	return v * math.Exp(-float64(elapsed)/float64(ewmaDecayTime))
+}
+
+// forgetOutstanding drops load of an instance which is removed from discovery cache,
+// requests in flight still decrease the dropped counter
+func forgetOutstanding(ins *registry.MicroServiceInstance) {
+	for _, ep := range ins.EndpointsMap {
+		if ep != nil {
+			endpoints.Delete(ep.Address)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package loadbalancer
+
+import (
+	"time"
+
+	"github.com/go-chassis/go-chassis/v2/core/invocation"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+)
+
+// P2CEWMAStrategy picks two instances randomly, then uses the one with lower cost,
+// cost is peak EWMA latency multiplied by in-flight requests plus one
+type P2CEWMAStrategy struct {
+	instances []*registry.MicroServiceInstance
+	stats     []*endpointStats
+	slowStart SlowStart
+}
+
+func newP2CEWMAStrategy() Strategy {
+	return &P2CEWMAStrategy{}
+}
+
+// ReceiveData receive data
+func (r *P2CEWMAStrategy) ReceiveData(inv *invocation.Invocation, instances []*registry.MicroServiceInstance, serviceKey string) {
+	r.instances = instances
+	r.stats = loadOf(inv, instances)
+	r.slowStart = NewSlowStart(serviceOf(serviceKey))
+}
+
+// Pick return instance
+func (r *P2CEWMAStrategy) Pick() (*registry.MicroServiceInstance, error) {
+	n := len(r.instances)
+	switch n {
+	case 0:
+		return nil, ErrNoneAvailableInstance
+	case 1:
+		return r.instances[0], nil
+	}
+	a := randIntn(n)
+	b := randIntn(n - 1)
+	if b >= a {
+		b++
+	}
+	now := time.Now().UnixNano()
+	if r.cost(b, now) < r.cost(a, now) {
+		return r.instances[b], nil
+	}
+	return r.instances[a], nil
+}
+
+func (r *P2CEWMAStrategy) cost(i int, now int64) float64 {
+	var latency float64
+	if s := r.stats[i]; s != nil {
+		latency = s.latency(now)
+	}
+	// an instance without latency yet still compares by in-flight requests
+	return (latency + 1) * (inflightOf(r.stats[i]) + 1) / r.slowStart.Weight(r.instances[i])
+}
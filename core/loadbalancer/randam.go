@@ -12,6 +12,7 @@ import (
 type RandomStrategy struct {
 	instances []*registry.MicroServiceInstance
 	mtx       sync.Mutex
+	slowStart SlowStart
 }
 
 func newRandomStrategy() Strategy {
@@ -21,6 +22,7 @@ func newRandomStrategy() Strategy {
 // ReceiveData receive data
 func (r *RandomStrategy) ReceiveData(inv *invocation.Invocation, instances []*registry.MicroServiceInstance, serviceName string) {
 	r.instances = instances
+	r.slowStart = NewSlowStart(serviceOf(serviceName))
 }
 
 // Pick return instance
@@ -29,9 +31,27 @@ func (r *RandomStrategy) Pick() (*registry.MicroServiceInstance, error) {
 		return nil, ErrNoneAvailableInstance
 	}
 
+	if weights, ok := r.slowStart.warmingUp(r.instances); ok {
+		return r.instances[weightedIndex(weights)], nil
+	}
 	r.mtx.Lock()
 	k := rand.Int() % len(r.instances)
 	r.mtx.Unlock()
 	return r.instances[k], nil
 
 }
+
+// weightedIndex picks an index randomly in proportion to weights
+func weightedIndex(weights []float64) int {
+	var sum float64
+	for _, w := range weights {
+		sum += w
+	}
+	x := rand.Float64() * sum
+	for i, w := range weights {
+		if x -= w; x < 0 {
+			return i
+		}
+	}
+	return len(weights) - 1
+}
@@ -12,6 +12,7 @@ import (
 type RoundRobinStrategy struct {
 	instances []*registry.MicroServiceInstance
 	key       string
+	slowStart SlowStart
 }
 
 func newRoundRobinStrategy() Strategy {
@@ -22,6 +23,7 @@ func newRoundRobinStrategy() Strategy {
 func (r *RoundRobinStrategy) ReceiveData(inv *invocation.Invocation, instances []*registry.MicroServiceInstance, serviceKey string) {
 	r.instances = instances
 	r.key = serviceKey
+	r.slowStart = NewSlowStart(serviceOf(serviceKey))
 }
 
 // Pick return instance
@@ -30,8 +32,15 @@ func (r *RoundRobinStrategy) Pick() (*registry.MicroServiceInstance, error) {
 		return nil, ErrNoneAvailableInstance
 	}
 
-	i := pick(r.key)
-	return r.instances[i%len(r.instances)], nil
+	ins := r.instances[pick(r.key)%len(r.instances)]
+	if r.slowStart.window <= 0 {
+		return ins, nil
+	}
+	// an instance which is warming up is skipped in proportion to its weight
+	for n := 1; n < len(r.instances) && !r.slowStart.Accept(ins); n++ {
+		ins = r.instances[pick(r.key)%len(r.instances)]
+	}
+	return ins, nil
 }
 
 var rrIdxMap = make(map[string]int)
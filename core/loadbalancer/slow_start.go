@@ -0,0 +1,112 @@
+package loadbalancer
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chassis/go-chassis/v2/core/config"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+)
+
+// watchBufferSize is the buffer of instance events, an instance whose event is dropped does not warm up
+const watchBufferSize = 1000
+
+var (
+	// firstSeen saves when an instance appears in discovery cache, key is instance id
+	firstSeen sync.Map
+	watchOnce sync.Once
+)
+
+// watchInstances records when instances appear in discovery cache, and forgets removed instances.
+// instances which are already in cache are not new, they never warm up
+func watchInstances() {
+	watchOnce.Do(func() {
+		w := registry.WatchInstances(registry.WithBufferSize(watchBufferSize))
+		go func() {
+			for e := range w.Events() {
+				switch e.Type {
+				case registry.EventCreate:
+					firstSeen.LoadOrStore(e.Instance.InstanceID, time.Now())
+				case registry.EventDelete:
+					firstSeen.Delete(e.Instance.InstanceID)
+					forgetRemovedInstances(e.Instance)
+					forgetOutstanding(e.Instance)
+				}
+			}
+		}()
+	})
+}
+
+// serviceOf returns service name of a service key, which is name and tags joined by "|"
+func serviceOf(serviceKey string) string {
+	return strings.SplitN(serviceKey, "|", 2)[0]
+}
+
+// SlowStart is the slow start rule of a service, a new instance has a low weight,
+// it ramps up during warm-up window, so that the instance gets less traffic
+type SlowStart struct {
+	window    time.Duration
+	curve     string
+	minWeight float64
+}
+
+// NewSlowStart returns the slow start rule of a service
+func NewSlowStart(service string) SlowStart {
+	return SlowStart{
+		window:    config.GetSlowStartWindow(service),
+		curve:     config.GetSlowStartCurve(service),
+		minWeight: config.GetSlowStartMinWeight(service),
+	}
+}
+
+// Weight returns the weight of an instance in range [minWeight,1],
+// it is 1 once the instance warms up or slow start is disabled
+func (s SlowStart) Weight(ins *registry.MicroServiceInstance) float64 {
+	if s.window <= 0 {
+		return 1
+	}
+	v, ok := firstSeen.Load(ins.InstanceID)
+	if !ok {
+		return 1
+	}
+	return rampUp(time.Since(v.(time.Time)), s.window, s.curve, s.minWeight)
+}
+
+// rampUp returns minWeight at the beginning of the window, then ramps up to 1 at the end of it.
+// exponential curve doubles the weight at a fixed rate, linear curve adds the weight at a fixed rate
+func rampUp(elapsed, window time.Duration, curve string, minWeight float64) float64 {
+	if elapsed >= window {
+		return 1
+	}
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	x := float64(elapsed) / float64(window)
+	if curve == config.SlowStartExponential {
+		return math.Pow(minWeight, 1-x)
+	}
+	return minWeight + (1-minWeight)*x
+}
+
+// warmingUp reports if any instance is warming up, it returns weights of instances if so
+func (s SlowStart) warmingUp(instances []*registry.MicroServiceInstance) ([]float64, bool) {
+	if s.window <= 0 {
+		return nil, false
+	}
+	weights := make([]float64, len(instances))
+	warming := false
+	for i, ins := range instances {
+		weights[i] = s.Weight(ins)
+		warming = warming || weights[i] < 1
+	}
+	return weights, warming
+}
+
+// Accept decides randomly if an instance is picked, so that it gets traffic in proportion to its weight
+func (s SlowStart) Accept(ins *registry.MicroServiceInstance) bool {
+	w := s.Weight(ins)
+	return w >= 1 || rand.Float64() < w
+}
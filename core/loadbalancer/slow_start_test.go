@@ -0,0 +1,66 @@
+package loadbalancer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/invocation"
+	"github.com/go-chassis/go-chassis/v2/core/loadbalancer"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlowStart(t *testing.T) {
+	// old never appears in cache after lb is enabled, so it is not new
+	old := &registry.MicroServiceInstance{InstanceID: "old"}
+	index := registry.NewIndexCache()
+	assert.NoError(t, loadbalancer.Enable(loadbalancer.StrategyRoundRobin))
+	fresh := &registry.MicroServiceInstance{InstanceID: "fresh"}
+	index.Set("warm", []*registry.MicroServiceInstance{fresh})
+
+	s := loadbalancer.NewSlowStart("warm")
+	assert.Equal(t, float64(1), s.Weight(fresh), "slow start is disabled by default")
+
+	archaius.Set("cse.loadbalance.slowStart.window", "1h")
+	archaius.Set("cse.loadbalance.warm.slowStart.minWeight", "0.01")
+	assert.Eventually(t, func() bool {
+		return loadbalancer.NewSlowStart("warm").Weight(fresh) < 1
+	}, time.Second, 10*time.Millisecond)
+	s = loadbalancer.NewSlowStart("warm")
+	assert.InDelta(t, 0.01, s.Weight(fresh), 0.001)
+	assert.Equal(t, float64(1), s.Weight(old), "instances which are not seen appearing are not new")
+
+	archaius.Set("cse.loadbalance.warm.slowStart.curve", "exponential")
+	assert.InDelta(t, 0.01, loadbalancer.NewSlowStart("warm").Weight(fresh), 0.001)
+
+	t.Run("strategies", func(t *testing.T) {
+		instances := []*registry.MicroServiceInstance{old, fresh}
+		inv := &invocation.Invocation{MicroServiceName: "warm", Protocol: common.ProtocolRest}
+		for _, name := range []string{loadbalancer.StrategyRoundRobin, loadbalancer.StrategyRandom} {
+			f, err := loadbalancer.GetStrategyPlugin(name)
+			assert.NoError(t, err)
+			st := f()
+			st.ReceiveData(inv, instances, "warm|version:1.0.0")
+			picked := 0
+			for i := 0; i < 1000; i++ {
+				ins, err := st.Pick()
+				assert.NoError(t, err)
+				if ins == fresh {
+					picked++
+				}
+			}
+			assert.Less(t, picked, 100, name)
+		}
+	})
+	t.Run("warmed up", func(t *testing.T) {
+		archaius.Set("cse.loadbalance.warm.slowStart.window", "1ns")
+		assert.Equal(t, float64(1), loadbalancer.NewSlowStart("warm").Weight(fresh))
+		index.Delete("warm")
+		archaius.Set("cse.loadbalance.warm.slowStart.window", "1h")
+		assert.Eventually(t, func() bool {
+			return loadbalancer.NewSlowStart("warm").Weight(fresh) == 1
+		}, time.Second, 10*time.Millisecond, "removed instance is forgotten")
+	})
+}
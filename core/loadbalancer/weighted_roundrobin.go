@@ -0,0 +1,148 @@
+package loadbalancer
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/go-chassis/go-chassis/v2/core/config"
+	"github.com/go-chassis/go-chassis/v2/core/invocation"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/go-chassis/v2/core/router/weightpool"
+	"github.com/go-chassis/openlog"
+)
+
+// wrrStates caches weighted round robin state of every service key,
+// it is rebuilt only when instances or their weights change
+var wrrStates sync.Map
+
+// wrrState is smooth weighted round robin, every pick adds weights to current weights,
+// the instance with max current weight is picked and its current weight is reduced by total weight.
+// weights are divided by their greatest common divisor, so that current weights stay small
+type wrrState struct {
+	mu      sync.Mutex
+	weights map[string]int // key is instance id
+	index   map[string]int
+	current []int
+	reduced []int
+	total   int
+}
+
+func newWRRState(instances []*registry.MicroServiceInstance, weights []int) *wrrState {
+	s := &wrrState{
+		weights: make(map[string]int, len(instances)),
+		index:   make(map[string]int, len(instances)),
+		current: make([]int, len(instances)),
+		reduced: make([]int, len(instances)),
+	}
+	d := 0
+	for _, w := range weights {
+		d = weightpool.GCD(d, w)
+	}
+	for i, ins := range instances {
+		s.weights[ins.InstanceID] = weights[i]
+		s.index[ins.InstanceID] = i
+		s.reduced[i] = weights[i] / d
+		s.total += s.reduced[i]
+	}
+	return s
+}
+
+// positions returns position of every instance of the state in instances,
+// it returns false if instances or their weights are changed
+func (s *wrrState) positions(instances []*registry.MicroServiceInstance, weights []int) ([]int, bool) {
+	if len(s.weights) != len(instances) {
+		return nil, false
+	}
+	pos := make([]int, len(instances))
+	for i, ins := range instances {
+		w, ok := s.weights[ins.InstanceID]
+		if !ok || w != weights[i] {
+			return nil, false
+		}
+		pos[s.index[ins.InstanceID]] = i
+	}
+	return pos, true
+}
+
+func (s *wrrState) next() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	best := 0
+	for i, w := range s.reduced {
+		s.current[i] += w
+		if s.current[i] > s.current[best] {
+			best = i
+		}
+	}
+	s.current[best] -= s.total
+	return best
+}
+
+// InstanceWeight returns weight of an instance in its metadata, invalid weight is replaced by default weight
+func InstanceWeight(ins *registry.MicroServiceInstance, key string, def int) int {
+	v, ok := ins.Metadata[key]
+	if !ok {
+		return def
+	}
+	w, err := strconv.Atoi(v)
+	if err != nil || w <= 0 {
+		openlog.Debug(fmt.Sprintf("invalid weight [%s] of instance [%s], use %d", v, ins.InstanceID, def))
+		return def
+	}
+	return w
+}
+
+// WeightedRoundRobinStrategy sends traffic to instances in proportion to their weights in metadata,
+// picks of instances are interleaved smoothly
+type WeightedRoundRobinStrategy struct {
+	instances []*registry.MicroServiceInstance
+	state     *wrrState
+	// positions maps index of state to index of instances, because registry may return instances in any order
+	positions []int
+	slowStart SlowStart
+}
+
+func newWeightedRoundRobinStrategy() Strategy {
+	return &WeightedRoundRobinStrategy{}
+}
+
+// ReceiveData receive data
+func (r *WeightedRoundRobinStrategy) ReceiveData(inv *invocation.Invocation, instances []*registry.MicroServiceInstance, serviceKey string) {
+	service := serviceOf(serviceKey)
+	r.instances = instances
+	r.slowStart = NewSlowStart(service)
+	key, def := config.GetWeightKey(service), config.GetDefaultInstanceWeight(service)
+	weights := make([]int, len(instances))
+	for i, ins := range instances {
+		weights[i] = InstanceWeight(ins, key, def)
+	}
+	if v, ok := wrrStates.Load(serviceKey); ok {
+		if pos, ok := v.(*wrrState).positions(instances, weights); ok {
+			r.state, r.positions = v.(*wrrState), pos
+			return
+		}
+	}
+	r.state = newWRRState(instances, weights)
+	r.positions = make([]int, len(instances))
+	for i := range instances {
+		r.positions[i] = i
+	}
+	wrrStates.Store(serviceKey, r.state)
+}
+
+// Pick return instance
+func (r *WeightedRoundRobinStrategy) Pick() (*registry.MicroServiceInstance, error) {
+	if len(r.instances) == 0 || r.state == nil {
+		return nil, ErrNoneAvailableInstance
+	}
+	ins := r.instances[r.positions[r.state.next()]]
+	if r.slowStart.window <= 0 {
+		return ins, nil
+	}
+	// an instance which is warming up is skipped in proportion to its weight
+	for n := 1; n < len(r.instances) && !r.slowStart.Accept(ins); n++ {
+		ins = r.instances[r.positions[r.state.next()]]
+	}
+	return ins, nil
+}
@@ -0,0 +1,58 @@
+package loadbalancer_test
+
+import (
+	"testing"
+
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-chassis/go-chassis/v2/core/invocation"
+	"github.com/go-chassis/go-chassis/v2/core/loadbalancer"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWeightedRoundRobinStrategy(t *testing.T) {
+	a := &registry.MicroServiceInstance{InstanceID: "a", Metadata: map[string]string{"weight": "50"}}
+	b := &registry.MicroServiceInstance{InstanceID: "b", Metadata: map[string]string{"weight": "10"}}
+	c := &registry.MicroServiceInstance{InstanceID: "c", Metadata: map[string]string{"weight": "invalid"}}
+	inv := &invocation.Invocation{MicroServiceName: "weighted"}
+	sequence := func(instances []*registry.MicroServiceInstance, n int) []string {
+		ids := make([]string, 0, n)
+		for i := 0; i < n; i++ {
+			s := &loadbalancer.WeightedRoundRobinStrategy{}
+			s.ReceiveData(inv, instances, "weighted|")
+			ins, err := s.Pick()
+			assert.NoError(t, err)
+			ids = append(ids, ins.InstanceID)
+		}
+		return ids
+	}
+
+	_, err := (&loadbalancer.WeightedRoundRobinStrategy{}).Pick()
+	assert.Equal(t, loadbalancer.ErrNoneAvailableInstance, err)
+	archaius.Set("cse.loadbalance.weighted.weightedRoundRobin.defaultWeight", "20")
+	assert.Equal(t, 20, loadbalancer.InstanceWeight(c, "weight", 20))
+	assert.Equal(t, []string{"a", "c", "a", "a", "b", "a", "c", "a"},
+		sequence([]*registry.MicroServiceInstance{a, b, c}, 8), "picks are interleaved smoothly")
+
+	t.Run("order", func(t *testing.T) {
+		assert.Equal(t, []string{"a", "c", "a", "a"}, sequence([]*registry.MicroServiceInstance{c, b, a}, 4),
+			"state is kept when registry returns instances in another order")
+	})
+	t.Run("weight changes", func(t *testing.T) {
+		b2 := &registry.MicroServiceInstance{InstanceID: "b", Metadata: map[string]string{"weight": "100"}}
+		counts := make(map[string]int)
+		for _, id := range sequence([]*registry.MicroServiceInstance{a, b2, c}, 170) {
+			counts[id]++
+		}
+		assert.Equal(t, map[string]int{"a": 50, "b": 100, "c": 20}, counts)
+	})
+	t.Run("weight key", func(t *testing.T) {
+		archaius.Set("cse.loadbalance.weighted.weightedRoundRobin.weightKey", "capacity")
+		d := &registry.MicroServiceInstance{InstanceID: "d", Metadata: map[string]string{"capacity": "3"}}
+		counts := make(map[string]int)
+		for _, id := range sequence([]*registry.MicroServiceInstance{a, d}, 23) {
+			counts[id]++
+		}
+		assert.Equal(t, map[string]int{"a": 20, "d": 3}, counts, "a has no capacity, it has default weight")
+	})
+}
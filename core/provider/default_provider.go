@@ -260,8 +260,13 @@ func (p *DefaultProvider) Invoke(inv *invocation.Invocation) (interface{}, error
 	}()
 
 	function := op.method.Func
+	// the first argument is usually context.Context, give invocation context to it
+	ctx := reflect.Indirect(reflect.New(op.In[0]))
+	if inv.Ctx != nil && reflect.TypeOf(inv.Ctx).AssignableTo(op.In[0]) {
+		ctx = reflect.ValueOf(inv.Ctx)
+	}
 	// Invoke the method, providing a new value for the reply.
-	returnValues := function.Call([]reflect.Value{schema.rcvr, reflect.Indirect(reflect.New(op.In[0])), reflect.ValueOf(inv.Args)})
+	returnValues := function.Call([]reflect.Value{schema.rcvr, ctx, reflect.ValueOf(inv.Args)})
 	// The return value for the method is an error.
 	errInter := returnValues[1].Interface()
 
@@ -1,10 +1,12 @@
 package registry
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/go-chassis/go-chassis/v2/core/common"
 	"github.com/go-chassis/go-chassis/v2/pkg/runtime"
+	utiltags "github.com/go-chassis/go-chassis/v2/pkg/util/tags"
 	"github.com/go-chassis/openlog"
 	"github.com/patrickmn/go-cache"
 )
@@ -32,9 +34,10 @@ var ProvidersMicroServiceCache *cache.Cache
 
 func initCache() *cache.Cache { return cache.New(DefaultExpireTime, 0) }
 
-// EnableRegistryCache init caches
+// EnableRegistryCache init caches, the instance index tracks instances loaded from snapshot
 func EnableRegistryCache() {
-	MicroserviceInstanceIndex = NewIndexCache()
+	resetHolders()
+	MicroserviceInstanceIndex = newStaleIndex(NewIndexCache())
 	ipIndexedCache = initCache()
 	SchemaServiceIndexedCache = initCache()
 	SchemaInterfaceIndexedCache = initCache()
@@ -43,6 +46,7 @@ func EnableRegistryCache() {
 
 // CacheIndex is a unified local instances cache manager
 type CacheIndex interface {
+	//Get returns instances shared with cache, they must be copied before changing
 	Get(service string, tags map[string]string) ([]*MicroServiceInstance, bool)
 	//Set will overwrite all instances correspond to a service name
 	Set(service string, instances []*MicroServiceInstance)
@@ -50,6 +54,18 @@ type CacheIndex interface {
 	Delete(service string)
 }
 
+// globalIndex refers to MicroserviceInstanceIndex, so that it still works after the cache is enabled again
+type globalIndex struct{}
+
+func (globalIndex) Get(service string, tags map[string]string) ([]*MicroServiceInstance, bool) {
+	return MicroserviceInstanceIndex.Get(service, tags)
+}
+func (globalIndex) Set(service string, instances []*MicroServiceInstance) {
+	MicroserviceInstanceIndex.Set(service, instances)
+}
+func (globalIndex) FullCache() *cache.Cache { return MicroserviceInstanceIndex.FullCache() }
+func (globalIndex) Delete(service string)   { MicroserviceInstanceIndex.Delete(service) }
+
 // SetIPIndex save ip index
 func SetIPIndex(ip string, si *SourceInfo) {
 	ipIndexedCache.Set(ip, si, 0)
@@ -97,3 +113,33 @@ func AddProviderToCache(serverName, appID string) {
 		ProvidersMicroServiceCache.Set(key, MicroService{ServiceName: serverName, AppID: appID}, 0)
 	}
 }
+
+// WrapTags sets latest version and current app if they are not specified,
+// registry caches need version and app to index instances
+func WrapTags(t utiltags.Tags) utiltags.Tags {
+	if t.KV != nil {
+		if v, ok := t.KV[common.BuildinTagVersion]; !ok || v == "" {
+			t.KV[common.BuildinTagVersion] = common.LatestVersion
+			t.Label += "|" + common.BuildinLabelVersion
+		}
+		if v, ok := t.KV[common.BuildinTagApp]; !ok || v == "" {
+			t.KV[common.BuildinTagApp] = runtime.App
+			t.Label += "|" + common.BuildinTagApp + ":" + runtime.App
+		}
+		return t
+	}
+	//if app and version is empty, need to find with latest version in same app
+	return utiltags.NewDefaultTag(common.LatestVersion, runtime.App)
+}
+
+// FindInstances wraps tags and gets instances of a service from index,
+// it returns nil if there is no instance, source is the name of registry in log
+func FindInstances(index CacheIndex, source, consumerID, service string, tags utiltags.Tags) []*MicroServiceInstance {
+	tags = WrapTags(tags)
+	instances, ok := index.Get(service, tags.KV)
+	if !ok || instances == nil {
+		openlog.Debug(fmt.Sprintf("%s find no instances of %s:%s:%s in %s", consumerID, tags.AppID(), service, tags.Version(), source))
+		return nil
+	}
+	return instances
+}
@@ -19,11 +19,21 @@ type IndexCache struct {
 	indexedCache *cache.Cache
 
 	CriteriaStore []map[string]string //all criteria need to be saved in here so that we can update indexedCache, during Set process
+
+	muxWrite sync.Mutex //serialize changes, so that instance events are emitted in order
+
+	source string //the discovery backend which saves instances into this cache
 }
 
 // NewIndexCache create a cache which saves and manage instances
 func NewIndexCache() *IndexCache {
+	return NewBackendIndexCache("")
+}
+
+// NewBackendIndexCache create a cache of a discovery backend, instance events of the cache are tagged with its name
+func NewBackendIndexCache(backend string) *IndexCache {
 	return &IndexCache{
+		source:       backend,
 		simpleCache:  cache.New(DefaultExpireTime, 0),
 		latestV:      map[string]string{},
 		indexedCache: cache.New(DefaultExpireTime, 0),
@@ -36,12 +46,28 @@ func (ic *IndexCache) FullCache() *cache.Cache { return ic.simpleCache }
 
 // Delete remove one service's instances
 func (ic *IndexCache) Delete(k string) {
+	ic.muxWrite.Lock()
+	defer ic.muxWrite.Unlock()
+	old := ic.instances(k)
 	ic.simpleCache.Delete(k)
 	ic.indexedCache.Delete(k)
+	publish(ic, diffInstances(k, old, nil))
+}
+
+func (ic *IndexCache) instances(k string) []*MicroServiceInstance {
+	value, ok := ic.simpleCache.Get(k)
+	if !ok {
+		return nil
+	}
+	instances, _ := value.([]*MicroServiceInstance)
+	return instances
 }
 
 // Set overwrite instances cache
 func (ic *IndexCache) Set(k string, instances []*MicroServiceInstance) {
+	ic.muxWrite.Lock()
+	defer ic.muxWrite.Unlock()
+	old := ic.instances(k)
 	latestV, _ := version.NewVersion("0.0.0")
 	for _, instance := range instances {
 		//update latest version number
@@ -70,7 +96,7 @@ func (ic *IndexCache) Set(k string, instances []*MicroServiceInstance) {
 	//ic.muxCriteria.RUnlock()
 
 	ic.simpleCache.Set(k, instances, 0)
-
+	publish(ic, diffInstances(k, old, instances))
 }
 
 // Get return instances cache by criteria
@@ -1,7 +1,10 @@
 package registry_test
 
 import (
+	"github.com/go-chassis/go-chassis/v2/core/common"
 	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/go-chassis/v2/pkg/runtime"
+	utiltags "github.com/go-chassis/go-chassis/v2/pkg/util/tags"
 	"testing"
 
 	"strings"
@@ -60,3 +63,28 @@ func TestAddProviderToCache(t *testing.T) {
 	}
 
 }
+
+func TestWrapTags(t *testing.T) {
+	runtime.App = "default"
+	tags := registry.WrapTags(utiltags.Tags{})
+	assert.Equal(t, common.LatestVersion, tags.Version())
+	assert.Equal(t, "default", tags.AppID())
+
+	tags = registry.WrapTags(utiltags.NewDefaultTag("1.0.0", ""))
+	assert.Equal(t, "1.0.0", tags.Version())
+	assert.Equal(t, "default", tags.AppID())
+}
+
+func TestFindInstances(t *testing.T) {
+	runtime.App = "default"
+	index := registry.NewIndexCache()
+	assert.Nil(t, registry.FindInstances(index, "test", "consumer", "Server", utiltags.Tags{}))
+
+	index.Set("Server", []*registry.MicroServiceInstance{{
+		InstanceID: "1",
+		Metadata:   map[string]string{common.BuildinTagApp: "default", common.BuildinTagVersion: "1.0.0"},
+	}})
+	instances := registry.FindInstances(index, "test", "consumer", "Server", utiltags.Tags{})
+	assert.Len(t, instances, 1)
+	assert.Nil(t, registry.FindInstances(index, "test", "consumer", "Server", utiltags.NewDefaultTag("2.0.0", "")))
+}
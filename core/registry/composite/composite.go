@@ -0,0 +1,360 @@
+// Package composite is a service discovery plugin which wraps several discovery plugins,
+// services are discovered from several registries at once, like during migration from one registry to another.
+// every backend saves instances into its own cache, and composite discovery merges instances found by backends
+package composite
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-chassis/go-chassis/v2/core/config"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/go-chassis/v2/pkg/metrics"
+	utiltags "github.com/go-chassis/go-chassis/v2/pkg/util/tags"
+	"github.com/go-chassis/openlog"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Name is the plugin name of composite discovery
+const Name = "composite"
+
+// merge policies of composite discovery
+const (
+	// PolicyUnion merges instances of all backends
+	PolicyUnion = "union"
+	// PolicyFirstNonEmpty uses instances of the first backend which has any
+	PolicyFirstNonEmpty = "first-non-empty"
+	// PolicyPreferred uses instances of the first backend, other backends are used only if it fails
+	PolicyPreferred = "preferred"
+)
+
+// MetricsBackendHealthy is the metric name of backend health
+const MetricsBackendHealthy = "scb_composite_discovery_backend_healthy"
+
+// ErrNoBackend means composite discovery has no available backend
+var ErrNoBackend = errors.New("composite discovery has no backend")
+
+var healthyGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: MetricsBackendHealthy,
+	Help: "1 if the last lookup and sync of a composite discovery backend succeeded, otherwise 0",
+}, []string{"backend"})
+
+func init() {
+	registry.InstallRegistrator(Name, NewRegistrator)
+	registry.InstallServiceDiscovery(Name, NewServiceDiscovery)
+	metrics.GetSystemPrometheusRegistry().MustRegister(healthyGauge)
+}
+
+// NewRegistrator returns the registrator of the configured plugin,
+// service is not registered if there is no such plugin
+func NewRegistrator(opts registry.Options) registry.Registrator {
+	t := config.GetRegistratorCompositeType()
+	if t == "" || t == Name {
+		openlog.Warn("composite registry has no registrator, service is not registered")
+		return registry.NewNoopRegistrator(opts)
+	}
+	o, err := backendOptions(t, opts, registry.RTag)
+	if err == nil {
+		var r registry.Registrator
+		if r, err = registry.NewRegistrator(t, o); err == nil {
+			return r
+		}
+	}
+	openlog.Error(fmt.Sprintf("create [%s] registrator failed: %s, service is not registered", t, err))
+	return registry.NewNoopRegistrator(opts)
+}
+
+// backendOptions returns options of a backend, addresses of composite registry are used if the backend has none
+func backendOptions(name string, opts registry.Options, tag string) (registry.Options, error) {
+	o := opts
+	if address := config.GetServiceDiscoveryCompositeAddress(name); address != "" {
+		bo, err := registry.NewOptions(address, tag)
+		if err != nil {
+			return o, err
+		}
+		o.Addrs, o.TLSConfig, o.EnableSSL = bo.Addrs, bo.TLSConfig, bo.EnableSSL
+	}
+	return o, nil
+}
+
+// BackendStatus is the health of a backend
+type BackendStatus struct {
+	Name string
+	// Healthy reports whether the last call to the backend and its last sync succeeded
+	Healthy bool
+	// Error is the error of the last failed call
+	Error string
+	// LastSuccess is the time of the last successful call
+	LastSuccess time.Time
+	// Stale reports whether the backend is failing and instances it found before are still used
+	Stale bool
+}
+
+// remembered is the last successful lookup of a backend
+type remembered struct {
+	instances []*registry.MicroServiceInstance
+	at        time.Time
+}
+
+type backend struct {
+	name string
+	sd   registry.ServiceDiscovery
+
+	mu          sync.Mutex
+	err         error
+	lastSuccess time.Time
+	found       map[string]*remembered // key is service name and tags
+}
+
+// ServiceDiscovery merges instances found by backends
+type ServiceDiscovery struct {
+	backends   []*backend
+	policy     string
+	staleAfter time.Duration
+}
+
+// NewServiceDiscovery creates configured backends, each of them has its own instance cache.
+// a backend which can not be created is skipped
+func NewServiceDiscovery(opts registry.Options) registry.ServiceDiscovery {
+	r := &ServiceDiscovery{
+		policy:     config.GetServiceDiscoveryCompositePolicy(),
+		staleAfter: config.GetServiceDiscoveryCompositeStaleAfter(),
+	}
+	switch r.policy {
+	case PolicyUnion, PolicyFirstNonEmpty, PolicyPreferred:
+	default:
+		openlog.Warn(fmt.Sprintf("unknown composite policy [%s], use %s", r.policy, PolicyUnion))
+		r.policy = PolicyUnion
+	}
+	for _, name := range config.GetServiceDiscoveryCompositeBackends() {
+		if name == Name {
+			openlog.Warn("composite discovery can not be a backend of itself")
+			continue
+		}
+		o, err := backendOptions(name, opts, registry.SDTag)
+		if err != nil {
+			openlog.Error(fmt.Sprintf("invalid options of [%s] discovery: %s", name, err))
+			continue
+		}
+		o.Index = registry.NewBackendIndexCache(name)
+		sd, err := registry.NewDiscovery(name, o)
+		if err != nil {
+			openlog.Error(fmt.Sprintf("create [%s] discovery failed: %s", name, err))
+			continue
+		}
+		r.backends = append(r.backends, &backend{name: name, sd: sd, found: make(map[string]*remembered)})
+		healthyGauge.WithLabelValues(name).Set(1)
+	}
+	if len(r.backends) == 0 {
+		openlog.Error(ErrNoBackend.Error())
+	}
+	return r
+}
+
+// GetMicroService returns the micro service found by the first backend which has it
+func (r *ServiceDiscovery) GetMicroService(microServiceID string) (*registry.MicroService, error) {
+	err := ErrNoBackend
+	for _, b := range r.backends {
+		var ms *registry.MicroService
+		if ms, err = b.sd.GetMicroService(microServiceID); err == nil && ms != nil {
+			return ms, nil
+		}
+	}
+	return nil, err
+}
+
+// FindMicroServiceInstances merges instances found by backends according to the policy,
+// instances with the same endpoint are de-duplicated, the one found by the former backend is kept.
+// instances found by a failing backend before are still used until they are stale
+func (r *ServiceDiscovery) FindMicroServiceInstances(consumerID, microServiceName string, tags utiltags.Tags) ([]*registry.MicroServiceInstance, error) {
+	tags = registry.WrapTags(tags)
+	m := newMerger()
+	answered := false
+	err := ErrNoBackend
+	find := func(b *backend) []*registry.MicroServiceInstance {
+		instances, e := r.lookup(b, consumerID, microServiceName, tags)
+		if e == nil {
+			answered = true
+		} else {
+			err = e
+		}
+		return instances
+	}
+	switch r.policy {
+	case PolicyUnion:
+		for _, b := range r.backends {
+			m.add(find(b))
+		}
+	case PolicyFirstNonEmpty:
+		for _, b := range r.backends {
+			if instances := find(b); len(instances) != 0 {
+				m.add(instances)
+				break
+			}
+		}
+	case PolicyPreferred:
+		for i, b := range r.backends {
+			instances := find(b)
+			// the preferred backend is trusted even if it has no instance, unless it fails
+			if i == 0 && answered || len(instances) != 0 {
+				m.add(instances)
+				break
+			}
+		}
+	}
+	if len(m.instances) != 0 {
+		return m.instances, nil
+	}
+	if answered {
+		return nil, nil
+	}
+	return nil, fmt.Errorf("find instances of [%s] failed: %w", microServiceName, err)
+}
+
+// syncError returns the error of the last sync of a backend which syncs instances in background
+func (b *backend) syncError() error {
+	if h, ok := b.sd.(registry.HealthReporter); ok {
+		return h.Healthy()
+	}
+	return nil
+}
+
+// lookup finds instances by a backend, instances found before are returned with the error if the backend fails.
+// a backend whose sync fails is failing as well, even if it returns instances from its cache
+func (r *ServiceDiscovery) lookup(b *backend, consumerID, name string, tags utiltags.Tags) ([]*registry.MicroServiceInstance, error) {
+	key := registry.GetIndexedCacheKey(name, tags.KV)
+	// cache of backend replaces latest version in tags with the real version, so every backend gets a copy
+	kv := make(map[string]string, len(tags.KV))
+	for k, v := range tags.KV {
+		kv[k] = v
+	}
+	instances, err := b.sd.FindMicroServiceInstances(consumerID, name, utiltags.Tags{KV: kv, Label: tags.Label})
+	if err == nil {
+		err = b.syncError()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		if b.err != nil {
+			openlog.Info(fmt.Sprintf("[%s] discovery recovers", b.name))
+			healthyGauge.WithLabelValues(b.name).Set(1)
+		}
+		b.err = nil
+		b.lastSuccess = time.Now()
+		b.found[key] = &remembered{instances: instances, at: b.lastSuccess}
+		return instances, nil
+	}
+	if b.err == nil {
+		openlog.Warn(fmt.Sprintf("[%s] discovery fails: %s", b.name, err))
+		healthyGauge.WithLabelValues(b.name).Set(0)
+	}
+	b.err = err
+	if f, ok := b.found[key]; ok && time.Since(f.at) <= r.staleAfter {
+		return f.instances, err
+	}
+	return nil, err
+}
+
+// merger de-duplicates instances by endpoints
+type merger struct {
+	instances []*registry.MicroServiceInstance
+	seen      map[string]struct{}
+}
+
+func newMerger() *merger {
+	return &merger{seen: make(map[string]struct{})}
+}
+
+func (m *merger) add(instances []*registry.MicroServiceInstance) {
+	for _, ins := range instances {
+		keys := make([]string, 0, len(ins.EndpointsMap))
+		for p, ep := range ins.EndpointsMap {
+			if ep != nil && ep.Address != "" {
+				keys = append(keys, p+"://"+ep.Address)
+			}
+		}
+		if len(keys) == 0 {
+			keys = append(keys, ins.InstanceID)
+		}
+		sort.Strings(keys)
+		duplicated := false
+		for _, k := range keys {
+			if _, ok := m.seen[k]; ok {
+				duplicated = true
+				break
+			}
+		}
+		if duplicated {
+			continue
+		}
+		for _, k := range keys {
+			m.seen[k] = struct{}{}
+		}
+		m.instances = append(m.instances, ins)
+	}
+}
+
+// Backends returns health of backends of default service discovery in order,
+// it returns nil if default service discovery is not composite
+func Backends() []BackendStatus {
+	if r, ok := registry.DefaultServiceDiscoveryService.(*ServiceDiscovery); ok {
+		return r.Backends()
+	}
+	return nil
+}
+
+// Backends returns health of backends in order, a backend whose sync fails is unhealthy even if it is not looked up
+func (r *ServiceDiscovery) Backends() []BackendStatus {
+	ss := make([]BackendStatus, 0, len(r.backends))
+	for _, b := range r.backends {
+		b.mu.Lock()
+		err := b.err
+		if err == nil {
+			err = b.syncError()
+		}
+		s := BackendStatus{Name: b.name, Healthy: err == nil, LastSuccess: b.lastSuccess}
+		if err != nil {
+			s.Error = err.Error()
+			s.Stale = !b.lastSuccess.IsZero() && time.Since(b.lastSuccess) <= r.staleAfter
+		}
+		b.mu.Unlock()
+		ss = append(ss, s)
+	}
+	return ss
+}
+
+// Healthy returns an error only if sync of every backend fails, backends which do not sync in background are healthy
+func (r *ServiceDiscovery) Healthy() error {
+	var err error
+	for _, b := range r.backends {
+		e := b.syncError()
+		if e == nil {
+			return nil
+		}
+		if err == nil {
+			err = fmt.Errorf("[%s] discovery sync failed: %w", b.name, e)
+		}
+	}
+	return err
+}
+
+// AutoSync starts syncing of every backend
+func (r *ServiceDiscovery) AutoSync() {
+	for _, b := range r.backends {
+		b.sd.AutoSync()
+	}
+}
+
+// Close closes every backend, it returns the first error
+func (r *ServiceDiscovery) Close() error {
+	var err error
+	for _, b := range r.backends {
+		if e := b.sd.Close(); e != nil && err == nil {
+			err = fmt.Errorf("close [%s] discovery failed: %w", b.name, e)
+		}
+	}
+	return err
+}
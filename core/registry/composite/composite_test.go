@@ -0,0 +1,309 @@
+package composite
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/go-chassis/v2/pkg/metrics"
+	"github.com/go-chassis/go-chassis/v2/pkg/runtime"
+	utiltags "github.com/go-chassis/go-chassis/v2/pkg/util/tags"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDiscovery saves instances into the cache it is given, like real plugins
+type fakeDiscovery struct {
+	index registry.CacheIndex
+
+	mu     sync.Mutex
+	err    error
+	synced bool
+	closed bool
+}
+
+var fakes = make(map[string]*fakeDiscovery)
+
+func installFake(name string) {
+	registry.InstallServiceDiscovery(name, func(opts registry.Options) registry.ServiceDiscovery {
+		f := &fakeDiscovery{index: opts.InstanceIndex()}
+		fakes[name] = f
+		return f
+	})
+}
+
+func init() {
+	archaius.Init(archaius.WithMemorySource())
+	runtime.App = "shop"
+	installFake("fake-a")
+	installFake("fake-b")
+	registry.InstallServiceDiscovery("fake-sync", func(opts registry.Options) registry.ServiceDiscovery {
+		f := &syncingDiscovery{fakeDiscovery: &fakeDiscovery{index: opts.InstanceIndex()}}
+		syncing = f
+		return f
+	})
+	registry.InstallRegistrator("fake-b", func(opts registry.Options) registry.Registrator { return &fakeRegistrator{} })
+}
+
+func (f *fakeDiscovery) set(service string, instances ...*registry.MicroServiceInstance) {
+	f.index.Set(service, instances)
+}
+
+func (f *fakeDiscovery) fail(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+func (f *fakeDiscovery) GetMicroService(microServiceID string) (*registry.MicroService, error) {
+	if _, ok := f.index.Get(microServiceID, nil); !ok {
+		return nil, errors.New("not found")
+	}
+	return &registry.MicroService{ServiceID: microServiceID, ServiceName: microServiceID}, nil
+}
+
+func (f *fakeDiscovery) FindMicroServiceInstances(consumerID, microServiceName string, tags utiltags.Tags) ([]*registry.MicroServiceInstance, error) {
+	f.mu.Lock()
+	err := f.err
+	f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	instances, ok := f.index.Get(microServiceName, tags.KV)
+	if !ok {
+		return nil, nil
+	}
+	return instances, nil
+}
+
+func (f *fakeDiscovery) AutoSync() { f.synced = true }
+
+func (f *fakeDiscovery) Close() error {
+	f.closed = true
+	return nil
+}
+
+// syncingDiscovery syncs instances in background, lookups read its cache and never fail
+type syncingDiscovery struct {
+	*fakeDiscovery
+	registry.SyncHealth
+}
+
+var syncing *syncingDiscovery
+
+type fakeRegistrator struct {
+	registry.NoopRegistrator
+}
+
+func instance(id, addr string) *registry.MicroServiceInstance {
+	return (&registry.MicroServiceInstance{
+		InstanceID:   id,
+		EndpointsMap: map[string]*registry.Endpoint{common.ProtocolRest: {Address: addr}},
+		Metadata:     map[string]string{common.BuildinTagVersion: "1.0.0"},
+	}).WithAppID("shop")
+}
+
+func ids(instances []*registry.MicroServiceInstance) []string {
+	s := make([]string, 0, len(instances))
+	for _, ins := range instances {
+		s = append(s, ins.InstanceID)
+	}
+	return s
+}
+
+func newComposite(t *testing.T, policy string) *ServiceDiscovery {
+	archaius.Set("servicecomb.registry.composite.backends", "fake-a, fake-b,composite")
+	archaius.Set("servicecomb.registry.composite.policy", policy)
+	archaius.Set("servicecomb.registry.composite.staleAfter", "200ms")
+	sd := NewServiceDiscovery(registry.Options{}).(*ServiceDiscovery)
+	assert.Equal(t, 2, len(sd.backends))
+	return sd
+}
+
+func TestUnion(t *testing.T) {
+	registry.EnableRegistryCache()
+	sd := newComposite(t, PolicyUnion)
+	a, b := fakes["fake-a"], fakes["fake-b"]
+	sd.AutoSync()
+	assert.True(t, a.synced && b.synced)
+	a.set("orders", instance("1", "10.0.0.1:8080"), instance("2", "10.0.0.2:8080"))
+	b.set("orders", instance("3", "10.0.0.2:8080"), instance("4", "10.0.0.3:8080"))
+
+	t.Run("merge and de-duplicate by endpoint", func(t *testing.T) {
+		ins, err := sd.FindMicroServiceInstances("", "orders", utiltags.Tags{})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"1", "2", "4"}, ids(ins))
+		// backends do not write the global cache
+		_, ok := registry.MicroserviceInstanceIndex.Get("orders", nil)
+		assert.False(t, ok)
+		ins, err = sd.FindMicroServiceInstances("", "orders", utiltags.NewDefaultTag("2.0.0", "shop"))
+		assert.NoError(t, err)
+		assert.Nil(t, ins)
+		ms, err := sd.GetMicroService("orders")
+		assert.NoError(t, err)
+		assert.Equal(t, "orders", ms.ServiceName)
+	})
+	t.Run("failing backend", func(t *testing.T) {
+		b.fail(errors.New("connection refused"))
+		ins, err := sd.FindMicroServiceInstances("", "orders", utiltags.Tags{})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"1", "2", "4"}, ids(ins))
+		s := sd.Backends()
+		assert.True(t, s[0].Healthy)
+		assert.False(t, s[1].Healthy)
+		assert.True(t, s[1].Stale)
+		assert.Equal(t, "connection refused", s[1].Error)
+
+		// instances of the failing backend are not used once they are stale
+		time.Sleep(250 * time.Millisecond)
+		ins, err = sd.FindMicroServiceInstances("", "orders", utiltags.Tags{})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"1", "2"}, ids(ins))
+		assert.False(t, sd.Backends()[1].Stale)
+
+		a.fail(errors.New("timeout"))
+		_, err = sd.FindMicroServiceInstances("", "users", utiltags.Tags{})
+		assert.Error(t, err)
+		a.fail(nil)
+		b.fail(nil)
+		assert.False(t, sd.Backends()[1].Healthy)
+		_, err = sd.FindMicroServiceInstances("", "users", utiltags.Tags{})
+		assert.NoError(t, err)
+		assert.True(t, sd.Backends()[1].Healthy)
+	})
+	assert.NoError(t, sd.Close())
+	assert.True(t, a.closed && b.closed)
+}
+
+func TestFirstNonEmpty(t *testing.T) {
+	registry.EnableRegistryCache()
+	sd := newComposite(t, PolicyFirstNonEmpty)
+	a, b := fakes["fake-a"], fakes["fake-b"]
+	a.set("orders", instance("1", "10.0.0.1:8080"))
+	b.set("orders", instance("2", "10.0.0.2:8080"))
+	b.set("users", instance("3", "10.0.0.3:8080"))
+
+	ins, err := sd.FindMicroServiceInstances("", "orders", utiltags.Tags{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1"}, ids(ins))
+	ins, err = sd.FindMicroServiceInstances("", "users", utiltags.Tags{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"3"}, ids(ins))
+	ins, err = sd.FindMicroServiceInstances("", "unknown", utiltags.Tags{})
+	assert.NoError(t, err)
+	assert.Nil(t, ins)
+}
+
+func TestPreferred(t *testing.T) {
+	registry.EnableRegistryCache()
+	sd := newComposite(t, PolicyPreferred)
+	a, b := fakes["fake-a"], fakes["fake-b"]
+	a.set("orders", instance("1", "10.0.0.1:8080"))
+	b.set("orders", instance("2", "10.0.0.2:8080"))
+	b.set("users", instance("3", "10.0.0.3:8080"))
+
+	ins, err := sd.FindMicroServiceInstances("", "orders", utiltags.Tags{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1"}, ids(ins))
+	// preferred backend is trusted even if it has no instance
+	ins, err = sd.FindMicroServiceInstances("", "users", utiltags.Tags{})
+	assert.NoError(t, err)
+	assert.Nil(t, ins)
+
+	a.fail(errors.New("connection refused"))
+	ins, err = sd.FindMicroServiceInstances("", "users", utiltags.Tags{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"3"}, ids(ins))
+	// remembered instances of preferred backend are still used
+	ins, err = sd.FindMicroServiceInstances("", "orders", utiltags.Tags{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1"}, ids(ins))
+}
+
+func TestNewRegistrator(t *testing.T) {
+	archaius.Set("servicecomb.registry.composite.backends", "fake-a")
+	archaius.Set("servicecomb.registry.composite.registrator", "")
+	// fake-a has no registrator
+	assert.IsType(t, &registry.NoopRegistrator{}, NewRegistrator(registry.Options{}))
+
+	archaius.Set("servicecomb.registry.composite.registrator", "fake-b")
+	assert.IsType(t, &fakeRegistrator{}, NewRegistrator(registry.Options{}))
+
+	archaius.Set("servicecomb.registry.composite.fake-b.address", "http://127.0.0.1:30100")
+	o, err := backendOptions("fake-b", registry.Options{Addrs: []string{"127.0.0.1:1"}}, registry.RTag)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"127.0.0.1:30100"}, o.Addrs)
+	o, err = backendOptions("fake-a", registry.Options{Addrs: []string{"127.0.0.1:1"}}, registry.RTag)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"127.0.0.1:1"}, o.Addrs)
+}
+
+func healthyMetric(backend string) float64 {
+	families, _ := metrics.GetSystemPrometheusRegistry().Gather()
+	for _, f := range families {
+		if f.GetName() != MetricsBackendHealthy {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			if m.GetLabel()[0].GetValue() == backend {
+				return m.GetGauge().GetValue()
+			}
+		}
+	}
+	return -1
+}
+
+func TestSyncHealth(t *testing.T) {
+	registry.EnableRegistryCache()
+	archaius.Set("servicecomb.registry.composite.backends", "fake-a,fake-sync")
+	archaius.Set("servicecomb.registry.composite.policy", PolicyUnion)
+	archaius.Set("servicecomb.registry.composite.staleAfter", "200ms")
+	sd := NewServiceDiscovery(registry.Options{}).(*ServiceDiscovery)
+	old := registry.DefaultServiceDiscoveryService
+	registry.DefaultServiceDiscoveryService = sd
+	defer func() { registry.DefaultServiceDiscoveryService = old }()
+	a := fakes["fake-a"]
+	a.set("orders", instance("1", "10.0.0.1:8080"))
+	syncing.set("orders", instance("2", "10.0.0.2:8080"))
+
+	ins, err := sd.FindMicroServiceInstances("", "orders", utiltags.Tags{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, ids(ins))
+	assert.True(t, Backends()[1].Healthy)
+
+	// sync fails, cache of backend is not refreshed, instances in it are used until they are stale
+	syncing.ReportSync(errors.New("registry is unreachable"))
+	assert.False(t, Backends()[1].Healthy, "sync failure is reported before lookup")
+	assert.NoError(t, sd.Healthy(), "fake-a does not sync in background")
+	syncing.set("orders", instance("2", "10.0.0.2:8080"), instance("3", "10.0.0.3:8080"))
+	ins, err = sd.FindMicroServiceInstances("", "orders", utiltags.Tags{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, ids(ins))
+	s := Backends()
+	assert.False(t, s[1].Healthy)
+	assert.True(t, s[1].Stale)
+	assert.Equal(t, "registry is unreachable", s[1].Error)
+	assert.Equal(t, float64(0), healthyMetric("fake-sync"))
+
+	time.Sleep(250 * time.Millisecond)
+	ins, err = sd.FindMicroServiceInstances("", "orders", utiltags.Tags{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1"}, ids(ins))
+
+	syncing.ReportSync(nil)
+	ins, err = sd.FindMicroServiceInstances("", "orders", utiltags.Tags{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "2", "3"}, ids(ins))
+	assert.True(t, Backends()[1].Healthy)
+	assert.Equal(t, float64(1), healthyMetric("fake-sync"))
+
+	// discovery is unhealthy once every backend fails
+	archaius.Set("servicecomb.registry.composite.backends", "fake-sync")
+	only := NewServiceDiscovery(registry.Options{}).(*ServiceDiscovery)
+	assert.NoError(t, only.Healthy())
+	syncing.ReportSync(errors.New("registry is unreachable"))
+	assert.EqualError(t, only.Healthy(), "[fake-sync] discovery sync failed: registry is unreachable")
+}
@@ -0,0 +1,231 @@
+package consul
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// errors of consul api
+var (
+	ErrNoAddress = errors.New("no consul address")
+	ErrNotFound  = errors.New("not found in consul")
+)
+
+// agentCheck is the check registered along with a service
+type agentCheck struct {
+	CheckID                        string `json:"CheckID"`
+	Name                           string `json:"Name,omitempty"`
+	TTL                            string `json:"TTL"`
+	Status                         string `json:"Status,omitempty"`
+	DeregisterCriticalServiceAfter string `json:"DeregisterCriticalServiceAfter,omitempty"`
+}
+
+// agentService is a service registered to consul agent, it is an instance of micro service
+type agentService struct {
+	ID      string            `json:"ID"`
+	Service string            `json:"Service,omitempty"`
+	Name    string            `json:"Name,omitempty"`
+	Tags    []string          `json:"Tags,omitempty"`
+	Address string            `json:"Address,omitempty"`
+	Port    int               `json:"Port,omitempty"`
+	Meta    map[string]string `json:"Meta,omitempty"`
+	Check   *agentCheck       `json:"Check,omitempty"`
+}
+
+type node struct {
+	Node       string `json:"Node"`
+	Address    string `json:"Address"`
+	Datacenter string `json:"Datacenter"`
+}
+
+type healthCheck struct {
+	CheckID   string `json:"CheckID"`
+	Status    string `json:"Status"`
+	ServiceID string `json:"ServiceID"`
+}
+
+// serviceEntry is an element of health service api response
+type serviceEntry struct {
+	Node    *node          `json:"Node"`
+	Service *agentService  `json:"Service"`
+	Checks  []*healthCheck `json:"Checks"`
+}
+
+type kvPair struct {
+	Key   string `json:"Key"`
+	Value []byte `json:"Value"`
+}
+
+// check status of consul, warning is regarded as passing
+const (
+	checkPassing  = "passing"
+	checkCritical = "critical"
+)
+
+// client calls consul http api, addresses are tried in turn until one responds
+type client struct {
+	addrs  []string
+	scheme string
+	token  string
+	http   *http.Client
+	next   uint32
+}
+
+func newClient(addrs []string, tlsConfig *tls.Config, token string, timeout time.Duration) *client {
+	c := &client{addrs: addrs, scheme: "http", token: token, http: &http.Client{}}
+	if tlsConfig != nil {
+		c.scheme = "https"
+		c.http.Transport = &http.Transport{TLSClientConfig: tlsConfig}
+	}
+	c.http.Timeout = timeout
+	return c
+}
+
+// do sends a request, response body is decoded into out if out is not nil.
+// it returns the X-Consul-Index header which is used by blocking queries
+func (c *client) do(ctx context.Context, hc *http.Client, method, path string, query url.Values, in, out interface{}) (uint64, error) {
+	if len(c.addrs) == 0 {
+		return 0, ErrNoAddress
+	}
+	var b []byte
+	if raw, ok := in.([]byte); ok {
+		b = raw
+	} else if in != nil {
+		var err error
+		if b, err = json.Marshal(in); err != nil {
+			return 0, err
+		}
+	}
+	var lastErr error
+	for i := 0; i < len(c.addrs); i++ {
+		addr := c.addrs[int(atomic.AddUint32(&c.next, 1))%len(c.addrs)]
+		u := c.scheme + "://" + addr + path
+		if len(query) != 0 {
+			u += "?" + query.Encode()
+		}
+		r, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(b))
+		if err != nil {
+			return 0, err
+		}
+		if c.token != "" {
+			r.Header.Set("X-Consul-Token", c.token)
+		}
+		resp, err := hc.Do(r)
+		if err != nil {
+			lastErr = err
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		return c.handle(resp, out)
+	}
+	return 0, lastErr
+}
+
+func (c *client) handle(resp *http.Response, out interface{}) (uint64, error) {
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return 0, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return 0, fmt.Errorf("consul error %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	index, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	if out == nil {
+		_, err := io.Copy(io.Discard, resp.Body)
+		return index, err
+	}
+	return index, json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (c *client) call(ctx context.Context, method, path string, in, out interface{}) error {
+	_, err := c.do(ctx, c.http, method, path, nil, in, out)
+	return err
+}
+
+// blocking sends a blocking query, it returns once the index changes or wait time passes
+func (c *client) blocking(ctx context.Context, path string, index uint64, wait time.Duration, out interface{}) (uint64, error) {
+	// consul adds at most wait/16 jitter to wait time
+	hc := *c.http
+	hc.Timeout = wait + wait/16 + c.http.Timeout
+	q := url.Values{}
+	q.Set("index", strconv.FormatUint(index, 10))
+	if wait > 0 {
+		q.Set("wait", fmt.Sprintf("%dms", wait.Milliseconds()))
+	}
+	return c.do(ctx, &hc, http.MethodGet, path, q, nil, out)
+}
+
+func (c *client) register(ctx context.Context, s *agentService) error {
+	return c.call(ctx, http.MethodPut, "/v1/agent/service/register", s, nil)
+}
+
+func (c *client) deregister(ctx context.Context, id string) error {
+	return c.call(ctx, http.MethodPut, "/v1/agent/service/deregister/"+url.PathEscape(id), nil, nil)
+}
+
+// agentService returns the service registered to local agent
+func (c *client) agentService(ctx context.Context, id string) (*agentService, error) {
+	s := &agentService{}
+	if err := c.call(ctx, http.MethodGet, "/v1/agent/service/"+url.PathEscape(id), nil, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (c *client) passCheck(ctx context.Context, checkID string) error {
+	return c.call(ctx, http.MethodPut, "/v1/agent/check/pass/"+url.PathEscape(checkID), nil, nil)
+}
+
+// services returns all service names in catalog
+func (c *client) services(ctx context.Context, index uint64, wait time.Duration) (map[string][]string, uint64, error) {
+	s := make(map[string][]string)
+	index, err := c.blocking(ctx, "/v1/catalog/services", index, wait, &s)
+	return s, index, err
+}
+
+// health returns all instances of a service with their checks
+func (c *client) health(ctx context.Context, name string, index uint64, wait time.Duration) ([]*serviceEntry, uint64, error) {
+	entries := make([]*serviceEntry, 0)
+	index, err := c.blocking(ctx, "/v1/health/service/"+url.PathEscape(name), index, wait, &entries)
+	return entries, index, err
+}
+
+func (c *client) kvPut(ctx context.Context, key string, value []byte) error {
+	return c.call(ctx, http.MethodPut, "/v1/kv/"+key, value, nil)
+}
+
+// kvGet returns value of the key, it returns ErrNotFound if the key does not exist
+func (c *client) kvGet(ctx context.Context, key string) ([]byte, error) {
+	pairs := make([]*kvPair, 0)
+	if err := c.call(ctx, http.MethodGet, "/v1/kv/"+key, nil, &pairs); err != nil {
+		return nil, err
+	}
+	if len(pairs) == 0 {
+		return nil, ErrNotFound
+	}
+	return pairs[0].Value, nil
+}
+
+// kvList returns all pairs with the prefix, it returns empty if there is no key
+func (c *client) kvList(ctx context.Context, prefix string) ([]*kvPair, error) {
+	pairs := make([]*kvPair, 0)
+	_, err := c.do(ctx, c.http, http.MethodGet, "/v1/kv/"+prefix, url.Values{"recurse": []string{"true"}}, nil, &pairs)
+	if errors.Is(err, ErrNotFound) {
+		return pairs, nil
+	}
+	return pairs, err
+}
@@ -0,0 +1,451 @@
+// Package consul is a registry plugin of consul,
+// instances are registered to consul agent as services with ttl checks,
+// micro services and schemas are stored in consul kv store
+package consul
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/config"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/go-chassis/v2/pkg/runtime"
+	"github.com/go-chassis/openlog"
+)
+
+// Name is the plugin name of consul registry
+const Name = "consul"
+
+// DefaultTimeout is the timeout of consul requests
+const DefaultTimeout = 10 * time.Second
+
+// keys in consul service meta which are reserved by go chassis, they are not instance metadata
+const (
+	MetaPrefix    = "chassis_"
+	MetaServiceID = MetaPrefix + "service_id"
+	MetaHostName  = MetaPrefix + "host_name"
+	MetaStatus    = MetaPrefix + "status"
+	MetaEndpoints = MetaPrefix + "endpoints"
+	MetaRegion    = MetaPrefix + "region"
+	MetaZone      = MetaPrefix + "zone"
+)
+
+// errors of consul registry
+var (
+	ErrServiceNotFound = errors.New("micro service not found in consul")
+)
+
+// consul only accepts meta keys of letters, digits, underscores and dashes
+var metaKeyRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{1,128}$`)
+
+func init() {
+	registry.InstallRegistrator(Name, NewRegistrator)
+	registry.InstallServiceDiscovery(Name, NewServiceDiscovery)
+	registry.InstallContractDiscovery(Name, NewContractDiscovery)
+}
+
+// service is the value of service key in kv store
+type service struct {
+	ServiceID   string            `json:"serviceId"`
+	AppID       string            `json:"appId"`
+	ServiceName string            `json:"serviceName"`
+	Version     string            `json:"version"`
+	Environment string            `json:"environment,omitempty"`
+	Schemas     []string          `json:"schemas,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+// keys of consul kv store
+func serviceKey(prefix, sid string) string          { return prefix + "/services/" + sid }
+func schemaPrefix(prefix, sid string) string        { return prefix + "/schemas/" + sid + "/" }
+func schemaKey(prefix, sid, schemaID string) string { return schemaPrefix(prefix, sid) + schemaID }
+
+func checkID(iid string) string { return "service:" + iid }
+
+// toMicroService converts service to registry micro service
+func (s *service) toMicroService() *registry.MicroService {
+	return &registry.MicroService{
+		ServiceID:   s.ServiceID,
+		AppID:       s.AppID,
+		ServiceName: s.ServiceName,
+		Version:     s.Version,
+		Environment: s.Environment,
+		Schemas:     s.Schemas,
+		Metadata:    s.Metadata,
+		Status:      common.DefaultStatus,
+	}
+}
+
+// toMicroServiceInstance converts a health entry to registry instance.
+// tags in key=value form and service meta become metadata, meta takes precedence;
+// instances not registered by go chassis get default version and app, and a rest endpoint of service address
+func (e *serviceEntry) toMicroServiceInstance() *registry.MicroServiceInstance {
+	s := e.Service
+	msi := &registry.MicroServiceInstance{
+		InstanceID: s.ID,
+		ServiceID:  s.Meta[MetaServiceID],
+		HostName:   s.Meta[MetaHostName],
+		Status:     s.Meta[MetaStatus],
+		Metadata:   make(map[string]string, len(s.Tags)+len(s.Meta)),
+	}
+	for _, t := range s.Tags {
+		kv := strings.SplitN(t, "=", 2)
+		if len(kv) == 2 {
+			msi.Metadata[kv[0]] = kv[1]
+		}
+	}
+	for k, v := range s.Meta {
+		if !strings.HasPrefix(k, MetaPrefix) {
+			msi.Metadata[k] = v
+		}
+	}
+	msi.ServiceName = s.Service
+	if msi.ServiceID == "" {
+		msi.ServiceID = s.Service
+	}
+	if msi.Status == "" {
+		msi.Status = common.DefaultStatus
+	}
+	for _, c := range e.Checks {
+		if c.Status == checkCritical {
+			msi.Status = runtime.StatusDown
+		}
+	}
+	if msi.Metadata[common.BuildinTagVersion] == "" {
+		msi.Metadata[common.BuildinTagVersion] = common.DefaultVersion
+	}
+	if msi.Metadata[common.BuildinTagApp] == "" {
+		msi.Metadata[common.BuildinTagApp] = common.DefaultApp
+	}
+	msi.Version = msi.Metadata[common.BuildinTagVersion]
+	msi.App = msi.Metadata[common.BuildinTagApp]
+
+	addr := s.Address
+	if e.Node != nil {
+		if addr == "" {
+			addr = e.Node.Address
+		}
+		if msi.HostName == "" {
+			msi.HostName = e.Node.Node
+		}
+	}
+	var eps []string
+	if s.Meta[MetaEndpoints] != "" {
+		eps = strings.Split(s.Meta[MetaEndpoints], ",")
+	} else if addr != "" && s.Port != 0 {
+		eps = []string{common.ProtocolRest + "://" + net.JoinHostPort(addr, strconv.Itoa(s.Port))}
+	}
+	m, p := registry.GetProtocolMap(eps)
+	msi.EndpointsMap = m
+	if len(m) != 0 {
+		msi.DefaultEndpoint = m[p].GenEndpoint()
+		msi.DefaultProtocol = p
+	}
+	if s.Meta[MetaRegion] != "" || s.Meta[MetaZone] != "" {
+		msi.DataCenterInfo = &registry.DataCenterInfo{Region: s.Meta[MetaRegion], AvailableZone: s.Meta[MetaZone]}
+		if e.Node != nil {
+			msi.DataCenterInfo.Name = e.Node.Datacenter
+		}
+	}
+	return msi
+}
+
+// Registrator registers instances to consul agent and keeps their ttl checks passing
+type Registrator struct {
+	c               *client
+	prefix          string
+	ttl             time.Duration
+	deregisterAfter time.Duration
+	keeper          registry.HeartbeatKeeper // persistent heartbeat of instances
+}
+
+// NewRegistrator returns consul registrator
+func NewRegistrator(opts registry.Options) registry.Registrator {
+	return &Registrator{
+		c:               newClient(opts.Addrs, opts.TLSConfig, config.GetRegistratorConsulToken(), timeout(opts)),
+		prefix:          config.GetRegistratorConsulPrefix(),
+		ttl:             config.GetRegistratorConsulTTL(),
+		deregisterAfter: config.GetRegistratorConsulDeregisterAfter(),
+	}
+}
+
+func timeout(opts registry.Options) time.Duration {
+	if opts.Timeout > 0 {
+		return opts.Timeout
+	}
+	return DefaultTimeout
+}
+
+func (r *Registrator) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), r.c.http.Timeout)
+}
+
+// RegisterService puts service into kv store, service id is app:name:version if it is not specified
+func (r *Registrator) RegisterService(ms *registry.MicroService) (string, error) {
+	s := &service{
+		ServiceID:   ms.ServiceID,
+		AppID:       ms.AppID,
+		ServiceName: ms.ServiceName,
+		Version:     ms.Version,
+		Environment: ms.Environment,
+		Schemas:     ms.Schemas,
+		Metadata:    ms.Metadata,
+	}
+	if s.AppID == "" {
+		s.AppID = common.DefaultApp
+	}
+	if s.Version == "" {
+		s.Version = common.DefaultVersion
+	}
+	if s.ServiceID == "" {
+		s.ServiceID = fmt.Sprintf("%s:%s:%s", s.AppID, s.ServiceName, s.Version)
+	}
+	if err := r.putJSON(serviceKey(r.prefix, s.ServiceID), s); err != nil {
+		return "", fmt.Errorf("register service [%s] failed: %w", s.ServiceName, err)
+	}
+	return s.ServiceID, nil
+}
+
+// RegisterServiceInstance registers instance to consul agent with a ttl check
+func (r *Registrator) RegisterServiceInstance(sid string, msi *registry.MicroServiceInstance) (string, error) {
+	s, err := getService(r.c, r.prefix, sid)
+	if err != nil {
+		return "", err
+	}
+	iid := msi.InstanceID
+	if iid == "" {
+		if iid, err = newID(); err != nil {
+			return "", err
+		}
+	}
+	status := msi.Status
+	if status == "" {
+		status = common.DefaultStatus
+	}
+	eps := registry.GetProtocolList(msi.EndpointsMap)
+	sort.Strings(eps)
+	as := &agentService{
+		ID:   iid,
+		Name: s.ServiceName,
+		Meta: map[string]string{
+			MetaServiceID:            sid,
+			MetaStatus:               status,
+			MetaEndpoints:            strings.Join(eps, ","),
+			common.BuildinTagVersion: s.Version,
+			common.BuildinTagApp:     s.AppID,
+		},
+	}
+	if msi.HostName != "" {
+		as.Meta[MetaHostName] = msi.HostName
+	}
+	if dc := msi.DataCenterInfo; dc != nil {
+		as.Meta[MetaRegion] = dc.Region
+		as.Meta[MetaZone] = dc.AvailableZone
+	}
+	setMetadata(as, msi.Metadata)
+	// address of default endpoint is the service address of consul, so that other consul clients can use it
+	if ep, ok := msi.EndpointsMap[msi.DefaultProtocol]; ok {
+		setAddress(as, ep.Address)
+	} else if len(eps) != 0 {
+		m, p := registry.GetProtocolMap(eps)
+		setAddress(as, m[p].Address)
+	}
+	if err := r.register(as); err != nil {
+		return "", fmt.Errorf("register instance of [%s] failed: %w", s.ServiceName, err)
+	}
+	return iid, nil
+}
+
+// setMetadata replaces instance metadata in service meta, reserved keys and keys consul does not accept are skipped
+func setMetadata(as *agentService, metadata map[string]string) {
+	for k := range as.Meta {
+		if !reserved(k) {
+			delete(as.Meta, k)
+		}
+	}
+	for k, v := range metadata {
+		if reserved(k) {
+			continue
+		}
+		if !metaKeyRegex.MatchString(k) {
+			openlog.Warn(fmt.Sprintf("metadata [%s] is not a valid consul meta key, skip it", k))
+			continue
+		}
+		as.Meta[k] = v
+	}
+}
+
+// reserved returns whether the meta key is written by go chassis, version and app are kept as tags of router
+func reserved(k string) bool {
+	return strings.HasPrefix(k, MetaPrefix) || k == common.BuildinTagVersion || k == common.BuildinTagApp
+}
+
+func setAddress(as *agentService, addr string) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return
+	}
+	as.Address = host
+	as.Port, _ = strconv.Atoi(port)
+}
+
+// register registers service along with a passing ttl check
+func (r *Registrator) register(as *agentService) error {
+	as.Check = &agentCheck{
+		CheckID:                        checkID(as.ID),
+		Name:                           "go chassis heartbeat",
+		TTL:                            r.ttl.String(),
+		Status:                         checkPassing,
+		DeregisterCriticalServiceAfter: r.deregisterAfter.String(),
+	}
+	ctx, cancel := r.context()
+	defer cancel()
+	return r.c.register(ctx, as)
+}
+
+// RegisterServiceAndInstance registers service and instance
+func (r *Registrator) RegisterServiceAndInstance(ms *registry.MicroService, msi *registry.MicroServiceInstance) (string, string, error) {
+	sid, err := r.RegisterService(ms)
+	if err != nil {
+		return "", "", err
+	}
+	iid, err := r.RegisterServiceInstance(sid, msi)
+	if err != nil {
+		return "", "", err
+	}
+	return sid, iid, nil
+}
+
+// Heartbeat passes the ttl check of instance, it returns error if the check is gone,
+// then heartbeat service registers the instance again
+func (r *Registrator) Heartbeat(microServiceID, microServiceInstanceID string) (bool, error) {
+	ctx, cancel := r.context()
+	defer cancel()
+	if err := r.c.passCheck(ctx, checkID(microServiceInstanceID)); err != nil {
+		return false, fmt.Errorf("heartbeat of instance [%s] failed: %w", microServiceInstanceID, err)
+	}
+	return true, nil
+}
+
+// WSHeartbeat passes the ttl check in background at a third of ttl,
+// callback is called to register instance again once heartbeat fails
+func (r *Registrator) WSHeartbeat(microServiceID, microServiceInstanceID string, callback func()) (bool, error) {
+	err := r.keeper.Keep(microServiceInstanceID, r.ttl/3, func() error {
+		_, err := r.Heartbeat(microServiceID, microServiceInstanceID)
+		return err
+	}, callback)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// UnRegisterMicroServiceInstance deregisters instance from consul agent
+func (r *Registrator) UnRegisterMicroServiceInstance(microServiceID, microServiceInstanceID string) error {
+	r.keeper.Stop(microServiceInstanceID)
+	ctx, cancel := r.context()
+	defer cancel()
+	if err := r.c.deregister(ctx, microServiceInstanceID); err != nil {
+		return fmt.Errorf("unregister instance [%s] failed: %w", microServiceInstanceID, err)
+	}
+	return nil
+}
+
+// UpdateMicroServiceInstanceStatus writes instance status into service meta
+func (r *Registrator) UpdateMicroServiceInstanceStatus(microServiceID, microServiceInstanceID, status string) error {
+	return r.updateInstance(microServiceInstanceID, func(as *agentService) {
+		as.Meta[MetaStatus] = status
+	})
+}
+
+// UpdateMicroServiceInstanceProperties replaces instance metadata in service meta
+func (r *Registrator) UpdateMicroServiceInstanceProperties(microServiceID, microServiceInstanceID string, properties map[string]string) error {
+	return r.updateInstance(microServiceInstanceID, func(as *agentService) {
+		setMetadata(as, properties)
+	})
+}
+
+// UpdateMicroServiceProperties replaces service metadata
+func (r *Registrator) UpdateMicroServiceProperties(microServiceID string, properties map[string]string) error {
+	s, err := getService(r.c, r.prefix, microServiceID)
+	if err != nil {
+		return err
+	}
+	s.Metadata = properties
+	return r.putJSON(serviceKey(r.prefix, microServiceID), s)
+}
+
+// AddSchemas puts schema content into kv store
+func (r *Registrator) AddSchemas(microServiceID, schemaName, schemaInfo string) error {
+	ctx, cancel := r.context()
+	defer cancel()
+	return r.c.kvPut(ctx, schemaKey(r.prefix, microServiceID, schemaName), []byte(schemaInfo))
+}
+
+// Close stops persistent heartbeats
+func (r *Registrator) Close() error {
+	r.keeper.StopAll()
+	return nil
+}
+
+// updateInstance reads service from agent, modifies it and registers it again
+func (r *Registrator) updateInstance(iid string, f func(*agentService)) error {
+	ctx, cancel := r.context()
+	defer cancel()
+	as, err := r.c.agentService(ctx, iid)
+	if err != nil {
+		return fmt.Errorf("get instance [%s] failed: %w", iid, err)
+	}
+	as.Name, as.Service = as.Service, ""
+	if as.Meta == nil {
+		as.Meta = make(map[string]string)
+	}
+	f(as)
+	return r.register(as)
+}
+
+func (r *Registrator) putJSON(key string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := r.context()
+	defer cancel()
+	return r.c.kvPut(ctx, key, b)
+}
+
+func getService(c *client, prefix, sid string) (*service, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.http.Timeout)
+	defer cancel()
+	b, err := c.kvGet(ctx, serviceKey(prefix, sid))
+	if errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrServiceNotFound, sid)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := &service{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,215 @@
+package consul
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/go-chassis/v2/pkg/runtime"
+	utiltags "github.com/go-chassis/go-chassis/v2/pkg/util/tags"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	archaius.Init(archaius.WithMemorySource())
+	runtime.App = common.DefaultApp
+}
+
+func newInstance(id string, eps ...string) *registry.MicroServiceInstance {
+	m, _ := registry.GetProtocolMap(eps)
+	return &registry.MicroServiceInstance{
+		InstanceID:      id,
+		HostName:        "host-" + id,
+		EndpointsMap:    m,
+		DefaultProtocol: common.ProtocolRest,
+		Metadata:        map[string]string{"zone": "az1", "not.valid": "x"},
+		DataCenterInfo:  &registry.DataCenterInfo{Region: "cn-north-1", AvailableZone: "az1"},
+	}
+}
+
+func TestServiceEntry_ToMicroServiceInstance(t *testing.T) {
+	e := &serviceEntry{
+		Node: &node{Node: "node-1", Address: "10.0.0.1", Datacenter: "dc1"},
+		Service: &agentService{
+			ID:      "web-1",
+			Service: "web",
+			Tags:    []string{"primary", "zone=az2", "version=2.0.0"},
+			Port:    8080,
+			Meta:    map[string]string{"zone": "az3", MetaStatus: common.DefaultStatus},
+		},
+		Checks: []*healthCheck{{Status: checkPassing}},
+	}
+	ins := e.toMicroServiceInstance()
+	assert.Equal(t, "web-1", ins.InstanceID)
+	assert.Equal(t, "web", ins.ServiceID)
+	assert.Equal(t, "node-1", ins.HostName)
+	assert.Equal(t, common.DefaultStatus, ins.Status)
+	assert.Equal(t, "az3", ins.Metadata["zone"])
+	assert.Equal(t, "2.0.0", ins.Metadata[common.BuildinTagVersion])
+	assert.Equal(t, common.DefaultApp, ins.Metadata[common.BuildinTagApp])
+	assert.NotContains(t, ins.Metadata, "primary")
+	assert.NotContains(t, ins.Metadata, MetaStatus)
+	assert.Equal(t, "10.0.0.1:8080", ins.EndpointsMap[common.ProtocolRest].Address)
+	assert.Nil(t, ins.DataCenterInfo)
+
+	e.Checks = append(e.Checks, &healthCheck{Status: checkCritical})
+	assert.Equal(t, runtime.StatusDown, e.toMicroServiceInstance().Status)
+}
+
+func TestRegistrator(t *testing.T) {
+	f := newFakeConsul()
+	defer f.Close()
+	archaius.Set("servicecomb.registry.consul.token", "secret")
+	defer archaius.Delete("servicecomb.registry.consul.token")
+	r := NewRegistrator(registry.Options{Addrs: []string{"127.0.0.1:1", f.addr()}})
+	defer r.Close()
+
+	sid, iid, err := r.RegisterServiceAndInstance(
+		&registry.MicroService{ServiceName: "orders", Version: "1.0.0", AppID: "shop"},
+		newInstance("", "rest://127.0.0.1:8080", "grpc://127.0.0.1:9090"))
+	assert.NoError(t, err)
+	assert.Equal(t, "shop:orders:1.0.0", sid)
+	assert.NotEmpty(t, iid)
+	s := f.service(iid)
+	assert.Equal(t, "orders", s.Service)
+	assert.Equal(t, "127.0.0.1", s.Address)
+	assert.Equal(t, 8080, s.Port)
+	assert.Equal(t, sid, s.Meta[MetaServiceID])
+	assert.Equal(t, "grpc://127.0.0.1:9090,rest://127.0.0.1:8080", s.Meta[MetaEndpoints])
+	assert.Equal(t, "shop", s.Meta[common.BuildinTagApp])
+	assert.Equal(t, "1.0.0", s.Meta[common.BuildinTagVersion])
+	assert.Equal(t, "az1", s.Meta["zone"])
+	assert.Equal(t, "cn-north-1", s.Meta[MetaRegion])
+	assert.NotContains(t, s.Meta, "not.valid")
+	assert.Equal(t, checkPassing, f.check(checkID(iid)))
+	assert.Contains(t, f.tokens, "secret")
+
+	_, err = r.RegisterServiceInstance("unknown", newInstance(""))
+	assert.ErrorIs(t, err, ErrServiceNotFound)
+
+	t.Run("heartbeat", func(t *testing.T) {
+		f.setCheck(checkID(iid), checkCritical)
+		ok, err := r.Heartbeat(sid, iid)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, checkPassing, f.check(checkID(iid)))
+	})
+	t.Run("update instance", func(t *testing.T) {
+		assert.NoError(t, r.UpdateMicroServiceInstanceStatus(sid, iid, runtime.StatusDown))
+		assert.NoError(t, r.UpdateMicroServiceInstanceProperties(sid, iid, map[string]string{"zone": "az2", "version": "x"}))
+		s := f.service(iid)
+		assert.Equal(t, runtime.StatusDown, s.Meta[MetaStatus])
+		assert.Equal(t, "az2", s.Meta["zone"])
+		assert.Equal(t, "1.0.0", s.Meta[common.BuildinTagVersion])
+		assert.Equal(t, sid, s.Meta[MetaServiceID])
+		assert.Equal(t, checkPassing, f.check(checkID(iid)))
+		assert.ErrorIs(t, r.UpdateMicroServiceInstanceStatus(sid, "unknown", common.DefaultStatus), ErrNotFound)
+	})
+	t.Run("service properties and schema", func(t *testing.T) {
+		assert.NoError(t, r.UpdateMicroServiceProperties(sid, map[string]string{"owner": "team"}))
+		assert.NoError(t, r.AddSchemas(sid, "order", "swagger: \"2.0\""))
+		sd := NewServiceDiscovery(registry.Options{Addrs: []string{f.addr()}})
+		ms, err := sd.GetMicroService(sid)
+		assert.NoError(t, err)
+		assert.Equal(t, "team", ms.Metadata["owner"])
+		assert.Equal(t, "swagger: \"2.0\"", string(f.value(schemaKey("servicecomb/registry", sid, "order"))))
+	})
+	t.Run("unregister", func(t *testing.T) {
+		assert.NoError(t, r.UnRegisterMicroServiceInstance(sid, iid))
+		assert.Nil(t, f.service(iid))
+		// heartbeat fails, then heartbeat service registers instance again
+		_, err := r.Heartbeat(sid, iid)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestServiceDiscovery(t *testing.T) {
+	registry.EnableRegistryCache()
+	f := newFakeConsul()
+	defer f.Close()
+	opts := registry.Options{Addrs: []string{f.addr()}}
+	r := NewRegistrator(opts)
+	defer r.Close()
+	sid, _, err := r.RegisterServiceAndInstance(
+		&registry.MicroService{ServiceName: "payments", Version: "1.0.0"},
+		newInstance("i1", "rest://127.0.0.1:8081"))
+	assert.NoError(t, err)
+	_, err = r.RegisterServiceInstance(sid, newInstance("i2", "rest://127.0.0.1:8082"))
+	assert.NoError(t, err)
+
+	sd := NewServiceDiscovery(opts)
+	defer sd.Close()
+	sd.AutoSync()
+	find := func(name string) []*registry.MicroServiceInstance {
+		ins, err := sd.FindMicroServiceInstances("", name, utiltags.NewDefaultTag("1.0.0", common.DefaultApp))
+		assert.NoError(t, err)
+		return ins
+	}
+	ins := find("payments")
+	assert.Equal(t, 2, len(ins))
+	assert.Equal(t, "az1", ins[0].Metadata["zone"])
+	assert.Equal(t, sid, ins[0].ServiceID)
+	assert.Equal(t, "dc1", ins[0].DataCenterInfo.Name)
+
+	t.Run("check critical", func(t *testing.T) {
+		f.setCheck(checkID("i1"), checkCritical)
+		assert.Eventually(t, func() bool {
+			ins := find("payments")
+			return len(ins) == 1 && ins[0].InstanceID == "i2"
+		}, 3*time.Second, 20*time.Millisecond)
+		_, err := r.Heartbeat(sid, "i1")
+		assert.NoError(t, err)
+		assert.Eventually(t, func() bool { return len(find("payments")) == 2 }, 3*time.Second, 20*time.Millisecond)
+	})
+	t.Run("status down", func(t *testing.T) {
+		assert.NoError(t, r.UpdateMicroServiceInstanceStatus(sid, "i2", runtime.StatusDown))
+		assert.Eventually(t, func() bool {
+			ins := find("payments")
+			return len(ins) == 1 && ins[0].InstanceID == "i1"
+		}, 3*time.Second, 20*time.Millisecond)
+	})
+	t.Run("new service", func(t *testing.T) {
+		_, _, err := r.RegisterServiceAndInstance(
+			&registry.MicroService{ServiceName: "refunds", Version: "1.0.0"},
+			newInstance("i3", "rest://127.0.0.1:8083"))
+		assert.NoError(t, err)
+		assert.Eventually(t, func() bool { return len(find("refunds")) == 1 }, 3*time.Second, 20*time.Millisecond)
+	})
+	t.Run("service gone", func(t *testing.T) {
+		assert.NoError(t, r.UnRegisterMicroServiceInstance(sid, "i1"))
+		assert.NoError(t, r.UnRegisterMicroServiceInstance(sid, "i2"))
+		assert.Eventually(t, func() bool {
+			_, ok := registry.MicroserviceInstanceIndex.Get("payments", nil)
+			return !ok
+		}, 3*time.Second, 20*time.Millisecond)
+		assert.Equal(t, 1, len(find("refunds")))
+	})
+}
+
+func TestContractDiscovery(t *testing.T) {
+	f := newFakeConsul()
+	defer f.Close()
+	opts := registry.Options{Addrs: []string{f.addr()}}
+	r := NewRegistrator(opts)
+	defer r.Close()
+	sid1, err := r.RegisterService(&registry.MicroService{ServiceName: "orders", Version: "1.0.0"})
+	assert.NoError(t, err)
+	sid2, err := r.RegisterService(&registry.MicroService{ServiceName: "orders", Version: "2.0.0"})
+	assert.NoError(t, err)
+	assert.NoError(t, r.AddSchemas(sid1, "order", "swagger: \"2.0\"\ninfo:\n  x-java-interface: com.shop.Order\nbasePath: /v1"))
+	assert.NoError(t, r.AddSchemas(sid2, "order", "swagger: \"2.0\"\ninfo:\n  x-java-interface: com.shop.Order\nbasePath: /v2"))
+	assert.NoError(t, r.AddSchemas(sid2, "refund", "swagger: \"2.0\"\ninfo:\n  x-java-interface: com.shop.Refund"))
+
+	cd := NewContractDiscovery(opts)
+	defer cd.Close()
+	services := cd.GetMicroServicesByInterface("com.shop.Order")
+	assert.Equal(t, 2, len(services))
+	assert.Equal(t, "1.0.0", services[0].Version)
+	assert.Equal(t, "/v1", cd.GetSchemaContentByInterface("com.shop.Order").BasePath)
+	assert.Empty(t, cd.GetSchemaContentByInterface("com.shop.None").BasePath)
+	assert.Equal(t, 2, len(cd.GetSchemaContentByServiceName("orders", "2.0.0", "", "")))
+	assert.Equal(t, 3, len(cd.GetSchemaContentByServiceName("orders", "", common.DefaultApp, "")))
+	assert.Empty(t, cd.GetSchemaContentByServiceName("orders", "", "other", ""))
+}
@@ -0,0 +1,315 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/config"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/go-chassis/v2/health"
+	utiltags "github.com/go-chassis/go-chassis/v2/pkg/util/tags"
+	"github.com/go-chassis/openlog"
+	"gopkg.in/yaml.v2"
+)
+
+// ServiceDiscovery watches consul catalog and health of every service by blocking queries
+type ServiceDiscovery struct {
+	registry.SyncHealth
+	c      *client
+	prefix string
+	wait   time.Duration
+	index  registry.CacheIndex
+
+	mu       sync.Mutex
+	watching map[string]context.CancelFunc // key is service name
+	cached   map[string]struct{}           // service names in instance cache
+
+	once   sync.Once
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewServiceDiscovery returns consul service discovery
+func NewServiceDiscovery(opts registry.Options) registry.ServiceDiscovery {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ServiceDiscovery{
+		c:        newClient(opts.Addrs, opts.TLSConfig, config.GetRegistratorConsulToken(), timeout(opts)),
+		prefix:   config.GetRegistratorConsulPrefix(),
+		wait:     config.GetServiceDiscoveryConsulWaitTime(),
+		index:    opts.InstanceIndex(),
+		watching: make(map[string]context.CancelFunc),
+		cached:   make(map[string]struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// GetMicroService returns micro service from kv store
+func (r *ServiceDiscovery) GetMicroService(microServiceID string) (*registry.MicroService, error) {
+	s, err := getService(r.c, r.prefix, microServiceID)
+	if err != nil {
+		return nil, err
+	}
+	return s.toMicroService(), nil
+}
+
+// FindMicroServiceInstances returns instances from cache, it returns nil if no instance matches the tags
+func (r *ServiceDiscovery) FindMicroServiceInstances(consumerID, microServiceName string, tags utiltags.Tags) ([]*registry.MicroServiceInstance, error) {
+	return registry.FindInstances(r.index, "consul", consumerID, microServiceName, tags), nil
+}
+
+// AutoSync caches instances of all services in catalog, then watches catalog and every service
+func (r *ServiceDiscovery) AutoSync() {
+	r.once.Do(func() {
+		ctx, cancel := context.WithTimeout(r.ctx, r.c.http.Timeout)
+		defer cancel()
+		services, index, err := r.c.services(ctx, 0, 0)
+		r.ReportSync(err)
+		if err != nil {
+			openlog.Error("list services from consul failed: " + err.Error())
+		}
+		for name := range services {
+			entries, idx, err := r.c.health(ctx, name, 0, 0)
+			if err != nil {
+				openlog.Error(fmt.Sprintf("list instances of [%s] from consul failed: %s", name, err))
+			} else {
+				r.refresh(name, entries)
+			}
+			r.watchService(name, idx)
+		}
+		r.wg.Add(1)
+		go r.watchCatalog(index)
+	})
+}
+
+// blockingLoop runs blocking queries until ctx is done, f returns the new index.
+// index is reset if it goes backwards, and query backs off once it fails
+func (r *ServiceDiscovery) blockingLoop(ctx context.Context, index uint64, f func(index uint64) (uint64, error)) {
+	var b registry.Backoff
+	for ctx.Err() == nil {
+		next, err := f(index)
+		if ctx.Err() != nil {
+			return
+		}
+		r.ReportSync(err)
+		if err != nil {
+			openlog.Warn(fmt.Sprintf("consul blocking query failed: %s", err))
+			if !b.Wait(ctx) {
+				return
+			}
+			continue
+		}
+		b.Reset()
+		if next < index {
+			next = 0
+		}
+		index = next
+	}
+}
+
+// watchCatalog starts watching new services and stops watching removed services
+func (r *ServiceDiscovery) watchCatalog(index uint64) {
+	defer r.wg.Done()
+	r.blockingLoop(r.ctx, index, func(index uint64) (uint64, error) {
+		services, next, err := r.c.services(r.ctx, index, r.wait)
+		if err != nil || next == index {
+			return next, err
+		}
+		r.mu.Lock()
+		for name, cancel := range r.watching {
+			if _, ok := services[name]; !ok {
+				cancel()
+				delete(r.watching, name)
+				r.refreshLocked(name, nil)
+			}
+		}
+		r.mu.Unlock()
+		for name := range services {
+			r.watchService(name, 0)
+		}
+		return next, nil
+	})
+}
+
+// watchService watches health of a service from index, it does nothing if the service is watched
+func (r *ServiceDiscovery) watchService(name string, index uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.watching[name]; ok || r.ctx.Err() != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(r.ctx)
+	r.watching[name] = cancel
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.blockingLoop(ctx, index, func(index uint64) (uint64, error) {
+			entries, next, err := r.c.health(ctx, name, index, r.wait)
+			if err != nil || next == index {
+				return next, err
+			}
+			r.mu.Lock()
+			if ctx.Err() == nil {
+				r.refreshLocked(name, entries)
+			}
+			r.mu.Unlock()
+			return next, nil
+		})
+	}()
+}
+
+func (r *ServiceDiscovery) refresh(name string, entries []*serviceEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.refreshLocked(name, entries)
+}
+
+// refreshLocked saves up instances of a service into cache, the cache is deleted if the service has no instance
+func (r *ServiceDiscovery) refreshLocked(name string, entries []*serviceEntry) {
+	if len(entries) == 0 {
+		if _, ok := r.cached[name]; ok {
+			r.index.Delete(name)
+			delete(r.cached, name)
+			openlog.Info(fmt.Sprintf("service [%s] has no instance in consul", name))
+		}
+		return
+	}
+	ups := make([]*registry.MicroServiceInstance, 0, len(entries))
+	downs := make(map[string]struct{})
+	for _, e := range entries {
+		if e.Service == nil {
+			continue
+		}
+		ins := e.toMicroServiceInstance()
+		if ins.Status != common.DefaultStatus && ins.Status != common.TESTINGStatus {
+			downs[ins.InstanceID] = struct{}{}
+			continue
+		}
+		ups = append(ups, ins)
+	}
+	health.RefreshCacheIndex(r.index, name, ups, downs)
+	r.cached[name] = struct{}{}
+}
+
+// Close stops all blocking queries
+func (r *ServiceDiscovery) Close() error {
+	r.cancel()
+	r.wg.Wait()
+	return nil
+}
+
+// ContractDiscovery reads services and schemas from consul kv store
+type ContractDiscovery struct {
+	c      *client
+	prefix string
+}
+
+// NewContractDiscovery returns consul contract discovery
+func NewContractDiscovery(opts registry.Options) registry.ContractDiscovery {
+	return &ContractDiscovery{
+		c:      newClient(opts.Addrs, opts.TLSConfig, config.GetRegistratorConsulToken(), timeout(opts)),
+		prefix: config.GetRegistratorConsulPrefix(),
+	}
+}
+
+// services returns all services in kv store ordered by id
+func (c *ContractDiscovery) services(ctx context.Context) []*service {
+	pairs, err := c.c.kvList(ctx, c.prefix+"/services/")
+	if err != nil {
+		openlog.Error("list services from consul failed: " + err.Error())
+		return nil
+	}
+	services := make([]*service, 0, len(pairs))
+	for _, p := range pairs {
+		s := &service{}
+		if err := json.Unmarshal(p.Value, s); err != nil {
+			openlog.Warn(fmt.Sprintf("invalid service [%s] in consul: %s", p.Key, err))
+			continue
+		}
+		services = append(services, s)
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].ServiceID < services[j].ServiceID })
+	return services
+}
+
+// schemas returns schemas of a service ordered by schema id
+func (c *ContractDiscovery) schemas(ctx context.Context, sid string) []*registry.SchemaContent {
+	pairs, err := c.c.kvList(ctx, schemaPrefix(c.prefix, sid))
+	if err != nil {
+		openlog.Error(fmt.Sprintf("list schemas of [%s] from consul failed: %s", sid, err))
+		return nil
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Key < pairs[j].Key })
+	schemas := make([]*registry.SchemaContent, 0, len(pairs))
+	for _, p := range pairs {
+		sc := &registry.SchemaContent{}
+		if err := yaml.Unmarshal(p.Value, sc); err != nil {
+			openlog.Warn(fmt.Sprintf("invalid schema [%s] in consul: %s", p.Key, err))
+			continue
+		}
+		schemas = append(schemas, sc)
+	}
+	return schemas
+}
+
+func (c *ContractDiscovery) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), c.c.http.Timeout)
+}
+
+// GetMicroServicesByInterface returns services which have schemas of the java interface
+func (c *ContractDiscovery) GetMicroServicesByInterface(interfaceName string) []*registry.MicroService {
+	ctx, cancel := c.context()
+	defer cancel()
+	services := make([]*registry.MicroService, 0)
+	for _, s := range c.services(ctx) {
+		for _, sc := range c.schemas(ctx, s.ServiceID) {
+			if sc.Info["x-java-interface"] == interfaceName {
+				services = append(services, s.toMicroService())
+				break
+			}
+		}
+	}
+	return services
+}
+
+// GetSchemaContentByInterface returns the first schema of the java interface
+func (c *ContractDiscovery) GetSchemaContentByInterface(interfaceName string) registry.SchemaContent {
+	ctx, cancel := c.context()
+	defer cancel()
+	for _, s := range c.services(ctx) {
+		for _, sc := range c.schemas(ctx, s.ServiceID) {
+			if sc.Info["x-java-interface"] == interfaceName {
+				return *sc
+			}
+		}
+	}
+	return registry.SchemaContent{}
+}
+
+// GetSchemaContentByServiceName returns schemas of services, empty version, app or env matches all
+func (c *ContractDiscovery) GetSchemaContentByServiceName(svcName, version, appID, env string) []*registry.SchemaContent {
+	ctx, cancel := c.context()
+	defer cancel()
+	schemas := make([]*registry.SchemaContent, 0)
+	for _, s := range c.services(ctx) {
+		if s.ServiceName != svcName ||
+			(version != "" && s.Version != version) ||
+			(appID != "" && s.AppID != appID) ||
+			(env != "" && s.Environment != env) {
+			continue
+		}
+		schemas = append(schemas, c.schemas(ctx, s.ServiceID)...)
+	}
+	return schemas
+}
+
+// Close does nothing
+func (c *ContractDiscovery) Close() error {
+	return nil
+}
@@ -0,0 +1,230 @@
+package consul
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeConsul is a stand-in of consul http api, it supports the apis used by the plugin
+type fakeConsul struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	index    uint64
+	changed  chan struct{} // closed on every change to wake up blocking queries
+	services map[string]*agentService
+	checks   map[string]string // status of check
+	kv       map[string][]byte
+	tokens   []string
+}
+
+func newFakeConsul() *fakeConsul {
+	f := &fakeConsul{
+		index:    1,
+		changed:  make(chan struct{}),
+		services: make(map[string]*agentService),
+		checks:   make(map[string]string),
+		kv:       make(map[string][]byte),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/agent/service/register", f.register)
+	mux.HandleFunc("/v1/agent/service/deregister/", f.deregister)
+	mux.HandleFunc("/v1/agent/service/", f.agentService)
+	mux.HandleFunc("/v1/agent/check/pass/", f.pass)
+	mux.HandleFunc("/v1/catalog/services", f.catalog)
+	mux.HandleFunc("/v1/health/service/", f.health)
+	mux.HandleFunc("/v1/kv/", f.kvHandler)
+	f.Server = httptest.NewServer(mux)
+	return f
+}
+
+func (f *fakeConsul) addr() string {
+	return strings.TrimPrefix(f.URL, "http://")
+}
+
+// changeLocked increases index and wakes up blocking queries
+func (f *fakeConsul) changeLocked() {
+	f.index++
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+// setCheck sets check status, like consul does once ttl passes
+func (f *fakeConsul) setCheck(id, status string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.checks[id] = status
+	f.changeLocked()
+}
+
+func (f *fakeConsul) service(id string) *agentService {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.services[id]
+}
+
+func (f *fakeConsul) check(id string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.checks[id]
+}
+
+func (f *fakeConsul) value(key string) []byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.kv[key]
+}
+
+func (f *fakeConsul) register(w http.ResponseWriter, r *http.Request) {
+	s := &agentService{}
+	if err := json.NewDecoder(r.Body).Decode(s); err != nil || s.Name == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tokens = append(f.tokens, r.Header.Get("X-Consul-Token"))
+	if s.ID == "" {
+		s.ID = s.Name
+	}
+	s.Service, s.Name = s.Name, ""
+	if s.Check != nil {
+		status := s.Check.Status
+		if status == "" {
+			status = checkCritical
+		}
+		f.checks[s.Check.CheckID] = status
+		s.Check = nil
+	}
+	f.services[s.ID] = s
+	f.changeLocked()
+}
+
+func (f *fakeConsul) deregister(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/v1/agent/service/deregister/")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.services[id]; !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	delete(f.services, id)
+	delete(f.checks, checkID(id))
+	f.changeLocked()
+}
+
+func (f *fakeConsul) agentService(w http.ResponseWriter, r *http.Request) {
+	s := f.service(strings.TrimPrefix(r.URL.Path, "/v1/agent/service/"))
+	if s == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(s)
+}
+
+func (f *fakeConsul) pass(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/v1/agent/check/pass/")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	status, ok := f.checks[id]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, "Unknown check ID")
+		return
+	}
+	if status != checkPassing {
+		f.checks[id] = checkPassing
+		f.changeLocked()
+	}
+}
+
+// block waits until index is greater than the index of query or wait time passes
+func (f *fakeConsul) block(r *http.Request) {
+	index, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64)
+	wait, err := time.ParseDuration(r.URL.Query().Get("wait"))
+	if err != nil {
+		wait = 5 * time.Minute
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		f.mu.Lock()
+		if index == 0 || f.index > index {
+			return
+		}
+		changed := f.changed
+		f.mu.Unlock()
+		select {
+		case <-changed:
+		case <-timer.C:
+			f.mu.Lock()
+			return
+		case <-r.Context().Done():
+			f.mu.Lock()
+			return
+		}
+	}
+}
+
+func (f *fakeConsul) catalog(w http.ResponseWriter, r *http.Request) {
+	f.block(r)
+	defer f.mu.Unlock()
+	services := make(map[string][]string)
+	for _, s := range f.services {
+		services[s.Service] = append(services[s.Service], s.Tags...)
+	}
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(f.index, 10))
+	_ = json.NewEncoder(w).Encode(services)
+}
+
+func (f *fakeConsul) health(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/v1/health/service/")
+	f.block(r)
+	defer f.mu.Unlock()
+	entries := make([]*serviceEntry, 0)
+	for _, s := range f.services {
+		if s.Service != name {
+			continue
+		}
+		cp := *s
+		entries = append(entries, &serviceEntry{
+			Node:    &node{Node: "node-1", Address: "10.0.0.1", Datacenter: "dc1"},
+			Service: &cp,
+			Checks:  []*healthCheck{{CheckID: checkID(s.ID), Status: f.checks[checkID(s.ID)], ServiceID: s.ID}},
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Service.ID < entries[j].Service.ID })
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(f.index, 10))
+	_ = json.NewEncoder(w).Encode(entries)
+}
+
+func (f *fakeConsul) kvHandler(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Method == http.MethodPut {
+		b, _ := io.ReadAll(r.Body)
+		f.kv[key] = b
+		f.changeLocked()
+		_, _ = io.WriteString(w, "true")
+		return
+	}
+	pairs := make([]*kvPair, 0)
+	for k, v := range f.kv {
+		if k == key || (r.URL.Query().Get("recurse") != "" && strings.HasPrefix(k, key)) {
+			pairs = append(pairs, &kvPair{Key: k, Value: v})
+		}
+	}
+	if len(pairs) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(pairs)
+}
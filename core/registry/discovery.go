@@ -2,6 +2,7 @@ package registry
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/go-chassis/go-chassis/v2/core/config"
 	"github.com/go-chassis/go-chassis/v2/pkg/util/tags"
@@ -44,6 +45,33 @@ type ServiceDiscovery interface {
 // DefaultServiceDiscoveryService supplies service discovery
 var DefaultServiceDiscoveryService ServiceDiscovery
 
+// HealthReporter is implemented by discovery plugins which sync instances into cache in background,
+// FindMicroServiceInstances of them reads cache, so it does not fail once registry is unreachable
+type HealthReporter interface {
+	// Healthy returns the error of the last sync, it is nil if the last sync succeeded
+	Healthy() error
+}
+
+// SyncHealth records result of the last sync, discovery plugins embed it to implement HealthReporter
+type SyncHealth struct {
+	mu  sync.RWMutex
+	err error
+}
+
+// ReportSync records result of a sync
+func (h *SyncHealth) ReportSync(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.err = err
+}
+
+// Healthy returns the error of the last sync
+func (h *SyncHealth) Healthy() error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.err
+}
+
 // DefaultContractDiscoveryService supplies contract discovery
 var DefaultContractDiscoveryService ContractDiscovery
 
@@ -76,11 +104,21 @@ func enableServiceDiscovery(opts Options) error {
 	}
 
 	DefaultServiceDiscoveryService.AutoSync()
+	startSnapshot(DefaultServiceDiscoveryService)
 
 	openlog.Info(fmt.Sprintf("enable %s service discovery.", t))
 	return nil
 }
 
+// CloseServiceDiscovery stops saving discovery cache snapshot and closes default service discovery
+func CloseServiceDiscovery() error {
+	stopSnapshot()
+	if DefaultServiceDiscoveryService == nil {
+		return nil
+	}
+	return DefaultServiceDiscoveryService.Close()
+}
+
 func enableContractDiscovery(opts Options) {
 	if config.GetContractDiscoveryDisable() {
 		return
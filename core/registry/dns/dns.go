@@ -0,0 +1,288 @@
+// Package dns is a service discovery plugin which resolves services by dns,
+// SRV records are queried first, A/AAAA records with a configured port are used if there is no SRV record
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/config"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/go-chassis/v2/health"
+	"github.com/go-chassis/go-chassis/v2/pkg/runtime"
+	utiltags "github.com/go-chassis/go-chassis/v2/pkg/util/tags"
+	"github.com/go-chassis/openlog"
+)
+
+// Name is the plugin name of dns registry
+const Name = "dns"
+
+// DefaultTimeout is the timeout of a dns query
+const DefaultTimeout = 5 * time.Second
+
+// MetadataWeight is the metadata key of srv weight
+const MetadataWeight = "weight"
+
+func init() {
+	registry.InstallRegistrator(Name, registry.NewNoopRegistrator)
+	registry.InstallServiceDiscovery(Name, NewServiceDiscovery)
+}
+
+// ServiceDiscovery resolves a service once it is looked up for the first time,
+// then resolves it again when ttl of records expires
+type ServiceDiscovery struct {
+	registry.SyncHealth
+	r       *resolver
+	domain  string
+	srvName string
+	port    int
+	minTTL  time.Duration
+	maxTTL  time.Duration
+	index   registry.CacheIndex
+
+	mu       sync.Mutex
+	services map[string]*resolving
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// resolving is the state of a resolved service
+type resolving struct {
+	ready chan struct{}       // closed once the first resolving is done
+	ids   map[string]struct{} // instances in cache
+}
+
+// NewServiceDiscovery returns dns service discovery, registry addresses are dns servers,
+// name servers in /etc/resolv.conf are used if there is no address
+func NewServiceDiscovery(opts registry.Options) registry.ServiceDiscovery {
+	t := opts.Timeout
+	if t <= 0 {
+		t = DefaultTimeout
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ServiceDiscovery{
+		r:        newResolver(opts.Addrs, t),
+		domain:   strings.Trim(config.GetServiceDiscoveryDNSDomain(), "."),
+		srvName:  config.GetServiceDiscoveryDNSSRVName(),
+		port:     config.GetServiceDiscoveryDNSPort(),
+		minTTL:   config.GetServiceDiscoveryDNSMinTTL(),
+		maxTTL:   config.GetServiceDiscoveryDNSMaxTTL(),
+		index:    opts.InstanceIndex(),
+		services: make(map[string]*resolving),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// GetMicroService returns a micro service of the name, service id of dns registry is service name
+func (r *ServiceDiscovery) GetMicroService(microServiceID string) (*registry.MicroService, error) {
+	return &registry.MicroService{
+		ServiceID:   microServiceID,
+		ServiceName: microServiceID,
+		AppID:       runtime.App,
+		Version:     common.DefaultVersion,
+		Status:      common.DefaultStatus,
+	}, nil
+}
+
+// FindMicroServiceInstances returns instances from cache, the service is resolved if it is looked up for the first time.
+// it returns nil if no instance matches the tags
+func (r *ServiceDiscovery) FindMicroServiceInstances(consumerID, microServiceName string, tags utiltags.Tags) ([]*registry.MicroServiceInstance, error) {
+	r.watch(microServiceName)
+	return registry.FindInstances(r.index, "dns", consumerID, microServiceName, tags), nil
+}
+
+// AutoSync does nothing, services are resolved on demand
+func (r *ServiceDiscovery) AutoSync() {}
+
+// watch resolves a service and keeps resolving it in background, it returns once the first resolving is done
+func (r *ServiceDiscovery) watch(name string) {
+	r.mu.Lock()
+	s, ok := r.services[name]
+	if !ok {
+		if r.ctx.Err() != nil {
+			r.mu.Unlock()
+			return
+		}
+		s = &resolving{ready: make(chan struct{}), ids: make(map[string]struct{})}
+		r.services[name] = s
+		r.wg.Add(1)
+		go r.run(name, s)
+	}
+	r.mu.Unlock()
+	select {
+	case <-s.ready:
+	case <-r.ctx.Done():
+	}
+}
+
+func (r *ServiceDiscovery) run(name string, s *resolving) {
+	defer r.wg.Done()
+	once := sync.Once{}
+	defer once.Do(func() { close(s.ready) })
+	for {
+		d := r.refresh(name, s)
+		once.Do(func() { close(s.ready) })
+		t := time.NewTimer(d)
+		select {
+		case <-r.ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// refresh resolves a service and saves instances into cache, it returns the interval of next resolving.
+// instances in cache are kept if resolving fails
+func (r *ServiceDiscovery) refresh(name string, s *resolving) time.Duration {
+	instances, ttl, err := r.resolve(name)
+	r.ReportSync(err)
+	if err != nil {
+		openlog.Warn(fmt.Sprintf("resolve [%s] failed: %s", name, err))
+		return r.minTTL
+	}
+	ids := make(map[string]struct{}, len(instances))
+	for _, ins := range instances {
+		ids[ins.InstanceID] = struct{}{}
+	}
+	if len(instances) == 0 {
+		if len(s.ids) != 0 {
+			r.index.Delete(name)
+			openlog.Info(fmt.Sprintf("service [%s] has no record in dns", name))
+		}
+	} else {
+		downs := make(map[string]struct{})
+		for id := range s.ids {
+			if _, ok := ids[id]; !ok {
+				downs[id] = struct{}{}
+			}
+		}
+		health.RefreshCacheIndex(r.index, name, instances, downs)
+	}
+	s.ids = ids
+	d := time.Duration(ttl) * time.Second
+	if d < r.minTTL {
+		d = r.minTTL
+	}
+	if d > r.maxTTL {
+		d = r.maxTTL
+	}
+	return d
+}
+
+// name returns the absolute name of a service
+func (r *ServiceDiscovery) name(service string) string {
+	if r.domain == "" {
+		return fqdn(service)
+	}
+	return fqdn(service + "." + r.domain)
+}
+
+// resolve returns instances of a service and min ttl of records
+func (r *ServiceDiscovery) resolve(service string) ([]*registry.MicroServiceInstance, uint32, error) {
+	ctx, cancel := context.WithCancel(r.ctx)
+	defer cancel()
+	if r.srvName != "" {
+		instances, ttl, err := r.resolveSRV(ctx, service)
+		if err != nil || len(instances) != 0 {
+			return instances, ttl, err
+		}
+	}
+	ips, ttl, err := r.r.lookupIP(ctx, r.name(service))
+	if err != nil {
+		return nil, 0, err
+	}
+	instances := make([]*registry.MicroServiceInstance, 0, len(ips))
+	for _, ip := range ips {
+		instances = append(instances, newInstance(service, service, ip, r.port, nil))
+	}
+	sortInstances(instances)
+	return instances, ttl, nil
+}
+
+// resolveSRV resolves srv records, only targets of the lowest priority are used.
+// target addresses are read from additional section, or resolved by A/AAAA records
+func (r *ServiceDiscovery) resolveSRV(ctx context.Context, service string) ([]*registry.MicroServiceInstance, uint32, error) {
+	a, err := r.r.lookupSRV(ctx, r.name(strings.ReplaceAll(r.srvName, "{service}", service)))
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(a.srvs) == 0 {
+		return nil, 0, nil
+	}
+	priority := a.srvs[0].priority
+	for _, srv := range a.srvs {
+		if srv.priority < priority {
+			priority = srv.priority
+		}
+	}
+	ttl := a.ttl
+	instances := make([]*registry.MicroServiceInstance, 0, len(a.srvs))
+	for _, srv := range a.srvs {
+		// target "." means the service is not available
+		if srv.priority != priority || srv.target == "" {
+			continue
+		}
+		ips, ok := a.ips[srv.target]
+		if !ok {
+			var ipTTL uint32
+			if ips, ipTTL, err = r.r.lookupIP(ctx, srv.target); err != nil {
+				return nil, 0, err
+			}
+			if len(ips) != 0 && ipTTL < ttl {
+				ttl = ipTTL
+			}
+		}
+		md := map[string]string{MetadataWeight: strconv.Itoa(int(srv.weight))}
+		for _, ip := range ips {
+			instances = append(instances, newInstance(service, srv.target, ip, int(srv.port), md))
+		}
+	}
+	sortInstances(instances)
+	return instances, ttl, nil
+}
+
+// newInstance returns an instance with a rest endpoint, instance id is the address,
+// instances belong to the app of current service with default version
+func newInstance(service, host string, ip net.IP, port int, md map[string]string) *registry.MicroServiceInstance {
+	addr := net.JoinHostPort(ip.String(), strconv.Itoa(port))
+	m, p := registry.GetProtocolMap([]string{common.ProtocolRest + "://" + addr})
+	ins := &registry.MicroServiceInstance{
+		InstanceID:      addr,
+		ServiceID:       service,
+		ServiceName:     service,
+		App:             runtime.App,
+		Version:         common.DefaultVersion,
+		HostName:        host,
+		Status:          common.DefaultStatus,
+		EndpointsMap:    m,
+		DefaultProtocol: p,
+		DefaultEndpoint: m[p].GenEndpoint(),
+		Metadata:        map[string]string{common.BuildinTagVersion: common.DefaultVersion},
+	}
+	for k, v := range md {
+		ins.Metadata[k] = v
+	}
+	return ins.WithAppID(runtime.App)
+}
+
+func sortInstances(instances []*registry.MicroServiceInstance) {
+	sort.Slice(instances, func(i, j int) bool { return instances[i].InstanceID < instances[j].InstanceID })
+}
+
+// Close stops resolving
+func (r *ServiceDiscovery) Close() error {
+	r.cancel()
+	r.wg.Wait()
+	return nil
+}
@@ -0,0 +1,185 @@
+package dns
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/invocation"
+	"github.com/go-chassis/go-chassis/v2/core/loadbalancer"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/go-chassis/v2/pkg/runtime"
+	utiltags "github.com/go-chassis/go-chassis/v2/pkg/util/tags"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func init() {
+	archaius.Init(archaius.WithMemorySource())
+	runtime.App = "shop"
+}
+
+func TestResolver(t *testing.T) {
+	f := newFakeDNS()
+	defer f.Close()
+	f.setSRV("_web._tcp.example.com", 30,
+		srvRecord{target: "a.example.com", port: 8080, priority: 10, weight: 5},
+		srvRecord{target: "b.example.com", port: 8080, priority: 20})
+	f.setA("a.example.com", 20, "10.0.0.1")
+	f.setAAAA("a.example.com", 10, "fd00::1")
+	r := newResolver([]string{"", f.addr()}, time.Second)
+
+	a, err := r.lookupSRV(context.Background(), "_web._tcp.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, []srvRecord{
+		{target: "a.example.com", port: 8080, priority: 10, weight: 5},
+		{target: "b.example.com", port: 8080, priority: 20},
+	}, a.srvs)
+	assert.Equal(t, "10.0.0.1", a.ips["a.example.com"][0].String())
+	assert.Equal(t, uint32(20), a.ttl)
+
+	ips, ttl, err := r.lookupIP(context.Background(), "a.example.com.")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(ips))
+	assert.Equal(t, "fd00::1", ips[1].String())
+	assert.Equal(t, uint32(10), ttl)
+
+	ips, _, err = r.lookupIP(context.Background(), "none.example.com")
+	assert.NoError(t, err)
+	assert.Empty(t, ips)
+
+	t.Run("truncated", func(t *testing.T) {
+		f.setA("big.example.com", 30, "10.0.1.1", "10.0.1.2", "10.0.1.3", "10.0.1.4", "10.0.1.5")
+		ips, _, err := r.lookupIP(context.Background(), "big.example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, 5, len(ips))
+		assert.Equal(t, 1, f.count("tcp", "big.example.com"))
+	})
+	t.Run("one type fails", func(t *testing.T) {
+		f.setA("v4.example.com", 30, "10.0.2.1")
+		f.fail("v4.example.com", dnsmessage.TypeAAAA, dnsmessage.RCodeServerFailure)
+		ips, ttl, err := r.lookupIP(context.Background(), "v4.example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(ips))
+		assert.Equal(t, "10.0.2.1", ips[0].String())
+		assert.Equal(t, uint32(30), ttl)
+
+		f.setAAAA("v6.example.com", 30, "fd00::2")
+		f.fail("v6.example.com", dnsmessage.TypeA, dnsmessage.RCodeServerFailure)
+		ips, _, err = r.lookupIP(context.Background(), "v6.example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(ips))
+		assert.Equal(t, "fd00::2", ips[0].String())
+
+		f.fail("v6.example.com", dnsmessage.TypeAAAA, dnsmessage.RCodeRefused)
+		_, _, err = r.lookupIP(context.Background(), "v6.example.com")
+		assert.Error(t, err)
+	})
+	t.Run("no server", func(t *testing.T) {
+		_, err := (&resolver{}).lookupSRV(context.Background(), "example.com")
+		assert.ErrorIs(t, err, ErrNoServer)
+	})
+}
+
+func TestServiceDiscovery(t *testing.T) {
+	registry.EnableRegistryCache()
+	f := newFakeDNS()
+	defer f.Close()
+	archaius.Set("servicecomb.registry.dns.domain", "svc.local.")
+	archaius.Set("servicecomb.registry.dns.port", 8080)
+	archaius.Set("servicecomb.registry.dns.minTTL", "50ms")
+	sd := NewServiceDiscovery(registry.Options{Addrs: []string{f.addr()}})
+	defer sd.Close()
+	sd.AutoSync()
+
+	t.Run("srv", func(t *testing.T) {
+		f.setSRV("_orders._tcp.svc.local", 30,
+			srvRecord{target: "orders-1.svc.local", port: 9000, priority: 10, weight: 3},
+			srvRecord{target: "orders-2.svc.local", port: 9000, priority: 10, weight: 1},
+			srvRecord{target: "orders-backup.svc.local", port: 9000, priority: 20, weight: 1})
+		f.setA("orders-1.svc.local", 30, "10.0.0.1")
+		f.setA("orders-2.svc.local", 30, "10.0.0.2")
+		f.setA("orders-backup.svc.local", 30, "10.0.0.9")
+
+		ins, err := sd.FindMicroServiceInstances("", "orders", utiltags.Tags{})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(ins))
+		i := ins[0]
+		if i.InstanceID != "10.0.0.1:9000" {
+			i = ins[1]
+		}
+		assert.Equal(t, "10.0.0.1:9000", i.EndpointsMap[common.ProtocolRest].Address)
+		assert.Equal(t, "orders-1.svc.local", i.HostName)
+		assert.Equal(t, "3", i.Metadata[MetadataWeight])
+		assert.Equal(t, "shop", i.Metadata[common.BuildinTagApp])
+		assert.Equal(t, common.DefaultVersion, i.Metadata[common.BuildinTagVersion])
+
+		ins, err = sd.FindMicroServiceInstances("", "orders", utiltags.NewDefaultTag(common.DefaultVersion, "shop"))
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(ins))
+		ins, err = sd.FindMicroServiceInstances("", "orders", utiltags.NewDefaultTag("2.0.0", "shop"))
+		assert.NoError(t, err)
+		assert.Nil(t, ins)
+
+		ms, err := sd.GetMicroService("orders")
+		assert.NoError(t, err)
+		assert.Equal(t, "orders", ms.ServiceName)
+	})
+	t.Run("load balance", func(t *testing.T) {
+		registry.DefaultServiceDiscoveryService = sd
+		inv := &invocation.Invocation{MicroServiceName: "orders", RouteTags: utiltags.NewDefaultTag(common.LatestVersion, "shop")}
+		s, err := loadbalancer.BuildStrategy(inv, nil)
+		assert.NoError(t, err)
+		picked := make(map[string]bool)
+		for i := 0; i < 4; i++ {
+			ins, err := s.Pick()
+			assert.NoError(t, err)
+			picked[ins.EndpointsMap[common.ProtocolRest].Address] = true
+		}
+		assert.Equal(t, map[string]bool{"10.0.0.1:9000": true, "10.0.0.2:9000": true}, picked)
+	})
+	t.Run("fallback to A records", func(t *testing.T) {
+		f.setA("users.svc.local", 0, "10.0.1.1", "10.0.1.2")
+		f.setAAAA("users.svc.local", 0, "fd00::1")
+		ins, err := sd.FindMicroServiceInstances("", "users", utiltags.Tags{})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, len(ins))
+		for _, i := range ins {
+			assert.Contains(t, []string{"10.0.1.1:8080", "10.0.1.2:8080", "[fd00::1]:8080"}, i.EndpointsMap[common.ProtocolRest].Address)
+			assert.Equal(t, "users", i.HostName)
+		}
+	})
+	t.Run("resolve again after ttl", func(t *testing.T) {
+		f.setA("users.svc.local", 0, "10.0.1.2")
+		f.setAAAA("users.svc.local", 0)
+		assert.Eventually(t, func() bool {
+			ins, _ := sd.FindMicroServiceInstances("", "users", utiltags.Tags{})
+			return len(ins) == 1 && ins[0].InstanceID == "10.0.1.2:8080"
+		}, 3*time.Second, 20*time.Millisecond)
+
+		f.setA("users.svc.local", 0)
+		assert.Eventually(t, func() bool {
+			_, ok := registry.MicroserviceInstanceIndex.Get("users", nil)
+			return !ok
+		}, 3*time.Second, 20*time.Millisecond)
+	})
+	t.Run("unknown service", func(t *testing.T) {
+		ins, err := sd.FindMicroServiceInstances("", "unknown", utiltags.Tags{})
+		assert.NoError(t, err)
+		assert.Nil(t, ins)
+	})
+	t.Run("server fails", func(t *testing.T) {
+		h := sd.(registry.HealthReporter)
+		assert.NoError(t, h.Healthy())
+		for _, typ := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+			f.fail("users.svc.local", typ, dnsmessage.RCodeServerFailure)
+		}
+		assert.Eventually(t, func() bool { return h.Healthy() != nil }, 3*time.Second, 20*time.Millisecond)
+		for _, typ := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+			f.succeed("users.svc.local", typ)
+		}
+		assert.Eventually(t, func() bool { return h.Healthy() == nil }, 3*time.Second, 20*time.Millisecond)
+	})
+}
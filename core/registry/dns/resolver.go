@@ -0,0 +1,262 @@
+package dns
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chassis/openlog"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ErrNoServer means there is no dns server
+var ErrNoServer = errors.New("no dns server")
+
+const resolvConf = "/etc/resolv.conf"
+
+// srvRecord is a srv answer, ips are read from additional section
+type srvRecord struct {
+	target   string
+	port     uint16
+	priority uint16
+	weight   uint16
+}
+
+// answer is the result of a query, ttl is the min ttl of records
+type answer struct {
+	srvs []srvRecord
+	ips  map[string][]net.IP // key is host name
+	ttl  uint32
+}
+
+// resolver queries dns servers in turn until one responds, over udp, and over tcp if the answer is truncated
+type resolver struct {
+	servers []string
+	timeout time.Duration
+	next    uint32
+}
+
+func newResolver(servers []string, timeout time.Duration) *resolver {
+	r := &resolver{timeout: timeout}
+	for _, s := range servers {
+		if s == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(s); err != nil {
+			s = net.JoinHostPort(s, "53")
+		}
+		r.servers = append(r.servers, s)
+	}
+	if len(r.servers) == 0 {
+		r.servers = systemServers()
+	}
+	return r
+}
+
+// systemServers returns name servers in resolv.conf
+func systemServers() []string {
+	f, err := os.Open(resolvConf)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	servers := make([]string, 0)
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			servers = append(servers, net.JoinHostPort(fields[1], "53"))
+		}
+	}
+	return servers
+}
+
+// fqdn returns the absolute name of a name
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// lookupSRV returns srv records, ips of targets in additional section are returned as well
+func (r *resolver) lookupSRV(ctx context.Context, name string) (*answer, error) {
+	return r.query(ctx, name, dnsmessage.TypeSRV)
+}
+
+// lookupIP returns ipv4 and ipv6 addresses of a host,
+// failure of one type is logged and addresses of the other type are returned,
+// it fails only if both types fail
+func (r *resolver) lookupIP(ctx context.Context, host string) ([]net.IP, uint32, error) {
+	a, errA := r.query(ctx, host, dnsmessage.TypeA)
+	aaaa, errAAAA := r.query(ctx, host, dnsmessage.TypeAAAA)
+	if errA != nil && errAAAA != nil {
+		return nil, 0, errA
+	}
+	if errA != nil {
+		openlog.Warn(fmt.Sprintf("lookup A of [%s] failed: %s", host, errA))
+		a = &answer{}
+	}
+	if errAAAA != nil {
+		openlog.Warn(fmt.Sprintf("lookup AAAA of [%s] failed: %s", host, errAAAA))
+		aaaa = &answer{}
+	}
+	ips := append(a.all(), aaaa.all()...)
+	ttl := a.ttl
+	if len(a.all()) == 0 || (len(aaaa.all()) != 0 && aaaa.ttl < ttl) {
+		ttl = aaaa.ttl
+	}
+	return ips, ttl, nil
+}
+
+// all returns all addresses in answer
+func (a *answer) all() []net.IP {
+	ips := make([]net.IP, 0)
+	for _, v := range a.ips {
+		ips = append(ips, v...)
+	}
+	return ips
+}
+
+func (a *answer) minTTL(ttl uint32) {
+	if a.ttl == 0 || ttl < a.ttl {
+		a.ttl = ttl
+	}
+}
+
+// query sends a question, a name which does not exist gets an empty answer
+func (r *resolver) query(ctx context.Context, name string, t dnsmessage.Type) (*answer, error) {
+	if len(r.servers) == 0 {
+		return nil, ErrNoServer
+	}
+	n, err := dnsmessage.NewName(fqdn(name))
+	if err != nil {
+		return nil, err
+	}
+	var lastErr error
+	for i := 0; i < len(r.servers); i++ {
+		server := r.servers[int(atomic.AddUint32(&r.next, 1))%len(r.servers)]
+		m, err := r.exchange(ctx, server, n, t)
+		if err != nil {
+			lastErr = err
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		return parse(m)
+	}
+	return nil, lastErr
+}
+
+func parse(m *dnsmessage.Message) (*answer, error) {
+	a := &answer{ips: make(map[string][]net.IP)}
+	switch m.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return a, nil
+	default:
+		return nil, fmt.Errorf("dns error: %s", m.RCode)
+	}
+	for _, rr := range append(m.Answers, m.Additionals...) {
+		host := strings.TrimSuffix(rr.Header.Name.String(), ".")
+		switch b := rr.Body.(type) {
+		case *dnsmessage.SRVResource:
+			a.srvs = append(a.srvs, srvRecord{
+				target:   strings.TrimSuffix(b.Target.String(), "."),
+				port:     b.Port,
+				priority: b.Priority,
+				weight:   b.Weight,
+			})
+		case *dnsmessage.AResource:
+			a.ips[host] = append(a.ips[host], net.IP(b.A[:]))
+		case *dnsmessage.AAAAResource:
+			a.ips[host] = append(a.ips[host], net.IP(b.AAAA[:]))
+		default:
+			// CNAME and others do not count for ttl
+			continue
+		}
+		a.minTTL(rr.Header.TTL)
+	}
+	return a, nil
+}
+
+func (r *resolver) exchange(ctx context.Context, server string, name dnsmessage.Name, t dnsmessage.Type) (*dnsmessage.Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	var id [2]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+	q := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: binary.BigEndian.Uint16(id[:]), RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: t, Class: dnsmessage.ClassINET}},
+	}
+	b, err := q.Pack()
+	if err != nil {
+		return nil, err
+	}
+	m, err := roundTrip(ctx, "udp", server, b)
+	if err == nil && m.Truncated {
+		m, err = roundTrip(ctx, "tcp", server, b)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if m.ID != q.ID || len(m.Questions) != 1 || m.Questions[0].Name != name || m.Questions[0].Type != t {
+		return nil, fmt.Errorf("invalid dns response of %s from %s", name, server)
+	}
+	return m, nil
+}
+
+// roundTrip sends a packed message and reads the response, messages over tcp are prefixed by length
+func roundTrip(ctx context.Context, network, server string, b []byte) (*dnsmessage.Message, error) {
+	d := net.Dialer{}
+	conn, err := d.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	var resp []byte
+	if network == "tcp" {
+		l := make([]byte, 2)
+		binary.BigEndian.PutUint16(l, uint16(len(b)))
+		if _, err := conn.Write(append(l, b...)); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(conn, l); err != nil {
+			return nil, err
+		}
+		resp = make([]byte, binary.BigEndian.Uint16(l))
+		if _, err := io.ReadFull(conn, resp); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := conn.Write(b); err != nil {
+			return nil, err
+		}
+		resp = make([]byte, 65535)
+		n, err := conn.Read(resp)
+		if err != nil {
+			return nil, err
+		}
+		resp = resp[:n]
+	}
+	m := &dnsmessage.Message{}
+	if err := m.Unpack(resp); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,198 @@
+package dns
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// fakeDNS is an in-process dns server over udp and tcp, answers with more records than maxUDP are truncated over udp
+type fakeDNS struct {
+	udp    net.PacketConn
+	tcp    net.Listener
+	maxUDP int
+
+	mu      sync.Mutex
+	records map[string][]dnsmessage.Resource // key is name and type
+	rcodes  map[string]dnsmessage.RCode      // error code to answer, key is name and type
+	queries map[string]int                   // count of queries over network and name
+}
+
+func newFakeDNS() *fakeDNS {
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	tcp, err := net.Listen("tcp", udp.LocalAddr().String())
+	if err != nil {
+		panic(err)
+	}
+	f := &fakeDNS{udp: udp, tcp: tcp, maxUDP: 4, records: make(map[string][]dnsmessage.Resource), rcodes: make(map[string]dnsmessage.RCode), queries: make(map[string]int)}
+	go f.serveUDP()
+	go f.serveTCP()
+	return f
+}
+
+func (f *fakeDNS) addr() string {
+	return f.udp.LocalAddr().String()
+}
+
+func (f *fakeDNS) Close() {
+	_ = f.udp.Close()
+	_ = f.tcp.Close()
+}
+
+func key(name string, t dnsmessage.Type) string {
+	return strings.ToLower(fqdn(name)) + t.String()
+}
+
+func header(name string, t dnsmessage.Type, ttl uint32) dnsmessage.ResourceHeader {
+	return dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(fqdn(name)), Type: t, Class: dnsmessage.ClassINET, TTL: ttl}
+}
+
+// setA replaces A records of a name
+func (f *fakeDNS) setA(name string, ttl uint32, ips ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rs := make([]dnsmessage.Resource, 0, len(ips))
+	for _, ip := range ips {
+		a := dnsmessage.AResource{}
+		copy(a.A[:], net.ParseIP(ip).To4())
+		rs = append(rs, dnsmessage.Resource{Header: header(name, dnsmessage.TypeA, ttl), Body: &a})
+	}
+	f.records[key(name, dnsmessage.TypeA)] = rs
+}
+
+func (f *fakeDNS) setAAAA(name string, ttl uint32, ips ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rs := make([]dnsmessage.Resource, 0, len(ips))
+	for _, ip := range ips {
+		a := dnsmessage.AAAAResource{}
+		copy(a.AAAA[:], net.ParseIP(ip).To16())
+		rs = append(rs, dnsmessage.Resource{Header: header(name, dnsmessage.TypeAAAA, ttl), Body: &a})
+	}
+	f.records[key(name, dnsmessage.TypeAAAA)] = rs
+}
+
+// setSRV replaces SRV records of a name
+func (f *fakeDNS) setSRV(name string, ttl uint32, srvs ...srvRecord) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rs := make([]dnsmessage.Resource, 0, len(srvs))
+	for _, s := range srvs {
+		rs = append(rs, dnsmessage.Resource{Header: header(name, dnsmessage.TypeSRV, ttl), Body: &dnsmessage.SRVResource{
+			Priority: s.priority, Weight: s.weight, Port: s.port, Target: dnsmessage.MustNewName(fqdn(s.target)),
+		}})
+	}
+	f.records[key(name, dnsmessage.TypeSRV)] = rs
+}
+
+// fail makes queries of a name and type answered with an error code
+func (f *fakeDNS) fail(name string, t dnsmessage.Type, rcode dnsmessage.RCode) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rcodes[key(name, t)] = rcode
+}
+
+// succeed makes queries of a name and type answered with records again
+func (f *fakeDNS) succeed(name string, t dnsmessage.Type) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.rcodes, key(name, t))
+}
+
+func (f *fakeDNS) count(network, name string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.queries[network+" "+fqdn(name)]
+}
+
+// answer builds response of a query, A records of srv targets are put into additional section
+func (f *fakeDNS) answer(network string, b []byte) []byte {
+	q := &dnsmessage.Message{}
+	if err := q.Unpack(b); err != nil || len(q.Questions) != 1 {
+		return nil
+	}
+	question := q.Questions[0]
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queries[network+" "+question.Name.String()]++
+	resp := &dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: q.ID, Response: true, RecursionAvailable: true},
+		Questions: q.Questions,
+	}
+	if rcode, ok := f.rcodes[key(question.Name.String(), question.Type)]; ok {
+		resp.RCode = rcode
+		out, _ := resp.Pack()
+		return out
+	}
+	rs, ok := f.records[key(question.Name.String(), question.Type)]
+	if !ok {
+		exists := false
+		for _, t := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA, dnsmessage.TypeSRV} {
+			if _, ok := f.records[key(question.Name.String(), t)]; ok {
+				exists = true
+			}
+		}
+		if !exists {
+			resp.RCode = dnsmessage.RCodeNameError
+		}
+	}
+	resp.Answers = rs
+	for _, r := range rs {
+		if srv, ok := r.Body.(*dnsmessage.SRVResource); ok {
+			resp.Additionals = append(resp.Additionals, f.records[key(srv.Target.String(), dnsmessage.TypeA)]...)
+		}
+	}
+	if network == "udp" && len(resp.Answers) > f.maxUDP {
+		resp.Answers = resp.Answers[:f.maxUDP]
+		resp.Additionals = nil
+		resp.Truncated = true
+	}
+	out, err := resp.Pack()
+	if err != nil {
+		return nil
+	}
+	return out
+}
+
+func (f *fakeDNS) serveUDP() {
+	b := make([]byte, 65535)
+	for {
+		n, addr, err := f.udp.ReadFrom(b)
+		if err != nil {
+			return
+		}
+		if resp := f.answer("udp", b[:n]); resp != nil {
+			_, _ = f.udp.WriteTo(resp, addr)
+		}
+	}
+}
+
+func (f *fakeDNS) serveTCP() {
+	for {
+		conn, err := f.tcp.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			l := make([]byte, 2)
+			if _, err := io.ReadFull(conn, l); err != nil {
+				return
+			}
+			b := make([]byte, binary.BigEndian.Uint16(l))
+			if _, err := io.ReadFull(conn, b); err != nil {
+				return
+			}
+			resp := f.answer("tcp", b)
+			binary.BigEndian.PutUint16(l, uint16(len(resp)))
+			_, _ = conn.Write(append(l, resp...))
+		}()
+	}
+}
@@ -0,0 +1,312 @@
+package etcd
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNoAddress means there is no etcd address
+var ErrNoAddress = errors.New("no etcd address")
+
+// int64String is int64 in json, etcd gateway encodes int64 as string
+type int64String int64
+
+// MarshalJSON encodes int64 as string
+func (i int64String) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strconv.FormatInt(int64(i), 10))
+}
+
+// UnmarshalJSON accepts both string and number
+func (i *int64String) UnmarshalJSON(b []byte) error {
+	s := string(bytes.Trim(b, `"`))
+	if s == "" || s == "null" {
+		*i = 0
+		return nil
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	*i = int64String(v)
+	return nil
+}
+
+// keyValue is a key value pair of etcd, bytes are base64 encoded in json as etcd gateway requires
+type keyValue struct {
+	Key            []byte      `json:"key,omitempty"`
+	Value          []byte      `json:"value,omitempty"`
+	CreateRevision int64String `json:"create_revision,omitempty"`
+	ModRevision    int64String `json:"mod_revision,omitempty"`
+	Lease          int64String `json:"lease,omitempty"`
+}
+
+type header struct {
+	Revision int64String `json:"revision,omitempty"`
+}
+
+type putRequest struct {
+	Key   []byte      `json:"key"`
+	Value []byte      `json:"value"`
+	Lease int64String `json:"lease,omitempty"`
+}
+
+type rangeRequest struct {
+	Key      []byte `json:"key"`
+	RangeEnd []byte `json:"range_end,omitempty"`
+}
+
+type rangeResponse struct {
+	Header header      `json:"header"`
+	Kvs    []*keyValue `json:"kvs"`
+}
+
+type leaseRequest struct {
+	ID  int64String `json:"ID,omitempty"`
+	TTL int64String `json:"TTL,omitempty"`
+}
+
+type leaseResponse struct {
+	ID  int64String `json:"ID"`
+	TTL int64String `json:"TTL"`
+}
+
+type watchCreateRequest struct {
+	Key           []byte      `json:"key"`
+	RangeEnd      []byte      `json:"range_end,omitempty"`
+	StartRevision int64String `json:"start_revision,omitempty"`
+	PrevKV        bool        `json:"prev_kv,omitempty"`
+}
+
+type watchRequest struct {
+	CreateRequest *watchCreateRequest `json:"create_request"`
+}
+
+// event type of watch, PUT is omitted in json because it is the zero value
+const eventDelete = "DELETE"
+
+type event struct {
+	Type   string    `json:"type,omitempty"`
+	KV     *keyValue `json:"kv"`
+	PrevKV *keyValue `json:"prev_kv,omitempty"`
+}
+
+type watchResponse struct {
+	Header          header      `json:"header"`
+	Created         bool        `json:"created,omitempty"`
+	Canceled        bool        `json:"canceled,omitempty"`
+	CompactRevision int64String `json:"compact_revision,omitempty"`
+	Events          []*event    `json:"events,omitempty"`
+}
+
+// streamMessage wraps messages of streaming api
+type streamMessage struct {
+	Result json.RawMessage `json:"result"`
+	Error  *gatewayError   `json:"error"`
+}
+
+// gatewayError is the error returned by etcd gateway
+type gatewayError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *gatewayError) Error() string {
+	return fmt.Sprintf("etcd error %d: %s", e.Code, e.Message)
+}
+
+// client calls etcd v3 api through its grpc gateway, addresses are tried in turn until one responds
+type client struct {
+	addrs  []string
+	scheme string
+	http   *http.Client
+	next   uint32
+}
+
+func newClient(addrs []string, tlsConfig *tls.Config, timeout time.Duration) *client {
+	c := &client{addrs: addrs, scheme: "http", http: &http.Client{}}
+	if tlsConfig != nil {
+		c.scheme = "https"
+		c.http.Transport = &http.Transport{TLSClientConfig: tlsConfig}
+	}
+	c.http.Timeout = timeout
+	return c
+}
+
+// post sends request to an address, the response body must be closed by caller
+func (c *client) post(ctx context.Context, hc *http.Client, path string, req interface{}) (*http.Response, error) {
+	if len(c.addrs) == 0 {
+		return nil, ErrNoAddress
+	}
+	b, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	var lastErr error
+	for i := 0; i < len(c.addrs); i++ {
+		addr := c.addrs[int(atomic.AddUint32(&c.next, 1))%len(c.addrs)]
+		r, err := http.NewRequestWithContext(ctx, http.MethodPost, c.scheme+"://"+addr+path, bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		r.Header.Set("Content-Type", "application/json")
+		resp, err := hc.Do(r)
+		if err != nil {
+			lastErr = err
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			defer resp.Body.Close()
+			ge := &gatewayError{Code: resp.StatusCode}
+			if err := json.NewDecoder(resp.Body).Decode(ge); err != nil || ge.Message == "" {
+				ge.Message = http.StatusText(resp.StatusCode)
+			}
+			return nil, ge
+		}
+		return resp, nil
+	}
+	return nil, lastErr
+}
+
+// call sends a unary request
+func (c *client) call(ctx context.Context, path string, req, resp interface{}) error {
+	r, err := c.post(ctx, c.http, path, req)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if resp == nil {
+		_, err = io.Copy(io.Discard, r.Body)
+		return err
+	}
+	return json.NewDecoder(r.Body).Decode(resp)
+}
+
+// stream sends a streaming request, f is called with every result until it returns error or stream ends,
+// stream has no timeout, it ends when ctx is done
+func (c *client) stream(ctx context.Context, path string, req interface{}, f func(json.RawMessage) error) error {
+	hc := *c.http
+	hc.Timeout = 0
+	r, err := c.post(ctx, &hc, path, req)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	d := json.NewDecoder(r.Body)
+	for {
+		m := &streamMessage{}
+		if err := d.Decode(m); err != nil {
+			if errors.Is(err, io.EOF) {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		if m.Error != nil {
+			return m.Error
+		}
+		if err := f(m.Result); err != nil {
+			return err
+		}
+	}
+}
+
+// prefixEnd returns the range end of keys with the prefix
+func prefixEnd(prefix []byte) []byte {
+	end := make([]byte, len(prefix))
+	copy(end, prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	// prefix is all 0xff, range to the end
+	return []byte{0}
+}
+
+func (c *client) put(ctx context.Context, key string, value []byte, lease int64) error {
+	return c.call(ctx, "/v3/kv/put", &putRequest{Key: []byte(key), Value: value, Lease: int64String(lease)}, nil)
+}
+
+// get returns the key value, it returns nil if the key does not exist
+func (c *client) get(ctx context.Context, key string) (*keyValue, error) {
+	resp := &rangeResponse{}
+	if err := c.call(ctx, "/v3/kv/range", &rangeRequest{Key: []byte(key)}, resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, nil
+	}
+	return resp.Kvs[0], nil
+}
+
+// list returns all key values with the prefix and the revision of the result
+func (c *client) list(ctx context.Context, prefix string) ([]*keyValue, int64, error) {
+	resp := &rangeResponse{}
+	if err := c.call(ctx, "/v3/kv/range", &rangeRequest{Key: []byte(prefix), RangeEnd: prefixEnd([]byte(prefix))}, resp); err != nil {
+		return nil, 0, err
+	}
+	return resp.Kvs, int64(resp.Header.Revision), nil
+}
+
+func (c *client) delete(ctx context.Context, key string) error {
+	return c.call(ctx, "/v3/kv/deleterange", &rangeRequest{Key: []byte(key)}, nil)
+}
+
+func (c *client) grant(ctx context.Context, ttl time.Duration) (int64, error) {
+	resp := &leaseResponse{}
+	if err := c.call(ctx, "/v3/lease/grant", &leaseRequest{TTL: int64String(ttl / time.Second)}, resp); err != nil {
+		return 0, err
+	}
+	return int64(resp.ID), nil
+}
+
+// keepAlive renews a lease once and returns its remaining ttl, it is 0 if the lease expired
+func (c *client) keepAlive(ctx context.Context, id int64) (int64, error) {
+	var ttl int64
+	err := c.stream(ctx, "/v3/lease/keepalive", &leaseRequest{ID: int64String(id)}, func(b json.RawMessage) error {
+		resp := &leaseResponse{}
+		if err := json.Unmarshal(b, resp); err != nil {
+			return err
+		}
+		ttl = int64(resp.TTL)
+		return io.EOF
+	})
+	if errors.Is(err, io.EOF) {
+		return ttl, nil
+	}
+	return 0, err
+}
+
+func (c *client) revoke(ctx context.Context, id int64) error {
+	return c.call(ctx, "/v3/lease/revoke", &leaseRequest{ID: int64String(id)}, nil)
+}
+
+// watch watches keys with the prefix from a revision, f is called with every response,
+// it returns once the stream breaks or ctx is done
+func (c *client) watch(ctx context.Context, prefix string, rev int64, f func(*watchResponse) error) error {
+	req := &watchRequest{CreateRequest: &watchCreateRequest{
+		Key:           []byte(prefix),
+		RangeEnd:      prefixEnd([]byte(prefix)),
+		StartRevision: int64String(rev),
+		PrevKV:        true,
+	}}
+	return c.stream(ctx, "/v3/watch", req, func(b json.RawMessage) error {
+		resp := &watchResponse{}
+		if err := json.Unmarshal(b, resp); err != nil {
+			return err
+		}
+		return f(resp)
+	})
+}
@@ -0,0 +1,218 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/config"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/go-chassis/v2/health"
+	utiltags "github.com/go-chassis/go-chassis/v2/pkg/util/tags"
+	"github.com/go-chassis/openlog"
+)
+
+// errResync means watch can not continue from its revision, all instances must be listed again
+var errResync = errors.New("watch is canceled or compacted")
+
+// ServiceDiscovery watches instance keys in etcd, instance cache is refreshed by events instead of polling
+type ServiceDiscovery struct {
+	registry.SyncHealth
+	c      *client
+	prefix string
+	index  registry.CacheIndex
+
+	mu        sync.Mutex
+	instances map[string]*registry.MicroServiceInstance // key is etcd key
+	cached    map[string]struct{}                       // service names in instance cache
+
+	once   sync.Once
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewServiceDiscovery returns etcd service discovery
+func NewServiceDiscovery(opts registry.Options) registry.ServiceDiscovery {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ServiceDiscovery{
+		c:         newClient(opts.Addrs, opts.TLSConfig, timeout(opts)),
+		prefix:    config.GetRegistratorEtcdPrefix(),
+		index:     opts.InstanceIndex(),
+		instances: make(map[string]*registry.MicroServiceInstance),
+		cached:    make(map[string]struct{}),
+		ctx:       ctx,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+}
+
+// GetMicroService returns micro service from etcd
+func (r *ServiceDiscovery) GetMicroService(microServiceID string) (*registry.MicroService, error) {
+	s, err := getService(r.c, r.prefix, microServiceID)
+	if err != nil {
+		return nil, err
+	}
+	return s.toMicroService(), nil
+}
+
+// FindMicroServiceInstances returns instances from cache, it returns nil if no instance matches the tags
+func (r *ServiceDiscovery) FindMicroServiceInstances(consumerID, microServiceName string, tags utiltags.Tags) ([]*registry.MicroServiceInstance, error) {
+	return registry.FindInstances(r.index, "etcd", consumerID, microServiceName, tags), nil
+}
+
+// AutoSync lists all instances, then watches changes from the listed revision,
+// once watch breaks, instances are listed again
+func (r *ServiceDiscovery) AutoSync() {
+	r.once.Do(func() {
+		rev, err := r.resync()
+		r.ReportSync(err)
+		if err != nil {
+			openlog.Error("list instances from etcd failed: " + err.Error())
+		}
+		go r.run(rev)
+	})
+}
+
+func (r *ServiceDiscovery) run(rev int64) {
+	defer close(r.done)
+	var b registry.Backoff
+	for {
+		if rev > 0 {
+			err := r.c.watch(r.ctx, instancePrefix(r.prefix), rev+1, func(resp *watchResponse) error {
+				if resp.Canceled || resp.CompactRevision > 0 {
+					return errResync
+				}
+				// watch works, reset retry interval
+				b.Reset()
+				if len(resp.Events) != 0 {
+					rev = int64(resp.Header.Revision)
+					r.apply(resp.Events)
+				}
+				return nil
+			})
+			if r.ctx.Err() != nil {
+				return
+			}
+			openlog.Warn(fmt.Sprintf("etcd watch stopped: %v", err))
+			if !errors.Is(err, errResync) {
+				r.ReportSync(err)
+			}
+		}
+		if !b.Wait(r.ctx) {
+			return
+		}
+		var err error
+		rev, err = r.resync()
+		r.ReportSync(err)
+		if err != nil {
+			openlog.Error("list instances from etcd failed: " + err.Error())
+		}
+	}
+}
+
+// resync lists all instances, and refreshes cache of services which are changed
+func (r *ServiceDiscovery) resync() (int64, error) {
+	ctx, cancel := context.WithTimeout(r.ctx, r.c.http.Timeout)
+	defer cancel()
+	kvs, rev, err := r.c.list(ctx, instancePrefix(r.prefix))
+	if err != nil {
+		return 0, err
+	}
+	instances := make(map[string]*registry.MicroServiceInstance, len(kvs))
+	for _, kv := range kvs {
+		if ins := decodeInstance(kv); ins != nil {
+			instances[string(kv.Key)] = ins
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.instances = instances
+	services := make(map[string]struct{}, len(r.cached))
+	for name := range r.cached {
+		services[name] = struct{}{}
+	}
+	for _, ins := range instances {
+		services[ins.ServiceName] = struct{}{}
+	}
+	for name := range services {
+		r.refresh(name)
+	}
+	return rev, nil
+}
+
+// apply applies watch events to instances, and refreshes cache of services which are changed
+func (r *ServiceDiscovery) apply(events []*event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	services := make(map[string]struct{})
+	for _, e := range events {
+		if e.KV == nil {
+			continue
+		}
+		key := string(e.KV.Key)
+		if old, ok := r.instances[key]; ok {
+			services[old.ServiceName] = struct{}{}
+		}
+		if e.Type == eventDelete {
+			delete(r.instances, key)
+			continue
+		}
+		if ins := decodeInstance(e.KV); ins != nil {
+			r.instances[key] = ins
+			services[ins.ServiceName] = struct{}{}
+		}
+	}
+	for name := range services {
+		r.refresh(name)
+	}
+}
+
+// refresh saves up instances of a service into cache, the cache is deleted if the service has no instance
+func (r *ServiceDiscovery) refresh(name string) {
+	ups := make([]*registry.MicroServiceInstance, 0)
+	downs := make(map[string]struct{})
+	exist := false
+	for _, ins := range r.instances {
+		if ins.ServiceName != name {
+			continue
+		}
+		exist = true
+		if ins.Status != common.DefaultStatus && ins.Status != common.TESTINGStatus {
+			downs[ins.InstanceID] = struct{}{}
+			continue
+		}
+		ups = append(ups, ins)
+	}
+	if !exist {
+		if _, ok := r.cached[name]; ok {
+			r.index.Delete(name)
+			delete(r.cached, name)
+			openlog.Info(fmt.Sprintf("service [%s] has no instance in etcd", name))
+		}
+		return
+	}
+	health.RefreshCacheIndex(r.index, name, ups, downs)
+	r.cached[name] = struct{}{}
+}
+
+func decodeInstance(kv *keyValue) *registry.MicroServiceInstance {
+	ins := &instance{}
+	if err := json.Unmarshal(kv.Value, ins); err != nil {
+		openlog.Warn(fmt.Sprintf("invalid instance [%s] in etcd: %s", kv.Key, err))
+		return nil
+	}
+	return ins.toMicroServiceInstance()
+}
+
+// Close stops watching
+func (r *ServiceDiscovery) Close() error {
+	r.cancel()
+	// watch never starts if AutoSync is not called
+	r.once.Do(func() { close(r.done) })
+	<-r.done
+	return nil
+}
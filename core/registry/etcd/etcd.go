@@ -0,0 +1,384 @@
+// Package etcd is a registry plugin which stores services and instances in etcd,
+// it talks to etcd v3 api through the grpc gateway of etcd, so no etcd client library is needed.
+// instances are put with leases, heartbeat keeps the lease alive,
+// discovery watches instance keys and pushes changes into instance cache
+package etcd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/config"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/openlog"
+)
+
+// Name is the plugin name of etcd registry
+const Name = "etcd"
+
+// DefaultTimeout is the timeout of etcd requests
+const DefaultTimeout = 10 * time.Second
+
+// errors of etcd registry
+var (
+	ErrServiceNotFound  = errors.New("micro service not found in etcd")
+	ErrInstanceNotFound = errors.New("instance not found in etcd")
+	ErrLeaseExpired     = errors.New("instance lease expired")
+)
+
+func init() {
+	registry.InstallRegistrator(Name, NewRegistrator)
+	registry.InstallServiceDiscovery(Name, NewServiceDiscovery)
+}
+
+// service is the value of service key
+type service struct {
+	ServiceID   string            `json:"serviceId"`
+	AppID       string            `json:"appId"`
+	ServiceName string            `json:"serviceName"`
+	Version     string            `json:"version"`
+	Environment string            `json:"environment,omitempty"`
+	Schemas     []string          `json:"schemas,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+// instance is the value of instance key
+type instance struct {
+	InstanceID     string                   `json:"instanceId"`
+	ServiceID      string                   `json:"serviceId"`
+	ServiceName    string                   `json:"serviceName"`
+	AppID          string                   `json:"appId"`
+	Version        string                   `json:"version"`
+	HostName       string                   `json:"hostName,omitempty"`
+	Status         string                   `json:"status"`
+	Endpoints      []string                 `json:"endpoints"`
+	Metadata       map[string]string        `json:"metadata,omitempty"`
+	DataCenterInfo *registry.DataCenterInfo `json:"dataCenterInfo,omitempty"`
+}
+
+// keys of etcd registry, instances are grouped by service id
+func serviceKey(prefix, sid string) string       { return prefix + "/services/" + sid }
+func instancePrefix(prefix string) string        { return prefix + "/instances/" }
+func instanceKey(prefix, sid, iid string) string { return instancePrefix(prefix) + sid + "/" + iid }
+func schemaKey(prefix, sid, schemaID string) string {
+	return prefix + "/schemas/" + sid + "/" + schemaID
+}
+
+// toMicroService converts service to registry micro service
+func (s *service) toMicroService() *registry.MicroService {
+	return &registry.MicroService{
+		ServiceID:   s.ServiceID,
+		AppID:       s.AppID,
+		ServiceName: s.ServiceName,
+		Version:     s.Version,
+		Environment: s.Environment,
+		Schemas:     s.Schemas,
+		Metadata:    s.Metadata,
+		Status:      common.DefaultStatus,
+	}
+}
+
+// toMicroServiceInstance converts instance to registry instance, app and version are set to metadata
+func (ins *instance) toMicroServiceInstance() *registry.MicroServiceInstance {
+	msi := &registry.MicroServiceInstance{
+		App:            ins.AppID,
+		ServiceName:    ins.ServiceName,
+		Version:        ins.Version,
+		InstanceID:     ins.InstanceID,
+		HostName:       ins.HostName,
+		ServiceID:      ins.ServiceID,
+		Status:         ins.Status,
+		DataCenterInfo: ins.DataCenterInfo,
+		Metadata:       make(map[string]string, len(ins.Metadata)+2),
+	}
+	for k, v := range ins.Metadata {
+		msi.Metadata[k] = v
+	}
+	msi.Metadata[common.BuildinTagVersion] = ins.Version
+	m, p := registry.GetProtocolMap(ins.Endpoints)
+	msi.EndpointsMap = m
+	if len(m) != 0 {
+		msi.DefaultEndpoint = m[p].GenEndpoint()
+		msi.DefaultProtocol = p
+	}
+	return msi.WithAppID(ins.AppID)
+}
+
+// Registrator puts services and instances into etcd
+type Registrator struct {
+	c      *client
+	prefix string
+	ttl    time.Duration
+
+	mu     sync.Mutex
+	leases map[string]int64         // key is instance id
+	keeper registry.HeartbeatKeeper // persistent heartbeat of instances
+}
+
+// NewRegistrator returns etcd registrator
+func NewRegistrator(opts registry.Options) registry.Registrator {
+	return &Registrator{
+		c:      newClient(opts.Addrs, opts.TLSConfig, timeout(opts)),
+		prefix: config.GetRegistratorEtcdPrefix(),
+		ttl:    config.GetRegistratorEtcdTTL(),
+		leases: make(map[string]int64),
+	}
+}
+
+func timeout(opts registry.Options) time.Duration {
+	if opts.Timeout > 0 {
+		return opts.Timeout
+	}
+	return DefaultTimeout
+}
+
+func (r *Registrator) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), r.c.http.Timeout)
+}
+
+// RegisterService puts service, service id is app:name:version if it is not specified
+func (r *Registrator) RegisterService(ms *registry.MicroService) (string, error) {
+	s := &service{
+		ServiceID:   ms.ServiceID,
+		AppID:       ms.AppID,
+		ServiceName: ms.ServiceName,
+		Version:     ms.Version,
+		Environment: ms.Environment,
+		Schemas:     ms.Schemas,
+		Metadata:    ms.Metadata,
+	}
+	if s.AppID == "" {
+		s.AppID = common.DefaultApp
+	}
+	if s.Version == "" {
+		s.Version = common.DefaultVersion
+	}
+	if s.ServiceID == "" {
+		s.ServiceID = fmt.Sprintf("%s:%s:%s", s.AppID, s.ServiceName, s.Version)
+	}
+	if err := r.putJSON(serviceKey(r.prefix, s.ServiceID), s, 0); err != nil {
+		return "", fmt.Errorf("register service [%s] failed: %w", s.ServiceName, err)
+	}
+	return s.ServiceID, nil
+}
+
+// RegisterServiceInstance grants a lease and puts instance with it, instance expires if heartbeat stops
+func (r *Registrator) RegisterServiceInstance(sid string, msi *registry.MicroServiceInstance) (string, error) {
+	s, err := r.getService(sid)
+	if err != nil {
+		return "", err
+	}
+	iid := msi.InstanceID
+	if iid == "" {
+		if iid, err = newID(); err != nil {
+			return "", err
+		}
+	}
+	status := msi.Status
+	if status == "" {
+		status = common.DefaultStatus
+	}
+	eps := registry.GetProtocolList(msi.EndpointsMap)
+	sort.Strings(eps)
+	ins := &instance{
+		InstanceID:     iid,
+		ServiceID:      sid,
+		ServiceName:    s.ServiceName,
+		AppID:          s.AppID,
+		Version:        s.Version,
+		HostName:       msi.HostName,
+		Status:         status,
+		Endpoints:      eps,
+		Metadata:       msi.Metadata,
+		DataCenterInfo: msi.DataCenterInfo,
+	}
+	ctx, cancel := r.context()
+	defer cancel()
+	lease, err := r.c.grant(ctx, r.ttl)
+	if err != nil {
+		return "", fmt.Errorf("grant lease failed: %w", err)
+	}
+	if err := r.putJSON(instanceKey(r.prefix, sid, iid), ins, lease); err != nil {
+		return "", fmt.Errorf("register instance of [%s] failed: %w", s.ServiceName, err)
+	}
+	r.mu.Lock()
+	r.leases[iid] = lease
+	r.mu.Unlock()
+	return iid, nil
+}
+
+// RegisterServiceAndInstance registers service and instance
+func (r *Registrator) RegisterServiceAndInstance(ms *registry.MicroService, msi *registry.MicroServiceInstance) (string, string, error) {
+	sid, err := r.RegisterService(ms)
+	if err != nil {
+		return "", "", err
+	}
+	iid, err := r.RegisterServiceInstance(sid, msi)
+	if err != nil {
+		return "", "", err
+	}
+	return sid, iid, nil
+}
+
+// Heartbeat keeps the lease of instance alive, it returns ErrLeaseExpired if the lease is gone,
+// then heartbeat service registers the instance again
+func (r *Registrator) Heartbeat(microServiceID, microServiceInstanceID string) (bool, error) {
+	r.mu.Lock()
+	lease, ok := r.leases[microServiceInstanceID]
+	r.mu.Unlock()
+	if !ok {
+		return false, ErrLeaseExpired
+	}
+	ctx, cancel := r.context()
+	defer cancel()
+	ttl, err := r.c.keepAlive(ctx, lease)
+	if err != nil {
+		return false, err
+	}
+	if ttl <= 0 {
+		r.mu.Lock()
+		if r.leases[microServiceInstanceID] == lease {
+			delete(r.leases, microServiceInstanceID)
+		}
+		r.mu.Unlock()
+		return false, ErrLeaseExpired
+	}
+	return true, nil
+}
+
+// WSHeartbeat keeps the lease alive in background at a third of ttl,
+// callback is called to register instance again once heartbeat fails
+func (r *Registrator) WSHeartbeat(microServiceID, microServiceInstanceID string, callback func()) (bool, error) {
+	err := r.keeper.Keep(microServiceInstanceID, r.ttl/3, func() error {
+		_, err := r.Heartbeat(microServiceID, microServiceInstanceID)
+		return err
+	}, callback)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// UnRegisterMicroServiceInstance deletes instance and revokes its lease
+func (r *Registrator) UnRegisterMicroServiceInstance(microServiceID, microServiceInstanceID string) error {
+	r.mu.Lock()
+	lease, ok := r.leases[microServiceInstanceID]
+	delete(r.leases, microServiceInstanceID)
+	r.mu.Unlock()
+	r.keeper.Stop(microServiceInstanceID)
+	ctx, cancel := r.context()
+	defer cancel()
+	if err := r.c.delete(ctx, instanceKey(r.prefix, microServiceID, microServiceInstanceID)); err != nil {
+		return fmt.Errorf("unregister instance [%s] failed: %w", microServiceInstanceID, err)
+	}
+	if ok {
+		if err := r.c.revoke(ctx, lease); err != nil {
+			openlog.Warn(fmt.Sprintf("revoke lease of instance [%s] failed: %s", microServiceInstanceID, err))
+		}
+	}
+	return nil
+}
+
+// UpdateMicroServiceInstanceStatus writes instance status
+func (r *Registrator) UpdateMicroServiceInstanceStatus(microServiceID, microServiceInstanceID, status string) error {
+	return r.updateInstance(microServiceID, microServiceInstanceID, func(ins *instance) {
+		ins.Status = status
+	})
+}
+
+// UpdateMicroServiceInstanceProperties replaces instance metadata
+func (r *Registrator) UpdateMicroServiceInstanceProperties(microServiceID, microServiceInstanceID string, properties map[string]string) error {
+	return r.updateInstance(microServiceID, microServiceInstanceID, func(ins *instance) {
+		ins.Metadata = properties
+	})
+}
+
+// UpdateMicroServiceProperties replaces service metadata
+func (r *Registrator) UpdateMicroServiceProperties(microServiceID string, properties map[string]string) error {
+	s, err := r.getService(microServiceID)
+	if err != nil {
+		return err
+	}
+	s.Metadata = properties
+	return r.putJSON(serviceKey(r.prefix, microServiceID), s, 0)
+}
+
+// AddSchemas puts schema content
+func (r *Registrator) AddSchemas(microServiceID, schemaName, schemaInfo string) error {
+	ctx, cancel := r.context()
+	defer cancel()
+	return r.c.put(ctx, schemaKey(r.prefix, microServiceID, schemaName), []byte(schemaInfo), 0)
+}
+
+// Close stops persistent heartbeats
+func (r *Registrator) Close() error {
+	r.keeper.StopAll()
+	return nil
+}
+
+// updateInstance reads instance, modifies it and writes it back with the same lease
+func (r *Registrator) updateInstance(sid, iid string, f func(*instance)) error {
+	ctx, cancel := r.context()
+	defer cancel()
+	key := instanceKey(r.prefix, sid, iid)
+	kv, err := r.c.get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if kv == nil {
+		return fmt.Errorf("%w: %s", ErrInstanceNotFound, iid)
+	}
+	ins := &instance{}
+	if err := json.Unmarshal(kv.Value, ins); err != nil {
+		return err
+	}
+	f(ins)
+	return r.putJSON(key, ins, int64(kv.Lease))
+}
+
+func (r *Registrator) getService(sid string) (*service, error) {
+	return getService(r.c, r.prefix, sid)
+}
+
+func (r *Registrator) putJSON(key string, v interface{}, lease int64) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := r.context()
+	defer cancel()
+	return r.c.put(ctx, key, b, lease)
+}
+
+func getService(c *client, prefix, sid string) (*service, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.http.Timeout)
+	defer cancel()
+	kv, err := c.get(ctx, serviceKey(prefix, sid))
+	if err != nil {
+		return nil, err
+	}
+	if kv == nil {
+		return nil, fmt.Errorf("%w: %s", ErrServiceNotFound, sid)
+	}
+	s := &service{}
+	if err := json.Unmarshal(kv.Value, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
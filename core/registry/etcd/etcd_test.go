@@ -0,0 +1,175 @@
+package etcd
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/config"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/go-chassis/v2/pkg/runtime"
+	utiltags "github.com/go-chassis/go-chassis/v2/pkg/util/tags"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	archaius.Init(archaius.WithMemorySource())
+	runtime.App = common.DefaultApp
+}
+
+func newInstance(id, addr string) *registry.MicroServiceInstance {
+	return &registry.MicroServiceInstance{
+		InstanceID:   id,
+		HostName:     id,
+		EndpointsMap: map[string]*registry.Endpoint{common.ProtocolRest: {Address: addr}},
+		Metadata:     map[string]string{"zone": "az1"},
+	}
+}
+
+func instanceOf(t *testing.T, f *testEtcd, sid, iid string) *instance {
+	kv := f.get(instanceKey(config.DefaultEtcdPrefix, sid, iid))
+	if kv == nil {
+		return nil
+	}
+	ins := &instance{}
+	assert.NoError(t, json.Unmarshal(kv.Value, ins))
+	return ins
+}
+
+func TestPrefixEnd(t *testing.T) {
+	assert.Equal(t, []byte("/b"), prefixEnd([]byte("/a")))
+	assert.Equal(t, []byte("/b"), prefixEnd([]byte{'/', 'a', 0xff}))
+	assert.Equal(t, []byte{0}, prefixEnd([]byte{0xff}))
+}
+
+func TestRegistrator(t *testing.T) {
+	f := startEtcd(t)
+	defer f.Close()
+	r := NewRegistrator(registry.Options{Addrs: []string{"127.0.0.1:1", f.addr()}})
+	defer r.Close()
+
+	sid, iid, err := r.RegisterServiceAndInstance(
+		&registry.MicroService{ServiceName: "orders", Version: "1.0.0", AppID: "shop"},
+		newInstance("", "127.0.0.1:8080"))
+	assert.NoError(t, err)
+	assert.Equal(t, "shop:orders:1.0.0", sid)
+	assert.NotEmpty(t, iid)
+	ins := instanceOf(t, f, sid, iid)
+	assert.Equal(t, "orders", ins.ServiceName)
+	assert.Equal(t, common.DefaultStatus, ins.Status)
+	assert.Equal(t, []string{"rest://127.0.0.1:8080"}, ins.Endpoints)
+	lease := f.get(instanceKey(config.DefaultEtcdPrefix, sid, iid)).Lease
+	assert.NotZero(t, lease)
+
+	_, err = r.RegisterServiceInstance("unknown", newInstance("", "127.0.0.1:8080"))
+	assert.ErrorIs(t, err, ErrServiceNotFound)
+
+	ok, err := r.Heartbeat(sid, iid)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	t.Run("update instance keeps lease", func(t *testing.T) {
+		assert.NoError(t, r.UpdateMicroServiceInstanceStatus(sid, iid, runtime.StatusDown))
+		assert.NoError(t, r.UpdateMicroServiceInstanceProperties(sid, iid, map[string]string{"zone": "az2"}))
+		ins := instanceOf(t, f, sid, iid)
+		assert.Equal(t, runtime.StatusDown, ins.Status)
+		assert.Equal(t, "az2", ins.Metadata["zone"])
+		assert.Equal(t, lease, f.get(instanceKey(config.DefaultEtcdPrefix, sid, iid)).Lease)
+		assert.ErrorIs(t, r.UpdateMicroServiceInstanceStatus(sid, "unknown", common.DefaultStatus), ErrInstanceNotFound)
+	})
+	t.Run("service properties and schema", func(t *testing.T) {
+		assert.NoError(t, r.UpdateMicroServiceProperties(sid, map[string]string{"owner": "team"}))
+		assert.NoError(t, r.AddSchemas(sid, "order", "swagger: 2.0"))
+		sd := NewServiceDiscovery(registry.Options{Addrs: []string{f.addr()}})
+		ms, err := sd.GetMicroService(sid)
+		assert.NoError(t, err)
+		assert.Equal(t, "team", ms.Metadata["owner"])
+		assert.Equal(t, "swagger: 2.0", string(f.get(schemaKey(config.DefaultEtcdPrefix, sid, "order")).Value))
+	})
+	t.Run("lease expired", func(t *testing.T) {
+		f.expire(int64(lease))
+		assert.Nil(t, instanceOf(t, f, sid, iid))
+		_, err := r.Heartbeat(sid, iid)
+		assert.ErrorIs(t, err, ErrLeaseExpired)
+		_, err = r.Heartbeat(sid, iid)
+		assert.ErrorIs(t, err, ErrLeaseExpired)
+
+		// heartbeat service registers instance again with the same id
+		_, err = r.RegisterServiceInstance(sid, newInstance(iid, "127.0.0.1:8080"))
+		assert.NoError(t, err)
+		ok, err := r.Heartbeat(sid, iid)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+	t.Run("unregister", func(t *testing.T) {
+		lease := f.get(instanceKey(config.DefaultEtcdPrefix, sid, iid)).Lease
+		assert.NoError(t, r.UnRegisterMicroServiceInstance(sid, iid))
+		assert.Nil(t, instanceOf(t, f, sid, iid))
+		assert.False(t, f.leaseAlive(int64(lease)))
+	})
+}
+
+func TestServiceDiscovery(t *testing.T) {
+	registry.EnableRegistryCache()
+	f := startEtcd(t)
+	defer f.Close()
+	opts := registry.Options{Addrs: []string{f.addr()}}
+	r := NewRegistrator(opts)
+	defer r.Close()
+	sid, iid1, err := r.RegisterServiceAndInstance(
+		&registry.MicroService{ServiceName: "payments", Version: "1.0.0"},
+		newInstance("i1", "127.0.0.1:8081"))
+	assert.NoError(t, err)
+
+	sd := NewServiceDiscovery(opts)
+	defer sd.Close()
+	sd.AutoSync()
+	find := func() []*registry.MicroServiceInstance {
+		ins, err := sd.FindMicroServiceInstances("", "payments", utiltags.NewDefaultTag("1.0.0", common.DefaultApp))
+		assert.NoError(t, err)
+		return ins
+	}
+	ins := find()
+	assert.Equal(t, 1, len(ins))
+	assert.Equal(t, "127.0.0.1:8081", ins[0].EndpointsMap[common.ProtocolRest].Address)
+	assert.Equal(t, "az1", ins[0].Metadata["zone"])
+	assert.Equal(t, sid, ins[0].ServiceID)
+
+	_, err = r.RegisterServiceInstance(sid, newInstance("i2", "127.0.0.1:8082"))
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return len(find()) == 2 }, 3*time.Second, 20*time.Millisecond)
+
+	t.Run("instance down", func(t *testing.T) {
+		assert.NoError(t, r.UpdateMicroServiceInstanceStatus(sid, iid1, runtime.StatusDown))
+		assert.Eventually(t, func() bool {
+			ins := find()
+			return len(ins) == 1 && ins[0].InstanceID == "i2"
+		}, 3*time.Second, 20*time.Millisecond)
+	})
+	t.Run("watch breaks", func(t *testing.T) {
+		h := sd.(registry.HealthReporter)
+		assert.NoError(t, h.Healthy())
+		f.stop()
+		assert.Eventually(t, func() bool { return h.Healthy() != nil }, 3*time.Second, 20*time.Millisecond)
+		f.start()
+		assert.NoError(t, r.UpdateMicroServiceInstanceStatus(sid, iid1, common.DefaultStatus))
+		assert.Eventually(t, func() bool { return len(find()) == 2 }, 5*time.Second, 20*time.Millisecond)
+		assert.NoError(t, h.Healthy())
+	})
+	t.Run("lease expired", func(t *testing.T) {
+		f.expire(int64(f.get(instanceKey(config.DefaultEtcdPrefix, sid, "i2")).Lease))
+		assert.Eventually(t, func() bool {
+			ins := find()
+			return len(ins) == 1 && ins[0].InstanceID == "i1"
+		}, 3*time.Second, 20*time.Millisecond)
+	})
+	t.Run("all instances gone", func(t *testing.T) {
+		assert.NoError(t, r.UnRegisterMicroServiceInstance(sid, iid1))
+		assert.Eventually(t, func() bool {
+			_, ok := registry.MicroserviceInstanceIndex.Get("payments", nil)
+			return !ok
+		}, 3*time.Second, 20*time.Millisecond)
+	})
+}
@@ -0,0 +1,268 @@
+package etcd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testEtcd is a fake of etcd v3 grpc gateway, it keeps keys, leases and an event log in memory,
+// which is enough for the plugin to put, list, grant, keep alive and watch
+type testEtcd struct {
+	t      *testing.T
+	srv    *httptest.Server
+	mu     sync.Mutex
+	rev    int64
+	kvs    map[string]*keyValue
+	leases map[int64]int64
+	lease  int64
+	log    []*event
+	// changed is closed and replaced once there are new events
+	changed chan struct{}
+	// down is closed when the server is stopped, streams end and requests fail until it starts again
+	down chan struct{}
+}
+
+func startEtcd(t *testing.T) *testEtcd {
+	f := &testEtcd{
+		t:       t,
+		rev:     1,
+		kvs:     make(map[string]*keyValue),
+		leases:  make(map[int64]int64),
+		changed: make(chan struct{}),
+	}
+	f.srv = httptest.NewServer(http.HandlerFunc(f.serve))
+	f.start()
+	return f
+}
+
+// start makes a stopped server serve again, data are kept
+func (f *testEtcd) start() {
+	f.mu.Lock()
+	f.down = make(chan struct{})
+	f.mu.Unlock()
+}
+
+// stop breaks all streams and fails requests until start is called
+func (f *testEtcd) stop() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	select {
+	case <-f.down:
+	default:
+		close(f.down)
+	}
+}
+
+// Close ends streams and stops the server
+func (f *testEtcd) Close() {
+	f.stop()
+	f.srv.Close()
+}
+
+func (f *testEtcd) addr() string {
+	return strings.TrimPrefix(f.srv.URL, "http://")
+}
+
+func (f *testEtcd) get(key string) *keyValue {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.kvs[key]
+}
+
+// expire revokes a lease, etcd deletes its keys like it does once ttl passes
+func (f *testEtcd) expire(id int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.revoke(id)
+}
+
+// leaseAlive returns if the lease exists
+func (f *testEtcd) leaseAlive(id int64) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.leases[id]
+	return ok
+}
+
+func (f *testEtcd) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	down := f.down
+	f.mu.Unlock()
+	select {
+	case <-down:
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	default:
+	}
+	switch r.URL.Path {
+	case "/v3/kv/put":
+		req := &putRequest{}
+		f.decode(r, req)
+		f.mu.Lock()
+		f.rev++
+		f.put(string(req.Key), req.Value, int64(req.Lease))
+		f.notify()
+		f.mu.Unlock()
+		f.reply(w, struct{}{})
+	case "/v3/kv/range":
+		req := &rangeRequest{}
+		f.decode(r, req)
+		f.mu.Lock()
+		resp := &rangeResponse{Header: header{Revision: int64String(f.rev)}}
+		for k, kv := range f.kvs {
+			if inRange(k, req) {
+				resp.Kvs = append(resp.Kvs, kv)
+			}
+		}
+		f.mu.Unlock()
+		sort.Slice(resp.Kvs, func(i, j int) bool { return bytes.Compare(resp.Kvs[i].Key, resp.Kvs[j].Key) < 0 })
+		f.reply(w, resp)
+	case "/v3/kv/deleterange":
+		req := &rangeRequest{}
+		f.decode(r, req)
+		f.mu.Lock()
+		if _, ok := f.kvs[string(req.Key)]; ok {
+			f.rev++
+			f.delete(string(req.Key))
+			f.notify()
+		}
+		f.mu.Unlock()
+		f.reply(w, struct{}{})
+	case "/v3/lease/grant":
+		req := &leaseRequest{}
+		f.decode(r, req)
+		f.mu.Lock()
+		f.lease++
+		id := f.lease
+		f.leases[id] = int64(req.TTL)
+		f.mu.Unlock()
+		f.reply(w, &leaseResponse{ID: int64String(id), TTL: req.TTL})
+	case "/v3/lease/revoke":
+		req := &leaseRequest{}
+		f.decode(r, req)
+		f.expire(int64(req.ID))
+		f.reply(w, struct{}{})
+	case "/v3/lease/keepalive":
+		req := &leaseRequest{}
+		f.decode(r, req)
+		f.mu.Lock()
+		ttl := f.leases[int64(req.ID)]
+		f.mu.Unlock()
+		f.push(w, &leaseResponse{ID: req.ID, TTL: int64String(ttl)})
+		select {
+		case <-r.Context().Done():
+		case <-down:
+		}
+	case "/v3/watch":
+		req := &watchRequest{}
+		f.decode(r, req)
+		f.watch(w, r, req.CreateRequest, down)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// watch pushes events from the start revision until the client goes away or the server stops
+func (f *testEtcd) watch(w http.ResponseWriter, r *http.Request, req *watchCreateRequest, down chan struct{}) {
+	rr := &rangeRequest{Key: req.Key, RangeEnd: req.RangeEnd}
+	f.mu.Lock()
+	rev := f.rev
+	f.mu.Unlock()
+	f.push(w, &watchResponse{Header: header{Revision: int64String(rev)}, Created: true})
+	next := int64(req.StartRevision)
+	f.mu.Lock()
+	for {
+		resp := &watchResponse{Header: header{Revision: int64String(f.rev)}}
+		for _, e := range f.log {
+			if int64(e.KV.ModRevision) >= next && inRange(string(e.KV.Key), rr) {
+				resp.Events = append(resp.Events, e)
+			}
+		}
+		next = f.rev + 1
+		changed := f.changed
+		f.mu.Unlock()
+		if len(resp.Events) != 0 {
+			f.push(w, resp)
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-down:
+			return
+		case <-changed:
+		}
+		f.mu.Lock()
+	}
+}
+
+func (f *testEtcd) put(key string, value []byte, lease int64) {
+	kv := &keyValue{Key: []byte(key), Value: value, ModRevision: int64String(f.rev), Lease: int64String(lease)}
+	kv.CreateRevision = kv.ModRevision
+	if old, ok := f.kvs[key]; ok {
+		kv.CreateRevision = old.CreateRevision
+	}
+	f.kvs[key] = kv
+	f.log = append(f.log, &event{KV: kv})
+}
+
+func (f *testEtcd) delete(key string) {
+	old := f.kvs[key]
+	delete(f.kvs, key)
+	f.log = append(f.log, &event{Type: eventDelete, KV: &keyValue{Key: []byte(key), ModRevision: int64String(f.rev)}, PrevKV: old})
+}
+
+// revoke deletes a lease and its keys in one revision
+func (f *testEtcd) revoke(id int64) {
+	if _, ok := f.leases[id]; !ok {
+		return
+	}
+	delete(f.leases, id)
+	f.rev++
+	for k, kv := range f.kvs {
+		if int64(kv.Lease) == id {
+			f.delete(k)
+		}
+	}
+	f.notify()
+}
+
+func (f *testEtcd) notify() {
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func inRange(key string, req *rangeRequest) bool {
+	if len(req.RangeEnd) == 0 {
+		return key == string(req.Key)
+	}
+	return key >= string(req.Key) && key < string(req.RangeEnd)
+}
+
+func (f *testEtcd) decode(r *http.Request, v interface{}) {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		f.t.Error(err)
+	}
+}
+
+func (f *testEtcd) reply(w http.ResponseWriter, v interface{}) {
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		f.t.Log(err)
+	}
+}
+
+// push writes a message of streaming api
+func (f *testEtcd) push(w http.ResponseWriter, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		f.t.Error(err)
+		return
+	}
+	f.reply(w, &streamMessage{Result: b})
+	w.(http.Flusher).Flush()
+}
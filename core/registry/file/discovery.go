@@ -0,0 +1,169 @@
+package file
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/config"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/go-chassis/v2/health"
+	utiltags "github.com/go-chassis/go-chassis/v2/pkg/util/tags"
+	"github.com/go-chassis/openlog"
+)
+
+// Name is the plugin name of file registry
+const Name = "file"
+
+func init() {
+	registry.InstallRegistrator(Name, registry.NewNoopRegistrator)
+	registry.InstallServiceDiscovery(Name, NewServiceDiscovery)
+	registry.InstallContractDiscovery(Name, NewContractDiscovery)
+}
+
+// ServiceDiscovery reads services and instances from registry file
+type ServiceDiscovery struct {
+	source *source
+	index  registry.CacheIndex
+	// mu guards cached, refresh is called by AutoSync and file watcher
+	mu        sync.Mutex
+	cached    map[string]struct{}
+	closeOnce sync.Once
+}
+
+// NewServiceDiscovery returns file service discovery
+func NewServiceDiscovery(opts registry.Options) registry.ServiceDiscovery {
+	return &ServiceDiscovery{
+		source: getSource(config.GetServiceDiscoveryFilePath()),
+		index:  opts.InstanceIndex(),
+		cached: make(map[string]struct{}),
+	}
+}
+
+// GetMicroService returns micro service by id
+func (r *ServiceDiscovery) GetMicroService(microServiceID string) (*registry.MicroService, error) {
+	ms, ok := r.source.snapshot().services[microServiceID]
+	if !ok {
+		return nil, fmt.Errorf("micro service [%s] not found in registry file", microServiceID)
+	}
+	return ms, nil
+}
+
+// FindMicroServiceInstances returns instances from cache, it returns nil if no instance matches the tags
+func (r *ServiceDiscovery) FindMicroServiceInstances(consumerID, microServiceName string, tags utiltags.Tags) ([]*registry.MicroServiceInstance, error) {
+	return registry.FindInstances(r.index, "registry file", consumerID, microServiceName, tags), nil
+}
+
+// AutoSync caches all instances in registry file, then watches file changes
+func (r *ServiceDiscovery) AutoSync() {
+	r.source.onChange(r.refresh)
+	r.refresh(r.source.snapshot())
+	r.source.watch()
+}
+
+// refresh saves up instances into cache, services which are removed from file are deleted from cache
+func (r *ServiceDiscovery) refresh(s *snapshot) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name := range r.cached {
+		if _, ok := s.instances[name]; !ok {
+			r.index.Delete(name)
+			delete(r.cached, name)
+			openlog.Info(fmt.Sprintf("service [%s] is removed from registry file", name))
+		}
+	}
+	for name, instances := range s.instances {
+		ups := make([]*registry.MicroServiceInstance, 0, len(instances))
+		downs := make(map[string]struct{})
+		for _, ins := range instances {
+			if ins.Status != common.DefaultStatus && ins.Status != common.TESTINGStatus {
+				downs[ins.InstanceID] = struct{}{}
+				continue
+			}
+			ups = append(ups, ins)
+		}
+		health.RefreshCacheIndex(r.index, name, ups, downs)
+		r.cached[name] = struct{}{}
+	}
+}
+
+// Close releases registry file, it is not watched once contract discovery is closed too
+func (r *ServiceDiscovery) Close() error {
+	var err error
+	r.closeOnce.Do(func() { err = r.source.release() })
+	return err
+}
+
+// ContractDiscovery reads schemas in registry file
+type ContractDiscovery struct {
+	source    *source
+	closeOnce sync.Once
+}
+
+// NewContractDiscovery returns file contract discovery
+func NewContractDiscovery(opts registry.Options) registry.ContractDiscovery {
+	return &ContractDiscovery{source: getSource(config.GetServiceDiscoveryFilePath())}
+}
+
+// GetMicroServicesByInterface returns services which have schema of the java interface
+func (c *ContractDiscovery) GetMicroServicesByInterface(interfaceName string) []*registry.MicroService {
+	s := c.source.snapshot()
+	services := make([]*registry.MicroService, 0)
+	for _, id := range sortedIDs(s) {
+		for _, sc := range s.schemas[id] {
+			if sc.Info["x-java-interface"] == interfaceName {
+				services = append(services, s.services[id])
+				break
+			}
+		}
+	}
+	return services
+}
+
+// GetSchemaContentByInterface returns the first schema of the java interface
+func (c *ContractDiscovery) GetSchemaContentByInterface(interfaceName string) registry.SchemaContent {
+	s := c.source.snapshot()
+	for _, id := range sortedIDs(s) {
+		for _, sc := range s.schemas[id] {
+			if sc.Info["x-java-interface"] == interfaceName {
+				return *sc
+			}
+		}
+	}
+	return registry.SchemaContent{}
+}
+
+// GetSchemaContentByServiceName returns schemas of services, empty version, app or env matches all
+func (c *ContractDiscovery) GetSchemaContentByServiceName(svcName, version, appID, env string) []*registry.SchemaContent {
+	s := c.source.snapshot()
+	schemas := make([]*registry.SchemaContent, 0)
+	for _, id := range sortedIDs(s) {
+		ms := s.services[id]
+		if ms.ServiceName != svcName ||
+			(version != "" && ms.Version != version) ||
+			(appID != "" && ms.AppID != appID) ||
+			(env != "" && ms.Environment != env) {
+			continue
+		}
+		schemas = append(schemas, s.schemas[id]...)
+	}
+	return schemas
+}
+
+// Close releases registry file, it is not watched once service discovery is closed too
+func (c *ContractDiscovery) Close() error {
+	var err error
+	c.closeOnce.Do(func() { err = c.source.release() })
+	return err
+}
+
+// sortedIDs returns service ids in order, so that query result is stable
+func sortedIDs(s *snapshot) []string {
+	ids := make([]string, 0, len(s.services))
+	for id := range s.services {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,409 @@
+// Package file is a static registry plugin, services, instances and contracts are read from a yaml file,
+// the file is watched, changes are pushed into instance cache.
+// it is useful in development and CI, there is no need to run a service center
+package file
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/openlog"
+	"gopkg.in/yaml.v2"
+)
+
+// Definition is the content of registry file
+type Definition struct {
+	Services []*Service `yaml:"services"`
+}
+
+// Service is a micro service and its instances
+type Service struct {
+	ServiceID   string            `yaml:"serviceID"`
+	AppID       string            `yaml:"appID"`
+	ServiceName string            `yaml:"serviceName"`
+	Version     string            `yaml:"version"`
+	Environment string            `yaml:"environment"`
+	Metadata    map[string]string `yaml:"metadata"`
+	// Schemas maps schema id to swagger file, relative path is relative to the registry file
+	Schemas   map[string]string `yaml:"schemas"`
+	Instances []*Instance       `yaml:"instances"`
+}
+
+// Instance is a micro service instance
+type Instance struct {
+	InstanceID string `yaml:"instanceID"`
+	HostName   string `yaml:"hostName"`
+	Status     string `yaml:"status"`
+	// Endpoints are like rest://127.0.0.1:8080 or highway://127.0.0.1:6000?sslEnabled=true
+	Endpoints      []string          `yaml:"endpoints"`
+	Metadata       map[string]string `yaml:"metadata"`
+	DataCenterInfo *DataCenterInfo   `yaml:"dataCenterInfo"`
+}
+
+// DataCenterInfo is the data center of an instance
+type DataCenterInfo struct {
+	Name          string `yaml:"name"`
+	Region        string `yaml:"region"`
+	AvailableZone string `yaml:"availableZone"`
+}
+
+// snapshot is the parsed registry file
+type snapshot struct {
+	services  map[string]*registry.MicroService // key is service id
+	instances map[string][]*registry.MicroServiceInstance
+	schemas   map[string][]*registry.SchemaContent // key is service id
+}
+
+// Parse parses registry file content, default values are set to services and instances
+func Parse(b []byte) (*Definition, error) {
+	d := &Definition{}
+	if err := yaml.Unmarshal(b, d); err != nil {
+		return nil, err
+	}
+	for _, s := range d.Services {
+		if s == nil {
+			continue
+		}
+		if s.ServiceName == "" {
+			return nil, fmt.Errorf("service name is required")
+		}
+		if s.AppID == "" {
+			s.AppID = common.DefaultApp
+		}
+		if s.Version == "" {
+			s.Version = common.DefaultVersion
+		}
+		if s.ServiceID == "" {
+			s.ServiceID = fmt.Sprintf("%s:%s:%s", s.AppID, s.ServiceName, s.Version)
+		}
+		for i, ins := range s.Instances {
+			if ins == nil {
+				continue
+			}
+			if ins.InstanceID == "" {
+				ins.InstanceID = fmt.Sprintf("%s-%d", s.ServiceID, i)
+			}
+			if ins.Status == "" {
+				ins.Status = common.DefaultStatus
+			}
+		}
+	}
+	return d, nil
+}
+
+// ToMicroService converts service definition to registry micro service
+func (s *Service) ToMicroService() *registry.MicroService {
+	ms := &registry.MicroService{
+		ServiceID:   s.ServiceID,
+		AppID:       s.AppID,
+		ServiceName: s.ServiceName,
+		Version:     s.Version,
+		Environment: s.Environment,
+		Status:      common.DefaultStatus,
+		Metadata:    s.Metadata,
+	}
+	for id := range s.Schemas {
+		ms.Schemas = append(ms.Schemas, id)
+	}
+	return ms
+}
+
+// ToMicroServiceInstance converts instance definition to registry instance, app and version are set to metadata
+func (ins *Instance) ToMicroServiceInstance(s *Service) *registry.MicroServiceInstance {
+	msi := &registry.MicroServiceInstance{
+		App:         s.AppID,
+		ServiceName: s.ServiceName,
+		Version:     s.Version,
+		InstanceID:  ins.InstanceID,
+		HostName:    ins.HostName,
+		ServiceID:   s.ServiceID,
+		Status:      ins.Status,
+		Metadata:    make(map[string]string, len(ins.Metadata)+2),
+	}
+	for k, v := range ins.Metadata {
+		msi.Metadata[k] = v
+	}
+	msi.Metadata[common.BuildinTagVersion] = s.Version
+	m, p := registry.GetProtocolMap(ins.Endpoints)
+	msi.EndpointsMap = m
+	if len(m) != 0 {
+		msi.DefaultEndpoint = m[p].GenEndpoint()
+		msi.DefaultProtocol = p
+	}
+	if ins.DataCenterInfo != nil {
+		msi.DataCenterInfo = &registry.DataCenterInfo{
+			Name:          ins.DataCenterInfo.Name,
+			Region:        ins.DataCenterInfo.Region,
+			AvailableZone: ins.DataCenterInfo.AvailableZone,
+		}
+	}
+	return msi.WithAppID(s.AppID)
+}
+
+// schemaFiles reads schema files of definition, relative path is relative to dir,
+// key is the absolute path, a file which can not be read is saved as nil
+func schemaFiles(d *Definition, dir string) map[string][]byte {
+	files := make(map[string][]byte)
+	for _, svc := range d.Services {
+		if svc == nil {
+			continue
+		}
+		for _, path := range svc.Schemas {
+			path = schemaPath(dir, path)
+			if _, ok := files[path]; ok {
+				continue
+			}
+			b, err := os.ReadFile(path)
+			if err != nil {
+				b = nil
+			}
+			files[path] = b
+		}
+	}
+	return files
+}
+
+func schemaPath(dir, path string) string {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	return filepath.Clean(path)
+}
+
+// sameFiles reports if schema files have the same content
+func sameFiles(a, b map[string][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, content := range a {
+		old, ok := b[path]
+		if !ok || (old == nil) != (content == nil) || !bytes.Equal(old, content) {
+			return false
+		}
+	}
+	return true
+}
+
+// newSnapshot converts definition into registry models, files are content of schema files
+func newSnapshot(d *Definition, dir string, files map[string][]byte) *snapshot {
+	s := &snapshot{
+		services:  make(map[string]*registry.MicroService),
+		instances: make(map[string][]*registry.MicroServiceInstance),
+		schemas:   make(map[string][]*registry.SchemaContent),
+	}
+	for _, svc := range d.Services {
+		if svc == nil {
+			continue
+		}
+		s.services[svc.ServiceID] = svc.ToMicroService()
+		if _, ok := s.instances[svc.ServiceName]; !ok {
+			s.instances[svc.ServiceName] = make([]*registry.MicroServiceInstance, 0)
+		}
+		for _, ins := range svc.Instances {
+			if ins == nil {
+				continue
+			}
+			s.instances[svc.ServiceName] = append(s.instances[svc.ServiceName], ins.ToMicroServiceInstance(svc))
+		}
+		for id, path := range svc.Schemas {
+			path = schemaPath(dir, path)
+			b := files[path]
+			if b == nil {
+				openlog.Error(fmt.Sprintf("read schema [%s] of [%s] failed: %s", id, svc.ServiceName, path))
+				continue
+			}
+			sc := &registry.SchemaContent{}
+			if err := yaml.Unmarshal(b, sc); err != nil {
+				openlog.Error(fmt.Sprintf("parse schema [%s] of [%s] failed: %s", id, svc.ServiceName, err))
+				continue
+			}
+			s.schemas[svc.ServiceID] = append(s.schemas[svc.ServiceID], sc)
+		}
+	}
+	return s
+}
+
+// source loads registry file and reloads it once the file or one of its schema files changes
+type source struct {
+	path string
+
+	mu       sync.RWMutex
+	raw      []byte
+	schemas  map[string][]byte
+	current  *snapshot
+	watchers []func(*snapshot)
+	// dirs are watched directories
+	dirs map[string]struct{}
+
+	// refs is the number of discoveries holding the source, it is guarded by sourcesMu
+	refs    int
+	once    sync.Once
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+}
+
+var (
+	sources   = make(map[string]*source)
+	sourcesMu sync.Mutex
+)
+
+// getSource returns the shared source of a file, discovery and contract discovery read the same source,
+// each of them must release it once
+func getSource(path string) *source {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	s, ok := sources[path]
+	if !ok {
+		s = &source{
+			path:    path,
+			current: newSnapshot(&Definition{}, filepath.Dir(path), nil),
+			dirs:    make(map[string]struct{}),
+			done:    make(chan struct{}),
+		}
+		if err := s.reload(); err != nil {
+			openlog.Error(fmt.Sprintf("load registry file [%s] failed: %s", path, err))
+		}
+		sources[path] = s
+	}
+	s.refs++
+	return s
+}
+
+func (s *source) snapshot() *snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current
+}
+
+// onChange adds a callback which is called after file is reloaded
+func (s *source) onChange(f func(*snapshot)) {
+	s.mu.Lock()
+	s.watchers = append(s.watchers, f)
+	s.mu.Unlock()
+}
+
+// reload reads the file and its schema files, it does nothing if none of them is changed,
+// snapshot is kept if file is invalid
+func (s *source) reload() error {
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	d, err := Parse(b)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(s.path)
+	files := schemaFiles(d, dir)
+	s.mu.RLock()
+	same := s.raw != nil && bytes.Equal(b, s.raw) && sameFiles(files, s.schemas)
+	s.mu.RUnlock()
+	if same {
+		return nil
+	}
+	snap := newSnapshot(d, dir, files)
+	s.mu.Lock()
+	s.raw = b
+	s.schemas = files
+	s.current = snap
+	watchers := s.watchers
+	s.mu.Unlock()
+	s.watchDirs()
+	openlog.Info(fmt.Sprintf("registry file [%s] loaded, %d services", s.path, len(snap.services)))
+	for _, f := range watchers {
+		f(snap)
+	}
+	return nil
+}
+
+// watch watches the directories of the file and its schema files,
+// so that file replacement like k8s config map update is noticed
+func (s *source) watch() {
+	s.once.Do(func() {
+		w, err := fsnotify.NewWatcher()
+		if err != nil {
+			openlog.Error("create registry file watcher failed: " + err.Error())
+			return
+		}
+		if err := w.Add(filepath.Dir(s.path)); err != nil {
+			openlog.Error(fmt.Sprintf("watch registry file [%s] failed: %s", s.path, err))
+			w.Close()
+			return
+		}
+		s.mu.Lock()
+		s.watcher = w
+		s.dirs[filepath.Dir(s.path)] = struct{}{}
+		s.mu.Unlock()
+		s.watchDirs()
+		go s.loop(w)
+	})
+}
+
+// watchDirs adds directories of schema files to watcher, directories are not removed until close
+func (s *source) watchDirs() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.watcher == nil {
+		return
+	}
+	for path := range s.schemas {
+		dir := filepath.Dir(path)
+		if _, ok := s.dirs[dir]; ok {
+			continue
+		}
+		if err := s.watcher.Add(dir); err != nil {
+			openlog.Error(fmt.Sprintf("watch schema directory [%s] failed: %s", dir, err))
+			continue
+		}
+		s.dirs[dir] = struct{}{}
+	}
+}
+
+func (s *source) loop(w *fsnotify.Watcher) {
+	for {
+		select {
+		case <-s.done:
+			return
+		case e, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			openlog.Debug(fmt.Sprintf("registry file event: %s", e))
+			if err := s.reload(); err != nil {
+				openlog.Error(fmt.Sprintf("reload registry file [%s] failed: %s", s.path, err))
+			}
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			openlog.Error("registry file watcher error: " + err.Error())
+		}
+	}
+}
+
+// release drops a reference of the source, file is not watched any more once the last reference is released
+func (s *source) release() error {
+	sourcesMu.Lock()
+	s.refs--
+	if s.refs > 0 {
+		sourcesMu.Unlock()
+		return nil
+	}
+	delete(sources, s.path)
+	sourcesMu.Unlock()
+	// stop watch from starting after close
+	s.once.Do(func() {})
+	close(s.done)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.watcher != nil {
+		return s.watcher.Close()
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package file_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/go-chassis/v2/core/registry/file"
+	"github.com/go-chassis/go-chassis/v2/pkg/runtime"
+	utiltags "github.com/go-chassis/go-chassis/v2/pkg/util/tags"
+	"github.com/stretchr/testify/assert"
+)
+
+const registryFile = `
+services:
+  - serviceName: OrderService
+    version: 1.0.0
+    environment: development
+    schemas:
+      order: order.yaml
+    instances:
+      - hostName: order-1
+        endpoints:
+          - rest://127.0.0.1:8080
+        dataCenterInfo:
+          name: dc
+          region: cn-north-1
+          availableZone: az1
+      - instanceID: order-down
+        status: DOWN
+        endpoints:
+          - rest://127.0.0.1:8081
+  - serviceName: OrderService
+    version: 1.1.0
+    instances:
+      - endpoints:
+          - highway://127.0.0.1:9090?sslEnabled=true
+        metadata:
+          env: canary
+  - serviceName: UserService
+    appID: other
+    instances:
+      - endpoints:
+          - rest://127.0.0.1:7070
+`
+
+const orderSchema = `
+swagger: "2.0"
+info:
+  title: order
+  x-java-interface: com.example.OrderService
+basePath: /orders
+`
+
+func writeFile(t *testing.T, path, content string) {
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0600))
+}
+
+func TestParse(t *testing.T) {
+	d, err := file.Parse([]byte(registryFile))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(d.Services))
+	s := d.Services[0]
+	assert.Equal(t, common.DefaultApp, s.AppID)
+	assert.Equal(t, "default:OrderService:1.0.0", s.ServiceID)
+	assert.Equal(t, "default:OrderService:1.0.0-0", s.Instances[0].InstanceID)
+	assert.Equal(t, common.DefaultStatus, s.Instances[0].Status)
+	assert.Equal(t, common.DefaultVersion, d.Services[2].Version)
+
+	ins := s.Instances[0].ToMicroServiceInstance(s)
+	assert.Equal(t, "rest", ins.DefaultProtocol)
+	assert.Equal(t, "127.0.0.1:8080", ins.DefaultEndpoint)
+	assert.Equal(t, "az1", ins.DataCenterInfo.AvailableZone)
+	assert.Equal(t, "1.0.0", ins.Metadata[common.BuildinTagVersion])
+	assert.Equal(t, common.DefaultApp, ins.Metadata[common.BuildinTagApp])
+
+	canary := d.Services[1].Instances[0].ToMicroServiceInstance(d.Services[1])
+	assert.True(t, canary.EndpointsMap["highway"].IsSSLEnable())
+
+	_, err = file.Parse([]byte("services:\n  - version: 1.0.0\n"))
+	assert.Error(t, err)
+}
+
+func TestServiceDiscovery(t *testing.T) {
+	archaius.Init(archaius.WithMemorySource())
+	registry.EnableRegistryCache()
+	runtime.App = common.DefaultApp
+	dir := t.TempDir()
+	path := filepath.Join(dir, "registry.yaml")
+	writeFile(t, path, registryFile)
+	writeFile(t, filepath.Join(dir, "order.yaml"), orderSchema)
+	archaius.Set("servicecomb.registry.file.path", path)
+
+	sd := file.NewServiceDiscovery(registry.Options{})
+	cd := file.NewContractDiscovery(registry.Options{})
+	defer cd.Close()
+	defer sd.Close()
+	sd.AutoSync()
+
+	t.Run("get micro service", func(t *testing.T) {
+		ms, err := sd.GetMicroService("default:OrderService:1.0.0")
+		assert.NoError(t, err)
+		assert.Equal(t, "development", ms.Environment)
+		assert.Equal(t, []string{"order"}, ms.Schemas)
+		_, err = sd.GetMicroService("none")
+		assert.Error(t, err)
+	})
+	t.Run("find latest version, down instance is not cached", func(t *testing.T) {
+		ins, err := sd.FindMicroServiceInstances("", "OrderService", utiltags.Tags{})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(ins))
+		assert.Equal(t, "1.1.0", ins[0].Version)
+
+		ins, err = sd.FindMicroServiceInstances("", "OrderService", utiltags.NewDefaultTag("1.0.0", common.DefaultApp))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(ins))
+		assert.Equal(t, "order-1", ins[0].HostName)
+	})
+	t.Run("find by app", func(t *testing.T) {
+		ins, err := sd.FindMicroServiceInstances("", "UserService", utiltags.Tags{})
+		assert.NoError(t, err)
+		assert.Nil(t, ins)
+		ins, err = sd.FindMicroServiceInstances("", "UserService", utiltags.NewDefaultTag(common.LatestVersion, "other"))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(ins))
+	})
+	t.Run("contracts", func(t *testing.T) {
+		schemas := cd.GetSchemaContentByServiceName("OrderService", "", "", "")
+		assert.Equal(t, 1, len(schemas))
+		assert.Equal(t, "/orders", schemas[0].BasePath)
+		assert.Empty(t, cd.GetSchemaContentByServiceName("OrderService", "1.1.0", "", ""))
+		assert.Equal(t, "/orders", cd.GetSchemaContentByInterface("com.example.OrderService").BasePath)
+		services := cd.GetMicroServicesByInterface("com.example.OrderService")
+		assert.Equal(t, 1, len(services))
+		assert.Equal(t, "1.0.0", services[0].Version)
+	})
+	t.Run("hot reload", func(t *testing.T) {
+		// invalid file is ignored
+		writeFile(t, path, "services: [")
+		time.Sleep(100 * time.Millisecond)
+		ins, _ := sd.FindMicroServiceInstances("", "UserService", utiltags.NewDefaultTag(common.LatestVersion, "other"))
+		assert.Equal(t, 1, len(ins))
+
+		writeFile(t, path, `
+services:
+  - serviceName: OrderService
+    version: 1.0.0
+    instances:
+      - endpoints: [rest://127.0.0.1:8080]
+      - endpoints: [rest://127.0.0.1:8082]
+`)
+		assert.Eventually(t, func() bool {
+			ins, _ := sd.FindMicroServiceInstances("", "OrderService", utiltags.Tags{})
+			return len(ins) == 2
+		}, 3*time.Second, 20*time.Millisecond)
+		_, ok := registry.MicroserviceInstanceIndex.Get("UserService", nil)
+		assert.False(t, ok)
+	})
+}
+
+func TestSchemaReload(t *testing.T) {
+	archaius.Init(archaius.WithMemorySource())
+	registry.EnableRegistryCache()
+	dir := t.TempDir()
+	schemaDir := filepath.Join(dir, "schemas")
+	assert.NoError(t, os.Mkdir(schemaDir, 0700))
+	path := filepath.Join(dir, "conf", "registry.yaml")
+	assert.NoError(t, os.Mkdir(filepath.Dir(path), 0700))
+	writeFile(t, path, `
+services:
+  - serviceName: OrderService
+    schemas:
+      order: ../schemas/order.yaml
+`)
+	writeFile(t, filepath.Join(schemaDir, "order.yaml"), orderSchema)
+	archaius.Set("servicecomb.registry.file.path", path)
+
+	sd := file.NewServiceDiscovery(registry.Options{})
+	cd := file.NewContractDiscovery(registry.Options{})
+	defer cd.Close()
+	defer sd.Close()
+	sd.AutoSync()
+	assert.Equal(t, "/orders", cd.GetSchemaContentByInterface("com.example.OrderService").BasePath)
+	// source is shared, contract discovery still watches the file once service discovery is closed
+	assert.NoError(t, sd.Close())
+
+	// registry file is not changed, schema file in another directory is
+	writeFile(t, filepath.Join(schemaDir, "order.yaml"), strings.Replace(orderSchema, "/orders", "/v2/orders", 1))
+	assert.Eventually(t, func() bool {
+		return cd.GetSchemaContentByInterface("com.example.OrderService").BasePath == "/v2/orders"
+	}, 3*time.Second, 20*time.Millisecond)
+}
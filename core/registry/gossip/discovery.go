@@ -0,0 +1,115 @@
+package gossip
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/go-chassis/v2/health"
+	utiltags "github.com/go-chassis/go-chassis/v2/pkg/util/tags"
+	"github.com/go-chassis/openlog"
+)
+
+// ServiceDiscovery refreshes instance cache from the local view of members once it changes
+type ServiceDiscovery struct {
+	n        *node
+	err      error
+	index    registry.CacheIndex
+	listener int
+	once     sync.Once
+	closed   sync.Once
+
+	mu     sync.Mutex
+	cached map[string]map[string]struct{} // service name to ids of instances in cache
+}
+
+// NewServiceDiscovery returns gossip service discovery
+func NewServiceDiscovery(opts registry.Options) registry.ServiceDiscovery {
+	n, err := acquire(opts)
+	if err != nil {
+		openlog.Error(err.Error())
+	}
+	return &ServiceDiscovery{n: n, err: err, index: opts.InstanceIndex(), cached: make(map[string]map[string]struct{})}
+}
+
+// GetMicroService returns a micro service registered on any member, the one registered on local member comes first
+func (r *ServiceDiscovery) GetMicroService(microServiceID string) (*registry.MicroService, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	for _, m := range append([]*member{r.n.local()}, r.n.view()...) {
+		if i := indexService(m, microServiceID); i >= 0 {
+			return m.Services[i].toMicroService(), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrServiceNotFound, microServiceID)
+}
+
+// FindMicroServiceInstances returns instances from cache, it returns nil if no instance matches the tags
+func (r *ServiceDiscovery) FindMicroServiceInstances(consumerID, microServiceName string, tags utiltags.Tags) ([]*registry.MicroServiceInstance, error) {
+	return registry.FindInstances(r.index, "gossip cluster", consumerID, microServiceName, tags), nil
+}
+
+// AutoSync fills cache with current members and keeps refreshing it on changes
+func (r *ServiceDiscovery) AutoSync() {
+	if r.err != nil {
+		return
+	}
+	r.once.Do(func() {
+		r.listener = r.n.onChange(r.refresh)
+	})
+	r.refresh()
+}
+
+// refresh saves up instances of every service into cache, instances which are gone or not up are removed,
+// the cache of a service is deleted if it has no instance
+func (r *ServiceDiscovery) refresh() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	services := make(map[string][]*registry.MicroServiceInstance)
+	for _, m := range r.n.view() {
+		for _, ins := range m.Instances {
+			services[ins.ServiceName] = append(services[ins.ServiceName], ins.toMicroServiceInstance())
+		}
+	}
+	for name, instances := range services {
+		ups := make([]*registry.MicroServiceInstance, 0, len(instances))
+		downs := make(map[string]struct{})
+		ids := make(map[string]struct{}, len(instances))
+		for _, ins := range instances {
+			ids[ins.InstanceID] = struct{}{}
+			if ins.Status != common.DefaultStatus && ins.Status != common.TESTINGStatus {
+				downs[ins.InstanceID] = struct{}{}
+				continue
+			}
+			ups = append(ups, ins)
+		}
+		for id := range r.cached[name] {
+			if _, ok := ids[id]; !ok {
+				downs[id] = struct{}{}
+			}
+		}
+		health.RefreshCacheIndex(r.index, name, ups, downs)
+		r.cached[name] = ids
+	}
+	for name := range r.cached {
+		if _, ok := services[name]; !ok {
+			r.index.Delete(name)
+			delete(r.cached, name)
+			openlog.Info(fmt.Sprintf("service [%s] has no instance in gossip cluster", name))
+		}
+	}
+}
+
+// Close stops refreshing, and leaves the cluster if registrator is closed as well
+func (r *ServiceDiscovery) Close() error {
+	if r.err != nil {
+		return nil
+	}
+	r.closed.Do(func() {
+		r.n.removeListener(r.listener)
+		release()
+	})
+	return nil
+}
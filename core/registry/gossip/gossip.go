@@ -0,0 +1,370 @@
+// Package gossip is a decentralized registry plugin which needs no registry server,
+// members form a cluster by a SWIM style gossip protocol over udp,
+// each member spreads services and instances registered on it and their status to others,
+// discovery reads instances from the local view of members
+package gossip
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/config"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+)
+
+// Name is the plugin name of gossip registry
+const Name = "gossip"
+
+// errors of gossip registry
+var (
+	ErrServiceNotFound  = errors.New("micro service not found in gossip member")
+	ErrInstanceNotFound = errors.New("instance not found in gossip member")
+)
+
+func init() {
+	registry.InstallRegistrator(Name, NewRegistrator)
+	registry.InstallServiceDiscovery(Name, NewServiceDiscovery)
+}
+
+// service is a micro service registered on a member
+type service struct {
+	ServiceID   string            `json:"serviceId"`
+	AppID       string            `json:"appId"`
+	ServiceName string            `json:"serviceName"`
+	Version     string            `json:"version"`
+	Environment string            `json:"environment,omitempty"`
+	Schemas     []string          `json:"schemas,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+// instance is an instance registered on a member
+type instance struct {
+	InstanceID     string                   `json:"instanceId"`
+	ServiceID      string                   `json:"serviceId"`
+	ServiceName    string                   `json:"serviceName"`
+	AppID          string                   `json:"appId"`
+	Version        string                   `json:"version"`
+	HostName       string                   `json:"hostName,omitempty"`
+	Status         string                   `json:"status"`
+	Endpoints      []string                 `json:"endpoints"`
+	Metadata       map[string]string        `json:"metadata,omitempty"`
+	DataCenterInfo *registry.DataCenterInfo `json:"dataCenterInfo,omitempty"`
+}
+
+// toMicroService converts service to registry micro service
+func (s *service) toMicroService() *registry.MicroService {
+	return &registry.MicroService{
+		ServiceID:   s.ServiceID,
+		AppID:       s.AppID,
+		ServiceName: s.ServiceName,
+		Version:     s.Version,
+		Environment: s.Environment,
+		Schemas:     s.Schemas,
+		Metadata:    s.Metadata,
+		Status:      common.DefaultStatus,
+	}
+}
+
+// toMicroServiceInstance converts instance to registry instance, app and version are set to metadata
+func (ins *instance) toMicroServiceInstance() *registry.MicroServiceInstance {
+	msi := &registry.MicroServiceInstance{
+		App:            ins.AppID,
+		ServiceName:    ins.ServiceName,
+		Version:        ins.Version,
+		InstanceID:     ins.InstanceID,
+		HostName:       ins.HostName,
+		ServiceID:      ins.ServiceID,
+		Status:         ins.Status,
+		DataCenterInfo: ins.DataCenterInfo,
+		Metadata:       make(map[string]string, len(ins.Metadata)+2),
+	}
+	for k, v := range ins.Metadata {
+		msi.Metadata[k] = v
+	}
+	msi.Metadata[common.BuildinTagVersion] = ins.Version
+	m, p := registry.GetProtocolMap(ins.Endpoints)
+	msi.EndpointsMap = m
+	if len(m) != 0 {
+		msi.DefaultEndpoint = m[p].GenEndpoint()
+		msi.DefaultProtocol = p
+	}
+	return msi.WithAppID(ins.AppID)
+}
+
+// registrator and discovery of a process share one member
+var (
+	sharedMu sync.Mutex
+	shared   *node
+	refs     int
+)
+
+// acquire returns the member of this process, it starts the member at the first call,
+// registry addresses are seeds to join the cluster
+func acquire(opts registry.Options) (*node, error) {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	if shared == nil {
+		n, err := newNode(config.GetRegistratorGossipBind(), config.GetRegistratorGossipAdvertise(), opts.Addrs,
+			config.GetRegistratorGossipInterval(), config.GetRegistratorGossipSuspectTimeout())
+		if err != nil {
+			return nil, fmt.Errorf("start gossip member failed: %w", err)
+		}
+		shared = n
+	}
+	refs++
+	return shared, nil
+}
+
+// release leaves the cluster once registrator and discovery are both closed
+func release() {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	if refs--; refs == 0 && shared != nil {
+		shared.leave()
+		shared = nil
+	}
+}
+
+// Registrator puts services and instances into the state of the local member,
+// instances disappear from others once the member leaves or fails
+type Registrator struct {
+	n    *node
+	err  error
+	once sync.Once
+}
+
+// NewRegistrator returns gossip registrator
+func NewRegistrator(opts registry.Options) registry.Registrator {
+	n, err := acquire(opts)
+	return &Registrator{n: n, err: err}
+}
+
+// update modifies the state of local member
+func (r *Registrator) update(f func(m *member) error) error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.n.update(f)
+}
+
+// RegisterService adds service to local member, service id is app:name:version if it is not specified
+func (r *Registrator) RegisterService(ms *registry.MicroService) (string, error) {
+	s := &service{
+		ServiceID:   ms.ServiceID,
+		AppID:       ms.AppID,
+		ServiceName: ms.ServiceName,
+		Version:     ms.Version,
+		Environment: ms.Environment,
+		Schemas:     ms.Schemas,
+		Metadata:    ms.Metadata,
+	}
+	if s.AppID == "" {
+		s.AppID = common.DefaultApp
+	}
+	if s.Version == "" {
+		s.Version = common.DefaultVersion
+	}
+	if s.ServiceID == "" {
+		s.ServiceID = fmt.Sprintf("%s:%s:%s", s.AppID, s.ServiceName, s.Version)
+	}
+	err := r.update(func(m *member) error {
+		if i := indexService(m, s.ServiceID); i >= 0 {
+			m.Services[i] = s
+			return nil
+		}
+		m.Services = append(m.Services, s)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("register service [%s] failed: %w", s.ServiceName, err)
+	}
+	return s.ServiceID, nil
+}
+
+// RegisterServiceInstance adds instance to local member
+func (r *Registrator) RegisterServiceInstance(sid string, msi *registry.MicroServiceInstance) (string, error) {
+	iid := msi.InstanceID
+	if iid == "" {
+		var err error
+		if iid, err = newID(); err != nil {
+			return "", err
+		}
+	}
+	status := msi.Status
+	if status == "" {
+		status = common.DefaultStatus
+	}
+	eps := registry.GetProtocolList(msi.EndpointsMap)
+	sort.Strings(eps)
+	err := r.update(func(m *member) error {
+		i := indexService(m, sid)
+		if i < 0 {
+			return fmt.Errorf("%w: %s", ErrServiceNotFound, sid)
+		}
+		s := m.Services[i]
+		ins := &instance{
+			InstanceID:     iid,
+			ServiceID:      sid,
+			ServiceName:    s.ServiceName,
+			AppID:          s.AppID,
+			Version:        s.Version,
+			HostName:       msi.HostName,
+			Status:         status,
+			Endpoints:      eps,
+			Metadata:       msi.Metadata,
+			DataCenterInfo: msi.DataCenterInfo,
+		}
+		if i := indexInstance(m, iid); i >= 0 {
+			m.Instances[i] = ins
+			return nil
+		}
+		m.Instances = append(m.Instances, ins)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("register instance of [%s] failed: %w", sid, err)
+	}
+	return iid, nil
+}
+
+// RegisterServiceAndInstance registers service and instance
+func (r *Registrator) RegisterServiceAndInstance(ms *registry.MicroService, msi *registry.MicroServiceInstance) (string, string, error) {
+	sid, err := r.RegisterService(ms)
+	if err != nil {
+		return "", "", err
+	}
+	iid, err := r.RegisterServiceInstance(sid, msi)
+	if err != nil {
+		return "", "", err
+	}
+	return sid, iid, nil
+}
+
+// Heartbeat checks the instance is still in local member, liveness is detected by members,
+// it returns ErrInstanceNotFound if the instance is gone, then heartbeat service registers the instance again
+func (r *Registrator) Heartbeat(microServiceID, microServiceInstanceID string) (bool, error) {
+	if r.err != nil {
+		return false, r.err
+	}
+	if indexInstance(r.n.local(), microServiceInstanceID) < 0 {
+		return false, fmt.Errorf("%w: %s", ErrInstanceNotFound, microServiceInstanceID)
+	}
+	return true, nil
+}
+
+// WSHeartbeat checks the instance once, there is nothing to keep alive in background
+func (r *Registrator) WSHeartbeat(microServiceID, microServiceInstanceID string, callback func()) (bool, error) {
+	return r.Heartbeat(microServiceID, microServiceInstanceID)
+}
+
+// UnRegisterMicroServiceInstance removes instance from local member
+func (r *Registrator) UnRegisterMicroServiceInstance(microServiceID, microServiceInstanceID string) error {
+	return r.update(func(m *member) error {
+		i := indexInstance(m, microServiceInstanceID)
+		if i < 0 {
+			return fmt.Errorf("%w: %s", ErrInstanceNotFound, microServiceInstanceID)
+		}
+		m.Instances = append(m.Instances[:i], m.Instances[i+1:]...)
+		return nil
+	})
+}
+
+// UpdateMicroServiceInstanceStatus changes instance status
+func (r *Registrator) UpdateMicroServiceInstanceStatus(microServiceID, microServiceInstanceID, status string) error {
+	return r.updateInstance(microServiceInstanceID, func(ins *instance) {
+		ins.Status = status
+	})
+}
+
+// UpdateMicroServiceInstanceProperties replaces instance metadata
+func (r *Registrator) UpdateMicroServiceInstanceProperties(microServiceID, microServiceInstanceID string, properties map[string]string) error {
+	return r.updateInstance(microServiceInstanceID, func(ins *instance) {
+		ins.Metadata = properties
+	})
+}
+
+// UpdateMicroServiceProperties replaces service metadata
+func (r *Registrator) UpdateMicroServiceProperties(microServiceID string, properties map[string]string) error {
+	return r.update(func(m *member) error {
+		i := indexService(m, microServiceID)
+		if i < 0 {
+			return fmt.Errorf("%w: %s", ErrServiceNotFound, microServiceID)
+		}
+		s := *m.Services[i]
+		s.Metadata = properties
+		m.Services[i] = &s
+		return nil
+	})
+}
+
+// AddSchemas adds schema id to service, schema content is not spread
+func (r *Registrator) AddSchemas(microServiceID, schemaName, schemaInfo string) error {
+	return r.update(func(m *member) error {
+		i := indexService(m, microServiceID)
+		if i < 0 {
+			return fmt.Errorf("%w: %s", ErrServiceNotFound, microServiceID)
+		}
+		s := *m.Services[i]
+		for _, id := range s.Schemas {
+			if id == schemaName {
+				return nil
+			}
+		}
+		s.Schemas = append(append([]string(nil), s.Schemas...), schemaName)
+		m.Services[i] = &s
+		return nil
+	})
+}
+
+// Close leaves the cluster if discovery is closed as well
+func (r *Registrator) Close() error {
+	if r.err == nil {
+		r.once.Do(release)
+	}
+	return nil
+}
+
+// updateInstance replaces an instance with a modified copy
+func (r *Registrator) updateInstance(iid string, f func(*instance)) error {
+	return r.update(func(m *member) error {
+		i := indexInstance(m, iid)
+		if i < 0 {
+			return fmt.Errorf("%w: %s", ErrInstanceNotFound, iid)
+		}
+		ins := *m.Instances[i]
+		f(&ins)
+		m.Instances[i] = &ins
+		return nil
+	})
+}
+
+func indexService(m *member, sid string) int {
+	for i, s := range m.Services {
+		if s.ServiceID == sid {
+			return i
+		}
+	}
+	return -1
+}
+
+func indexInstance(m *member, iid string) int {
+	for i, ins := range m.Instances {
+		if ins.InstanceID == iid {
+			return i
+		}
+	}
+	return -1
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
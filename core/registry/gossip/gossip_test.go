@@ -0,0 +1,108 @@
+package gossip
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	utiltags "github.com/go-chassis/go-chassis/v2/pkg/util/tags"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	archaius.Init(archaius.WithMemorySource())
+}
+
+func TestRegistryPlugin(t *testing.T) {
+	registry.EnableRegistryCache()
+	archaius.Set("servicecomb.registry.gossip.bind", "127.0.0.1:0")
+	archaius.Set("servicecomb.registry.gossip.interval", "100ms")
+	archaius.Set("servicecomb.registry.gossip.suspectTimeout", "500ms")
+	r := NewRegistrator(registry.Options{})
+	sd := NewServiceDiscovery(registry.Options{})
+	assert.Equal(t, shared, r.(*Registrator).n)
+	assert.Equal(t, 2, refs)
+	sd.AutoSync()
+
+	sid, iid, err := r.RegisterServiceAndInstance(&registry.MicroService{ServiceName: "orders", AppID: "shop", Version: "1.0.0"},
+		&registry.MicroServiceInstance{
+			HostName:     "local",
+			EndpointsMap: map[string]*registry.Endpoint{common.ProtocolRest: {Address: "127.0.0.1:8080"}},
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, "shop:orders:1.0.0", sid)
+
+	remote := startNode(t, shared.local().Addr)
+	defer remote.close()
+	_, _, err = (&Registrator{n: remote}).RegisterServiceAndInstance(&registry.MicroService{ServiceName: "orders", AppID: "shop", Version: "1.0.0"},
+		&registry.MicroServiceInstance{
+			InstanceID:   "remote",
+			EndpointsMap: map[string]*registry.Endpoint{common.ProtocolRest: {Address: "127.0.0.2:8080"}},
+		})
+	assert.NoError(t, err)
+
+	t.Run("discover", func(t *testing.T) {
+		assert.Eventually(t, func() bool {
+			ins, _ := sd.FindMicroServiceInstances("", "orders", utiltags.NewDefaultTag("1.0.0", "shop"))
+			return len(ins) == 2
+		}, 5*time.Second, 20*time.Millisecond)
+		ins, err := sd.FindMicroServiceInstances("", "orders", utiltags.NewDefaultTag("2.0.0", "shop"))
+		assert.NoError(t, err)
+		assert.Nil(t, ins)
+		ms, err := sd.GetMicroService(sid)
+		assert.NoError(t, err)
+		assert.Equal(t, "orders", ms.ServiceName)
+		_, err = sd.GetMicroService("unknown")
+		assert.ErrorIs(t, err, ErrServiceNotFound)
+	})
+	t.Run("heartbeat", func(t *testing.T) {
+		ok, err := r.Heartbeat(sid, iid)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		_, err = r.Heartbeat(sid, "unknown")
+		assert.ErrorIs(t, err, ErrInstanceNotFound)
+		_, err = r.RegisterServiceInstance("unknown", &registry.MicroServiceInstance{})
+		assert.ErrorIs(t, err, ErrServiceNotFound)
+	})
+	t.Run("update", func(t *testing.T) {
+		assert.NoError(t, r.UpdateMicroServiceInstanceProperties(sid, iid, map[string]string{"zone": "a"}))
+		assert.NoError(t, r.UpdateMicroServiceProperties(sid, map[string]string{"owner": "x"}))
+		assert.NoError(t, r.AddSchemas(sid, "orders", "swagger: '2.0'"))
+		ins, _ := sd.FindMicroServiceInstances("", "orders", utiltags.NewDefaultTag("1.0.0", "shop"))
+		for _, i := range ins {
+			if i.InstanceID == iid {
+				assert.Equal(t, "a", i.Metadata["zone"])
+				assert.Equal(t, "local", i.HostName)
+			}
+		}
+		ms, _ := sd.GetMicroService(sid)
+		assert.Equal(t, "x", ms.Metadata["owner"])
+		assert.Equal(t, []string{"orders"}, ms.Schemas)
+	})
+	t.Run("down and removed instances", func(t *testing.T) {
+		assert.NoError(t, r.UpdateMicroServiceInstanceStatus(sid, iid, "DOWN"))
+		ins, _ := sd.FindMicroServiceInstances("", "orders", utiltags.NewDefaultTag("1.0.0", "shop"))
+		assert.Equal(t, 1, len(ins))
+		assert.Equal(t, "remote", ins[0].InstanceID)
+
+		remote.close()
+		assert.Eventually(t, func() bool {
+			_, ok := registry.MicroserviceInstanceIndex.Get("orders", nil)
+			return !ok
+		}, 5*time.Second, 20*time.Millisecond)
+		assert.NoError(t, r.UnRegisterMicroServiceInstance(sid, iid))
+		_, err := r.Heartbeat(sid, iid)
+		assert.ErrorIs(t, err, ErrInstanceNotFound)
+	})
+	t.Run("close", func(t *testing.T) {
+		assert.NoError(t, sd.Close())
+		assert.NoError(t, sd.Close())
+		assert.NotNil(t, shared)
+		assert.NoError(t, r.Close())
+		assert.Nil(t, shared)
+		_, err := r.RegisterService(&registry.MicroService{ServiceName: "orders"})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,641 @@
+package gossip
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chassis/go-chassis/v2/pkg/util/iputil"
+	"github.com/go-chassis/openlog"
+)
+
+// states of a member, a state overrides another one of the same incarnation if its rank is higher
+const (
+	stateAlive   = "alive"
+	stateSuspect = "suspect"
+	stateDead    = "dead"
+	stateLeft    = "left"
+)
+
+// types of messages
+const (
+	msgPing    = "ping"
+	msgAck     = "ack"
+	msgPingReq = "ping-req"
+	msgSync    = "sync"  // carries states and asks for all states of the receiver
+	msgState   = "state" // only carries states
+)
+
+const (
+	maxPacketSize  = 60 * 1024
+	maxPiggyback   = 8
+	indirectProbes = 3
+	// syncRounds is the number of probe rounds between two full state exchanges with a random member
+	syncRounds = 10
+	// retransmitMult multiplies log2 of cluster size to get how many times a state is piggybacked
+	retransmitMult = 4
+	// deadRounds is the number of suspect timeouts a dead member is remembered,
+	// so that stale alive states of it are not accepted again
+	deadRounds = 6
+)
+
+// ErrInvalidInterval means probe interval or suspect timeout is not positive
+var ErrInvalidInterval = errors.New("gossip interval and suspect timeout must be positive")
+
+func rank(state string) int {
+	switch state {
+	case stateAlive:
+		return 0
+	case stateSuspect:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// member is the state of a node with services and instances registered on it.
+// members are never modified once they are stored, a new state replaces the old one
+type member struct {
+	ID          string      `json:"id"`
+	Addr        string      `json:"addr"`
+	Incarnation uint64      `json:"incarnation"`
+	State       string      `json:"state"`
+	Services    []*service  `json:"services,omitempty"`
+	Instances   []*instance `json:"instances,omitempty"`
+
+	changed time.Time
+}
+
+// visible reports whether instances of the member can be discovered, a suspected member may be still alive
+func (m *member) visible() bool {
+	return rank(m.State) < 2
+}
+
+// copy returns a copy of the member whose slices can be modified
+func (m *member) copy() *member {
+	c := *m
+	c.Services = append([]*service(nil), m.Services...)
+	c.Instances = append([]*instance(nil), m.Instances...)
+	return &c
+}
+
+type message struct {
+	Type   string    `json:"type"`
+	Seq    uint32    `json:"seq,omitempty"`
+	Target string    `json:"target,omitempty"`
+	States []*member `json:"states,omitempty"`
+}
+
+// node is a member of gossip cluster, it detects failures of others in SWIM style:
+// a random member is pinged every interval, it is pinged through other members if there is no ack,
+// then it is suspected, and declared dead if it does not refute the suspicion in suspect timeout.
+// state changes are piggybacked on pings and acks, and full states are exchanged with a random member periodically
+type node struct {
+	conn           *net.UDPConn
+	seeds          []string
+	interval       time.Duration
+	suspectTimeout time.Duration
+
+	mu           sync.Mutex
+	self         *member
+	members      map[string]*member // key is member id, self included
+	probes       []string           // members to probe in this round
+	seq          uint32
+	acks         map[uint32]func()
+	queue        map[string]int // member id to count of its state being piggybacked
+	listeners    map[int]func()
+	nextListener int
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newNode listens on bind address and joins the cluster through seeds,
+// it advertises the local ip if bind ip is unspecified and advertise address is empty
+func newNode(bind, advertise string, seeds []string, interval, suspectTimeout time.Duration) (*node, error) {
+	if interval <= 0 || suspectTimeout <= 0 {
+		return nil, ErrInvalidInterval
+	}
+	addr, err := net.ResolveUDPAddr("udp", bind)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	local := conn.LocalAddr().(*net.UDPAddr)
+	if advertise == "" {
+		ip := local.IP.String()
+		if local.IP.IsUnspecified() {
+			if ip = iputil.GetLocalIP(); ip == "" {
+				ip = iputil.Localhost()
+			}
+		}
+		advertise = net.JoinHostPort(ip, strconv.Itoa(local.Port))
+	}
+	id, err := newID()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	n := &node{
+		conn:           conn,
+		interval:       interval,
+		suspectTimeout: suspectTimeout,
+		self:           &member{ID: id, Addr: advertise, State: stateAlive, changed: time.Now()},
+		members:        make(map[string]*member),
+		acks:           make(map[uint32]func()),
+		queue:          make(map[string]int),
+		listeners:      make(map[int]func()),
+		done:           make(chan struct{}),
+	}
+	for _, s := range seeds {
+		if s != "" && s != advertise && s != local.String() {
+			n.seeds = append(n.seeds, s)
+		}
+	}
+	n.members[id] = n.self
+	n.wg.Add(2)
+	go n.receive()
+	go n.run()
+	openlog.Info(fmt.Sprintf("gossip member [%s] listens on %s, advertises %s", id, local, advertise))
+	return n, nil
+}
+
+// local returns state of the node itself
+func (n *node) local() *member {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.self
+}
+
+// view returns members whose instances can be discovered
+func (n *node) view() []*member {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	ms := make([]*member, 0, len(n.members))
+	for _, m := range n.members {
+		if m.visible() {
+			ms = append(ms, m)
+		}
+	}
+	sort.Slice(ms, func(i, j int) bool { return ms[i].ID < ms[j].ID })
+	return ms
+}
+
+// update modifies a copy of the local state, the copy is spread with a new incarnation
+func (n *node) update(f func(m *member) error) error {
+	n.mu.Lock()
+	if n.self.State != stateAlive {
+		n.mu.Unlock()
+		return fmt.Errorf("gossip member [%s] has left", n.self.ID)
+	}
+	m := n.self.copy()
+	if err := f(m); err != nil {
+		n.mu.Unlock()
+		return err
+	}
+	m.Incarnation++
+	n.setSelf(m)
+	n.mu.Unlock()
+	n.notify()
+	return nil
+}
+
+func (n *node) setSelf(m *member) {
+	m.changed = time.Now()
+	n.self = m
+	n.members[m.ID] = m
+	n.queue[m.ID] = 0
+}
+
+// onChange adds a listener which is called once members or their instances change
+func (n *node) onChange(f func()) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.nextListener++
+	n.listeners[n.nextListener] = f
+	return n.nextListener
+}
+
+func (n *node) removeListener(id int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.listeners, id)
+}
+
+func (n *node) notify() {
+	n.mu.Lock()
+	fs := make([]func(), 0, len(n.listeners))
+	for _, f := range n.listeners {
+		fs = append(fs, f)
+	}
+	n.mu.Unlock()
+	for _, f := range fs {
+		f()
+	}
+}
+
+// merge applies states received from others
+func (n *node) merge(states []*member) {
+	changed := false
+	n.mu.Lock()
+	for _, s := range states {
+		if n.apply(s) {
+			changed = true
+		}
+	}
+	n.mu.Unlock()
+	if changed {
+		n.notify()
+	}
+}
+
+// apply stores a state if it is newer than the known one, it reports whether visible members change.
+// a suspicion or death of the node itself is refuted by a higher incarnation
+func (n *node) apply(s *member) bool {
+	if s == nil || s.ID == "" {
+		return false
+	}
+	if s.ID == n.self.ID {
+		if s.State != stateAlive && s.Incarnation >= n.self.Incarnation && n.self.State == stateAlive {
+			m := n.self.copy()
+			m.Incarnation = s.Incarnation + 1
+			n.setSelf(m)
+			openlog.Info(fmt.Sprintf("gossip member [%s] refutes [%s] state", m.ID, s.State))
+		}
+		return false
+	}
+	cur, ok := n.members[s.ID]
+	if ok && (s.Incarnation < cur.Incarnation || s.Incarnation == cur.Incarnation && rank(s.State) <= rank(cur.State)) {
+		return false
+	}
+	s.changed = time.Now()
+	n.members[s.ID] = s
+	n.queue[s.ID] = 0
+	if s.State == stateSuspect {
+		n.startSuspect(s)
+	}
+	switch {
+	case s.visible() && (!ok || !cur.visible()):
+		openlog.Info(fmt.Sprintf("gossip member [%s] at %s joins", s.ID, s.Addr))
+	case !s.visible() && ok && cur.visible():
+		openlog.Info(fmt.Sprintf("gossip member [%s] at %s is %s", s.ID, s.Addr, s.State))
+	}
+	return s.visible() || ok && cur.visible()
+}
+
+// startSuspect declares a suspected member dead if its state is not changed in suspect timeout
+func (n *node) startSuspect(s *member) {
+	time.AfterFunc(n.suspectTimeout, func() {
+		n.mu.Lock()
+		if n.members[s.ID] != s || n.closed() {
+			n.mu.Unlock()
+			return
+		}
+		n.apply(&member{ID: s.ID, Addr: s.Addr, Incarnation: s.Incarnation, State: stateDead})
+		n.mu.Unlock()
+		n.notify()
+	})
+}
+
+func (n *node) closed() bool {
+	select {
+	case <-n.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (n *node) receive() {
+	defer n.wg.Done()
+	b := make([]byte, 65535)
+	for {
+		l, from, err := n.conn.ReadFromUDP(b)
+		if err != nil {
+			if n.closed() {
+				return
+			}
+			openlog.Warn(fmt.Sprintf("gossip member reads packet failed: %s", err))
+			continue
+		}
+		msg := &message{}
+		if err := json.Unmarshal(b[:l], msg); err != nil {
+			openlog.Debug(fmt.Sprintf("invalid gossip packet from %s: %s", from, err))
+			continue
+		}
+		n.handle(msg, from)
+	}
+}
+
+func (n *node) handle(msg *message, from *net.UDPAddr) {
+	n.merge(msg.States)
+	switch msg.Type {
+	case msgPing:
+		n.send(from, &message{Type: msgAck, Seq: msg.Seq})
+	case msgAck:
+		n.mu.Lock()
+		f, ok := n.acks[msg.Seq]
+		delete(n.acks, msg.Seq)
+		n.mu.Unlock()
+		if ok {
+			f()
+		}
+	case msgPingReq:
+		target, err := net.ResolveUDPAddr("udp", msg.Target)
+		if err != nil {
+			return
+		}
+		n.ping(target, func() {
+			n.send(from, &message{Type: msgAck, Seq: msg.Seq})
+		})
+	case msgSync:
+		n.sendStates(from, msgState)
+	}
+}
+
+// expect registers a callback of an ack and returns sequence number of the ping
+func (n *node) expect(f func()) uint32 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.seq++
+	n.acks[n.seq] = f
+	return n.seq
+}
+
+func (n *node) forget(seq uint32) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.acks, seq)
+}
+
+// ping sends a ping, f is called if it is acked in an interval
+func (n *node) ping(addr *net.UDPAddr, f func()) {
+	seq := n.expect(f)
+	n.send(addr, &message{Type: msgPing, Seq: seq})
+	time.AfterFunc(n.interval, func() { n.forget(seq) })
+}
+
+// send writes a message, states to spread are piggybacked if the message carries no state
+func (n *node) send(addr *net.UDPAddr, msg *message) {
+	n.mu.Lock()
+	if msg.States == nil {
+		msg.States = n.piggyback()
+	}
+	b, err := json.Marshal(msg)
+	n.mu.Unlock()
+	if err != nil {
+		openlog.Error(fmt.Sprintf("encode gossip message failed: %s", err))
+		return
+	}
+	if _, err := n.conn.WriteToUDP(b, addr); err != nil && !n.closed() {
+		openlog.Debug(fmt.Sprintf("send gossip message to %s failed: %s", addr, err))
+	}
+}
+
+// piggyback returns states which are spread less than log2(cluster size) * retransmitMult times
+func (n *node) piggyback() []*member {
+	limit := retransmitMult * int(math.Ceil(math.Log2(float64(len(n.members)+1))))
+	ids := make([]string, 0, len(n.queue))
+	for id := range n.queue {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return n.queue[ids[i]] < n.queue[ids[j]] })
+	states := make([]*member, 0, maxPiggyback)
+	size := 0
+	for _, id := range ids {
+		if len(states) == maxPiggyback {
+			break
+		}
+		m, ok := n.members[id]
+		if !ok {
+			delete(n.queue, id)
+			continue
+		}
+		b, err := json.Marshal(m)
+		if err != nil || size+len(b) > maxPacketSize {
+			continue
+		}
+		size += len(b)
+		states = append(states, m)
+		if n.queue[id]++; n.queue[id] >= limit {
+			delete(n.queue, id)
+		}
+	}
+	return states
+}
+
+// sendStates sends all known states, split into packets if they are too large for one
+func (n *node) sendStates(addr *net.UDPAddr, typ string) {
+	n.mu.Lock()
+	packets := make([][]*member, 0, 1)
+	states := make([]*member, 0)
+	size := 0
+	for _, m := range n.members {
+		b, err := json.Marshal(m)
+		if err != nil {
+			continue
+		}
+		if len(states) != 0 && size+len(b) > maxPacketSize {
+			packets = append(packets, states)
+			states, size = make([]*member, 0), 0
+		}
+		states = append(states, m)
+		size += len(b)
+	}
+	packets = append(packets, states)
+	n.mu.Unlock()
+	for i, states := range packets {
+		// only the first packet asks for states of the receiver
+		if i > 0 {
+			typ = msgState
+		}
+		n.send(addr, &message{Type: typ, States: states})
+	}
+}
+
+func (n *node) run() {
+	defer n.wg.Done()
+	t := time.NewTicker(n.interval)
+	defer t.Stop()
+	n.join()
+	for round := 1; ; round++ {
+		select {
+		case <-n.done:
+			return
+		case <-t.C:
+		}
+		if len(n.peers(1, "")) == 0 {
+			n.join()
+			continue
+		}
+		n.probe()
+		if round%syncRounds == 0 {
+			for _, m := range n.peers(1, "") {
+				n.sendStates(resolve(m.Addr), msgSync)
+			}
+		}
+		n.prune()
+	}
+}
+
+// join exchanges states with seeds, it is retried every interval until any other member is known
+func (n *node) join() {
+	for _, s := range n.seeds {
+		if addr := resolve(s); addr != nil {
+			n.sendStates(addr, msgSync)
+		}
+	}
+}
+
+func resolve(addr string) *net.UDPAddr {
+	a, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		openlog.Warn(fmt.Sprintf("invalid gossip member address [%s]: %s", addr, err))
+		return nil
+	}
+	return a
+}
+
+// peers returns at most k random visible members except the node itself and the excluded one
+func (n *node) peers(k int, exclude string) []*member {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	ms := make([]*member, 0, len(n.members))
+	for id, m := range n.members {
+		if id != n.self.ID && id != exclude && m.visible() {
+			ms = append(ms, m)
+		}
+	}
+	rand.Shuffle(len(ms), func(i, j int) { ms[i], ms[j] = ms[j], ms[i] })
+	if len(ms) > k {
+		ms = ms[:k]
+	}
+	return ms
+}
+
+// nextProbe returns the next member to probe, every member is probed once in a round of random order
+func (n *node) nextProbe() *member {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for {
+		if len(n.probes) == 0 {
+			for id, m := range n.members {
+				if id != n.self.ID && m.visible() {
+					n.probes = append(n.probes, id)
+				}
+			}
+			if len(n.probes) == 0 {
+				return nil
+			}
+			rand.Shuffle(len(n.probes), func(i, j int) { n.probes[i], n.probes[j] = n.probes[j], n.probes[i] })
+		}
+		id := n.probes[0]
+		n.probes = n.probes[1:]
+		if m, ok := n.members[id]; ok && m.visible() {
+			return m
+		}
+	}
+}
+
+// probe pings a member directly, and indirectly through other members if there is no ack in half an interval,
+// the member is suspected if there is still no ack at the end of the interval
+func (n *node) probe() {
+	target := n.nextProbe()
+	if target == nil {
+		return
+	}
+	addr := resolve(target.Addr)
+	if addr == nil {
+		return
+	}
+	acked := make(chan struct{})
+	once := sync.Once{}
+	ack := func() { once.Do(func() { close(acked) }) }
+	n.ping(addr, ack)
+	if n.wait(acked) {
+		return
+	}
+	seq := n.expect(ack)
+	defer n.forget(seq)
+	for _, m := range n.peers(indirectProbes, target.ID) {
+		if a := resolve(m.Addr); a != nil {
+			n.send(a, &message{Type: msgPingReq, Seq: seq, Target: target.Addr})
+		}
+	}
+	if n.wait(acked) {
+		return
+	}
+	n.mu.Lock()
+	if cur := n.members[target.ID]; cur != target || cur.State != stateAlive {
+		n.mu.Unlock()
+		return
+	}
+	s := *target
+	s.State = stateSuspect
+	n.apply(&s)
+	n.mu.Unlock()
+	n.notify()
+}
+
+// wait reports whether an ack arrives in half an interval
+func (n *node) wait(acked chan struct{}) bool {
+	t := time.NewTimer(n.interval / 2)
+	defer t.Stop()
+	select {
+	case <-acked:
+		return true
+	case <-n.done:
+		return true
+	case <-t.C:
+		return false
+	}
+}
+
+// prune forgets members which are dead or left long ago
+func (n *node) prune() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for id, m := range n.members {
+		if !m.visible() && id != n.self.ID && time.Since(m.changed) > deadRounds*n.suspectTimeout {
+			delete(n.members, id)
+			delete(n.queue, id)
+		}
+	}
+}
+
+// leave tells some members that the node leaves, then stops
+func (n *node) leave() {
+	n.mu.Lock()
+	m := &member{ID: n.self.ID, Addr: n.self.Addr, Incarnation: n.self.Incarnation + 1, State: stateLeft}
+	n.setSelf(m)
+	n.mu.Unlock()
+	for _, p := range n.peers(indirectProbes, "") {
+		if addr := resolve(p.Addr); addr != nil {
+			n.send(addr, &message{Type: msgState, States: []*member{m}})
+		}
+	}
+	n.close()
+}
+
+// close stops the node without telling others, they will detect its failure
+func (n *node) close() {
+	n.mu.Lock()
+	if n.closed() {
+		n.mu.Unlock()
+		return
+	}
+	close(n.done)
+	n.mu.Unlock()
+	n.conn.Close()
+	n.wg.Wait()
+}
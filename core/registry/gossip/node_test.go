@@ -0,0 +1,140 @@
+package gossip
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testInterval       = 100 * time.Millisecond
+	testSuspectTimeout = 500 * time.Millisecond
+)
+
+func startNode(t *testing.T, seeds ...string) *node {
+	n, err := newNode("127.0.0.1:0", "", seeds, testInterval, testSuspectTimeout)
+	assert.NoError(t, err)
+	return n
+}
+
+// instances returns ids and status of instances in the view of a node
+func instances(n *node) map[string]string {
+	ins := make(map[string]string)
+	for _, m := range n.view() {
+		for _, i := range m.Instances {
+			ins[i.InstanceID] = i.Status
+		}
+	}
+	return ins
+}
+
+func TestNewNode(t *testing.T) {
+	_, err := newNode("127.0.0.1:0", "", nil, 0, time.Second)
+	assert.ErrorIs(t, err, ErrInvalidInterval)
+
+	n, err := newNode("0.0.0.0:0", "", []string{"", "127.0.0.1:1"}, time.Second, time.Second)
+	assert.NoError(t, err)
+	defer n.close()
+	assert.NotEqual(t, "", n.local().Addr)
+	assert.Equal(t, []string{"127.0.0.1:1"}, n.seeds)
+	assert.Equal(t, 1, len(n.view()))
+}
+
+func TestCluster(t *testing.T) {
+	first := startNode(t)
+	nodes := []*node{first}
+	for i := 0; i < 3; i++ {
+		nodes = append(nodes, startNode(t, first.local().Addr))
+	}
+	defer func() {
+		for _, n := range nodes {
+			n.close()
+		}
+	}()
+	for i, n := range nodes {
+		r := &Registrator{n: n}
+		_, _, err := r.RegisterServiceAndInstance(&registry.MicroService{ServiceName: "orders"},
+			&registry.MicroServiceInstance{InstanceID: fmt.Sprintf("orders-%d", i)})
+		assert.NoError(t, err)
+	}
+
+	t.Run("converge", func(t *testing.T) {
+		for _, n := range nodes {
+			assert.Eventually(t, func() bool { return len(instances(n)) == 4 }, 5*time.Second, 20*time.Millisecond)
+		}
+	})
+	t.Run("spread status", func(t *testing.T) {
+		r := &Registrator{n: nodes[1]}
+		assert.NoError(t, r.UpdateMicroServiceInstanceStatus("", "orders-1", "DOWN"))
+		for _, n := range nodes {
+			assert.Eventually(t, func() bool { return instances(n)["orders-1"] == "DOWN" }, 5*time.Second, 20*time.Millisecond)
+		}
+	})
+	t.Run("leave", func(t *testing.T) {
+		nodes[3].leave()
+		for _, n := range nodes[:3] {
+			assert.Eventually(t, func() bool {
+				_, ok := instances(n)["orders-3"]
+				return len(n.view()) == 3 && !ok
+			}, 5*time.Second, 20*time.Millisecond)
+		}
+	})
+	t.Run("fail", func(t *testing.T) {
+		// close without telling others, its failure is detected by probes
+		nodes[2].close()
+		for _, n := range nodes[:2] {
+			assert.Eventually(t, func() bool { return len(n.view()) == 2 }, 5*time.Second, 20*time.Millisecond)
+		}
+		assert.Equal(t, map[string]string{"orders-0": common.DefaultStatus, "orders-1": "DOWN"}, instances(nodes[0]))
+	})
+}
+
+// state returns the state of a member known by a node
+func state(n *node, id string) string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.members[id].State
+}
+
+func TestApply(t *testing.T) {
+	// no probe happens in tests
+	n, err := newNode("127.0.0.1:0", "", nil, time.Hour, testSuspectTimeout)
+	assert.NoError(t, err)
+	defer n.close()
+	self := n.local()
+
+	t.Run("refute suspicion", func(t *testing.T) {
+		n.merge([]*member{{ID: self.ID, Addr: self.Addr, Incarnation: self.Incarnation, State: stateSuspect}})
+		assert.Equal(t, self.Incarnation+1, n.local().Incarnation)
+		assert.Equal(t, stateAlive, n.local().State)
+	})
+	t.Run("precedence", func(t *testing.T) {
+		var changes int32
+		n.onChange(func() { atomic.AddInt32(&changes, 1) })
+		n.merge([]*member{{ID: "a", Addr: "127.0.0.1:1", Incarnation: 1, State: stateAlive}})
+		assert.Equal(t, int32(1), atomic.LoadInt32(&changes))
+		// alive state of the same incarnation does not override suspicion
+		n.merge([]*member{{ID: "a", Addr: "127.0.0.1:1", Incarnation: 1, State: stateSuspect}})
+		n.merge([]*member{{ID: "a", Addr: "127.0.0.1:1", Incarnation: 1, State: stateAlive}})
+		assert.Equal(t, stateSuspect, state(n, "a"))
+		assert.Equal(t, int32(2), atomic.LoadInt32(&changes))
+		n.merge([]*member{{ID: "a", Addr: "127.0.0.1:1", Incarnation: 2, State: stateAlive}})
+		assert.Equal(t, stateAlive, state(n, "a"))
+		n.merge([]*member{{ID: "a", Addr: "127.0.0.1:1", Incarnation: 2, State: stateDead}})
+		assert.Equal(t, 1, len(n.view()))
+		// stale state of a dead member is ignored
+		n.merge([]*member{{ID: "a", Addr: "127.0.0.1:1", Incarnation: 1, State: stateAlive}})
+		assert.Equal(t, 1, len(n.view()))
+		assert.Equal(t, int32(4), atomic.LoadInt32(&changes))
+	})
+	t.Run("suspect timeout", func(t *testing.T) {
+		n.merge([]*member{{ID: "b", Addr: "127.0.0.1:1", Incarnation: 1, State: stateSuspect}})
+		assert.Equal(t, 2, len(n.view()))
+		assert.Eventually(t, func() bool { return len(n.view()) == 1 }, 3*time.Second, 20*time.Millisecond)
+	})
+}
@@ -0,0 +1,566 @@
+// Package kubernetes is a kubernetes native registry plugin,
+// Services and their Endpoints or EndpointSlices are watched by informers,
+// addresses are converted to micro service instances and pushed into instance cache.
+// instances are registered by kubernetes itself, so self registration does nothing
+package kubernetes
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/config"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/go-chassis/v2/health"
+	"github.com/go-chassis/go-chassis/v2/pkg/runtime"
+	utiltags "github.com/go-chassis/go-chassis/v2/pkg/util/tags"
+	"github.com/go-chassis/openlog"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1beta1 "k8s.io/api/discovery/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1beta1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Name is the plugin name of kubernetes registry
+const Name = "kubernetes"
+
+// labels of Service and Pod which are converted to instance tags
+const (
+	LabelVersion       = "version"
+	LabelK8sVersion    = "app.kubernetes.io/version"
+	LabelApp           = "app.kubernetes.io/part-of"
+	labelLegacyZone    = "failure-domain.beta.kubernetes.io/zone"
+	labelLegacyRegion  = "failure-domain.beta.kubernetes.io/region"
+	defaultSyncTimeout = 30 * time.Second
+)
+
+func init() {
+	registry.InstallRegistrator(Name, registry.NewNoopRegistrator)
+	registry.InstallServiceDiscovery(Name, NewServiceDiscovery)
+}
+
+// ServiceDiscovery watches Services in a namespace
+type ServiceDiscovery struct {
+	registry.SyncHealth
+	client         kubernetes.Interface
+	namespace      string
+	endpointSlices bool
+	index          registry.CacheIndex
+	// listers holds *listers, it is set once informers are created
+	listers atomic.Value
+
+	// NodeTopology decides to read topology of Endpoints from nodes,
+	// it needs cluster scope permission to list and watch nodes
+	NodeTopology bool
+
+	mu        sync.Mutex
+	stop      chan struct{}
+	startOnce sync.Once
+	stopOnce  sync.Once
+}
+
+// listers of informers, they are stored at once, so that callers never see a partially started discovery
+type listers struct {
+	services  corelisters.ServiceLister
+	endpoints corelisters.EndpointsLister
+	slices    discoverylisters.EndpointSliceLister
+	pods      corelisters.PodLister
+	nodes     corelisters.NodeLister
+}
+
+// NewServiceDiscovery returns kubernetes service discovery,
+// kube config in options is used if it exists, otherwise in cluster config is used
+func NewServiceDiscovery(opts registry.Options) registry.ServiceDiscovery {
+	var c kubernetes.Interface
+	rc, err := restConfig(opts.ConfigPath)
+	if err == nil {
+		c, err = kubernetes.NewForConfig(rc)
+	}
+	if err != nil {
+		openlog.Error(fmt.Sprintf("kubernetes client initialization failed: %s", err))
+	}
+	r := New(c, config.GetServiceDiscoveryKubernetesNamespace(), config.GetServiceDiscoveryKubernetesEndpointSlices())
+	r.index = opts.InstanceIndex()
+	r.NodeTopology = config.GetServiceDiscoveryKubernetesNodeTopology()
+	return r
+}
+
+// New returns kubernetes service discovery with a client, endpointSlices decides to read EndpointSlices or Endpoints
+func New(c kubernetes.Interface, namespace string, endpointSlices bool) *ServiceDiscovery {
+	return &ServiceDiscovery{
+		client:         c,
+		namespace:      namespace,
+		endpointSlices: endpointSlices,
+		index:          registry.Options{}.InstanceIndex(),
+		stop:           make(chan struct{}),
+	}
+}
+
+func restConfig(path string) (*rest.Config, error) {
+	if path != "" {
+		if _, err := os.Stat(path); err == nil {
+			return clientcmd.BuildConfigFromFlags("", path)
+		}
+	}
+	return rest.InClusterConfig()
+}
+
+// GetMicroService returns the Service which uid is the micro service id
+func (r *ServiceDiscovery) GetMicroService(microServiceID string) (*registry.MicroService, error) {
+	l := r.lister()
+	if l == nil {
+		return nil, fmt.Errorf("kubernetes discovery is not synced")
+	}
+	list, err := l.services.Services(r.namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	for _, svc := range list {
+		if string(svc.UID) == microServiceID {
+			return toMicroService(svc), nil
+		}
+	}
+	return nil, fmt.Errorf("micro service [%s] not found in namespace [%s]", microServiceID, r.namespace)
+}
+
+// FindMicroServiceInstances returns instances from cache, it returns nil if no instance matches the tags
+func (r *ServiceDiscovery) FindMicroServiceInstances(consumerID, microServiceName string, tags utiltags.Tags) ([]*registry.MicroServiceInstance, error) {
+	return registry.FindInstances(r.index, "kubernetes", consumerID, microServiceName, tags), nil
+}
+
+// AutoSync starts informers, once caches are synced, every change of Services,
+// Endpoints or EndpointSlices refreshes instance cache of the service
+func (r *ServiceDiscovery) AutoSync() {
+	if r.client == nil {
+		openlog.Error("kubernetes client is not initialized, can not sync instances")
+		return
+	}
+	r.startOnce.Do(r.start)
+}
+
+func (r *ServiceDiscovery) start() {
+	f := informers.NewSharedInformerFactoryWithOptions(r.client, 0, informers.WithNamespace(r.namespace))
+	svcInformer := f.Core().V1().Services()
+	podInformer := f.Core().V1().Pods()
+	l := &listers{services: svcInformer.Lister(), pods: podInformer.Lister()}
+	synced := []cache.InformerSynced{svcInformer.Informer().HasSynced, podInformer.Informer().HasSynced}
+
+	var epInformer cache.SharedIndexInformer
+	if r.endpointSlices {
+		i := f.Discovery().V1beta1().EndpointSlices()
+		l.slices = i.Lister()
+		epInformer = i.Informer()
+	} else {
+		i := f.Core().V1().Endpoints()
+		l.endpoints = i.Lister()
+		epInformer = i.Informer()
+		if r.NodeTopology {
+			// nodes are cluster scoped, they can not be listed by a namespace scoped factory
+			nf := informers.NewSharedInformerFactory(r.client, 0)
+			nodeInformer := nf.Core().V1().Nodes()
+			l.nodes = nodeInformer.Lister()
+			synced = append(synced, nodeInformer.Informer().HasSynced)
+			r.reportWatchError(nodeInformer.Informer())
+			nf.Start(r.stop)
+		}
+	}
+	synced = append(synced, epInformer.HasSynced)
+	for _, i := range []cache.SharedIndexInformer{svcInformer.Informer(), podInformer.Informer(), epInformer} {
+		r.reportWatchError(i)
+	}
+	r.listers.Store(l)
+	f.Start(r.stop)
+
+	if !cache.WaitForCacheSync(r.syncStop(defaultSyncTimeout), synced...) {
+		err := fmt.Errorf("sync kubernetes caches in namespace [%s] timeout", r.namespace)
+		openlog.Error(err.Error())
+		r.ReportSync(err)
+	}
+
+	// handlers added after start receive existing objects as add events, all services are cached by them
+	svcInformer.Informer().AddEventHandler(r.handler(serviceName))
+	epInformer.AddEventHandler(r.handler(serviceName))
+	podInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{UpdateFunc: r.onPodUpdate})
+	openlog.Info(fmt.Sprintf("kubernetes discovery is watching namespace [%s]", r.namespace))
+}
+
+// lister returns nil if discovery is not started
+func (r *ServiceDiscovery) lister() *listers {
+	l, _ := r.listers.Load().(*listers)
+	return l
+}
+
+// reportWatchError marks discovery unhealthy once list or watch of an informer fails,
+// it is healthy again once objects are listed and handled
+func (r *ServiceDiscovery) reportWatchError(i cache.SharedIndexInformer) {
+	err := i.SetWatchErrorHandler(func(reflector *cache.Reflector, err error) {
+		r.ReportSync(err)
+		cache.DefaultWatchErrorHandler(reflector, err)
+	})
+	if err != nil {
+		openlog.Warn("set watch error handler failed: " + err.Error())
+	}
+}
+
+// syncStop returns a channel which is closed once discovery is closed or timeout
+func (r *ServiceDiscovery) syncStop(timeout time.Duration) <-chan struct{} {
+	c := make(chan struct{})
+	go func() {
+		defer close(c)
+		select {
+		case <-r.stop:
+		case <-time.After(timeout):
+		}
+	}()
+	return c
+}
+
+// handler syncs the service which object belongs to
+func (r *ServiceDiscovery) handler(key func(interface{}) string) cache.ResourceEventHandler {
+	sync := func(obj interface{}) {
+		r.ReportSync(nil)
+		if name := key(obj); name != "" {
+			r.sync(name)
+		}
+	}
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    sync,
+		UpdateFunc: func(_, obj interface{}) { sync(obj) },
+		DeleteFunc: sync,
+	}
+}
+
+// serviceName returns the service name of Service, Endpoints and EndpointSlice
+func serviceName(obj interface{}) string {
+	if t, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = t.Obj
+	}
+	switch o := obj.(type) {
+	case *corev1.Service:
+		return o.Name
+	case *corev1.Endpoints:
+		return o.Name
+	case *discoveryv1beta1.EndpointSlice:
+		return o.Labels[discoveryv1beta1.LabelServiceName]
+	}
+	return ""
+}
+
+// onPodUpdate syncs services selecting the pod once its labels change, labels are instance tags
+func (r *ServiceDiscovery) onPodUpdate(oldObj, newObj interface{}) {
+	r.ReportSync(nil)
+	o, ok1 := oldObj.(*corev1.Pod)
+	n, ok2 := newObj.(*corev1.Pod)
+	if !ok1 || !ok2 || reflect.DeepEqual(o.Labels, n.Labels) {
+		return
+	}
+	list, err := r.lister().services.Services(r.namespace).List(labels.Everything())
+	if err != nil {
+		return
+	}
+	for _, svc := range list {
+		if len(svc.Spec.Selector) == 0 {
+			continue
+		}
+		s := labels.SelectorFromSet(svc.Spec.Selector)
+		if s.Matches(labels.Set(o.Labels)) || s.Matches(labels.Set(n.Labels)) {
+			r.sync(svc.Name)
+		}
+	}
+}
+
+// sync refreshes instance cache of a service, cache is deleted if the service does not exist
+func (r *ServiceDiscovery) sync(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	l := r.lister()
+	svc, err := l.services.Services(r.namespace).Get(name)
+	if errors.IsNotFound(err) {
+		r.index.Delete(name)
+		openlog.Info(fmt.Sprintf("service [%s] is removed from kubernetes", name))
+		return
+	}
+	if err != nil {
+		openlog.Error(fmt.Sprintf("get service [%s] failed: %s", name, err))
+		return
+	}
+	c := newCollector()
+	if r.endpointSlices {
+		r.fromEndpointSlices(l, svc, c)
+	} else {
+		r.fromEndpoints(l, svc, c)
+	}
+	health.RefreshCacheIndex(r.index, name, c.ups, c.downs)
+}
+
+// port is a named port of Endpoints or EndpointSlice
+type port struct {
+	name        string
+	appProtocol string
+	port        int32
+}
+
+// address is an address of Endpoints or EndpointSlice
+type address struct {
+	ip        string
+	hostname  string
+	targetRef *corev1.ObjectReference
+	topology  map[string]string
+	ready     bool
+}
+
+// collector collects instances of a service, a pod may be in several subsets or slices with different ports,
+// it is one instance with endpoints of all of them
+type collector struct {
+	ups   []*registry.MicroServiceInstance
+	downs map[string]struct{}
+	byID  map[string]*registry.MicroServiceInstance
+}
+
+func newCollector() *collector {
+	return &collector{
+		ups:   make([]*registry.MicroServiceInstance, 0),
+		downs: make(map[string]struct{}),
+		byID:  make(map[string]*registry.MicroServiceInstance),
+	}
+}
+
+func (c *collector) add(ins *registry.MicroServiceInstance) {
+	if ins.Status != common.DefaultStatus {
+		c.downs[ins.InstanceID] = struct{}{}
+		return
+	}
+	old, ok := c.byID[ins.InstanceID]
+	if !ok {
+		c.byID[ins.InstanceID] = ins
+		c.ups = append(c.ups, ins)
+		return
+	}
+	for k, ep := range ins.EndpointsMap {
+		if _, ok := old.EndpointsMap[k]; !ok {
+			old.EndpointsMap[k] = ep
+		}
+	}
+	if old.DefaultEndpoint == "" {
+		old.DefaultEndpoint, old.DefaultProtocol = ins.DefaultEndpoint, ins.DefaultProtocol
+	}
+}
+
+func (r *ServiceDiscovery) fromEndpoints(l *listers, svc *corev1.Service, c *collector) {
+	ep, err := l.endpoints.Endpoints(r.namespace).Get(svc.Name)
+	if err != nil {
+		return
+	}
+	for _, subset := range ep.Subsets {
+		ports := make([]port, 0, len(subset.Ports))
+		for _, p := range subset.Ports {
+			ports = append(ports, port{name: p.Name, appProtocol: stringValue(p.AppProtocol), port: p.Port})
+		}
+		add := func(addrs []corev1.EndpointAddress, ready bool) {
+			for _, a := range addrs {
+				c.add(toInstance(l, svc, address{
+					ip: a.IP, hostname: a.Hostname, targetRef: a.TargetRef,
+					topology: nodeLabels(l, a.NodeName), ready: ready,
+				}, ports))
+			}
+		}
+		add(subset.Addresses, true)
+		add(subset.NotReadyAddresses, false)
+	}
+}
+
+func (r *ServiceDiscovery) fromEndpointSlices(l *listers, svc *corev1.Service, c *collector) {
+	list, err := l.slices.EndpointSlices(r.namespace).List(labels.SelectorFromSet(labels.Set{
+		discoveryv1beta1.LabelServiceName: svc.Name,
+	}))
+	if err != nil {
+		return
+	}
+	for _, slice := range list {
+		if slice.AddressType == discoveryv1beta1.AddressTypeFQDN {
+			continue
+		}
+		ports := make([]port, 0, len(slice.Ports))
+		for _, p := range slice.Ports {
+			if p.Port == nil {
+				continue
+			}
+			ports = append(ports, port{name: stringValue(p.Name), appProtocol: stringValue(p.AppProtocol), port: *p.Port})
+		}
+		for _, e := range slice.Endpoints {
+			ready := e.Conditions.Ready == nil || *e.Conditions.Ready
+			for _, ip := range e.Addresses {
+				c.add(toInstance(l, svc, address{
+					ip: ip, hostname: stringValue(e.Hostname), targetRef: e.TargetRef,
+					topology: e.Topology, ready: ready,
+				}, ports))
+			}
+		}
+	}
+}
+
+// nodeLabels returns labels of a node, topology labels of it are data center of instances on it
+func nodeLabels(l *listers, name *string) map[string]string {
+	if name == nil || l.nodes == nil {
+		return nil
+	}
+	n, err := l.nodes.Get(*name)
+	if err != nil {
+		return nil
+	}
+	return n.Labels
+}
+
+// toInstance converts an address to instance, Service labels and Pod labels are instance metadata,
+// Pod labels take precedence
+func toInstance(l *listers, svc *corev1.Service, a address, ports []port) *registry.MicroServiceInstance {
+	md := make(map[string]string, len(svc.Labels))
+	for k, v := range svc.Labels {
+		md[k] = v
+	}
+	id := svc.Namespace + "/" + a.ip
+	host := a.hostname
+	if ref := a.targetRef; ref != nil {
+		if ref.UID != "" {
+			id = string(ref.UID)
+		}
+		if host == "" {
+			host = ref.Name
+		}
+		if ref.Kind == "Pod" && l.pods != nil {
+			if pod, err := l.pods.Pods(ref.Namespace).Get(ref.Name); err == nil {
+				for k, v := range pod.Labels {
+					md[k] = v
+				}
+			}
+		}
+	}
+	if host == "" {
+		host = a.ip
+	}
+	version, app := versionAndApp(md)
+	md[common.BuildinTagVersion] = version
+
+	eps := make([]string, 0, len(ports))
+	for _, p := range ports {
+		proto, ssl := protocol(p)
+		if proto == "" {
+			continue
+		}
+		ep := proto + "://" + net.JoinHostPort(a.ip, strconv.Itoa(int(p.port)))
+		if ssl {
+			ep += "?sslEnabled=true"
+		}
+		eps = append(eps, ep)
+	}
+	m, p := registry.GetProtocolMap(eps)
+	ins := &registry.MicroServiceInstance{
+		InstanceID:     id,
+		HostName:       host,
+		ServiceID:      string(svc.UID),
+		ServiceName:    svc.Name,
+		App:            app,
+		Version:        version,
+		Status:         common.DefaultStatus,
+		Metadata:       md,
+		EndpointsMap:   m,
+		DataCenterInfo: dataCenter(a.topology),
+	}
+	if !a.ready {
+		ins.Status = runtime.StatusDown
+	}
+	if len(m) != 0 {
+		ins.DefaultEndpoint = m[p].GenEndpoint()
+		ins.DefaultProtocol = p
+	}
+	return ins.WithAppID(app)
+}
+
+func toMicroService(svc *corev1.Service) *registry.MicroService {
+	version, app := versionAndApp(svc.Labels)
+	return &registry.MicroService{
+		ServiceID:   string(svc.UID),
+		ServiceName: svc.Name,
+		AppID:       app,
+		Version:     version,
+		Status:      common.DefaultStatus,
+		Metadata:    svc.Labels,
+	}
+}
+
+func versionAndApp(md map[string]string) (string, string) {
+	version := md[LabelVersion]
+	if version == "" {
+		version = md[LabelK8sVersion]
+	}
+	if version == "" {
+		version = common.DefaultVersion
+	}
+	app := md[LabelApp]
+	if app == "" {
+		app = common.DefaultApp
+	}
+	return version, app
+}
+
+// protocol returns the chassis protocol of a port, app protocol takes precedence,
+// otherwise the prefix of port name is used, like http-web or grpc.
+// unnamed port is rest, unknown protocol is ignored
+func protocol(p port) (string, bool) {
+	name := p.appProtocol
+	if name == "" {
+		name = strings.SplitN(p.name, "-", 2)[0]
+	}
+	switch strings.ToLower(name) {
+	case "", common.HTTP, common.ProtocolRest:
+		return common.ProtocolRest, false
+	case common.HTTPS:
+		return common.ProtocolRest, true
+	case "highway", "grpc":
+		return strings.ToLower(name), false
+	}
+	return "", false
+}
+
+// dataCenter converts topology labels to data center, region is used as data center name
+func dataCenter(topology map[string]string) *registry.DataCenterInfo {
+	region := topology[corev1.LabelTopologyRegion]
+	if region == "" {
+		region = topology[labelLegacyRegion]
+	}
+	zone := topology[corev1.LabelTopologyZone]
+	if zone == "" {
+		zone = topology[labelLegacyZone]
+	}
+	if region == "" && zone == "" {
+		return nil
+	}
+	return &registry.DataCenterInfo{Name: region, Region: region, AvailableZone: zone}
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// Close stops informers
+func (r *ServiceDiscovery) Close() error {
+	r.stopOnce.Do(func() { close(r.stop) })
+	return nil
+}
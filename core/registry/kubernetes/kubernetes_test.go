@@ -0,0 +1,235 @@
+package kubernetes_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/go-chassis/v2/core/registry/kubernetes"
+	"github.com/go-chassis/go-chassis/v2/pkg/runtime"
+	utiltags "github.com/go-chassis/go-chassis/v2/pkg/util/tags"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1beta1 "k8s.io/api/discovery/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const ns = "test"
+
+func init() {
+	archaius.Init(archaius.WithMemorySource())
+	runtime.App = common.DefaultApp
+}
+
+func strPtr(s string) *string { return &s }
+
+func newService(name string, labels map[string]string) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns, UID: types.UID("uid-" + name), Labels: labels},
+		Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": name}},
+	}
+}
+
+func newPod(name, app, version string) *corev1.Pod {
+	return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name: name, Namespace: ns, UID: types.UID("uid-" + name),
+		Labels: map[string]string{"app": app, kubernetes.LabelVersion: version},
+	}}
+}
+
+func podRef(name string) *corev1.ObjectReference {
+	return &corev1.ObjectReference{Kind: "Pod", Namespace: ns, Name: name, UID: types.UID("uid-" + name)}
+}
+
+func find(t *testing.T, sd registry.ServiceDiscovery, name string, tags utiltags.Tags) []*registry.MicroServiceInstance {
+	ins, err := sd.FindMicroServiceInstances("", name, tags)
+	assert.NoError(t, err)
+	return ins
+}
+
+func TestServiceDiscovery_Endpoints(t *testing.T) {
+	registry.EnableRegistryCache()
+	c := fake.NewSimpleClientset(
+		newService("orders", map[string]string{kubernetes.LabelApp: "shop"}),
+		newPod("orders-1", "orders", "1.0.0"),
+		newPod("orders-2", "orders", "2.0.0"),
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: map[string]string{
+			corev1.LabelTopologyRegion: "cn-north-1",
+			corev1.LabelTopologyZone:   "az1",
+		}}},
+		&corev1.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: ns},
+			Subsets: []corev1.EndpointSubset{{
+				Addresses: []corev1.EndpointAddress{
+					{IP: "10.0.0.1", TargetRef: podRef("orders-1"), NodeName: strPtr("node-1")},
+					{IP: "10.0.0.2", TargetRef: podRef("orders-2")},
+				},
+				NotReadyAddresses: []corev1.EndpointAddress{{IP: "10.0.0.3"}},
+				Ports: []corev1.EndpointPort{
+					{Name: "http-api", Port: 8080},
+					{Name: "grpc", Port: 9090},
+					{Name: "metrics-x", Port: 9100},
+				},
+			}},
+		},
+	)
+	sd := kubernetes.New(c, ns, false)
+	sd.NodeTopology = true
+	defer sd.Close()
+	sd.AutoSync()
+
+	var ins []*registry.MicroServiceInstance
+	assert.Eventually(t, func() bool {
+		ins = find(t, sd, "orders", utiltags.NewDefaultTag("1.0.0", "shop"))
+		return len(ins) == 1
+	}, 3*time.Second, 20*time.Millisecond)
+	i := ins[0]
+	assert.Equal(t, "uid-orders-1", i.InstanceID)
+	assert.Equal(t, "orders-1", i.HostName)
+	assert.Equal(t, "uid-orders", i.ServiceID)
+	assert.Equal(t, "shop", i.Metadata[common.BuildinTagApp])
+	assert.Equal(t, "10.0.0.1:8080", i.EndpointsMap[common.ProtocolRest].Address)
+	assert.Equal(t, "10.0.0.1:9090", i.EndpointsMap["grpc"].Address)
+	assert.Equal(t, 2, len(i.EndpointsMap))
+	assert.Equal(t, "cn-north-1", i.DataCenterInfo.Region)
+	assert.Equal(t, "az1", i.DataCenterInfo.AvailableZone)
+
+	ins = find(t, sd, "orders", utiltags.NewDefaultTag("", "shop"))
+	assert.Equal(t, 1, len(ins))
+	assert.Equal(t, "2.0.0", ins[0].Version)
+	assert.Nil(t, ins[0].DataCenterInfo)
+	assert.Nil(t, find(t, sd, "orders", utiltags.Tags{}))
+
+	ms, err := sd.GetMicroService("uid-orders")
+	assert.NoError(t, err)
+	assert.Equal(t, "shop", ms.AppID)
+	assert.Equal(t, common.DefaultVersion, ms.Version)
+
+	t.Run("pod labels change", func(t *testing.T) {
+		p := newPod("orders-2", "orders", "1.0.0")
+		_, err := c.CoreV1().Pods(ns).Update(context.TODO(), p, metav1.UpdateOptions{})
+		assert.NoError(t, err)
+		assert.Eventually(t, func() bool {
+			return len(find(t, sd, "orders", utiltags.NewDefaultTag("1.0.0", "shop"))) == 2
+		}, 3*time.Second, 20*time.Millisecond)
+	})
+	t.Run("service deleted", func(t *testing.T) {
+		assert.NoError(t, c.CoreV1().Services(ns).Delete(context.TODO(), "orders", metav1.DeleteOptions{}))
+		assert.Eventually(t, func() bool {
+			_, ok := registry.MicroserviceInstanceIndex.Get("orders", nil)
+			return !ok
+		}, 3*time.Second, 20*time.Millisecond)
+	})
+}
+
+func TestServiceDiscovery_SeveralSubsets(t *testing.T) {
+	registry.EnableRegistryCache()
+	c := fake.NewSimpleClientset(
+		newService("carts", nil),
+		newPod("carts-1", "carts", "1.0.0"),
+		&corev1.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{Name: "carts", Namespace: ns},
+			Subsets: []corev1.EndpointSubset{
+				{
+					Addresses: []corev1.EndpointAddress{{IP: "10.0.2.1", TargetRef: podRef("carts-1")}},
+					Ports:     []corev1.EndpointPort{{Name: "http", Port: 8080}},
+				},
+				{
+					Addresses: []corev1.EndpointAddress{{IP: "10.0.2.1", TargetRef: podRef("carts-1")}},
+					Ports:     []corev1.EndpointPort{{Name: "grpc", Port: 9090}},
+				},
+			},
+		},
+	)
+	sd := kubernetes.New(c, ns, false)
+	defer sd.Close()
+	// micro service is read while discovery starts
+	go sd.AutoSync()
+	assert.Eventually(t, func() bool {
+		_, err := sd.GetMicroService("uid-carts")
+		return err == nil
+	}, 3*time.Second, time.Millisecond)
+
+	var ins []*registry.MicroServiceInstance
+	assert.Eventually(t, func() bool {
+		ins = find(t, sd, "carts", utiltags.NewDefaultTag("1.0.0", common.DefaultApp))
+		return len(ins) != 0
+	}, 3*time.Second, 20*time.Millisecond)
+	assert.Equal(t, 1, len(ins), "pod in several subsets is one instance")
+	assert.Equal(t, "10.0.2.1:8080", ins[0].EndpointsMap[common.ProtocolRest].Address)
+	assert.Equal(t, "10.0.2.1:9090", ins[0].EndpointsMap["grpc"].Address)
+}
+
+func TestServiceDiscovery_EndpointSlices(t *testing.T) {
+	registry.EnableRegistryCache()
+	ready, notReady := true, false
+	c := fake.NewSimpleClientset(
+		newService("users", nil),
+		&discoveryv1beta1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{Name: "users-abc", Namespace: ns, Labels: map[string]string{
+				discoveryv1beta1.LabelServiceName: "users",
+			}},
+			AddressType: discoveryv1beta1.AddressTypeIPv4,
+			Endpoints: []discoveryv1beta1.Endpoint{
+				{Addresses: []string{"10.0.1.1"}, Conditions: discoveryv1beta1.EndpointConditions{Ready: &ready},
+					Hostname: strPtr("users-0"), Topology: map[string]string{corev1.LabelTopologyZone: "az2"}},
+				{Addresses: []string{"10.0.1.2"}, Conditions: discoveryv1beta1.EndpointConditions{Ready: &notReady}},
+			},
+			Ports: []discoveryv1beta1.EndpointPort{{Name: strPtr("https"), Port: func() *int32 { p := int32(8443); return &p }()}},
+		},
+	)
+	sd := kubernetes.New(c, ns, true)
+	defer sd.Close()
+	sd.AutoSync()
+
+	var ins []*registry.MicroServiceInstance
+	assert.Eventually(t, func() bool {
+		ins = find(t, sd, "users", utiltags.Tags{})
+		return len(ins) == 1
+	}, 3*time.Second, 20*time.Millisecond)
+	assert.Equal(t, "users-0", ins[0].HostName)
+	assert.Equal(t, "10.0.1.1:8443", ins[0].EndpointsMap[common.ProtocolRest].Address)
+	assert.True(t, ins[0].EndpointsMap[common.ProtocolRest].IsSSLEnable())
+	assert.Equal(t, "az2", ins[0].DataCenterInfo.AvailableZone)
+
+	t.Run("endpoint becomes ready", func(t *testing.T) {
+		s, err := c.DiscoveryV1beta1().EndpointSlices(ns).Get(context.TODO(), "users-abc", metav1.GetOptions{})
+		assert.NoError(t, err)
+		s.Endpoints[1].Conditions.Ready = &ready
+		_, err = c.DiscoveryV1beta1().EndpointSlices(ns).Update(context.TODO(), s, metav1.UpdateOptions{})
+		assert.NoError(t, err)
+		assert.Eventually(t, func() bool {
+			return len(find(t, sd, "users", utiltags.Tags{})) == 2
+		}, 3*time.Second, 20*time.Millisecond)
+	})
+}
+
+func TestServiceDiscovery_Healthy(t *testing.T) {
+	registry.EnableRegistryCache()
+	c := fake.NewSimpleClientset(newService("users", nil))
+	var failing int32 = 1
+	c.PrependWatchReactor("endpoints", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		if atomic.LoadInt32(&failing) == 1 {
+			return true, nil, errors.New("endpoints is forbidden")
+		}
+		return false, nil, nil
+	})
+	sd := kubernetes.New(c, ns, false)
+	defer sd.Close()
+	sd.AutoSync()
+	assert.Eventually(t, func() bool { return sd.Healthy() != nil }, 3*time.Second, 20*time.Millisecond)
+
+	atomic.StoreInt32(&failing, 0)
+	_, err := c.CoreV1().Services(ns).Update(context.TODO(), newService("users", map[string]string{"a": "b"}), metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return sd.Healthy() == nil }, 3*time.Second, 20*time.Millisecond)
+}
@@ -0,0 +1,92 @@
+package registry
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// NoopRegistrator does not register to any registry, it only generates ids,
+// it is used by registry plugins which instances are registered by others, like a static file or kubernetes
+type NoopRegistrator struct{}
+
+// NewNoopRegistrator returns noop registrator
+func NewNoopRegistrator(opts Options) Registrator {
+	return &NoopRegistrator{}
+}
+
+// RegisterService returns service id
+func (r *NoopRegistrator) RegisterService(ms *MicroService) (string, error) {
+	if ms.ServiceID != "" {
+		return ms.ServiceID, nil
+	}
+	return newID()
+}
+
+// RegisterServiceInstance returns instance id
+func (r *NoopRegistrator) RegisterServiceInstance(sid string, instance *MicroServiceInstance) (string, error) {
+	if instance.InstanceID != "" {
+		return instance.InstanceID, nil
+	}
+	return newID()
+}
+
+// RegisterServiceAndInstance returns service id and instance id
+func (r *NoopRegistrator) RegisterServiceAndInstance(ms *MicroService, instance *MicroServiceInstance) (string, string, error) {
+	sid, err := r.RegisterService(ms)
+	if err != nil {
+		return "", "", err
+	}
+	iid, err := r.RegisterServiceInstance(sid, instance)
+	if err != nil {
+		return "", "", err
+	}
+	return sid, iid, nil
+}
+
+// Heartbeat always succeeds
+func (r *NoopRegistrator) Heartbeat(microServiceID, microServiceInstanceID string) (bool, error) {
+	return true, nil
+}
+
+// WSHeartbeat always succeeds
+func (r *NoopRegistrator) WSHeartbeat(microServiceID, microServiceInstanceID string, callback func()) (bool, error) {
+	return true, nil
+}
+
+// UnRegisterMicroServiceInstance does nothing
+func (r *NoopRegistrator) UnRegisterMicroServiceInstance(microServiceID, microServiceInstanceID string) error {
+	return nil
+}
+
+// UpdateMicroServiceInstanceStatus does nothing
+func (r *NoopRegistrator) UpdateMicroServiceInstanceStatus(microServiceID, microServiceInstanceID, status string) error {
+	return nil
+}
+
+// UpdateMicroServiceProperties does nothing
+func (r *NoopRegistrator) UpdateMicroServiceProperties(microServiceID string, properties map[string]string) error {
+	return nil
+}
+
+// UpdateMicroServiceInstanceProperties does nothing
+func (r *NoopRegistrator) UpdateMicroServiceInstanceProperties(microServiceID, microServiceInstanceID string, properties map[string]string) error {
+	return nil
+}
+
+// AddSchemas does nothing
+func (r *NoopRegistrator) AddSchemas(microServiceID, schemaName, schemaInfo string) error {
+	return nil
+}
+
+// Close does nothing
+func (r *NoopRegistrator) Close() error {
+	return nil
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,19 @@
+package registry_test
+
+import (
+	"testing"
+
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNoopRegistrator(t *testing.T) {
+	r := registry.NewNoopRegistrator(registry.Options{})
+	sid, iid, err := r.RegisterServiceAndInstance(&registry.MicroService{ServiceID: "sid"}, &registry.MicroServiceInstance{})
+	assert.NoError(t, err)
+	assert.Equal(t, "sid", sid)
+	assert.Equal(t, 32, len(iid))
+	ok, err := r.Heartbeat(sid, iid)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
@@ -15,4 +15,15 @@ type Options struct {
 	Verbose    bool
 	Version    string
 	ConfigPath string
+	// Index is the instance cache of a discovery plugin, MicroserviceInstanceIndex is used if it is nil
+	Index CacheIndex
+}
+
+// InstanceIndex returns the cache a discovery plugin saves instances into, it is MicroserviceInstanceIndex
+// unless the plugin is given its own cache, like backends of composite discovery
+func (o Options) InstanceIndex() CacheIndex {
+	if o.Index != nil {
+		return o.Index
+	}
+	return globalIndex{}
 }
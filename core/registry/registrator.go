@@ -99,47 +99,39 @@ func NewRegistrator(name string, opts Options) (Registrator, error) {
 	}
 	return f(opts), nil
 }
-func getSpecifiedOptions() (oR, oSD, oCD Options, err error) {
-	hostsR, schemeR, err := URIs2Hosts(strings.Split(config.GetRegistratorAddress(), ","))
+
+// NewOptions returns options of a plugin with comma separated addresses,
+// tls config of the tag is loaded if addresses are https
+func NewOptions(address, tag string) (opts Options, err error) {
+	hosts, scheme, err := URIs2Hosts(strings.Split(address, ","))
 	if err != nil {
 		return
 	}
-	oR.Addrs = hostsR
-	oR.Version = config.GetRegistratorAPIVersion()
-	oR.TLSConfig, err = getTLSConfig(schemeR, RTag)
+	opts.Addrs = hosts
+	opts.TLSConfig, err = getTLSConfig(scheme, tag)
 	if err != nil {
 		return
 	}
-	if oR.TLSConfig != nil {
-		oR.EnableSSL = true
+	if opts.TLSConfig != nil {
+		opts.EnableSSL = true
 	}
-	hostsSD, schemeSD, err := URIs2Hosts(strings.Split(config.GetServiceDiscoveryAddress(), ","))
-	if err != nil {
+	return
+}
+
+func getSpecifiedOptions() (oR, oSD, oCD Options, err error) {
+	if oR, err = NewOptions(config.GetRegistratorAddress(), RTag); err != nil {
 		return
 	}
-	oSD.Addrs = hostsSD
-	oSD.Version = config.GetServiceDiscoveryAPIVersion()
-	oSD.ConfigPath = config.GetServiceDiscoveryConfigPath()
-	oSD.TLSConfig, err = getTLSConfig(schemeSD, SDTag)
-	if err != nil {
+	oR.Version = config.GetRegistratorAPIVersion()
+	if oSD, err = NewOptions(config.GetServiceDiscoveryAddress(), SDTag); err != nil {
 		return
 	}
-	if oSD.TLSConfig != nil {
-		oSD.EnableSSL = true
-	}
-	hostsCD, schemeCD, err := URIs2Hosts(strings.Split(config.GetContractDiscoveryAddress(), ","))
-	if err != nil {
+	oSD.Version = config.GetServiceDiscoveryAPIVersion()
+	oSD.ConfigPath = config.GetServiceDiscoveryConfigPath()
+	if oCD, err = NewOptions(config.GetContractDiscoveryAddress(), CDTag); err != nil {
 		return
 	}
-	oCD.Addrs = hostsCD
 	oCD.Version = config.GetContractDiscoveryAPIVersion()
-	oCD.TLSConfig, err = getTLSConfig(schemeCD, CDTag)
-	if err != nil {
-		return
-	}
-	if oCD.TLSConfig != nil {
-		oCD.EnableSSL = true
-	}
 	return
 }
 
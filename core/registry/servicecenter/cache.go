@@ -32,16 +32,18 @@ const (
 	InstanceIDIsNotExist = "instanceIdIsNotExist"
 )
 
-// CacheManager cache manager
+// CacheManager cache manager, instances are saved into index
 type CacheManager struct {
 	registryClient *sc.Client
+	health         *registry.SyncHealth
+	index          registry.CacheIndex
 }
 
 // AutoSync automatically sync the running instances
 func (c *CacheManager) AutoSync() {
 	c.refreshCache()
 	if config.GetServiceDiscoveryWatch() {
-		err := c.registryClient.WatchMicroService(runtime.ServiceID, watch)
+		err := c.registryClient.WatchMicroService(runtime.ServiceID, c.watch)
 		if err != nil {
 			openlog.Error(fmt.Sprintf("watch failed. Self Micro service Id:%s. %s", runtime.ServiceID, err))
 		}
@@ -75,6 +77,9 @@ func (c *CacheManager) refreshCache() {
 		}
 	}
 	err := c.pullMicroServiceInstance()
+	if c.health != nil {
+		c.health.ReportSync(err)
+	}
 	if err != nil {
 		openlog.Error(fmt.Sprintf("AutoUpdateMicroserviceInstance failed: %s", err))
 		//connection with sc may lost, reset the revision
@@ -230,16 +235,16 @@ func (c *CacheManager) pullMicroServiceInstance() error {
 		}
 	}
 	instances := RegroupInstances(services, response)
-	filterAndCache(serviceNameSet, instances)
+	filterAndCache(c.index, serviceNameSet, instances)
 
 	return nil
 }
 
 func (c *CacheManager) compareAndDeleteOutdatedProviders(newProviders sets.String) {
-	oldProviders := registry.MicroserviceInstanceIndex.FullCache().Items()
+	oldProviders := c.index.FullCache().Items()
 	for old := range oldProviders {
 		if !newProviders.Has(old) { //provider is outdated, delete it
-			registry.MicroserviceInstanceIndex.Delete(old)
+			c.index.Delete(old)
 			openlog.Info(fmt.Sprintf("Delete the service [%s] in the cache", old))
 		}
 	}
@@ -276,11 +281,11 @@ func getServiceSet(exist []*scregistry.FindService) (sets.String, map[string]set
 
 // set app into instance metadata, split instances into ups and downs
 // set instance to cache by service name
-func filterAndCache(services sets.String, providerInstances map[string][]*registry.MicroServiceInstance) {
+func filterAndCache(index registry.CacheIndex, services sets.String, providerInstances map[string][]*registry.MicroServiceInstance) {
 	//append instances from different app and same service name into one unified slice
 	downs := make(map[string]struct{})
 	if len(providerInstances) == 0 {
-		setEmptyCache(services)
+		setEmptyCache(index, services)
 		return
 	}
 
@@ -300,34 +305,34 @@ func filterAndCache(services sets.String, providerInstances map[string][]*regist
 				up = append(up, ins.WithAppID(ins.App))
 			}
 		}
-		health.RefreshCache(serviceName, up, downs) //save cache after get all instances of a service name
+		health.RefreshCacheIndex(index, serviceName, up, downs) //save cache after get all instances of a service name
 	}
 
 }
 
-func setEmptyCache(services sets.String) {
+func setEmptyCache(index registry.CacheIndex, services sets.String) {
 	for service := range services {
-		_, ok := registry.MicroserviceInstanceIndex.Get(service, nil)
+		_, ok := index.Get(service, nil)
 		if !ok {
 			openlog.Warn(fmt.Sprintf("set [%s] cache to avoid frequent call to service center", service))
-			registry.MicroserviceInstanceIndex.Set(service, make([]*registry.MicroServiceInstance, 0))
+			index.Set(service, make([]*registry.MicroServiceInstance, 0))
 		}
 
 	}
 }
 
 // watch watching micro-service instance status
-func watch(response *sc.MicroServiceInstanceChangedEvent) {
+func (c *CacheManager) watch(response *sc.MicroServiceInstanceChangedEvent) {
 	if response.Instance.Status != sc.MSInstanceUP {
 		response.Action = common.Delete
 	}
 	switch response.Action {
 	case sc.EventCreate:
-		createAction(response)
+		c.createAction(response)
 	case sc.EventDelete:
-		deleteAction(response)
+		c.deleteAction(response)
 	case sc.EventUpdate:
-		updateAction(response)
+		c.updateAction(response)
 	case sc.EventError:
 		openlog.Warn(fmt.Sprintf("MicroServiceInstanceChangedEvent action is error, MicroServiceInstanceChangedEvent = %v", response))
 	default:
@@ -337,9 +342,9 @@ func watch(response *sc.MicroServiceInstanceChangedEvent) {
 }
 
 // createAction added micro-service instance to the cache
-func createAction(response *sc.MicroServiceInstanceChangedEvent) {
+func (c *CacheManager) createAction(response *sc.MicroServiceInstanceChangedEvent) {
 	key := response.Key.ServiceName
-	microServiceInstances, ok := registry.MicroserviceInstanceIndex.Get(key, nil)
+	microServiceInstances, ok := c.index.Get(key, nil)
 	if !ok {
 		openlog.Error(fmt.Sprintf("ServiceID does not exist in MicroServiceInstanceCache,action is EVT_CREATE.key = %s", key))
 		return
@@ -349,19 +354,21 @@ func createAction(response *sc.MicroServiceInstanceChangedEvent) {
 		return
 	}
 	msi := ToMicroServiceInstance(response.Instance).WithAppID(response.Key.AppId)
-	microServiceInstances = append(microServiceInstances, msi)
-	registry.MicroserviceInstanceIndex.Set(key, microServiceInstances)
+	// instances got from cache are shared with it, append to a copy
+	microServiceInstances = append(append(make([]*registry.MicroServiceInstance, 0, len(microServiceInstances)+1),
+		microServiceInstances...), msi)
+	c.index.Set(key, microServiceInstances)
 	openlog.Info(fmt.Sprintf("Cached Instances,action is EVT_CREATE, sid = %s, instances length = %d", response.Instance.ServiceId, len(microServiceInstances)))
 }
 
 // deleteAction delete micro-service instance
-func deleteAction(response *sc.MicroServiceInstanceChangedEvent) {
+func (c *CacheManager) deleteAction(response *sc.MicroServiceInstanceChangedEvent) {
 	key := response.Key.ServiceName
 	openlog.Debug(fmt.Sprintf("Received event EVT_DELETE, sid = %s, endpoints = %s", response.Instance.ServiceId, response.Instance.Endpoints))
 	if err := health.HealthCheck(key, response.Key.Version, response.Key.AppId, ToMicroServiceInstance(response.Instance)); err == nil {
 		return
 	}
-	microServiceInstances, ok := registry.MicroserviceInstanceIndex.Get(key, nil)
+	microServiceInstances, ok := c.index.Get(key, nil)
 	if !ok {
 		openlog.Error(fmt.Sprintf("ServiceID does not exist in MicroserviceInstanceCache, action is EVT_DELETE, key = %s", key))
 		return
@@ -373,14 +380,14 @@ func deleteAction(response *sc.MicroServiceInstanceChangedEvent) {
 		}
 	}
 
-	registry.MicroserviceInstanceIndex.Set(key, newInstances)
+	c.index.Set(key, newInstances)
 	openlog.Debug(fmt.Sprintf("Cached [%d] Instances of service [%s]", len(newInstances), key))
 }
 
 // updateAction update micro-service instance event
-func updateAction(response *sc.MicroServiceInstanceChangedEvent) {
+func (c *CacheManager) updateAction(response *sc.MicroServiceInstanceChangedEvent) {
 	key := response.Key.ServiceName
-	microServiceInstances, ok := registry.MicroserviceInstanceIndex.Get(key, nil)
+	microServiceInstances, ok := c.index.Get(key, nil)
 	if !ok {
 		openlog.Error(fmt.Sprintf("ServiceID does not exist in MicroserviceInstanceCache, action is EVT_UPDATE, sid = %s", key))
 		return
@@ -390,6 +397,8 @@ func updateAction(response *sc.MicroServiceInstanceChangedEvent) {
 		return
 	}
 	msi := ToMicroServiceInstance(response.Instance).WithAppID(response.Key.AppId)
+	// instances got from cache are shared with it, change a copy, so that cache can tell the update
+	microServiceInstances = append(make([]*registry.MicroServiceInstance, 0, len(microServiceInstances)+1), microServiceInstances...)
 	var iidExist = InstanceIDIsNotExist
 	var arrayNum int
 	for k, v := range microServiceInstances {
@@ -406,6 +415,6 @@ func updateAction(response *sc.MicroServiceInstanceChangedEvent) {
 	default:
 		openlog.Warn(fmt.Sprintf("updateAction error, iid:%s", response.Instance.InstanceId))
 	}
-	registry.MicroserviceInstanceIndex.Set(key, microServiceInstances)
+	c.index.Set(key, microServiceInstances)
 	openlog.Info(fmt.Sprintf("Cached Instances,action is EVT_UPDATE, sid = %s, instances length = %d", response.Instance.ServiceId, len(microServiceInstances)))
 }
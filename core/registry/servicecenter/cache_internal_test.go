@@ -0,0 +1,38 @@
+package servicecenter
+
+import (
+	"testing"
+	"time"
+
+	scregistry "github.com/go-chassis/cari/discovery"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/sc-client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateAction(t *testing.T) {
+	registry.EnableRegistryCache()
+	c := &CacheManager{index: registry.NewBackendIndexCache("sc")}
+	c.index.Set("orders", []*registry.MicroServiceInstance{
+		{InstanceID: "1", Version: "1.0.0", Status: sc.MSInstanceUP},
+	})
+	w := registry.WatchInstances(registry.WithServices("orders"))
+	defer w.Stop()
+
+	c.updateAction(&sc.MicroServiceInstanceChangedEvent{
+		Action:   sc.EventUpdate,
+		Key:      &scregistry.MicroServiceKey{ServiceName: "orders", AppId: "shop"},
+		Instance: &scregistry.MicroServiceInstance{InstanceId: "1", Version: "1.0.1", Status: sc.MSInstanceUP},
+	})
+	var e *registry.InstanceEvent
+	select {
+	case e = <-w.Events():
+	case <-time.After(time.Second):
+		t.Fatal("no update event")
+	}
+	assert.Equal(t, registry.EventUpdate, e.Type)
+	assert.Equal(t, "1.0.1", e.Instance.Version)
+	assert.Equal(t, "1.0.0", e.Previous.Version)
+	_, ok := registry.MicroserviceInstanceIndex.Get("orders", nil)
+	assert.False(t, ok, "instances are saved into injected index only")
+}
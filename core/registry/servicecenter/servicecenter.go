@@ -191,9 +191,11 @@ func (r *Registrator) Close() error {
 
 // ServiceDiscovery to represent the object of service center to call the APIs of service center
 type ServiceDiscovery struct {
+	registry.SyncHealth
 	Name           string
 	registryClient *sc.Client
 	opts           sc.Options
+	index          registry.CacheIndex
 }
 
 // GetAllMicroServices : Get all MicroService information.
@@ -249,8 +251,8 @@ func (r *ServiceDiscovery) GetMicroServiceInstances(consumerID, providerID strin
 func (r *ServiceDiscovery) FindMicroServiceInstances(consumerID, microServiceName string, tags utiltags.Tags) ([]*registry.MicroServiceInstance, error) {
 	// TODO: wrap default tags for service center
 	// because sc need version and appID to generate tags
-	tags = wrapTagsForServiceCenter(tags)
-	microServiceInstance, boo := registry.MicroserviceInstanceIndex.Get(microServiceName, tags.KV)
+	tags = registry.WrapTags(tags)
+	microServiceInstance, boo := r.index.Get(microServiceName, tags.KV)
 	appID := tags.AppID()
 	if appID == "" {
 		appID = runtime.App
@@ -264,8 +266,8 @@ func (r *ServiceDiscovery) FindMicroServiceInstances(consumerID, microServiceNam
 			return nil, fmt.Errorf("FindMicroServiceInstances failed, ProviderID: %s, err: %w", microServiceName, err)
 		}
 		providerInstances := RegroupInstances(criteria, providerInstancesResponse)
-		filterAndCache(sets.NewString(microServiceName), providerInstances)
-		microServiceInstance, boo = registry.MicroserviceInstanceIndex.Get(microServiceName, tags.KV)
+		filterAndCache(r.index, sets.NewString(microServiceName), providerInstances)
+		microServiceInstance, boo = r.index.Get(microServiceName, tags.KV)
 		if !boo || microServiceInstance == nil {
 			openlog.Debug(fmt.Sprintf("Find no micro service instances for %s from cache", microServiceName))
 			return nil, nil
@@ -314,6 +316,8 @@ func (r *ServiceDiscovery) WatchMicroService(selfMicroServiceID string, callback
 func (r *ServiceDiscovery) AutoSync() {
 	c := &CacheManager{
 		registryClient: r.registryClient,
+		health:         &r.SyncHealth,
+		index:          r.index,
 	}
 	c.AutoSync()
 }
@@ -541,6 +545,7 @@ func NewServiceDiscovery(options registry.Options) registry.ServiceDiscovery {
 		Name:           ServiceCenter,
 		registryClient: r,
 		opts:           sco,
+		index:          options.InstanceIndex(),
 	}
 }
 func newContractDiscovery(options registry.Options) registry.ContractDiscovery {
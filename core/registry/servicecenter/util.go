@@ -3,10 +3,7 @@ package servicecenter
 import (
 	"fmt"
 	scregistry "github.com/go-chassis/cari/discovery"
-	"github.com/go-chassis/go-chassis/v2/core/common"
 	"github.com/go-chassis/go-chassis/v2/core/registry"
-	"github.com/go-chassis/go-chassis/v2/pkg/runtime"
-	"github.com/go-chassis/go-chassis/v2/pkg/util/tags"
 	"github.com/go-chassis/openlog"
 	"github.com/go-chassis/sc-client"
 	"gopkg.in/yaml.v2"
@@ -73,22 +70,6 @@ func closeClient(r *sc.Client) error {
 	return nil
 }
 
-func wrapTagsForServiceCenter(t utiltags.Tags) utiltags.Tags {
-	if t.KV != nil {
-		if v, ok := t.KV[common.BuildinTagVersion]; !ok || v == "" {
-			t.KV[common.BuildinTagVersion] = common.LatestVersion
-			t.Label += "|" + common.BuildinLabelVersion
-		}
-		if v, ok := t.KV[common.BuildinTagApp]; !ok || v == "" {
-			t.KV[common.BuildinTagApp] = runtime.App
-			t.Label += "|" + common.BuildinTagApp + ":" + runtime.App
-		}
-		return t
-	}
-	//if app and version is empty, need to find with latest version in same app
-	return utiltags.NewDefaultTag(common.LatestVersion, runtime.App)
-}
-
 // GetCriteria generate batch find criteria from provider cache
 func GetCriteria() []*scregistry.FindService {
 	services := make([]*scregistry.FindService, 0)
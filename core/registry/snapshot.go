@@ -0,0 +1,289 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	scregistry "github.com/go-chassis/cari/discovery"
+	"github.com/go-chassis/go-chassis/v2/core/config"
+	"github.com/go-chassis/go-chassis/v2/pkg/metrics"
+	"github.com/go-chassis/openlog"
+	"github.com/patrickmn/go-cache"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricsSnapshotStale is 1 for a service whose instances are loaded from snapshot and not refreshed by registry yet
+const MetricsSnapshotStale = "scb_registry_snapshot_stale"
+
+// ErrSnapshotExpired means the snapshot file is older than the max age
+var ErrSnapshotExpired = errors.New("snapshot is expired")
+
+// ErrSnapshotUnsupported means the discovery cache is replaced by one which can not track stale instances
+var ErrSnapshotUnsupported = errors.New("discovery cache does not support snapshot")
+
+var staleGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: MetricsSnapshotStale,
+	Help: "1 if instances of a service are loaded from discovery cache snapshot and not refreshed by registry yet",
+}, []string{"service"})
+
+// Snapshot is the content of discovery cache saved on disk
+type Snapshot struct {
+	SavedAt          time.Time                             `json:"savedAt"`
+	Instances        map[string][]*MicroServiceInstance    `json:"instances"`
+	Providers        []MicroService                        `json:"providers,omitempty"`
+	SchemaInterfaces map[string][]*scregistry.MicroService `json:"schemaInterfaces,omitempty"`
+	SchemaServices   map[string][]*scregistry.MicroService `json:"schemaServices,omitempty"`
+}
+
+// staleIndex records services loaded from snapshot,
+// a service is no longer stale once registry sets or deletes its instances
+type staleIndex struct {
+	CacheIndex
+
+	mu    sync.Mutex
+	stale map[string]struct{}
+}
+
+func newStaleIndex(index CacheIndex) *staleIndex {
+	return &staleIndex{CacheIndex: index, stale: make(map[string]struct{})}
+}
+
+func (s *staleIndex) Set(service string, instances []*MicroServiceInstance) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.CacheIndex.Set(service, instances)
+	s.refreshed(service)
+}
+
+func (s *staleIndex) Delete(service string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.CacheIndex.Delete(service)
+	s.refreshed(service)
+}
+
+func (s *staleIndex) refreshed(service string) {
+	if _, ok := s.stale[service]; ok {
+		delete(s.stale, service)
+		staleGauge.DeleteLabelValues(service)
+		openlog.Info(fmt.Sprintf("instances of [%s] loaded from snapshot are replaced by registry", service))
+	}
+}
+
+// load sets instances of a service which is not in cache, they stay stale until registry refreshes them
+func (s *staleIndex) load(service string, instances []*MicroServiceInstance) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.CacheIndex.FullCache().Get(service); ok {
+		return false
+	}
+	s.CacheIndex.Set(service, instances)
+	s.stale[service] = struct{}{}
+	staleGauge.WithLabelValues(service).Set(1)
+	return true
+}
+
+func (s *staleIndex) isStale(service string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.stale[service]
+	return ok
+}
+
+// StaleServices returns services whose instances are loaded from snapshot and not refreshed by registry yet
+func StaleServices() []string {
+	s, ok := MicroserviceInstanceIndex.(*staleIndex)
+	if !ok {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	services := make([]string, 0, len(s.stale))
+	for service := range s.stale {
+		services = append(services, service)
+	}
+	return services
+}
+
+// TakeSnapshot copies the discovery cache, stale instances loaded from snapshot are not included
+func TakeSnapshot() *Snapshot {
+	s := &Snapshot{
+		SavedAt:          time.Now(),
+		Instances:        make(map[string][]*MicroServiceInstance),
+		SchemaInterfaces: schemaIndexes(SchemaInterfaceIndexedCache),
+		SchemaServices:   schemaIndexes(SchemaServiceIndexedCache),
+	}
+	si, _ := MicroserviceInstanceIndex.(*staleIndex)
+	for service, item := range MicroserviceInstanceIndex.FullCache().Items() {
+		instances, ok := item.Object.([]*MicroServiceInstance)
+		if !ok || si != nil && si.isStale(service) {
+			continue
+		}
+		s.Instances[service] = instances
+	}
+	for _, item := range ProvidersMicroServiceCache.Items() {
+		if ms, ok := item.Object.(MicroService); ok {
+			s.Providers = append(s.Providers, ms)
+		}
+	}
+	return s
+}
+
+func schemaIndexes(c *cache.Cache) map[string][]*scregistry.MicroService {
+	m := make(map[string][]*scregistry.MicroService)
+	for k, item := range c.Items() {
+		if services, ok := item.Object.([]*scregistry.MicroService); ok {
+			m[k] = services
+		}
+	}
+	return m
+}
+
+// SaveSnapshot writes discovery cache into file, the file is replaced atomically.
+// nothing is written if there is no instance in cache, so that a former snapshot is kept during registry outage
+func SaveSnapshot(path string) error {
+	s := TakeSnapshot()
+	if len(s.Instances) == 0 {
+		return nil
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err = f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// ReadSnapshot reads snapshot file, it returns ErrSnapshotExpired if the snapshot is older than maxAge,
+// 0 means no limit
+func ReadSnapshot(path string, maxAge time.Duration) (*Snapshot, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &Snapshot{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("invalid snapshot [%s]: %w", path, err)
+	}
+	if maxAge > 0 && time.Since(s.SavedAt) > maxAge {
+		return nil, fmt.Errorf("%w: saved at %s", ErrSnapshotExpired, s.SavedAt.Format(time.RFC3339))
+	}
+	return s, nil
+}
+
+// LoadSnapshot fills discovery cache with the snapshot file, only services which are not in cache are loaded,
+// they are marked as stale until registry refreshes them. it returns how many services are loaded
+func LoadSnapshot(path string, maxAge time.Duration) (int, error) {
+	s, err := ReadSnapshot(path, maxAge)
+	if err != nil {
+		return 0, err
+	}
+	si, ok := MicroserviceInstanceIndex.(*staleIndex)
+	if !ok {
+		return 0, ErrSnapshotUnsupported
+	}
+	n := 0
+	for service, instances := range s.Instances {
+		if si.load(service, instances) {
+			n++
+		}
+	}
+	for _, ms := range s.Providers {
+		AddProviderToCache(ms.ServiceName, ms.AppID)
+	}
+	for k, services := range s.SchemaInterfaces {
+		_ = SchemaInterfaceIndexedCache.Add(k, services, 0)
+	}
+	for k, services := range s.SchemaServices {
+		_ = SchemaServiceIndexedCache.Add(k, services, 0)
+	}
+	return n, nil
+}
+
+var (
+	snapshotMu   sync.Mutex
+	snapshotStop chan struct{}
+	snapshotDone chan struct{}
+)
+
+// startSnapshot loads the snapshot if the first sync of discovery fails, which means registry is unreachable,
+// then starts saving snapshot periodically until stopSnapshot is called.
+// discovery which does not implement HealthReporter never loads the snapshot, because it can not tell an outage
+func startSnapshot(sd ServiceDiscovery) {
+	if !config.GetServiceDiscoverySnapshotEnabled() {
+		return
+	}
+	path := config.GetServiceDiscoverySnapshotPath()
+	if h, ok := sd.(HealthReporter); ok {
+		if syncErr := h.Healthy(); syncErr != nil {
+			n, err := LoadSnapshot(path, config.GetServiceDiscoverySnapshotMaxAge())
+			switch {
+			case errors.Is(err, os.ErrNotExist):
+				openlog.Info("no discovery cache snapshot: " + path)
+			case err != nil:
+				openlog.Warn("load discovery cache snapshot failed: " + err.Error())
+			default:
+				openlog.Warn(fmt.Sprintf("registry is unreachable: %s, %d services are loaded from snapshot [%s]",
+					syncErr, n, path))
+			}
+		}
+	}
+	interval := config.GetServiceDiscoverySnapshotInterval()
+	if interval <= 0 {
+		interval = config.DefaultSnapshotInterval
+	}
+	ticker := time.NewTicker(interval)
+	stop, done := make(chan struct{}), make(chan struct{})
+	stopSnapshot()
+	snapshotMu.Lock()
+	snapshotStop, snapshotDone = stop, done
+	snapshotMu.Unlock()
+	go func() {
+		defer close(done)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := SaveSnapshot(path); err != nil {
+					openlog.Error("save discovery cache snapshot failed: " + err.Error())
+				}
+			}
+		}
+	}()
+}
+
+// stopSnapshot stops saving snapshot periodically, it waits for the saving in progress
+func stopSnapshot() {
+	snapshotMu.Lock()
+	defer snapshotMu.Unlock()
+	if snapshotStop != nil {
+		close(snapshotStop)
+		<-snapshotDone
+		snapshotStop, snapshotDone = nil, nil
+	}
+}
+
+func init() {
+	metrics.GetSystemPrometheusRegistry().MustRegister(staleGauge)
+}
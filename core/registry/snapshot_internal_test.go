@@ -0,0 +1,71 @@
+package registry
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/go-archaius"
+	utiltags "github.com/go-chassis/go-chassis/v2/pkg/util/tags"
+	"github.com/stretchr/testify/assert"
+)
+
+type syncingDiscovery struct {
+	SyncHealth
+}
+
+func (d *syncingDiscovery) GetMicroService(string) (*MicroService, error) { return nil, nil }
+func (d *syncingDiscovery) FindMicroServiceInstances(string, string, utiltags.Tags) ([]*MicroServiceInstance, error) {
+	return nil, nil
+}
+func (d *syncingDiscovery) AutoSync()    {}
+func (d *syncingDiscovery) Close() error { return nil }
+
+func TestStartSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.json")
+	archaius.Set("servicecomb.registry.snapshot.enabled", true)
+	archaius.Set("servicecomb.registry.snapshot.path", path)
+	archaius.Set("servicecomb.registry.snapshot.interval", "10ms")
+	defer archaius.Delete("servicecomb.registry.snapshot.enabled")
+	defer archaius.Delete("servicecomb.registry.snapshot.path")
+	defer archaius.Delete("servicecomb.registry.snapshot.interval")
+	EnableRegistryCache()
+	MicroserviceInstanceIndex.Set("orders", []*MicroServiceInstance{{InstanceID: "1"}})
+	assert.NoError(t, SaveSnapshot(path))
+
+	t.Run("sync succeeds, should not load and should save periodically", func(t *testing.T) {
+		EnableRegistryCache()
+		startSnapshot(&syncingDiscovery{})
+		assert.Empty(t, StaleServices())
+		assert.Equal(t, 0, MicroserviceInstanceIndex.FullCache().ItemCount())
+
+		MicroserviceInstanceIndex.Set("orders", []*MicroServiceInstance{{InstanceID: "2"}})
+		assert.Eventually(t, func() bool {
+			s, err := ReadSnapshot(path, 0)
+			return err == nil && s.Instances["orders"][0].InstanceID == "2"
+		}, time.Second, 10*time.Millisecond)
+
+		stopSnapshot()
+		assert.NoError(t, os.Remove(path))
+		time.Sleep(50 * time.Millisecond)
+		_, err := os.Stat(path)
+		assert.True(t, os.IsNotExist(err), "no snapshot is saved after stop")
+		assert.NoError(t, SaveSnapshot(path))
+	})
+	t.Run("sync fails, should load", func(t *testing.T) {
+		EnableRegistryCache()
+		sd := &syncingDiscovery{}
+		sd.ReportSync(errors.New("registry is down"))
+		startSnapshot(sd)
+		defer stopSnapshot()
+		assert.Equal(t, []string{"orders"}, StaleServices())
+	})
+	t.Run("cache is replaced, should not load", func(t *testing.T) {
+		MicroserviceInstanceIndex = NewIndexCache()
+		defer EnableRegistryCache()
+		_, err := LoadSnapshot(path, 0)
+		assert.ErrorIs(t, err, ErrSnapshotUnsupported)
+	})
+}
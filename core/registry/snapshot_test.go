@@ -0,0 +1,71 @@
+package registry_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	scregistry "github.com/go-chassis/cari/discovery"
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot", "registry.json")
+	registry.EnableRegistryCache()
+	assert.NoError(t, registry.SaveSnapshot(path))
+	_, err := os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "empty cache is not saved")
+
+	registry.MicroserviceInstanceIndex.Set("orders", []*registry.MicroServiceInstance{{
+		InstanceID:   "1",
+		EndpointsMap: map[string]*registry.Endpoint{common.ProtocolRest: {Address: "10.0.0.1:8080"}},
+		Metadata:     map[string]string{common.BuildinTagVersion: "1.0.0", common.BuildinTagApp: "shop"},
+	}})
+	registry.MicroserviceInstanceIndex.Set("users", []*registry.MicroServiceInstance{})
+	registry.AddProviderToCache("orders", "shop")
+	registry.SchemaInterfaceIndexedCache.Set("com.shop.Orders", []*scregistry.MicroService{{ServiceId: "s1"}}, 0)
+	assert.NoError(t, registry.SaveSnapshot(path))
+
+	t.Run("load into empty cache", func(t *testing.T) {
+		registry.EnableRegistryCache()
+		registry.MicroserviceInstanceIndex.Set("users", []*registry.MicroServiceInstance{{InstanceID: "live"}})
+		n, err := registry.LoadSnapshot(path, time.Hour)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+		assert.Equal(t, []string{"orders"}, registry.StaleServices())
+
+		ins, ok := registry.MicroserviceInstanceIndex.Get("orders", map[string]string{common.BuildinTagVersion: "1.0.0", common.BuildinTagApp: "shop"})
+		assert.True(t, ok)
+		assert.Equal(t, "10.0.0.1:8080", ins[0].EndpointsMap[common.ProtocolRest].Address)
+		ins, _ = registry.MicroserviceInstanceIndex.Get("users", nil)
+		assert.Equal(t, "live", ins[0].InstanceID)
+		assert.Equal(t, 1, len(registry.GetProvidersFromCache()))
+		v, ok := registry.SchemaInterfaceIndexedCache.Get("com.shop.Orders")
+		assert.True(t, ok)
+		assert.Equal(t, "s1", v.([]*scregistry.MicroService)[0].ServiceId)
+	})
+	t.Run("stale instances are not saved", func(t *testing.T) {
+		registry.MicroserviceInstanceIndex.Delete("users")
+		assert.NoError(t, registry.SaveSnapshot(path))
+		s, err := registry.ReadSnapshot(path, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(s.Instances["orders"]))
+	})
+	t.Run("replaced by registry", func(t *testing.T) {
+		registry.MicroserviceInstanceIndex.Set("orders", []*registry.MicroServiceInstance{{InstanceID: "2"}})
+		assert.Empty(t, registry.StaleServices())
+		assert.NoError(t, registry.SaveSnapshot(path))
+		s, err := registry.ReadSnapshot(path, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, "2", s.Instances["orders"][0].InstanceID)
+	})
+	t.Run("expired", func(t *testing.T) {
+		registry.EnableRegistryCache()
+		_, err := registry.LoadSnapshot(path, time.Nanosecond)
+		assert.ErrorIs(t, err, registry.ErrSnapshotExpired)
+		assert.Equal(t, 0, registry.MicroserviceInstanceIndex.FullCache().ItemCount())
+	})
+}
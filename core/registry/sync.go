@@ -0,0 +1,109 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-chassis/openlog"
+)
+
+// retry interval of a sync loop after it fails
+const (
+	minRetryInterval = 500 * time.Millisecond
+	maxRetryInterval = 30 * time.Second
+)
+
+// Backoff is the retry interval of a sync loop, like a watch or a blocking query,
+// it starts from 500ms and doubles after every failure up to 30s.
+// zero value is ready to use, it is not safe for concurrent use
+type Backoff struct {
+	interval time.Duration
+}
+
+// Wait waits for the interval and doubles it, it returns false if ctx is done before
+func (b *Backoff) Wait(ctx context.Context) bool {
+	if b.interval == 0 {
+		b.interval = minRetryInterval
+	}
+	t := time.NewTimer(b.interval)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+	}
+	if b.interval *= 2; b.interval > maxRetryInterval {
+		b.interval = maxRetryInterval
+	}
+	return true
+}
+
+// Reset makes next Wait start from the min interval again, call it once sync works
+func (b *Backoff) Reset() {
+	b.interval = 0
+}
+
+// HeartbeatKeeper sends heartbeats of instances in background,
+// registry plugins which instances expire after a ttl use it to implement WSHeartbeat.
+// zero value is ready to use
+type HeartbeatKeeper struct {
+	mu    sync.Mutex
+	stops map[string]chan struct{}
+}
+
+// Keep sends a heartbeat, if it succeeds, keeps sending heartbeats at interval until Stop is called,
+// callback is called to register instance again once a heartbeat fails.
+// it replaces the previous heartbeats of the same instance
+func (k *HeartbeatKeeper) Keep(instanceID string, interval time.Duration, heartbeat func() error, callback func()) error {
+	if err := heartbeat(); err != nil {
+		return err
+	}
+	stop := make(chan struct{})
+	k.mu.Lock()
+	if k.stops == nil {
+		k.stops = make(map[string]chan struct{})
+	}
+	if old, ok := k.stops[instanceID]; ok {
+		close(old)
+	}
+	k.stops[instanceID] = stop
+	k.mu.Unlock()
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-t.C:
+			}
+			if err := heartbeat(); err != nil {
+				openlog.Error(fmt.Sprintf("keep alive instance [%s] failed: %s", instanceID, err))
+				callback()
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop stops heartbeats of an instance
+func (k *HeartbeatKeeper) Stop(instanceID string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if stop, ok := k.stops[instanceID]; ok {
+		close(stop)
+		delete(k.stops, instanceID)
+	}
+}
+
+// StopAll stops heartbeats of all instances
+func (k *HeartbeatKeeper) StopAll() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for iid, stop := range k.stops {
+		close(stop)
+		delete(k.stops, iid)
+	}
+}
@@ -0,0 +1,72 @@
+package registry_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff_Wait(t *testing.T) {
+	var b registry.Backoff
+	start := time.Now()
+	assert.True(t, b.Wait(context.Background()))
+	assert.True(t, b.Wait(context.Background()))
+	assert.GreaterOrEqual(t, time.Since(start), 1500*time.Millisecond)
+
+	b.Reset()
+	start = time.Now()
+	assert.True(t, b.Wait(context.Background()))
+	assert.Less(t, time.Since(start), time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.False(t, b.Wait(ctx))
+}
+
+func TestHeartbeatKeeper(t *testing.T) {
+	var k registry.HeartbeatKeeper
+	t.Run("first heartbeat fails", func(t *testing.T) {
+		err := k.Keep("1", time.Millisecond, func() error { return errors.New("gone") }, func() {})
+		assert.Error(t, err)
+	})
+	t.Run("keep until stop", func(t *testing.T) {
+		var beats, callbacks int32
+		err := k.Keep("1", 10*time.Millisecond, func() error {
+			if atomic.AddInt32(&beats, 1) == 3 {
+				return errors.New("gone")
+			}
+			return nil
+		}, func() { atomic.AddInt32(&callbacks, 1) })
+		assert.NoError(t, err)
+		assert.Eventually(t, func() bool { return atomic.LoadInt32(&callbacks) == 1 }, time.Second, 5*time.Millisecond)
+
+		k.Stop("1")
+		// a heartbeat may be sending while stopping
+		time.Sleep(20 * time.Millisecond)
+		n := atomic.LoadInt32(&beats)
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, n, atomic.LoadInt32(&beats))
+	})
+	t.Run("stop all", func(t *testing.T) {
+		var beats int32
+		for _, iid := range []string{"1", "2"} {
+			assert.NoError(t, k.Keep(iid, 10*time.Millisecond, func() error {
+				atomic.AddInt32(&beats, 1)
+				return nil
+			}, func() {}))
+		}
+		assert.Eventually(t, func() bool { return atomic.LoadInt32(&beats) > 4 }, time.Second, 5*time.Millisecond)
+
+		k.StopAll()
+		// a heartbeat may be sending while stopping
+		time.Sleep(20 * time.Millisecond)
+		n := atomic.LoadInt32(&beats)
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, n, atomic.LoadInt32(&beats))
+	})
+}
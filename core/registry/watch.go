@@ -0,0 +1,223 @@
+package registry
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-chassis/openlog"
+)
+
+// DefaultWatchBufferSize is the default number of events a watcher buffers
+const DefaultWatchBufferSize = 100
+
+// EventType is the type of instance event
+type EventType string
+
+// types of instance event
+const (
+	// EventCreate means an instance is added into cache
+	EventCreate EventType = "CREATE"
+	// EventUpdate means an instance in cache is changed
+	EventUpdate EventType = "UPDATE"
+	// EventDelete means an instance is removed from cache
+	EventDelete EventType = "DELETE"
+)
+
+// InstanceEvent is a change of instances in discovery cache.
+// instances are shared with cache, they must not be modified
+type InstanceEvent struct {
+	Type    EventType
+	Service string
+	// Instance is the instance after change, it is the removed instance of a delete event
+	Instance *MicroServiceInstance
+	// Previous is the instance before change, it is set only in update event
+	Previous *MicroServiceInstance
+	// Source is the discovery backend whose cache changes, it is empty for the default cache
+	Source string
+}
+
+// WatchOptions is options of watching instances
+type WatchOptions struct {
+	Services   []string
+	BufferSize int
+}
+
+// WatchOption is option of watching instances
+type WatchOption func(*WatchOptions)
+
+// WithServices only watches instances of those services
+func WithServices(services ...string) WatchOption {
+	return func(o *WatchOptions) {
+		o.Services = append(o.Services, services...)
+	}
+}
+
+// WithBufferSize specifies how many events a watcher buffers, events are dropped once the buffer is full
+func WithBufferSize(size int) WatchOption {
+	return func(o *WatchOptions) {
+		o.BufferSize = size
+	}
+}
+
+// Watcher receives instance events of every discovery plugin,
+// because plugins save instances into cache, events are emitted when cache changes.
+// an instance saved in caches of several backends is created once the first cache has it,
+// and deleted once the last cache removes it
+type Watcher struct {
+	services map[string]struct{}
+	events   chan *InstanceEvent
+	dropped  uint64
+	stop     sync.Once
+}
+
+var (
+	watchersMu sync.RWMutex
+	watchers   = make(map[*Watcher]struct{})
+	watching   int32
+
+	holdersMu sync.Mutex
+	// holders saves caches which have an instance, key is service name and instance id
+	holders = make(map[string]map[*IndexCache]struct{})
+)
+
+// WatchInstances subscribes instance events, watcher must be stopped once it is not used
+func WatchInstances(opts ...WatchOption) *Watcher {
+	o := &WatchOptions{BufferSize: DefaultWatchBufferSize}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.BufferSize < 0 {
+		o.BufferSize = 0
+	}
+	w := &Watcher{events: make(chan *InstanceEvent, o.BufferSize)}
+	if len(o.Services) != 0 {
+		w.services = make(map[string]struct{}, len(o.Services))
+		for _, s := range o.Services {
+			w.services[s] = struct{}{}
+		}
+	}
+	watchersMu.Lock()
+	watchers[w] = struct{}{}
+	atomic.StoreInt32(&watching, int32(len(watchers)))
+	watchersMu.Unlock()
+	return w
+}
+
+// Events returns the channel of events, it is closed once watcher stops
+func (w *Watcher) Events() <-chan *InstanceEvent {
+	return w.events
+}
+
+// Dropped returns how many events are dropped because the buffer is full
+func (w *Watcher) Dropped() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
+// Stop unsubscribes events and closes the channel
+func (w *Watcher) Stop() {
+	w.stop.Do(func() {
+		watchersMu.Lock()
+		delete(watchers, w)
+		atomic.StoreInt32(&watching, int32(len(watchers)))
+		close(w.events)
+		watchersMu.Unlock()
+	})
+}
+
+func (w *Watcher) send(e *InstanceEvent) {
+	if w.services != nil {
+		if _, ok := w.services[e.Service]; !ok {
+			return
+		}
+	}
+	select {
+	case w.events <- e:
+	default:
+		if atomic.AddUint64(&w.dropped, 1) == 1 {
+			openlog.Warn(fmt.Sprintf("instance watcher is too slow, %s event of [%s] is dropped", e.Type, e.Service))
+		}
+	}
+}
+
+// hasWatcher reports if anyone watches instances, so that events are not sent for nothing
+func hasWatcher() bool {
+	return atomic.LoadInt32(&watching) != 0
+}
+
+// publish sends changes of a cache to watchers, creating an instance which is in other caches already
+// and deleting an instance which is still in other caches are not sent, so that watchers see an instance once
+func publish(source *IndexCache, events []*InstanceEvent) {
+	if len(events) == 0 {
+		return
+	}
+	holdersMu.Lock()
+	sent := events[:0]
+	for _, e := range events {
+		e.Source = source.source
+		key := e.Service + "/" + e.Instance.InstanceID
+		caches := holders[key]
+		switch e.Type {
+		case EventCreate:
+			if caches == nil {
+				caches = make(map[*IndexCache]struct{})
+				holders[key] = caches
+			}
+			caches[source] = struct{}{}
+			if len(caches) > 1 {
+				continue
+			}
+		case EventDelete:
+			delete(caches, source)
+			if len(caches) != 0 {
+				continue
+			}
+			delete(holders, key)
+		}
+		sent = append(sent, e)
+	}
+	holdersMu.Unlock()
+	if len(sent) == 0 || !hasWatcher() {
+		return
+	}
+	watchersMu.RLock()
+	defer watchersMu.RUnlock()
+	for w := range watchers {
+		for _, e := range sent {
+			w.send(e)
+		}
+	}
+}
+
+// resetHolders forgets caches which have instances, because caches are created again
+func resetHolders() {
+	holdersMu.Lock()
+	defer holdersMu.Unlock()
+	holders = make(map[string]map[*IndexCache]struct{})
+}
+
+// diffInstances compares instances of a service by id
+func diffInstances(service string, old, instances []*MicroServiceInstance) []*InstanceEvent {
+	events := make([]*InstanceEvent, 0)
+	previous := make(map[string]*MicroServiceInstance, len(old))
+	for _, ins := range old {
+		previous[ins.InstanceID] = ins
+	}
+	for _, ins := range instances {
+		p, ok := previous[ins.InstanceID]
+		switch {
+		case !ok:
+			events = append(events, &InstanceEvent{Type: EventCreate, Service: service, Instance: ins})
+		case p != ins && !reflect.DeepEqual(p, ins):
+			events = append(events, &InstanceEvent{Type: EventUpdate, Service: service, Instance: ins, Previous: p})
+		}
+		delete(previous, ins.InstanceID)
+	}
+	for _, ins := range old {
+		if _, ok := previous[ins.InstanceID]; ok {
+			events = append(events, &InstanceEvent{Type: EventDelete, Service: service, Instance: ins})
+		}
+	}
+	return events
+}
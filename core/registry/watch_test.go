@@ -0,0 +1,98 @@
+package registry_test
+
+import (
+	"testing"
+
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+func next(t *testing.T, w *registry.Watcher) *registry.InstanceEvent {
+	select {
+	case e := <-w.Events():
+		return e
+	default:
+		t.Fatal("no event")
+		return nil
+	}
+}
+
+func TestWatchInstances(t *testing.T) {
+	registry.EnableRegistryCache()
+	index := registry.NewIndexCache()
+	all := registry.WatchInstances()
+	defer all.Stop()
+	orders := registry.WatchInstances(registry.WithServices("orders"), registry.WithBufferSize(2))
+	defer orders.Stop()
+
+	index.Set("orders", []*registry.MicroServiceInstance{{InstanceID: "1", Status: "UP"}, {InstanceID: "2", Status: "UP"}})
+	e := next(t, orders)
+	assert.Equal(t, registry.EventCreate, e.Type)
+	assert.Equal(t, "orders", e.Service)
+	assert.Equal(t, "1", e.Instance.InstanceID)
+	assert.Equal(t, "2", next(t, orders).Instance.InstanceID)
+
+	t.Run("update and delete", func(t *testing.T) {
+		index.Set("orders", []*registry.MicroServiceInstance{{InstanceID: "1", Status: "UP"}, {InstanceID: "2", Status: "TESTING"}})
+		e := next(t, orders)
+		assert.Equal(t, registry.EventUpdate, e.Type)
+		assert.Equal(t, "TESTING", e.Instance.Status)
+		assert.Equal(t, "UP", e.Previous.Status)
+
+		index.Set("orders", []*registry.MicroServiceInstance{{InstanceID: "2", Status: "TESTING"}})
+		e = next(t, orders)
+		assert.Equal(t, registry.EventDelete, e.Type)
+		assert.Equal(t, "1", e.Instance.InstanceID)
+
+		index.Delete("orders")
+		e = next(t, orders)
+		assert.Equal(t, registry.EventDelete, e.Type)
+		assert.Equal(t, "2", e.Instance.InstanceID)
+		assert.Equal(t, 0, len(orders.Events()))
+	})
+	t.Run("filter", func(t *testing.T) {
+		index.Set("users", []*registry.MicroServiceInstance{{InstanceID: "3"}})
+		assert.Equal(t, 0, len(orders.Events()))
+		assert.Equal(t, 6, len(all.Events()))
+	})
+	t.Run("full buffer", func(t *testing.T) {
+		index.Set("orders", []*registry.MicroServiceInstance{{InstanceID: "4"}, {InstanceID: "5"}, {InstanceID: "6"}})
+		assert.Equal(t, 2, len(orders.Events()))
+		assert.Equal(t, uint64(1), orders.Dropped())
+	})
+	t.Run("stop", func(t *testing.T) {
+		orders.Stop()
+		orders.Stop()
+		index.Set("orders", nil)
+		n := 0
+		for range orders.Events() {
+			n++
+		}
+		assert.Equal(t, 2, n)
+	})
+}
+
+func TestWatchInstances_SeveralCaches(t *testing.T) {
+	registry.EnableRegistryCache()
+	a, b := registry.NewBackendIndexCache("a"), registry.NewBackendIndexCache("b")
+	w := registry.WatchInstances()
+	defer w.Stop()
+
+	a.Set("orders", []*registry.MicroServiceInstance{{InstanceID: "1"}})
+	e := next(t, w)
+	assert.Equal(t, registry.EventCreate, e.Type)
+	assert.Equal(t, "a", e.Source)
+	b.Set("orders", []*registry.MicroServiceInstance{{InstanceID: "1"}, {InstanceID: "2"}})
+	e = next(t, w)
+	assert.Equal(t, "2", e.Instance.InstanceID, "instance in cache a already is not created again")
+	assert.Equal(t, "b", e.Source)
+	assert.Equal(t, 0, len(w.Events()))
+
+	a.Delete("orders")
+	assert.Equal(t, 0, len(w.Events()), "instance is still in cache b")
+	b.Set("orders", []*registry.MicroServiceInstance{{InstanceID: "2"}})
+	e = next(t, w)
+	assert.Equal(t, registry.EventDelete, e.Type)
+	assert.Equal(t, "1", e.Instance.InstanceID)
+	assert.Equal(t, "b", e.Source)
+}
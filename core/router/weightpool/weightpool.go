@@ -111,15 +111,16 @@ func (p *Pool) PickOne() *config.RouteTag {
 }
 
 func (p *Pool) refreshGCD(t *config.RouteTag) {
-	p.gcd = gcd(p.gcd, t.Weight)
+	p.gcd = GCD(p.gcd, t.Weight)
 	if p.max < t.Weight {
 		p.max = t.Weight
 	}
 }
 
-func gcd(a, b int) int {
+// GCD returns the greatest common divisor of two weights, it is the other one if a weight is 0
+func GCD(a, b int) int {
 	if b == 0 {
 		return a
 	}
-	return gcd(b, a%b)
+	return GCD(b, a%b)
 }
@@ -69,3 +69,10 @@ func BenchmarkPickOneParallel(b *testing.B) {
 
 	b.ReportAllocs()
 }
+
+func TestGCD(t *testing.T) {
+	assert.Equal(t, 10, wp.GCD(30, 20))
+	assert.Equal(t, 7, wp.GCD(0, 7))
+	assert.Equal(t, 7, wp.GCD(7, 0))
+	assert.Equal(t, 1, wp.GCD(3, 5))
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/go-chassis/v2/pkg/metrics"
+	"github.com/go-chassis/go-chassis/v2/pkg/runtime"
+	"github.com/go-chassis/openlog"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metrics of draining
+const (
+	MetricsInFlight = "scb_server_inflight_requests"
+	MetricsDraining = "scb_server_draining"
+)
+
+var (
+	inFlight sync.Map
+
+	inFlightGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: MetricsInFlight,
+		Help: "in-flight requests of a protocol server",
+	}, []string{"server"})
+	drainingGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: MetricsDraining,
+		Help: "1 if the process is draining, otherwise 0",
+	})
+)
+
+// progressInterval is the interval of draining progress logs
+var progressInterval = time.Second
+
+// GracefulServer is a ProtocolServer which is able to stop in bounded time,
+// it stops accepting new requests, and waits for in-flight requests until ctx is done,
+// then force closes all connections
+type GracefulServer interface {
+	Shutdown(ctx context.Context) error
+}
+
+// TrackRequest increases in-flight requests of a protocol server,
+// server plugin must call the returned func once the request is done
+func TrackRequest(name string) func() {
+	v, _ := inFlight.LoadOrStore(name, new(int64))
+	c := v.(*int64)
+	inFlightGauge.WithLabelValues(name).Set(float64(atomic.AddInt64(c, 1)))
+	return func() {
+		inFlightGauge.WithLabelValues(name).Set(float64(atomic.AddInt64(c, -1)))
+	}
+}
+
+// InFlight returns in-flight requests of a protocol server
+func InFlight(name string) int64 {
+	v, ok := inFlight.Load(name)
+	if !ok {
+		return 0
+	}
+	return atomic.LoadInt64(v.(*int64))
+}
+
+// TotalInFlight returns in-flight requests of all protocol servers
+func TotalInFlight() int64 {
+	var n int64
+	inFlight.Range(func(k, v interface{}) bool {
+		n += atomic.LoadInt64(v.(*int64))
+		return true
+	})
+	return n
+}
+
+// MarkSelfInstanceDown updates self instance status to DOWN, so that consumers stop sending new requests
+func MarkSelfInstanceDown() error {
+	drainingGauge.Set(1)
+	if registry.DefaultRegistrator == nil {
+		return errors.New("registrator is not initialized")
+	}
+	if err := registry.DefaultRegistrator.UpdateMicroServiceInstanceStatus(runtime.ServiceID, runtime.InstanceID, runtime.StatusDown); err != nil {
+		openlog.Error(fmt.Sprintf("mark instance down failed, sid/iid: %s/%s: %s",
+			runtime.ServiceID, runtime.InstanceID, err))
+		return err
+	}
+	openlog.Info("instance is marked DOWN: " + runtime.InstanceID)
+	return nil
+}
+
+// WaitForPropagation waits for consumers to know that the instance is DOWN,
+// servers still serve requests in this period
+func WaitForPropagation(delay time.Duration) {
+	if delay <= 0 {
+		return
+	}
+	openlog.Info(fmt.Sprintf("wait %s for instance status propagation", delay))
+	deadline := time.Now().Add(delay)
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			return
+		case <-ticker.C:
+			openlog.Info(fmt.Sprintf("draining, %d in-flight requests, %s left before stopping servers",
+				TotalInFlight(), time.Until(deadline).Round(time.Second)))
+		}
+	}
+}
+
+// StopServers stops all servers in parallel, in-flight requests have timeout to complete,
+// servers which are not GracefulServer are stopped directly
+func StopServers(timeout time.Duration) {
+	drainingGauge.Set(1)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	for name, s := range servers {
+		wg.Add(1)
+		go func(name string, s ProtocolServer) {
+			defer wg.Done()
+			openlog.Info(fmt.Sprintf("stopping server %s, %d in-flight requests...", name, InFlight(name)))
+			var err error
+			if gs, ok := s.(GracefulServer); ok {
+				err = gs.Shutdown(ctx)
+			} else {
+				err = s.Stop()
+			}
+			if err != nil {
+				openlog.Warn(fmt.Sprintf("server %s failed to stop gracefully, %d requests are dropped: %s",
+					name, InFlight(name), err))
+				return
+			}
+			openlog.Info(name + " server stop success")
+		}(name, s)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			drainingGauge.Set(0)
+			return
+		case <-ticker.C:
+			openlog.Info(fmt.Sprintf("stopping servers, %d in-flight requests", TotalInFlight()))
+		}
+	}
+}
+
+func init() {
+	metrics.GetSystemPrometheusRegistry().MustRegister(inFlightGauge, drainingGauge)
+}
@@ -0,0 +1,100 @@
+package server_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/go-chassis/v2/core/server"
+	"github.com/stretchr/testify/assert"
+)
+
+type drainServer struct {
+	name    string
+	stopped bool
+	err     error
+}
+
+func (d *drainServer) Register(interface{}, ...server.RegisterOption) (string, error) {
+	return "", nil
+}
+func (d *drainServer) Start() error   { return nil }
+func (d *drainServer) String() string { return d.name }
+func (d *drainServer) Stop() error {
+	d.stopped = true
+	return nil
+}
+
+// Shutdown waits for in-flight requests until ctx is done
+func (d *drainServer) Shutdown(ctx context.Context) error {
+	for server.InFlight(d.name) > 0 {
+		select {
+		case <-ctx.Done():
+			d.err = ctx.Err()
+			return d.err
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	d.stopped = true
+	return nil
+}
+
+type plainServer struct {
+	drainServer
+}
+
+func (p *plainServer) Stop() error {
+	p.stopped = true
+	return nil
+}
+
+func TestTrackRequest(t *testing.T) {
+	done1 := server.TrackRequest("track-a")
+	done2 := server.TrackRequest("track-a")
+	done3 := server.TrackRequest("track-b")
+	assert.Equal(t, int64(2), server.InFlight("track-a"))
+	assert.Equal(t, int64(1), server.InFlight("track-b"))
+	assert.Equal(t, int64(0), server.InFlight("track-c"))
+	assert.True(t, server.TotalInFlight() >= 3)
+	done1()
+	done2()
+	done3()
+	assert.Equal(t, int64(0), server.InFlight("track-a"))
+	assert.Equal(t, int64(0), server.InFlight("track-b"))
+}
+
+func TestStopServers(t *testing.T) {
+	servers := server.GetServers()
+	t.Run("in-flight requests complete in time", func(t *testing.T) {
+		s := &drainServer{name: "drain-ok"}
+		p := &plainServer{drainServer{name: "drain-plain"}}
+		servers[s.name] = s
+		servers[p.name] = p
+		defer delete(servers, s.name)
+		defer delete(servers, p.name)
+
+		done := server.TrackRequest(s.name)
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			done()
+		}()
+		server.StopServers(time.Second)
+		assert.True(t, s.stopped)
+		assert.NoError(t, s.err)
+		assert.True(t, p.stopped)
+		assert.Equal(t, int64(0), server.InFlight(s.name))
+	})
+	t.Run("drain timeout", func(t *testing.T) {
+		s := &drainServer{name: "drain-slow"}
+		servers[s.name] = s
+		defer delete(servers, s.name)
+
+		done := server.TrackRequest(s.name)
+		defer done()
+		start := time.Now()
+		server.StopServers(100 * time.Millisecond)
+		assert.True(t, time.Since(start) < time.Second)
+		assert.False(t, s.stopped)
+		assert.Equal(t, context.DeadlineExceeded, s.err)
+	})
+}
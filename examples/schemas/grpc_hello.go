@@ -0,0 +1,55 @@
+package schemas
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// GreeterServer is the server API of Greeter service, the request and response are protobuf well known types,
+// so that it does not need generated code
+type GreeterServer interface {
+	SayHello(context.Context, *wrapperspb.StringValue) (*wrapperspb.StringValue, error)
+}
+
+// GrpcHello is a gRPC service implementation
+type GrpcHello struct {
+}
+
+// SayHello is a method used to reply message
+func (s *GrpcHello) SayHello(ctx context.Context, in *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+	return wrapperspb.String("hello " + in.GetValue()), nil
+}
+
+func greeterSayHelloHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(wrapperspb.StringValue)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GreeterServer).SayHello(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/helloworld.Greeter/SayHello",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GreeterServer).SayHello(ctx, req.(*wrapperspb.StringValue))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// GreeterServiceDesc is the grpc.ServiceDesc for Greeter service,
+// register it with server.WithRPCServiceDesc
+var GreeterServiceDesc = grpc.ServiceDesc{
+	ServiceName: "helloworld.Greeter",
+	HandlerType: (*GreeterServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SayHello",
+			Handler:    greeterSayHelloHandler,
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
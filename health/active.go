@@ -0,0 +1,250 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-chassis/go-archaius/event"
+	"github.com/go-chassis/go-chassis/v2/core/config"
+	"github.com/go-chassis/go-chassis/v2/core/loadbalancer"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/go-chassis/v2/pkg/metrics"
+	"github.com/go-chassis/openlog"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metrics of active health check
+const (
+	MetricsHealthCheckProbes   = "scb_health_check_probes_total"
+	MetricsHealthCheckDuration = "scb_health_check_duration_seconds"
+	MetricsHealthCheckHealthy  = "scb_health_check_healthy"
+)
+
+// watchBufferSize is the buffer of instance events, an instance whose event is dropped is not probed
+const watchBufferSize = 1000
+
+var (
+	probesCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: MetricsHealthCheckProbes,
+		Help: "health check probes of instances by result, success or failure",
+	}, []string{"service", "type", "result"})
+	probeDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    MetricsHealthCheckDuration,
+		Help:    "time a health check probe takes",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"service", "type"})
+	healthyGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: MetricsHealthCheckHealthy,
+		Help: "1 if an instance passes active health check, otherwise 0",
+	}, []string{"service", "instance"})
+)
+
+var (
+	activeOnce sync.Once
+	targetsMu  sync.RWMutex
+	targets    = make(map[string]*target) // key is instance id
+)
+
+// target is an instance which is probed periodically
+type target struct {
+	service   string
+	instance  atomic.Value // *registry.MicroServiceInstance
+	unhealthy int32
+	// successes and failures are consecutive probe results, they are only accessed by run loop
+	successes int
+	failures  int
+	stop      chan struct{}
+}
+
+// EnableActiveCheck starts probing instances in discovery cache, only instances of services whose health check type
+// is configured are probed, services are probed or not once the config changes.
+// unhealthy instances are removed before load balancing, they are selected again once they recover
+func EnableActiveCheck() {
+	activeOnce.Do(func() {
+		w := registry.WatchInstances(registry.WithBufferSize(watchBufferSize))
+		syncTargets()
+		loadbalancer.InstallInstanceFilter(FilterUnhealthy)
+		if err := archaius.RegisterListener(&healthCheckListener{}, config.HealthCheckKey); err != nil {
+			openlog.Error("watch health check config failed: " + err.Error())
+		}
+		go func() {
+			for e := range w.Events() {
+				switch e.Type {
+				case registry.EventCreate, registry.EventUpdate:
+					track(e.Service, e.Instance)
+				case registry.EventDelete:
+					untrack(e.Instance)
+				}
+			}
+		}()
+		openlog.Info("active health check enabled")
+	})
+}
+
+// healthCheckListener syncs probed instances once health check config changes
+type healthCheckListener struct{}
+
+// Event syncs probed instances
+func (l *healthCheckListener) Event(e *event.Event) {
+	openlog.Info(fmt.Sprintf("health check config [%s] changed", e.Key))
+	syncTargets()
+}
+
+// syncTargets probes instances in cache of services whose health check type is configured,
+// and stops probing services whose health check type is removed
+func syncTargets() {
+	for service, item := range registry.MicroserviceInstanceIndex.FullCache().Items() {
+		instances, ok := item.Object.([]*registry.MicroServiceInstance)
+		if !ok {
+			continue
+		}
+		for _, ins := range instances {
+			track(service, ins)
+		}
+	}
+	targetsMu.Lock()
+	defer targetsMu.Unlock()
+	for id, t := range targets {
+		if config.GetHealthCheckType(t.service) == "" {
+			stopLocked(id, t)
+		}
+	}
+}
+
+// track starts probing an instance if health check type of its service is configured,
+// or updates the instance which is probed
+func track(service string, ins *registry.MicroServiceInstance) {
+	if config.GetHealthCheckType(service) == "" {
+		return
+	}
+	targetsMu.Lock()
+	defer targetsMu.Unlock()
+	if t, ok := targets[ins.InstanceID]; ok {
+		t.instance.Store(ins)
+		return
+	}
+	t := &target{service: service, stop: make(chan struct{})}
+	t.instance.Store(ins)
+	targets[ins.InstanceID] = t
+	go t.run()
+}
+
+func untrack(ins *registry.MicroServiceInstance) {
+	targetsMu.Lock()
+	defer targetsMu.Unlock()
+	if t, ok := targets[ins.InstanceID]; ok {
+		stopLocked(ins.InstanceID, t)
+	}
+}
+
+// stopLocked stops probing an instance, the instance is healthy since then
+func stopLocked(id string, t *target) {
+	close(t.stop)
+	delete(targets, id)
+	healthyGauge.DeleteLabelValues(t.service, id)
+}
+
+// run probes the instance every interval, the first probe is delayed randomly so that probes are spread
+func (t *target) run() {
+	timer := time.NewTimer(time.Duration(rand.Int63n(int64(config.GetHealthCheckInterval(t.service)))))
+	defer timer.Stop()
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-timer.C:
+		}
+		t.check()
+		timer.Reset(config.GetHealthCheckInterval(t.service))
+	}
+}
+
+func (t *target) check() {
+	ins := t.instance.Load().(*registry.MicroServiceInstance)
+	typ := config.GetHealthCheckType(t.service)
+	if typ == "" {
+		// health check type is just removed, target is stopped by config listener
+		return
+	}
+	probe := getProbe(typ)
+	if probe == nil {
+		openlog.Warn(fmt.Sprintf("unknown health check type [%s] of [%s]", typ, t.service))
+		return
+	}
+	ep, ok := ins.EndpointsMap[config.GetHealthCheckProtocol(t.service)]
+	if !ok || ep == nil {
+		openlog.Debug(fmt.Sprintf("instance [%s] of [%s] has no endpoint to probe", ins.InstanceID, t.service))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), config.GetHealthCheckTimeout(t.service))
+	start := time.Now()
+	err := probe(ctx, t.service, config.GetHealthCheckPath(t.service), ep)
+	cancel()
+	probeDuration.WithLabelValues(t.service, typ).Observe(time.Since(start).Seconds())
+	if err != nil {
+		probesCounter.WithLabelValues(t.service, typ, "failure").Inc()
+		openlog.Debug(fmt.Sprintf("health check of instance [%s] of [%s] failed: %s", ins.InstanceID, t.service, err))
+		t.successes = 0
+		t.failures++
+		if t.failures >= config.GetHealthCheckUnhealthyThreshold(t.service) {
+			t.setHealthy(ins, false)
+		}
+		return
+	}
+	probesCounter.WithLabelValues(t.service, typ, "success").Inc()
+	t.failures = 0
+	t.successes++
+	if t.successes >= config.GetHealthCheckHealthyThreshold(t.service) {
+		t.setHealthy(ins, true)
+	}
+}
+
+func (t *target) setHealthy(ins *registry.MicroServiceInstance, healthy bool) {
+	if healthy {
+		healthyGauge.WithLabelValues(t.service, ins.InstanceID).Set(1)
+		if atomic.CompareAndSwapInt32(&t.unhealthy, 1, 0) {
+			openlog.Info(fmt.Sprintf("instance [%s] of [%s] is healthy again", ins.InstanceID, t.service))
+		}
+		return
+	}
+	healthyGauge.WithLabelValues(t.service, ins.InstanceID).Set(0)
+	if atomic.CompareAndSwapInt32(&t.unhealthy, 0, 1) {
+		openlog.Warn(fmt.Sprintf("instance [%s] of [%s] is unhealthy", ins.InstanceID, t.service))
+	}
+}
+
+// IsHealthy reports if an instance passes active health check, an instance which is not probed yet is healthy
+func IsHealthy(instanceID string) bool {
+	targetsMu.RLock()
+	defer targetsMu.RUnlock()
+	t, ok := targets[instanceID]
+	return !ok || atomic.LoadInt32(&t.unhealthy) == 0
+}
+
+// FilterUnhealthy removes instances which fail active health check,
+// all instances are kept if none of them is healthy, because probes may be wrong
+func FilterUnhealthy(service string, instances []*registry.MicroServiceInstance) []*registry.MicroServiceInstance {
+	healthy := make([]*registry.MicroServiceInstance, 0, len(instances))
+	for _, ins := range instances {
+		if IsHealthy(ins.InstanceID) {
+			healthy = append(healthy, ins)
+		}
+	}
+	if len(healthy) == len(instances) {
+		return instances
+	}
+	if len(healthy) == 0 {
+		openlog.Debug(fmt.Sprintf("all instances of [%s] are unhealthy, keep them", service))
+		return instances
+	}
+	return healthy
+}
+
+func init() {
+	metrics.GetSystemPrometheusRegistry().MustRegister(probesCounter, probeDuration, healthyGauge)
+}
@@ -0,0 +1,35 @@
+package health
+
+import (
+	"testing"
+
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+func tracked(id string) bool {
+	targetsMu.RLock()
+	defer targetsMu.RUnlock()
+	_, ok := targets[id]
+	return ok
+}
+
+func TestTrack(t *testing.T) {
+	registry.EnableRegistryCache()
+	ins := &registry.MicroServiceInstance{
+		InstanceID:   "tracked",
+		EndpointsMap: map[string]*registry.Endpoint{"rest": {Address: "127.0.0.1:1"}},
+	}
+	track("untyped", ins)
+	assert.False(t, tracked("tracked"), "service without health check type is not probed")
+
+	archaius.Set("cse.loadbalance.typed.healthCheck.type", "tcp")
+	defer archaius.Delete("cse.loadbalance.typed.healthCheck.type")
+	track("typed", ins)
+	assert.True(t, tracked("tracked"))
+
+	archaius.Delete("cse.loadbalance.typed.healthCheck.type")
+	syncTargets()
+	assert.False(t, tracked("tracked"), "probing stops once health check type is removed")
+}
@@ -0,0 +1,125 @@
+package health_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/go-chassis/v2/health"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func instance(id, protocol, addr string) *registry.MicroServiceInstance {
+	return &registry.MicroServiceInstance{
+		InstanceID:   id,
+		EndpointsMap: map[string]*registry.Endpoint{protocol: {Address: addr}},
+	}
+}
+
+func TestActiveCheck(t *testing.T) {
+	registry.EnableRegistryCache()
+	var status int32 = http.StatusOK
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ping" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer s.Close()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	closed := l.Addr().String()
+	l.Close()
+
+	archaius.Set("cse.loadbalance.probed.healthCheck.type", "http")
+	archaius.Set("cse.loadbalance.probed.healthCheck.path", "/ping")
+	archaius.Set("cse.loadbalance.healthCheck.interval", "10ms")
+	archaius.Set("cse.loadbalance.healthCheck.healthyThreshold", "1")
+	archaius.Set("cse.loadbalance.healthCheck.unhealthyThreshold", "2")
+	up := instance("up", "rest", s.Listener.Addr().String())
+	down := instance("down", "rest", closed)
+	registry.MicroserviceInstanceIndex.Set("probed", []*registry.MicroServiceInstance{up})
+	health.EnableActiveCheck()
+	registry.MicroserviceInstanceIndex.Set("probed", []*registry.MicroServiceInstance{up, down})
+	instances := []*registry.MicroServiceInstance{up, down}
+
+	assert.Eventually(t, func() bool {
+		return !health.IsHealthy("down") && health.IsHealthy("up")
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []*registry.MicroServiceInstance{up}, health.FilterUnhealthy("probed", instances))
+
+	t.Run("recover", func(t *testing.T) {
+		atomic.StoreInt32(&status, http.StatusInternalServerError)
+		assert.Eventually(t, func() bool {
+			return !health.IsHealthy("up")
+		}, time.Second, 10*time.Millisecond)
+		assert.Equal(t, instances, health.FilterUnhealthy("probed", instances), "all instances are kept if none is healthy")
+
+		atomic.StoreInt32(&status, http.StatusOK)
+		assert.Eventually(t, func() bool {
+			return health.IsHealthy("up")
+		}, time.Second, 10*time.Millisecond)
+	})
+	t.Run("removed", func(t *testing.T) {
+		registry.MicroserviceInstanceIndex.Set("probed", []*registry.MicroServiceInstance{up})
+		assert.Eventually(t, func() bool {
+			return health.IsHealthy("down")
+		}, time.Second, 10*time.Millisecond)
+	})
+	t.Run("disabled", func(t *testing.T) {
+		registry.MicroserviceInstanceIndex.Set("unprobed", []*registry.MicroServiceInstance{instance("unprobed", "rest", closed)})
+		time.Sleep(50 * time.Millisecond)
+		assert.True(t, health.IsHealthy("unprobed"))
+	})
+	t.Run("config changes", func(t *testing.T) {
+		registry.MicroserviceInstanceIndex.Set("late", []*registry.MicroServiceInstance{instance("late", "rest", closed)})
+		time.Sleep(50 * time.Millisecond)
+		assert.True(t, health.IsHealthy("late"))
+
+		archaius.Set("cse.loadbalance.late.healthCheck.type", "tcp")
+		assert.Eventually(t, func() bool {
+			return !health.IsHealthy("late")
+		}, time.Second, 10*time.Millisecond)
+
+		archaius.Delete("cse.loadbalance.late.healthCheck.type")
+		assert.Eventually(t, func() bool {
+			return health.IsHealthy("late")
+		}, time.Second, 10*time.Millisecond)
+	})
+	t.Run("tcp", func(t *testing.T) {
+		archaius.Set("cse.loadbalance.tcp.healthCheck.type", "tcp")
+		registry.MicroserviceInstanceIndex.Set("tcp", []*registry.MicroServiceInstance{
+			instance("tcp-up", "rest", s.Listener.Addr().String()), instance("tcp-down", "rest", closed)})
+		assert.Eventually(t, func() bool {
+			return !health.IsHealthy("tcp-down") && health.IsHealthy("tcp-up")
+		}, time.Second, 10*time.Millisecond)
+	})
+	t.Run("grpc", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		hs := grpchealth.NewServer()
+		gs := grpc.NewServer()
+		healthpb.RegisterHealthServer(gs, hs)
+		go gs.Serve(l)
+		defer gs.Stop()
+
+		archaius.Set("cse.loadbalance.grpc.healthCheck.type", "grpc")
+		registry.MicroserviceInstanceIndex.Set("grpc", []*registry.MicroServiceInstance{instance("grpc", "grpc", l.Addr().String())})
+		assert.Eventually(t, func() bool {
+			return health.IsHealthy("grpc")
+		}, time.Second, 10*time.Millisecond)
+		hs.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+		assert.Eventually(t, func() bool {
+			return !health.IsHealthy("grpc")
+		}, time.Second, 10*time.Millisecond)
+	})
+}
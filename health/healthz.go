@@ -99,10 +99,15 @@ func HealthCheck(service, version, appID string, instance *registry.MicroService
 
 // RefreshCache is the function to filter changes between new pulling instances and simpleCache
 func RefreshCache(service string, ups []*registry.MicroServiceInstance, downs map[string]struct{}) {
-	c, ok := registry.MicroserviceInstanceIndex.Get(service, nil)
+	RefreshCacheIndex(registry.MicroserviceInstanceIndex, service, ups, downs)
+}
+
+// RefreshCacheIndex filters changes between new pulling instances and the given cache
+func RefreshCacheIndex(index registry.CacheIndex, service string, ups []*registry.MicroServiceInstance, downs map[string]struct{}) {
+	c, ok := index.Get(service, nil)
 	if !ok || c == nil {
 		// if full new instances or at less one instance, then refresh simpleCache immediately
-		registry.MicroserviceInstanceIndex.Set(service, ups)
+		index.Set(service, ups)
 		openlog.Debug(fmt.Sprintf("Cached [%d] Instances of service [%s]", len(ups), service))
 		return
 	}
@@ -123,10 +128,10 @@ func RefreshCache(service string, ups []*registry.MicroServiceInstance, downs ma
 	}
 
 	for _, exp := range mapExps {
-		// case: keep still alive instances
-		if _, ok := mapUps[exp.InstanceID]; ok {
-			lefts = append(lefts, exp)
-			openlog.Debug(fmt.Sprintf("cache instance: %v", exp))
+		// case: keep still alive instances, with their latest metadata and endpoints
+		if up, ok := mapUps[exp.InstanceID]; ok {
+			lefts = append(lefts, up)
+			openlog.Debug(fmt.Sprintf("cache instance: %v", up))
 			continue
 		} else {
 			for p, ep := range exp.EndpointsMap {
@@ -162,11 +167,11 @@ func RefreshCache(service string, ups []*registry.MicroServiceInstance, downs ma
 	lefts = append(lefts, saves...)
 	if len(lefts) == 0 {
 		//todo remove this when the simpleCache struct can delete the key if the input is an empty slice
-		registry.MicroserviceInstanceIndex.Delete(service)
+		index.Delete(service)
 		openlog.Info(fmt.Sprintf("Delete the service [%s] in the cache", service))
 		return
 	}
 
-	registry.MicroserviceInstanceIndex.Set(service, lefts)
+	index.Set(service, lefts)
 	openlog.Debug(fmt.Sprintf("Cached [%d] Instances of service [%s]", len(lefts), service))
 }
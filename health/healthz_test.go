@@ -81,4 +81,13 @@ func TestRefreshCache(t *testing.T) {
 	is, ok = registry.MicroserviceInstanceIndex.Get("test", nil)
 	assert.Equal(t, true, ok)
 	assert.Equal(t, 1, len(is))
+
+	// case: alive instance with new metadata
+	health.RefreshCache("test", []*registry.MicroServiceInstance{
+		{InstanceID: "1", Status: common.DefaultStatus, Metadata: map[string]string{"zone": "b"}}}, nil)
+
+	is, ok = registry.MicroserviceInstanceIndex.Get("test", nil)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, 1, len(is))
+	assert.Equal(t, "b", is[0].Metadata["zone"])
 }
@@ -0,0 +1,126 @@
+package health
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/config"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	chassisTLS "github.com/go-chassis/go-chassis/v2/core/tls"
+	"github.com/go-chassis/openlog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Probe checks an endpoint of a service instance, it returns error if the instance is not healthy.
+// path is the http path or grpc service name to check
+type Probe func(ctx context.Context, service, path string, ep *registry.Endpoint) error
+
+var probesMu sync.RWMutex
+
+var probes = map[string]Probe{
+	config.HealthCheckHTTP: probeHTTP,
+	config.HealthCheckTCP:  probeTCP,
+	config.HealthCheckGRPC: probeGRPC,
+}
+
+// InstallProbe installs a probe of a health check type
+func InstallProbe(name string, p Probe) {
+	probesMu.Lock()
+	defer probesMu.Unlock()
+	probes[name] = p
+	openlog.Info("installed health check probe: " + name)
+}
+
+func getProbe(name string) Probe {
+	probesMu.RLock()
+	defer probesMu.RUnlock()
+	return probes[name]
+}
+
+// tlsConfig returns tls config of a service, system root CAs are used if it is not configured
+func tlsConfig(service, protocol string) (*tls.Config, error) {
+	c, _, err := chassisTLS.GetTLSConfigByService(service, protocol, common.Consumer)
+	if err != nil {
+		if chassisTLS.IsSSLConfigNotExist(err) {
+			return &tls.Config{MinVersion: tls.VersionTLS12}, nil
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+// probeHTTP sends GET request, 2xx and 3xx status means healthy, redirect is not followed
+func probeHTTP(ctx context.Context, service, path string, ep *registry.Endpoint) error {
+	transport := &http.Transport{DisableKeepAlives: true}
+	scheme := common.HTTP
+	if ep.SSLEnabled {
+		c, err := tlsConfig(service, common.ProtocolRest)
+		if err != nil {
+			return err
+		}
+		transport.TLSClientConfig = c
+		scheme = common.HTTPS
+	}
+	c := &http.Client{
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, scheme+"://"+ep.Address+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// probeTCP establishes a tcp connection
+func probeTCP(ctx context.Context, _, _ string, ep *registry.Endpoint) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", ep.Address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// probeGRPC calls grpc.health.v1.Health/Check, SERVING status means healthy
+func probeGRPC(ctx context.Context, service, path string, ep *registry.Endpoint) error {
+	creds := insecure.NewCredentials()
+	if ep.SSLEnabled {
+		c, err := tlsConfig(service, common.ProtocolGRPC)
+		if err != nil {
+			return err
+		}
+		creds = credentials.NewTLS(c)
+	}
+	conn, err := grpc.DialContext(ctx, ep.Address, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: path})
+	if err != nil {
+		return err
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+// Package egress is the egress consumer handler,
+// it enforces egress rules of control panel on invocations which skip service discovery
+package egress
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chassis/go-chassis/v2/client/local"
+	"github.com/go-chassis/go-chassis/v2/control"
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/handler"
+	"github.com/go-chassis/go-chassis/v2/core/invocation"
+	"github.com/go-chassis/go-chassis/v2/pkg/metrics"
+	"github.com/go-chassis/openlog"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Name is the handler name
+const Name = "egress"
+
+// MetricsDenied is the count of denied egress invocations
+const MetricsDenied = "scb_egress_denied_total"
+
+// ErrDenied means the endpoint is not allowed by egress rules
+var ErrDenied = errors.New("egress denied")
+
+// denied is labelled by protocol only, no rule matches a denied invocation,
+// and its service name and endpoint are given by callers, so they are only logged
+var denied = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: MetricsDenied,
+	Help: "count of invocations denied by egress rules",
+}, []string{"protocol"})
+
+// Handler checks endpoint specified by invoker against egress rules,
+// it must be placed before loadbalance handler, endpoints picked from registry are not checked.
+// calls to providers in the same process never leave it, so they are not checked either.
+// if there is no egress rule, all endpoints are allowed
+type Handler struct{}
+
+// Handle rejects invocation with status 403 if its endpoint is not allowed
+func (h *Handler) Handle(chain *handler.Chain, i *invocation.Invocation, cb invocation.ResponseCallBack) {
+	if i.Endpoint == "" || i.Protocol == local.Name {
+		chain.Next(i, cb)
+		return
+	}
+	rules := control.DefaultPanel.GetEgressRule()
+	if len(rules) == 0 {
+		chain.Next(i, cb)
+		return
+	}
+	host, port := splitEndpoint(i.Endpoint, i.Protocol)
+	if Allowed(rules, host, port, i.Protocol) {
+		chain.Next(i, cb)
+		return
+	}
+	denied.WithLabelValues(i.Protocol).Inc()
+	openlog.Warn(fmt.Sprintf("egress to [%s] is denied, protocol: %s", i.Endpoint, i.Protocol))
+	if resp, ok := i.Reply.(*http.Response); ok {
+		resp.StatusCode = http.StatusForbidden
+	}
+	handler.WriteBackErr(fmt.Errorf("%w: %s", ErrDenied, i.Endpoint), http.StatusForbidden, cb)
+}
+
+// Name returns handler name
+func (h *Handler) Name() string {
+	return Name
+}
+
+// Allowed returns true if any rule allows the host and port,
+// port 0 means unknown port, it is allowed only by rules without ports
+func Allowed(rules []control.EgressConfig, host string, port int32, protocol string) bool {
+	for _, r := range rules {
+		if !matchHost(r.Hosts, host) {
+			continue
+		}
+		if len(r.Ports) == 0 {
+			return true
+		}
+		for _, p := range r.Ports {
+			if p != nil && p.Port == port && matchProtocol(p.Protocol, protocol) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchHost supports exact host, "*" and wildcard like "*.example.com"
+func matchHost(hosts []string, host string) bool {
+	host = strings.ToLower(host)
+	for _, h := range hosts {
+		h = strings.ToLower(strings.TrimSpace(h))
+		switch {
+		case h == "*" || h == host:
+			return true
+		case strings.HasPrefix(h, "*.") && strings.HasSuffix(host, h[1:]):
+			return true
+		}
+	}
+	return false
+}
+
+// matchProtocol matches rule protocol with invocation protocol, http and https mean rest
+func matchProtocol(rule, protocol string) bool {
+	rule = strings.ToLower(rule)
+	switch rule {
+	case "":
+		return true
+	case "http", "https":
+		return protocol == common.ProtocolRest
+	}
+	return rule == strings.ToLower(protocol)
+}
+
+// splitEndpoint returns host and port of endpoint, rest endpoint without port uses port 80
+func splitEndpoint(endpoint, protocol string) (string, int32) {
+	if i := strings.Index(endpoint, "://"); i >= 0 {
+		endpoint = endpoint[i+3:]
+	}
+	if i := strings.IndexAny(endpoint, "/?"); i >= 0 {
+		endpoint = endpoint[:i]
+	}
+	host, p, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		if protocol == common.ProtocolRest {
+			return endpoint, 80
+		}
+		return endpoint, 0
+	}
+	port, err := strconv.ParseInt(p, 10, 32)
+	if err != nil {
+		return host, 0
+	}
+	return host, int32(port)
+}
+
+func newHandler() handler.Handler {
+	return &Handler{}
+}
+
+func init() {
+	if err := handler.RegisterHandler(Name, newHandler); err != nil {
+		openlog.Error(err.Error())
+	}
+	metrics.GetSystemPrometheusRegistry().MustRegister(denied)
+}
@@ -0,0 +1,153 @@
+package egress_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-chassis/go-chassis/v2/client/local"
+	"github.com/go-chassis/go-chassis/v2/control"
+	_ "github.com/go-chassis/go-chassis/v2/core/client"
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/config"
+	"github.com/go-chassis/go-chassis/v2/core/handler"
+	"github.com/go-chassis/go-chassis/v2/core/invocation"
+	"github.com/go-chassis/go-chassis/v2/core/provider"
+	"github.com/go-chassis/go-chassis/v2/examples/schemas"
+	"github.com/go-chassis/go-chassis/v2/examples/schemas/helloworld"
+	"github.com/go-chassis/go-chassis/v2/middleware/egress"
+	"github.com/go-chassis/go-chassis/v2/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+type egressPanel struct {
+	control.Panel
+	rules []control.EgressConfig
+}
+
+func (p *egressPanel) GetEgressRule() []control.EgressConfig {
+	return p.rules
+}
+
+var rules = []control.EgressConfig{
+	{Hosts: []string{"*.google.com"}},
+	{Hosts: []string{"api.example.com"}, Ports: []*control.EgressPort{{Port: 443, Protocol: "HTTPS"}, {Port: 80}}},
+	{Hosts: []string{"10.0.0.1"}, Ports: []*control.EgressPort{{Port: 6000, Protocol: common.ProtocolHighway}}},
+}
+
+func TestAllowed(t *testing.T) {
+	assert.True(t, egress.Allowed(rules, "www.google.com", 8080, common.ProtocolRest))
+	assert.True(t, egress.Allowed(rules, "API.example.com", 443, common.ProtocolRest))
+	assert.True(t, egress.Allowed(rules, "api.example.com", 80, "grpc"))
+	assert.True(t, egress.Allowed(rules, "10.0.0.1", 6000, common.ProtocolHighway))
+	assert.False(t, egress.Allowed(rules, "google.com", 80, common.ProtocolRest))
+	assert.False(t, egress.Allowed(rules, "api.example.com", 443, "grpc"))
+	assert.False(t, egress.Allowed(rules, "api.example.com", 8443, common.ProtocolRest))
+	assert.False(t, egress.Allowed(rules, "10.0.0.1", 6000, common.ProtocolRest))
+	assert.True(t, egress.Allowed([]control.EgressConfig{{Hosts: []string{"*"}}}, "any", 0, ""))
+}
+
+func TestHandler_Handle(t *testing.T) {
+	p := &egressPanel{rules: rules}
+	control.DefaultPanel = p
+	c, err := handler.CreateChain(common.Consumer, "egress-test", egress.Name)
+	assert.NoError(t, err)
+	called := false
+	c.AddHandler(handlerFunc(func(i *invocation.Invocation, cb invocation.ResponseCallBack) {
+		called = true
+		cb(&invocation.Response{})
+	}))
+	handle := func(endpoint, protocol string) (*invocation.Response, *http.Response, bool) {
+		var r *invocation.Response
+		resp := &http.Response{}
+		i := invocation.New(context.Background())
+		i.MicroServiceName = "Server"
+		i.Endpoint = endpoint
+		i.Protocol = protocol
+		i.Reply = resp
+		called = false
+		c.Next(i, func(ir *invocation.Response) {
+			r = ir
+		})
+		return r, resp, called
+	}
+
+	t.Run("allowed endpoint", func(t *testing.T) {
+		r, _, called := handle("api.example.com:443", common.ProtocolRest)
+		assert.True(t, called)
+		assert.NoError(t, r.Err)
+		_, _, called = handle("www.google.com", common.ProtocolRest)
+		assert.True(t, called)
+	})
+	t.Run("denied endpoint", func(t *testing.T) {
+		r, resp, called := handle("api.example.com:8443", common.ProtocolRest)
+		assert.False(t, called)
+		assert.True(t, errors.Is(r.Err, egress.ErrDenied))
+		assert.Equal(t, http.StatusForbidden, r.Status)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+		_, _, called = handle("10.0.0.1:6000?sslEnabled=true", common.ProtocolRest)
+		assert.False(t, called)
+
+		mfs, err := metrics.GetSystemPrometheusRegistry().Gather()
+		assert.NoError(t, err)
+		for _, mf := range mfs {
+			if mf.GetName() != egress.MetricsDenied {
+				continue
+			}
+			for _, m := range mf.GetMetric() {
+				assert.Equal(t, 1, len(m.GetLabel()), "endpoint given by caller must not be a label")
+				assert.Equal(t, "protocol", m.GetLabel()[0].GetName())
+			}
+		}
+	})
+	t.Run("endpoint from service discovery", func(t *testing.T) {
+		_, _, called := handle("", common.ProtocolRest)
+		assert.True(t, called)
+	})
+	t.Run("no egress rule", func(t *testing.T) {
+		p.rules = nil
+		_, _, called := handle("evil.com:80", common.ProtocolRest)
+		assert.True(t, called)
+	})
+}
+
+type handlerFunc func(i *invocation.Invocation, cb invocation.ResponseCallBack)
+
+func (f handlerFunc) Handle(chain *handler.Chain, i *invocation.Invocation, cb invocation.ResponseCallBack) {
+	f(i, cb)
+}
+
+func (f handlerFunc) Name() string {
+	return "func"
+}
+
+func TestHandler_LocalTransport(t *testing.T) {
+	assert.NoError(t, archaius.Init(archaius.WithMemorySource()))
+	assert.NoError(t, config.ReadGlobalConfigFromArchaius())
+	archaius.Set("servicecomb.transport.local.enabled", true)
+	defer archaius.Set("servicecomb.transport.local.enabled", false)
+	provider.RegisterProvider(common.DefaultProvider, "EgressLocalServer")
+	_, err := provider.RegisterSchema("EgressLocalServer", &schemas.HelloServer{})
+	assert.NoError(t, err)
+	// deny all, local calls never leave the process
+	control.DefaultPanel = &egressPanel{rules: []control.EgressConfig{{Hosts: []string{"api.example.com"}}}}
+	c, err := handler.CreateChain(common.Consumer, "egress-local-test", egress.Name, handler.Transport)
+	assert.NoError(t, err)
+
+	inv := invocation.New(context.Background())
+	inv.MicroServiceName = "EgressLocalServer"
+	inv.SchemaID = "HelloServer"
+	inv.OperationID = "SayHello"
+	inv.Args = &helloworld.HelloRequest{Name: "peter"}
+	reply := &helloworld.HelloReply{}
+	inv.Reply = reply
+	assert.True(t, local.Redirect(inv))
+	var r *invocation.Response
+	c.Next(inv, func(ir *invocation.Response) {
+		r = ir
+	})
+	assert.NoError(t, r.Err)
+	assert.Equal(t, "Go Hello  peter", reply.Message)
+}
@@ -0,0 +1,162 @@
+// Package compression negotiates and compresses http body,
+// gzip and deflate are built in, other encodings like zstd can be installed by InstallCompressor
+package compression
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-chassis/go-chassis/v2/pkg/metrics"
+	"github.com/go-chassis/openlog"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// encodings
+const (
+	Gzip    = "gzip"
+	Deflate = "deflate"
+	Zstd    = "zstd"
+)
+
+// http headers
+const (
+	HeaderAcceptEncoding  = "Accept-Encoding"
+	HeaderContentEncoding = "Content-Encoding"
+	HeaderContentLength   = "Content-Length"
+	HeaderVary            = "Vary"
+)
+
+// MetricsRatio is the ratio of compressed size to original size
+const MetricsRatio = "scb_compression_ratio"
+
+// Compressor compress and decompress data in one encoding
+type Compressor interface {
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+var (
+	compressors = make(map[string]Compressor)
+	lock        sync.RWMutex
+
+	ratio = prometheus.NewSummaryVec(prometheus.SummaryOpts{
+		Name:       MetricsRatio,
+		Help:       "ratio of compressed body size to original body size",
+		Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+	}, []string{"side", "encoding"})
+)
+
+// InstallCompressor install a compressor for an encoding
+func InstallCompressor(encoding string, c Compressor) {
+	lock.Lock()
+	compressors[strings.ToLower(encoding)] = c
+	lock.Unlock()
+	openlog.Info("installed compressor: " + encoding)
+}
+
+// GetCompressor return the compressor of an encoding
+func GetCompressor(encoding string) (Compressor, bool) {
+	lock.RLock()
+	defer lock.RUnlock()
+	c, ok := compressors[strings.ToLower(encoding)]
+	return c, ok
+}
+
+// AcceptEncoding returns the value of Accept-Encoding header, which only contains installed encodings
+func AcceptEncoding(encodings []string) string {
+	s := make([]string, 0, len(encodings))
+	for _, e := range encodings {
+		if _, ok := GetCompressor(e); ok {
+			s = append(s, e)
+		}
+	}
+	return strings.Join(s, ", ")
+}
+
+// Negotiate picks the first installed encoding in supported list which is accepted by Accept-Encoding header,
+// it returns empty string if there is no such encoding
+func Negotiate(acceptEncoding string, supported []string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+	accepted := make(map[string]bool)
+	wildcard := false
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, q := parseCoding(part)
+		if q <= 0 {
+			continue
+		}
+		if name == "*" {
+			wildcard = true
+			continue
+		}
+		accepted[name] = true
+	}
+	for _, e := range supported {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if !accepted[e] && !wildcard {
+			continue
+		}
+		if _, ok := GetCompressor(e); ok {
+			return e
+		}
+	}
+	return ""
+}
+
+// parseCoding parses "gzip;q=0.8" into name and quality value
+func parseCoding(s string) (string, float64) {
+	parts := strings.Split(s, ";")
+	name := strings.ToLower(strings.TrimSpace(parts[0]))
+	q := 1.0
+	for _, p := range parts[1:] {
+		p = strings.TrimSpace(p)
+		if !strings.HasPrefix(p, "q=") {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimPrefix(p, "q="), 64)
+		if err != nil {
+			return name, 0
+		}
+		q = v
+	}
+	return name, q
+}
+
+func observeRatio(side, encoding string, original, compressed int64) {
+	if original <= 0 {
+		return
+	}
+	ratio.WithLabelValues(side, encoding).Observe(float64(compressed) / float64(original))
+}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+func (gzipCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// deflateCompressor uses zlib format, http "deflate" encoding is zlib wrapped deflate stream
+type deflateCompressor struct{}
+
+func (deflateCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zlib.NewWriter(w), nil
+}
+
+func (deflateCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return zlib.NewReader(r)
+}
+
+func init() {
+	InstallCompressor(Gzip, gzipCompressor{})
+	InstallCompressor(Deflate, deflateCompressor{})
+	metrics.GetSystemPrometheusRegistry().MustRegister(ratio)
+}
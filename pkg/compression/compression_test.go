@@ -0,0 +1,156 @@
+package compression_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chassis/go-chassis/v2/pkg/compression"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	supported := []string{"gzip", "deflate"}
+	assert.Equal(t, "gzip", compression.Negotiate("gzip, deflate", supported))
+	assert.Equal(t, "deflate", compression.Negotiate("deflate", supported))
+	assert.Equal(t, "deflate", compression.Negotiate("gzip;q=0, deflate;q=0.5", supported))
+	assert.Equal(t, "gzip", compression.Negotiate("*", supported))
+	assert.Equal(t, "", compression.Negotiate("br", supported))
+	assert.Equal(t, "", compression.Negotiate("", supported))
+	assert.Equal(t, "", compression.Negotiate("zstd", []string{"zstd"}), "zstd is not installed")
+	assert.Equal(t, "gzip, deflate", compression.AcceptEncoding([]string{"gzip", "zstd", "deflate"}))
+}
+
+func TestHandler(t *testing.T) {
+	large := strings.Repeat("hello world ", 200)
+	h := compression.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusCreated)
+		if r.URL.Query().Get("large") != "" {
+			io.WriteString(w, large)
+			return
+		}
+		io.WriteString(w, "hello")
+	}), func(r *http.Request) *compression.Options {
+		if r.Header.Get("disabled") != "" {
+			return nil
+		}
+		return &compression.Options{Encodings: []string{"gzip", "deflate"}, MinSize: 1024}
+	})
+	serve := func(body string, header http.Header) *http.Response {
+		target := "/"
+		if body == large {
+			target = "/?large=true"
+		}
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.Header = header
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Result()
+	}
+	t.Run("large body is compressed", func(t *testing.T) {
+		resp := serve(large, http.Header{"Accept-Encoding": {"gzip"}})
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+		assert.Equal(t, "Accept-Encoding", resp.Header.Get("Vary"))
+		zr, err := gzip.NewReader(resp.Body)
+		assert.NoError(t, err)
+		b, err := io.ReadAll(zr)
+		assert.NoError(t, err)
+		assert.Equal(t, large, string(b))
+	})
+	t.Run("decode deflate response", func(t *testing.T) {
+		resp := serve(large, http.Header{"Accept-Encoding": {"deflate"}})
+		assert.Equal(t, "deflate", resp.Header.Get("Content-Encoding"))
+		assert.NoError(t, compression.DecodeResponse(resp))
+		assert.Equal(t, "", resp.Header.Get("Content-Encoding"))
+		b, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, large, string(b))
+		assert.NoError(t, resp.Body.Close())
+	})
+	t.Run("small body is not compressed", func(t *testing.T) {
+		resp := serve("hello", http.Header{"Accept-Encoding": {"gzip"}})
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, "", resp.Header.Get("Content-Encoding"))
+		b, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, "hello", string(b))
+	})
+	t.Run("client does not accept encoding", func(t *testing.T) {
+		resp := serve(large, http.Header{})
+		assert.Equal(t, "", resp.Header.Get("Content-Encoding"))
+	})
+	t.Run("compression is disabled", func(t *testing.T) {
+		resp := serve(large, http.Header{"Accept-Encoding": {"gzip"}, "Disabled": {"true"}})
+		assert.Equal(t, "", resp.Header.Get("Content-Encoding"))
+	})
+	t.Run("response without encoding is not changed", func(t *testing.T) {
+		resp := &http.Response{Header: http.Header{}, Body: io.NopCloser(bytes.NewBufferString("hello"))}
+		assert.NoError(t, compression.DecodeResponse(resp))
+		b, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, "hello", string(b))
+	})
+}
+
+func TestHandler_HijackAndFlush(t *testing.T) {
+	release := make(chan struct{})
+	h := compression.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/hijack":
+			conn, rw, err := w.(http.Hijacker).Hijack()
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer conn.Close()
+			rw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 5\r\nConnection: close\r\n\r\nraw!!")
+			rw.Flush()
+		case "/upgrade":
+			_, ok := w.(http.Hijacker)
+			assert.True(t, ok)
+			io.WriteString(w, strings.Repeat("hello world ", 200))
+		default:
+			io.WriteString(w, "first")
+			w.(http.Flusher).Flush()
+			<-release
+			io.WriteString(w, "second")
+		}
+	}), func(r *http.Request) *compression.Options {
+		return &compression.Options{Encodings: []string{"gzip"}, MinSize: 1024}
+	})
+	s := httptest.NewServer(h)
+	defer s.Close()
+	get := func(path string, header http.Header) *http.Response {
+		r, err := http.NewRequest(http.MethodGet, s.URL+path, nil)
+		assert.NoError(t, err)
+		r.Header = header
+		resp, err := http.DefaultTransport.RoundTrip(r)
+		assert.NoError(t, err)
+		return resp
+	}
+	t.Run("hijack is forwarded", func(t *testing.T) {
+		resp := get("/hijack", http.Header{"Accept-Encoding": {"gzip"}})
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, "raw!!", string(b))
+	})
+	t.Run("upgrade request is not compressed", func(t *testing.T) {
+		resp := get("/upgrade", http.Header{"Accept-Encoding": {"gzip"}, "Upgrade": {"websocket"}})
+		defer resp.Body.Close()
+		assert.Equal(t, "", resp.Header.Get("Content-Encoding"))
+	})
+	t.Run("flushed body reaches client before handler returns", func(t *testing.T) {
+		resp := get("/stream", http.Header{"Accept-Encoding": {"gzip"}})
+		defer resp.Body.Close()
+		b := make([]byte, len("first"))
+		_, err := io.ReadFull(resp.Body, b)
+		assert.NoError(t, err)
+		assert.Equal(t, "first", string(b))
+		close(release)
+		rest, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, "second", string(rest))
+	})
+}
@@ -0,0 +1,243 @@
+package compression
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/go-chassis/openlog"
+)
+
+// metrics side label
+const (
+	SideServer = "server"
+	SideClient = "client"
+)
+
+// Options decides how to compress a response
+type Options struct {
+	// Encodings is supported encodings in order of preference
+	Encodings []string
+	// MinSize is the body size threshold, smaller body will not be compressed
+	MinSize int
+}
+
+// Handler compresses response body of next handler with the encoding negotiated by Accept-Encoding header,
+// opts returns nil if compression is disabled for the request. upgrade requests like websocket are not compressed
+func Handler(next http.Handler, opts func(r *http.Request) *Options) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		o := opts(r)
+		if o == nil || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		encoding := Negotiate(r.Header.Get(HeaderAcceptEncoding), o.Encodings)
+		if encoding == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		c, _ := GetCompressor(encoding)
+		cw := &responseWriter{ResponseWriter: w, encoding: encoding, c: c, minSize: o.MinSize}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// responseWriter buffers body until it reaches min size, then decides whether to compress it
+type responseWriter struct {
+	http.ResponseWriter
+	encoding string
+	c        Compressor
+	minSize  int
+
+	status  int
+	buf     []byte
+	decided bool
+	w       io.WriteCloser
+	counter *countWriter
+	size    int64
+}
+
+func (cw *responseWriter) WriteHeader(status int) {
+	if cw.decided || cw.status != 0 {
+		return
+	}
+	cw.status = status
+	// those responses have no body
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		cw.decide(false)
+	}
+}
+
+func (cw *responseWriter) Write(p []byte) (int, error) {
+	if !cw.decided {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) < cw.minSize {
+			return len(p), nil
+		}
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if cw.w != nil {
+		cw.size += int64(len(p))
+		return cw.w.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// decide writes header and buffered body, it compresses body only if compress is true
+// and handler did not encode the body by itself
+func (cw *responseWriter) decide(compress bool) error {
+	cw.decided = true
+	h := cw.Header()
+	if h.Get(HeaderContentEncoding) != "" {
+		compress = false
+	}
+	if compress {
+		h.Set(HeaderContentEncoding, cw.encoding)
+		h.Add(HeaderVary, HeaderAcceptEncoding)
+		h.Del(HeaderContentLength)
+	}
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	buf := cw.buf
+	cw.buf = nil
+	if !compress {
+		if len(buf) == 0 {
+			return nil
+		}
+		_, err := cw.ResponseWriter.Write(buf)
+		return err
+	}
+	cw.counter = &countWriter{w: cw.ResponseWriter}
+	w, err := cw.c.NewWriter(cw.counter)
+	if err != nil {
+		return err
+	}
+	cw.w = w
+	cw.size = int64(len(buf))
+	_, err = cw.w.Write(buf)
+	return err
+}
+
+// Flush sends buffered body to client
+func (cw *responseWriter) Flush() {
+	if !cw.decided {
+		if err := cw.decide(len(cw.buf) >= cw.minSize); err != nil {
+			openlog.Error("compress response failed: " + err.Error())
+		}
+	}
+	if f, ok := cw.w.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			openlog.Error("flush compressed response failed: " + err.Error())
+		}
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets handler take over the connection, response is not compressed any more
+func (cw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := cw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijack")
+	}
+	cw.decided = true
+	cw.buf = nil
+	return h.Hijack()
+}
+
+// Close writes the rest of body, if body is smaller than min size, it is sent without compression
+func (cw *responseWriter) Close() error {
+	if !cw.decided {
+		if cw.status == 0 && len(cw.buf) == 0 {
+			// handler did not write anything, let net/http write the default response
+			return nil
+		}
+		return cw.decide(false)
+	}
+	if cw.w == nil {
+		return nil
+	}
+	err := cw.w.Close()
+	observeRatio(SideServer, cw.encoding, cw.size, cw.counter.n)
+	return err
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// DecodeResponse replaces response body with a decompressing reader
+// if Content-Encoding of the response is an installed encoding
+func DecodeResponse(resp *http.Response) error {
+	encoding := resp.Header.Get(HeaderContentEncoding)
+	if encoding == "" || resp.Body == nil {
+		return nil
+	}
+	c, ok := GetCompressor(encoding)
+	if !ok {
+		return nil
+	}
+	counter := &countReader{r: resp.Body}
+	r, err := c.NewReader(counter)
+	if err != nil {
+		return err
+	}
+	resp.Body = &decodeReader{r: r, raw: resp.Body, counter: counter, encoding: encoding}
+	resp.Header.Del(HeaderContentEncoding)
+	resp.Header.Del(HeaderContentLength)
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+type countReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// decodeReader decompresses body and observes compression ratio once body is read to the end
+type decodeReader struct {
+	r        io.ReadCloser
+	raw      io.ReadCloser
+	counter  *countReader
+	encoding string
+	n        int64
+	done     bool
+}
+
+func (d *decodeReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	d.n += int64(n)
+	if err == io.EOF && !d.done {
+		d.done = true
+		observeRatio(SideClient, d.encoding, d.n, d.counter.n)
+	}
+	return n, err
+}
+
+func (d *decodeReader) Close() error {
+	d.r.Close()
+	return d.raw.Close()
+}
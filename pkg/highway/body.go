@@ -0,0 +1,23 @@
+package highway
+
+import (
+	"github.com/go-chassis/go-chassis/v2/pkg/codec"
+	"google.golang.org/protobuf/proto"
+)
+
+// EncodeBody encodes request args or response result,
+// messages generated by protoc-gen-go are encoded in protobuf, other values are encoded by codec plugin
+func EncodeBody(v interface{}) ([]byte, error) {
+	if m, ok := v.(proto.Message); ok {
+		return proto.Marshal(m)
+	}
+	return codec.Encode(v)
+}
+
+// DecodeBody decodes body into v in the same way as EncodeBody
+func DecodeBody(b []byte, v interface{}) error {
+	if m, ok := v.(proto.Message); ok {
+		return proto.Unmarshal(b, m)
+	}
+	return codec.Decode(b, v)
+}
@@ -0,0 +1,262 @@
+// Package highway defines the wire format of highway protocol,
+// it is the binary RPC protocol of ServiceComb, the frame layout follows ServiceComb Java
+//
+//	| magic "highway" | msg id int64 | total len int32 | header len int32 | header | body |
+//
+// header is encoded in protobuf. body of a message generated by protoc-gen-go is encoded in protobuf,
+// which is what ServiceComb Java sends for an operation whose only parameter and result are models,
+// body of other types is encoded by codec plugin and is only understood by go chassis
+package highway
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// constants for highway frame
+const (
+	Magic = "highway"
+	// FixedLen is the length of magic, msg id, total len and header len
+	FixedLen = len(Magic) + 8 + 4 + 4
+	// MsgTypeRequest is the message type of a normal request
+	MsgTypeRequest int32 = 0
+	// MsgTypeLogin is the message type of login request
+	MsgTypeLogin int32 = 1
+	// DefaultMaxFrameSize is the max length of header and body of a frame if no limit is specified
+	DefaultMaxFrameSize int64 = 8 * 1024 * 1024
+)
+
+// errors of highway frame
+var (
+	ErrInvalidMagic = errors.New("invalid highway magic")
+	ErrInvalidLen   = errors.New("invalid highway frame length")
+)
+
+// Frame is a highway message
+type Frame struct {
+	MsgID  int64
+	Header []byte
+	Body   []byte
+}
+
+// RequestHeader is the header of request frame
+type RequestHeader struct {
+	MsgType          int32
+	Flags            int32
+	DestMicroService string
+	SchemaID         string
+	OperationName    string
+	Context          map[string]string
+}
+
+// ResponseHeader is the header of response frame
+type ResponseHeader struct {
+	Flags        int32
+	StatusCode   int32
+	ReasonPhrase string
+	Context      map[string]string
+}
+
+// WriteFrame writes a frame to w
+func WriteFrame(w io.Writer, f *Frame) error {
+	buf := make([]byte, FixedLen, FixedLen+len(f.Header)+len(f.Body))
+	copy(buf, Magic)
+	binary.BigEndian.PutUint64(buf[len(Magic):], uint64(f.MsgID))
+	binary.BigEndian.PutUint32(buf[len(Magic)+8:], uint32(len(f.Header)+len(f.Body)))
+	binary.BigEndian.PutUint32(buf[len(Magic)+12:], uint32(len(f.Header)))
+	buf = append(buf, f.Header...)
+	buf = append(buf, f.Body...)
+	_, err := w.Write(buf)
+	return err
+}
+
+// ReadFrame reads a frame from r, frame larger than limit is refused before its data is read,
+// if limit is not bigger than 0, DefaultMaxFrameSize is used
+func ReadFrame(r *bufio.Reader, limit int64) (*Frame, error) {
+	if limit <= 0 {
+		limit = DefaultMaxFrameSize
+	}
+	fixed := make([]byte, FixedLen)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return nil, err
+	}
+	if string(fixed[:len(Magic)]) != Magic {
+		return nil, ErrInvalidMagic
+	}
+	f := &Frame{MsgID: int64(binary.BigEndian.Uint64(fixed[len(Magic):]))}
+	total := int64(binary.BigEndian.Uint32(fixed[len(Magic)+8:]))
+	headerLen := int64(binary.BigEndian.Uint32(fixed[len(Magic)+12:]))
+	if headerLen > total || total > limit {
+		return nil, ErrInvalidLen
+	}
+	data := make([]byte, total)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	f.Header = data[:headerLen]
+	f.Body = data[headerLen:]
+	return f, nil
+}
+
+// Marshal encodes request header in protobuf
+func (h *RequestHeader) Marshal() []byte {
+	var b []byte
+	b = appendVarint(b, 1, int64(h.MsgType))
+	b = appendVarint(b, 2, int64(h.Flags))
+	b = appendString(b, 3, h.DestMicroService)
+	b = appendString(b, 4, h.SchemaID)
+	b = appendString(b, 5, h.OperationName)
+	return appendMap(b, 6, h.Context)
+}
+
+// Unmarshal decodes request header
+func (h *RequestHeader) Unmarshal(b []byte) error {
+	return consumeFields(b, func(num protowire.Number, v uint64, s []byte) error {
+		switch num {
+		case 1:
+			h.MsgType = int32(v)
+		case 2:
+			h.Flags = int32(v)
+		case 3:
+			h.DestMicroService = string(s)
+		case 4:
+			h.SchemaID = string(s)
+		case 5:
+			h.OperationName = string(s)
+		case 6:
+			if h.Context == nil {
+				h.Context = make(map[string]string)
+			}
+			return consumeMapEntry(s, h.Context)
+		}
+		return nil
+	})
+}
+
+// Marshal encodes response header in protobuf
+func (h *ResponseHeader) Marshal() []byte {
+	var b []byte
+	b = appendVarint(b, 1, int64(h.Flags))
+	b = appendVarint(b, 2, int64(h.StatusCode))
+	b = appendString(b, 3, h.ReasonPhrase)
+	return appendMap(b, 4, h.Context)
+}
+
+// Unmarshal decodes response header
+func (h *ResponseHeader) Unmarshal(b []byte) error {
+	return consumeFields(b, func(num protowire.Number, v uint64, s []byte) error {
+		switch num {
+		case 1:
+			h.Flags = int32(v)
+		case 2:
+			h.StatusCode = int32(v)
+		case 3:
+			h.ReasonPhrase = string(s)
+		case 4:
+			if h.Context == nil {
+				h.Context = make(map[string]string)
+			}
+			return consumeMapEntry(s, h.Context)
+		}
+		return nil
+	})
+}
+
+func appendVarint(b []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// appendMap encodes map<string,string> in sorted key order, so that header bytes are stable
+func appendMap(b []byte, num protowire.Number, m map[string]string) []byte {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		var entry []byte
+		entry = protowire.AppendTag(entry, 1, protowire.BytesType)
+		entry = protowire.AppendString(entry, k)
+		entry = protowire.AppendTag(entry, 2, protowire.BytesType)
+		entry = protowire.AppendString(entry, m[k])
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+	return b
+}
+
+func consumeMapEntry(b []byte, m map[string]string) error {
+	var k, v string
+	err := consumeFields(b, func(num protowire.Number, _ uint64, s []byte) error {
+		switch num {
+		case 1:
+			k = string(s)
+		case 2:
+			v = string(s)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	m[k] = v
+	return nil
+}
+
+// consumeFields walks through protobuf fields, unknown fields are skipped
+func consumeFields(b []byte, f func(num protowire.Number, v uint64, s []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return fmt.Errorf("invalid highway header: %w", protowire.ParseError(n))
+		}
+		b = b[n:]
+		var v uint64
+		var s []byte
+		switch typ {
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			s, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return fmt.Errorf("invalid highway header: %w", protowire.ParseError(n))
+		}
+		b = b[n:]
+		if err := f(num, v, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StatusError is returned when provider responds a non 200 status code
+type StatusError struct {
+	Code    int
+	Message string
+}
+
+// Error returns error message
+func (e StatusError) Error() string {
+	return fmt.Sprintf("highway error status [%d]: %s", e.Code, e.Message)
+}
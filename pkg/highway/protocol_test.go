@@ -0,0 +1,75 @@
+package highway_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/go-chassis/go-chassis/v2/examples/schemas/helloworld"
+	"github.com/go-chassis/go-chassis/v2/pkg/highway"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestFrame(t *testing.T) {
+	rh := &highway.RequestHeader{
+		DestMicroService: "Server",
+		SchemaID:         "HelloServer",
+		OperationName:    "SayHello",
+		Context:          map[string]string{"x-cse-src-microservice": "Client", "a": ""},
+	}
+	buf := &bytes.Buffer{}
+	err := highway.WriteFrame(buf, &highway.Frame{MsgID: 7, Header: rh.Marshal(), Body: []byte(`{"Name":"peter"}`)})
+	assert.NoError(t, err)
+	t.Run("read frame, should success", func(t *testing.T) {
+		f, err := highway.ReadFrame(bufio.NewReader(bytes.NewReader(buf.Bytes())), 0)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(7), f.MsgID)
+		assert.Equal(t, `{"Name":"peter"}`, string(f.Body))
+		h := &highway.RequestHeader{}
+		assert.NoError(t, h.Unmarshal(f.Header))
+		assert.Equal(t, rh, h)
+	})
+	t.Run("read frame bigger than limit, should fail", func(t *testing.T) {
+		_, err := highway.ReadFrame(bufio.NewReader(bytes.NewReader(buf.Bytes())), 10)
+		assert.Equal(t, highway.ErrInvalidLen, err)
+	})
+	t.Run("read frame claiming a huge length without limit, should fail before reading data", func(t *testing.T) {
+		b := append([]byte{}, buf.Bytes()[:highway.FixedLen]...)
+		binary.BigEndian.PutUint32(b[len(highway.Magic)+8:], 0xffffffff)
+		_, err := highway.ReadFrame(bufio.NewReader(bytes.NewReader(b)), 0)
+		assert.Equal(t, highway.ErrInvalidLen, err)
+	})
+	t.Run("read frame with wrong magic, should fail", func(t *testing.T) {
+		b := append([]byte("halfway"), buf.Bytes()[len(highway.Magic):]...)
+		_, err := highway.ReadFrame(bufio.NewReader(bytes.NewReader(b)), 0)
+		assert.Equal(t, highway.ErrInvalidMagic, err)
+	})
+}
+
+func TestResponseHeader(t *testing.T) {
+	rh := &highway.ResponseHeader{StatusCode: 590, ReasonPhrase: "biz error", Context: map[string]string{"k": "v"}}
+	h := &highway.ResponseHeader{}
+	assert.NoError(t, h.Unmarshal(rh.Marshal()))
+	assert.Equal(t, rh, h)
+	assert.Error(t, h.Unmarshal([]byte{0xff}))
+}
+
+func TestBody(t *testing.T) {
+	t.Run("protobuf message, should encode in protobuf", func(t *testing.T) {
+		b, err := highway.EncodeBody(wrapperspb.String("peter"))
+		assert.NoError(t, err)
+		want, _ := proto.Marshal(wrapperspb.String("peter"))
+		assert.Equal(t, want, b)
+		v := &wrapperspb.StringValue{}
+		assert.NoError(t, highway.DecodeBody(b, v))
+		assert.Equal(t, "peter", v.Value)
+	})
+	t.Run("other types, should encode by codec", func(t *testing.T) {
+		b, err := highway.EncodeBody(&helloworld.HelloRequest{Name: "peter"})
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"Name":"peter"}`, string(b))
+	})
+}
@@ -56,6 +56,7 @@ type WeightedResponseStrategy struct {
 	serviceName string
 	protocol    string
 	tags        string
+	slowStart   loadbalancer.SlowStart
 }
 
 func init() {
@@ -102,6 +103,7 @@ func (r *WeightedResponseStrategy) ReceiveData(inv *invocation.Invocation, insta
 
 	}
 	r.protocol = inv.Protocol
+	r.slowStart = loadbalancer.NewSlowStart(r.serviceName)
 }
 
 // Pick return instance
@@ -115,16 +117,28 @@ func (r *WeightedResponseStrategy) Pick() (*registry.MicroServiceInstance, error
 		loadbalancer.LatencyMapRWMutex.RUnlock()
 		for _, instance := range r.instances {
 			if len(instanceAddr) != 0 && strings.Contains(instance.EndpointsMap[r.protocol].GenEndpoint(), instanceAddr) {
-				return instance, nil
+				// a new instance may be the fastest because it has few requests, it gets traffic in proportion to its weight
+				if r.slowStart.Accept(instance) {
+					return instance, nil
+				}
+				break
 			}
 		}
 	}
 
 	//if no instances are selected round robin will be done
+	node := r.next()
+	for n := 1; n < len(r.instances) && !r.slowStart.Accept(node); n++ {
+		node = r.next()
+	}
+	return node, nil
+
+}
+
+func (r *WeightedResponseStrategy) next() *registry.MicroServiceInstance {
 	weightedRespMutex.Lock()
+	defer weightedRespMutex.Unlock()
 	node := r.instances[i%len(r.instances)]
 	i++
-	weightedRespMutex.Unlock()
-	return node, nil
-
+	return node
 }
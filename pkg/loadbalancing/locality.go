@@ -0,0 +1,159 @@
+package loadbalancing
+
+import (
+	"math/rand"
+
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/config"
+	"github.com/go-chassis/go-chassis/v2/core/loadbalancer"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/go-chassis/v2/health"
+	"github.com/go-chassis/go-chassis/v2/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// locality tiers, traffic goes to zone first, then region, then failover regions, then remote
+const (
+	TierZone     = "zone"
+	TierRegion   = "region"
+	TierFailover = "failover"
+	TierRemote   = "remote"
+)
+
+// MetricsLocalityRequests is the metric of which locality tier serves calls
+const MetricsLocalityRequests = "scb_lb_locality_requests_total"
+
+var localityCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: MetricsLocalityRequests,
+	Help: "calls served by each locality tier",
+}, []string{"tier"})
+
+func init() {
+	loadbalancer.InstallFilter(loadbalancer.ZoneAware, FilterLocality)
+	metrics.GetSystemPrometheusRegistry().MustRegister(localityCounter)
+}
+
+// tier is a group of instances with the same locality priority
+type tier struct {
+	name      string
+	instances []*registry.MicroServiceInstance
+	healthy   []*registry.MicroServiceInstance
+}
+
+// FilterLocality keeps instances of one locality tier, tiers are same zone, same region,
+// failover regions in order, then other regions. a tier takes all traffic if its healthy instances reach
+// min healthy percent, otherwise traffic spills over to next tiers in proportion to how degraded it is
+func FilterLocality(old []*registry.MicroServiceInstance, c []*loadbalancer.Criteria) []*registry.MicroServiceInstance {
+	dc := config.GetDataCenter()
+	if dc == nil || dc.Name == "" || dc.AvailableZone == "" {
+		return old // Either no information or partial data center information specified, return all instances
+	}
+	// name of data center is registered as region of instances
+	tiers := groupTiers(old, dc.Name, dc.AvailableZone, config.GetLocalityFailoverRegions())
+	t := pickTier(tiers)
+	if t == nil {
+		return old
+	}
+	localityCounter.WithLabelValues(t.name).Inc()
+	if len(t.healthy) != 0 {
+		return t.healthy
+	}
+	return t.instances
+}
+
+// groupTiers groups instances by locality, instances without data center info are remote
+func groupTiers(instances []*registry.MicroServiceInstance, region, zone string, failover []string) []*tier {
+	tiers := []*tier{{name: TierZone}, {name: TierRegion}}
+	failoverTier := make(map[string]*tier, len(failover))
+	for _, r := range failover {
+		if _, ok := failoverTier[r]; ok || r == region {
+			continue
+		}
+		failoverTier[r] = &tier{name: TierFailover}
+		tiers = append(tiers, failoverTier[r])
+	}
+	remote := &tier{name: TierRemote}
+	tiers = append(tiers, remote)
+	for _, ins := range instances {
+		t := remote
+		switch dci := ins.DataCenterInfo; {
+		case dci == nil:
+		case dci.Region == region && dci.AvailableZone == zone:
+			t = tiers[0]
+		case dci.Region == region:
+			t = tiers[1]
+		case failoverTier[dci.Region] != nil:
+			t = failoverTier[dci.Region]
+		}
+		t.instances = append(t.instances, ins)
+		if isHealthy(ins) {
+			t.healthy = append(t.healthy, ins)
+		}
+	}
+	return tiers
+}
+
+// isHealthy reports if an instance is up, not ejected by outlier detection and passes active health check
+func isHealthy(ins *registry.MicroServiceInstance) bool {
+	if ins.Status != "" && ins.Status != common.DefaultStatus {
+		return false
+	}
+	for _, ep := range ins.EndpointsMap {
+		if ep != nil && loadbalancer.Ejected(ep.Address) {
+			return false
+		}
+	}
+	return health.IsHealthy(ins.InstanceID)
+}
+
+// tierLoads returns share of traffic of every tier, a tier takes its health divided by min healthy percent,
+// no more than traffic left by previous tiers. if all tiers are degraded, shares are scaled up to sum to 1
+func tierLoads(tiers []*tier) []float64 {
+	loads := make([]float64, len(tiers))
+	left, sum := 1.0, 0.0
+	for i, t := range tiers {
+		if len(t.instances) == 0 {
+			continue
+		}
+		h := float64(len(t.healthy)) / float64(len(t.instances))
+		effective := h * 100 / float64(config.GetLocalityMinHealthyPercent(t.name))
+		if effective > 1 {
+			effective = 1
+		}
+		if effective > left {
+			effective = left
+		}
+		loads[i] = effective
+		left -= effective
+		sum += effective
+	}
+	if sum > 0 && sum < 1 {
+		for i := range loads {
+			loads[i] /= sum
+		}
+	}
+	return loads
+}
+
+// pickTier picks a tier randomly by its share of traffic, the first tier with instances is used if none is healthy
+func pickTier(tiers []*tier) *tier {
+	loads := tierLoads(tiers)
+	x := rand.Float64()
+	for i, l := range loads {
+		if l > 0 && x < l {
+			return tiers[i]
+		}
+		x -= l
+	}
+	for i := len(loads) - 1; i >= 0; i-- {
+		if loads[i] > 0 {
+			return tiers[i]
+		}
+	}
+	for _, t := range tiers {
+		if len(t.instances) != 0 {
+			return t
+		}
+	}
+	return nil
+}
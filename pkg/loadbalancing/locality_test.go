@@ -0,0 +1,96 @@
+package loadbalancing_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-chassis/go-chassis/v2/core/config"
+	"github.com/go-chassis/go-chassis/v2/core/loadbalancer"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/go-chassis/v2/pkg/loadbalancing"
+	"github.com/go-chassis/go-chassis/v2/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func localityInstance(id, region, zone string) *registry.MicroServiceInstance {
+	return &registry.MicroServiceInstance{
+		InstanceID:     id,
+		Status:         "UP",
+		EndpointsMap:   map[string]*registry.Endpoint{"rest": {Address: id + ":8080"}},
+		DataCenterInfo: &registry.DataCenterInfo{Region: region, AvailableZone: zone},
+	}
+}
+
+// served counts instances by id of many calls
+func served(instances []*registry.MicroServiceInstance, n int) map[string]int {
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		for _, ins := range loadbalancing.FilterLocality(instances, nil) {
+			counts[ins.InstanceID]++
+		}
+	}
+	return counts
+}
+
+func TestFilterLocality(t *testing.T) {
+	config.GlobalDefinition.DataCenter.Name = "r1"
+	config.GlobalDefinition.DataCenter.AvailableZone = "r1-a"
+	instances := []*registry.MicroServiceInstance{
+		localityInstance("zone-1", "r1", "r1-a"),
+		localityInstance("zone-2", "r1", "r1-a"),
+		localityInstance("region-1", "r1", "r1-b"),
+		localityInstance("r2-1", "r2", "r2-a"),
+		localityInstance("r3-1", "r3", "r3-a"),
+		{InstanceID: "unknown", Status: "UP"},
+	}
+	assert.Equal(t, map[string]int{"zone-1": 100, "zone-2": 100}, served(instances, 100))
+
+	t.Run("spill over", func(t *testing.T) {
+		instances[1].Status = "DOWN"
+		counts := served(instances, 2000)
+		assert.Equal(t, 0, counts["zone-2"], "only healthy instances of a tier are returned")
+		// zone is 50% healthy, it takes 50/70 of traffic
+		assert.InDelta(t, 2000*50/70, counts["zone-1"], 150)
+		assert.InDelta(t, 2000-2000*50/70, counts["region-1"], 150)
+
+		archaius.Set("cse.loadbalance.locality.zone.minHealthyPercent", "50")
+		assert.Equal(t, map[string]int{"zone-1": 100}, served(instances, 100), "min healthy percent of tier")
+		archaius.Set("cse.loadbalance.locality.zone.minHealthyPercent", "70")
+	})
+	t.Run("outlier", func(t *testing.T) {
+		for i := 0; i < config.DefaultOutlierConsecutiveConnectFailures; i++ {
+			loadbalancer.RecordOutcome("locality", "zone-1:8080", loadbalancer.OutcomeConnectFailure)
+		}
+		assert.True(t, loadbalancer.Ejected("zone-1:8080"))
+		assert.Equal(t, map[string]int{"region-1": 100}, served(instances, 100))
+	})
+	t.Run("failover regions", func(t *testing.T) {
+		instances[2].Status = "DOWN"
+		assert.Equal(t, map[string]int{"r2-1": 100, "r3-1": 100, "unknown": 100}, served(instances, 100),
+			"other regions are one tier")
+
+		archaius.Set("cse.loadbalance.locality.failoverRegions", "r3, r2")
+		assert.Equal(t, map[string]int{"r3-1": 100}, served(instances, 100))
+	})
+	t.Run("metrics", func(t *testing.T) {
+		families, err := metrics.GetSystemPrometheusRegistry().Gather()
+		assert.NoError(t, err)
+		tiers := make(map[string]float64)
+		for _, f := range families {
+			if f.GetName() != loadbalancing.MetricsLocalityRequests {
+				continue
+			}
+			for _, m := range f.GetMetric() {
+				tiers[m.GetLabel()[0].GetValue()] = m.GetCounter().GetValue()
+			}
+		}
+		assert.Equal(t, 4, len(tiers), fmt.Sprint(tiers))
+		assert.Greater(t, tiers[loadbalancing.TierZone], float64(0))
+		assert.Equal(t, float64(100), tiers[loadbalancing.TierFailover], "failover regions have their own label")
+	})
+	t.Run("no data center", func(t *testing.T) {
+		config.GlobalDefinition.DataCenter.AvailableZone = ""
+		assert.Equal(t, instances, loadbalancing.FilterLocality(instances, nil))
+	})
+}
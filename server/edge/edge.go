@@ -0,0 +1,310 @@
+// Package edge is the edge service server plugin, it works as an api gateway.
+// incoming requests are mapped to micro services by path prefix,
+// and forwarded by rest invoker, so that routing, load balancing, circuit breaker and rate limiting apply
+package edge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-chassis/go-archaius/event"
+	"github.com/go-chassis/go-chassis/v2/core"
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/config"
+	"github.com/go-chassis/go-chassis/v2/core/config/model"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/go-chassis/v2/core/server"
+	"github.com/go-chassis/go-chassis/v2/pkg/util/iputil"
+	"github.com/go-chassis/openlog"
+)
+
+// Name is the protocol name of edge server
+const Name = "edge"
+
+const openTLS = "?sslEnabled=true"
+
+// headers of the forwarded request
+const (
+	HeaderForwardedFor  = "X-Forwarded-For"
+	HeaderForwardedHost = "X-Forwarded-Host"
+)
+
+// ErrNotSupported means edge server has no schema
+var ErrNotSupported = errors.New("edge server does not support schema registration")
+
+// hopHeaders are only meaningful for a single connection, they are not forwarded
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+func init() {
+	server.InstallPlugin(Name, New)
+}
+
+// route is a edge route with its name
+type route struct {
+	name string
+	model.EdgeRoute
+}
+
+// Server is edge server plugin
+type Server struct {
+	opts   server.Options
+	server *http.Server
+
+	mu     sync.RWMutex
+	routes []*route
+
+	invokers sync.Map
+	listener *routeListener
+}
+
+// New returns a edge server, routes are loaded from archaius and reloaded once they change
+func New(opts server.Options) server.ProtocolServer {
+	s := &Server{opts: opts}
+	s.LoadRoutes()
+	return s
+}
+
+// Register is not supported, edge server forwards requests to other services
+func (s *Server) Register(interface{}, ...server.RegisterOption) (string, error) {
+	return "", ErrNotSupported
+}
+
+// LoadRoutes loads routes from archaius, longer prefix is matched first
+func (s *Server) LoadRoutes() {
+	c, err := config.ReadEdgeFromArchaius()
+	if err != nil {
+		openlog.Error("load edge routes failed: " + err.Error())
+		return
+	}
+	s.SetRoutes(c.Routes)
+}
+
+// SetRoutes replaces all routes, invalid routes are ignored
+func (s *Server) SetRoutes(routes map[string]model.EdgeRoute) {
+	rs := make([]*route, 0, len(routes))
+	for name, r := range routes {
+		if r.Prefix == "" || r.Service == "" {
+			openlog.Warn(fmt.Sprintf("edge route [%s] is ignored, prefix and service are required", name))
+			continue
+		}
+		if !strings.HasPrefix(r.Prefix, "/") {
+			r.Prefix = "/" + r.Prefix
+		}
+		rs = append(rs, &route{name: name, EdgeRoute: r})
+	}
+	sort.Slice(rs, func(i, j int) bool {
+		if len(rs[i].Prefix) != len(rs[j].Prefix) {
+			return len(rs[i].Prefix) > len(rs[j].Prefix)
+		}
+		return rs[i].name < rs[j].name
+	})
+	s.mu.Lock()
+	s.routes = rs
+	s.mu.Unlock()
+	openlog.Info(fmt.Sprintf("%d edge routes loaded", len(rs)))
+}
+
+// match returns the route with longest prefix which matches the path
+func (s *Server) match(path string) *route {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, r := range s.routes {
+		if matchPrefix(path, r.Prefix) {
+			return r
+		}
+	}
+	return nil
+}
+
+// matchPrefix matches prefix in path segments, "/orders" matches "/orders/1" but not "/orders1"
+func matchPrefix(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
+}
+
+// rewrite replaces the matched prefix with route rewrite
+func (r *route) rewrite(path string) string {
+	if r.Rewrite == "" {
+		return path
+	}
+	p := strings.TrimSuffix(r.Rewrite, "/") + "/" + strings.TrimPrefix(strings.TrimPrefix(path, r.Prefix), "/")
+	if p != "/" && !strings.HasSuffix(path, "/") {
+		p = strings.TrimSuffix(p, "/")
+	}
+	return p
+}
+
+// invoker returns the rest invoker of a consumer chain
+func (s *Server) invoker(chain string) *core.RestInvoker {
+	if chain == "" {
+		chain = common.DefaultChainName
+	}
+	if v, ok := s.invokers.Load(chain); ok {
+		return v.(*core.RestInvoker)
+	}
+	v, _ := s.invokers.LoadOrStore(chain, core.NewRestInvoker(core.ChainName(chain)))
+	return v.(*core.RestInvoker)
+}
+
+// ServeHTTP forwards request to the micro service of matched route
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	done := server.TrackRequest(s.opts.ProtocolServerName)
+	defer done()
+	r := s.match(req.URL.Path)
+	if r == nil {
+		http.Error(w, "no route for "+req.URL.Path, http.StatusNotFound)
+		return
+	}
+	out, err := r.newRequest(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp, err := s.invoker(r.Chain).ContextDo(req.Context(), out)
+	if resp == nil || resp.StatusCode == 0 {
+		status := http.StatusBadGateway
+		if errors.Is(err, context.DeadlineExceeded) {
+			status = http.StatusGatewayTimeout
+		}
+		openlog.Error(fmt.Sprintf("edge route [%s] failed: %v", r.name, err))
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	defer resp.Body.Close()
+	copyHeader(w.Header(), resp.Header)
+	w.WriteHeader(resp.StatusCode)
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		openlog.Warn(fmt.Sprintf("edge route [%s] write response failed: %s", r.name, err))
+	}
+}
+
+// newRequest builds the request to micro service, its host is the service name
+func (r *route) newRequest(req *http.Request) (*http.Request, error) {
+	u := *req.URL
+	u.Scheme = core.HTTP
+	u.Host = r.Service
+	u.Path = r.rewrite(req.URL.Path)
+	u.RawPath = ""
+	out, err := http.NewRequest(req.Method, u.String(), req.Body)
+	if err != nil {
+		return nil, err
+	}
+	out.ContentLength = req.ContentLength
+	copyHeader(out.Header, req.Header)
+	for _, h := range r.StripHeaders {
+		out.Header.Del(h)
+	}
+	if ip, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		if prior := req.Header.Get(HeaderForwardedFor); prior != "" {
+			ip = prior + ", " + ip
+		}
+		out.Header.Set(HeaderForwardedFor, ip)
+	}
+	out.Header.Set(HeaderForwardedHost, req.Host)
+	return out, nil
+}
+
+func copyHeader(dst, src http.Header) {
+	for k, v := range src {
+		dst[k] = append([]string(nil), v...)
+	}
+	for _, h := range hopHeaders {
+		dst.Del(h)
+	}
+}
+
+// Start listens on the configured address and watches route changes
+func (s *Server) Start() error {
+	s.server = &http.Server{
+		Handler:           s,
+		ReadTimeout:       s.opts.Timeout,
+		WriteTimeout:      s.opts.Timeout,
+		IdleTimeout:       s.opts.Timeout,
+		ReadHeaderTimeout: s.opts.Timeout,
+	}
+	if s.opts.HeaderLimit > 0 {
+		s.server.MaxHeaderBytes = s.opts.HeaderLimit
+	}
+	sslFlag := ""
+	if s.opts.TLSConfig != nil {
+		sslFlag = openTLS
+	}
+	l, lIP, lPort, err := iputil.StartListener(s.opts.Address, s.opts.TLSConfig)
+	if err != nil {
+		return fmt.Errorf("failed to start listener: %w", err)
+	}
+	s.listener = &routeListener{s: s}
+	if err := archaius.RegisterListener(s.listener, config.EdgeKey); err != nil {
+		openlog.Error("watch edge routes failed: " + err.Error())
+	}
+	registry.InstanceEndpoints[s.opts.ProtocolServerName] = net.JoinHostPort(lIP, lPort) + sslFlag
+	go func() {
+		if err := s.server.Serve(l); err != nil && err != http.ErrServerClosed {
+			openlog.Error("edge server err: " + err.Error())
+			server.ErrRuntime <- err
+		}
+	}()
+	openlog.Info(fmt.Sprintf("edge server is listening at %s", registry.InstanceEndpoints[s.opts.ProtocolServerName]))
+	return nil
+}
+
+// Stop stops edge server, in-flight requests have drain timeout to complete
+func (s *Server) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), config.GetDrainTimeout())
+	defer cancel()
+	return s.Shutdown(ctx)
+}
+
+// Shutdown stops accepting new requests and waits for in-flight requests until ctx is done,
+// then closes all connections
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.server == nil {
+		openlog.Info("edge server never started")
+		return nil
+	}
+	if err := archaius.UnRegisterListener(s.listener, config.EdgeKey); err != nil {
+		openlog.Warn("stop watching edge routes failed: " + err.Error())
+	}
+	if err := s.server.Shutdown(ctx); err != nil {
+		if closeErr := s.server.Close(); closeErr != nil {
+			openlog.Warn("edge server close error: " + closeErr.Error())
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *Server) String() string {
+	return Name
+}
+
+// routeListener reloads all routes once any edge config changes
+type routeListener struct {
+	s *Server
+}
+
+// Event reloads routes
+func (l *routeListener) Event(e *event.Event) {
+	openlog.Info(fmt.Sprintf("edge config [%s] changed, reload routes", e.Key))
+	l.s.LoadRoutes()
+}
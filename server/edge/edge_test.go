@@ -0,0 +1,136 @@
+package edge_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/config/model"
+	"github.com/go-chassis/go-chassis/v2/core/handler"
+	"github.com/go-chassis/go-chassis/v2/core/invocation"
+	"github.com/go-chassis/go-chassis/v2/core/server"
+	"github.com/go-chassis/go-chassis/v2/server/edge"
+	"github.com/stretchr/testify/assert"
+)
+
+const echoHandler = "edge-echo"
+
+// echo writes the target of forwarded request into response instead of calling the service
+type echo struct{}
+
+func (echo) Name() string { return echoHandler }
+
+func (echo) Handle(chain *handler.Chain, i *invocation.Invocation, cb invocation.ResponseCallBack) {
+	req := i.Args.(*http.Request)
+	if i.MicroServiceName == "down" {
+		handler.WriteBackErr(errors.New("no available instance"), http.StatusBadGateway, cb)
+		return
+	}
+	resp := i.Reply.(*http.Response)
+	resp.StatusCode = http.StatusOK
+	resp.Header = http.Header{
+		"X-Service":       {i.MicroServiceName},
+		"X-Chain":         {chain.Name},
+		"X-Cookie":        {req.Header.Get("Cookie")},
+		"X-Forwarded-For": {req.Header.Get(edge.HeaderForwardedFor)},
+		"Connection":      {"close"},
+	}
+	resp.Body = io.NopCloser(bytes.NewBufferString(req.Method + " " + req.URL.RequestURI()))
+	cb(&invocation.Response{Result: resp})
+}
+
+func init() {
+	archaius.Init(archaius.WithMemorySource())
+	handler.RegisterHandler(echoHandler, func() handler.Handler { return echo{} })
+	for _, name := range []string{common.DefaultChainName, "edge-strict"} {
+		c, _ := handler.CreateChain(common.Consumer, name, echoHandler)
+		handler.ChainMap[common.Consumer+name] = c
+	}
+}
+
+func TestServer_ServeHTTP(t *testing.T) {
+	s := edge.New(server.Options{ProtocolServerName: edge.Name}).(*edge.Server)
+	s.SetRoutes(map[string]model.EdgeRoute{
+		"orders":  {Prefix: "/orders", Service: "OrderService", Rewrite: "/api/orders"},
+		"history": {Prefix: "/orders/history", Service: "HistoryService", Rewrite: "/", Chain: "edge-strict"},
+		"users":   {Prefix: "/users", Service: "UserService", StripHeaders: []string{"Cookie"}},
+		"down":    {Prefix: "/down", Service: "down"},
+		"invalid": {Prefix: "/invalid"},
+	})
+	serve := func(method, target string, h http.Header) *http.Response {
+		r := httptest.NewRequest(method, target, nil)
+		for k, v := range h {
+			r.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w.Result()
+	}
+	body := func(resp *http.Response) string {
+		b, _ := io.ReadAll(resp.Body)
+		return string(b)
+	}
+
+	t.Run("rewrite prefix", func(t *testing.T) {
+		resp := serve(http.MethodPost, "/orders/1?detail=true", http.Header{"Cookie": {"a=b"}})
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "OrderService", resp.Header.Get("X-Service"))
+		assert.Equal(t, common.DefaultChainName, resp.Header.Get("X-Chain"))
+		assert.Equal(t, "a=b", resp.Header.Get("X-Cookie"))
+		assert.Equal(t, "192.0.2.1", resp.Header.Get("X-Forwarded-For"))
+		assert.Equal(t, "", resp.Header.Get("Connection"))
+		assert.Equal(t, "POST /api/orders/1?detail=true", body(resp))
+	})
+	t.Run("longest prefix with its own chain", func(t *testing.T) {
+		resp := serve(http.MethodGet, "/orders/history/2", nil)
+		assert.Equal(t, "HistoryService", resp.Header.Get("X-Service"))
+		assert.Equal(t, "edge-strict", resp.Header.Get("X-Chain"))
+		assert.Equal(t, "GET /2", body(resp))
+	})
+	t.Run("strip headers", func(t *testing.T) {
+		resp := serve(http.MethodGet, "/users", http.Header{"Cookie": {"a=b"}, "X-Forwarded-For": {"10.0.0.1"}})
+		assert.Equal(t, "UserService", resp.Header.Get("X-Service"))
+		assert.Equal(t, "", resp.Header.Get("X-Cookie"))
+		assert.Equal(t, "10.0.0.1, 192.0.2.1", resp.Header.Get("X-Forwarded-For"))
+		assert.Equal(t, "GET /users", body(resp))
+	})
+	t.Run("prefix matches path segment", func(t *testing.T) {
+		resp := serve(http.MethodGet, "/ordersx", nil)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		resp = serve(http.MethodGet, "/invalid", nil)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+	t.Run("invocation failed", func(t *testing.T) {
+		resp := serve(http.MethodGet, "/down", nil)
+		assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	})
+}
+
+func TestServer_HotReload(t *testing.T) {
+	s := edge.New(server.Options{ProtocolServerName: edge.Name, Address: "127.0.0.1:0"}).(*edge.Server)
+	assert.NoError(t, s.Start())
+	defer s.Stop()
+	_, err := s.Register(struct{}{})
+	assert.Equal(t, edge.ErrNotSupported, err)
+
+	r := httptest.NewRequest(http.MethodGet, "/carts/1", nil)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	archaius.Set("servicecomb.edge.routes.carts.prefix", "/carts")
+	archaius.Set("servicecomb.edge.routes.carts.service", "CartService")
+	// config events are dispatched asynchronously
+	assert.Eventually(t, func() bool {
+		w = httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w.Code == http.StatusOK
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "CartService", w.Header().Get("X-Service"))
+}
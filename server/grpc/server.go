@@ -0,0 +1,258 @@
+// Package grpc is the gRPC protocol server plugin,
+// every unary call goes through the provider handler chain before reaching the service implementation
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/config"
+	"github.com/go-chassis/go-chassis/v2/core/handler"
+	"github.com/go-chassis/go-chassis/v2/core/invocation"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/go-chassis/v2/core/server"
+	"github.com/go-chassis/go-chassis/v2/core/status"
+	"github.com/go-chassis/go-chassis/v2/pkg/runtime"
+	"github.com/go-chassis/go-chassis/v2/pkg/util/iputil"
+	"github.com/go-chassis/openlog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+// Name is the protocol name of gRPC server
+const Name = common.ProtocolGRPC
+
+const openTLS = "?sslEnabled=true"
+
+// ErrInvalidDesc means schema is registered without a *grpc.ServiceDesc
+var ErrInvalidDesc = errors.New("gRPC schema must be registered with server.WithRPCServiceDesc(*grpc.ServiceDesc)")
+
+func init() {
+	server.InstallPlugin(Name, New)
+	status.Register(Name, map[string]int{
+		status.Unauthorized:        int(codes.Unauthenticated),
+		status.InternalServerError: int(codes.Internal),
+		status.ServiceUnavailable:  int(codes.Unavailable),
+	})
+}
+
+// Server is gRPC server plugin
+type Server struct {
+	s    *grpc.Server
+	opts server.Options
+	mux  sync.RWMutex
+}
+
+// New returns a gRPC protocol server
+func New(opts server.Options) server.ProtocolServer {
+	gs := &Server{opts: opts}
+	var grpcOpts = []grpc.ServerOption{grpc.UnaryInterceptor(gs.intercept)}
+	if opts.TLSConfig != nil {
+		grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(opts.TLSConfig)))
+	}
+	if opts.BodyLimit > 0 {
+		grpcOpts = append(grpcOpts, grpc.MaxRecvMsgSize(int(opts.BodyLimit)))
+	}
+	if opts.HeaderLimit > 0 {
+		grpcOpts = append(grpcOpts, grpc.MaxHeaderListSize(uint32(opts.HeaderLimit)))
+	}
+	if opts.Timeout > 0 {
+		grpcOpts = append(grpcOpts, grpc.ConnectionTimeout(opts.Timeout))
+	}
+	gs.s = grpc.NewServer(grpcOpts...)
+	return gs
+}
+
+// Register registers a service implementation with its *grpc.ServiceDesc, the schema id is the full service name
+func (gs *Server) Register(schema interface{}, options ...server.RegisterOption) (string, error) {
+	opts := server.RegisterOptions{}
+	for _, o := range options {
+		o(&opts)
+	}
+	desc, ok := opts.RPCSvcDesc.(*grpc.ServiceDesc)
+	if !ok {
+		return "", ErrInvalidDesc
+	}
+	gs.mux.Lock()
+	defer gs.mux.Unlock()
+	gs.s.RegisterService(desc, schema)
+	openlog.Info(fmt.Sprintf("gRPC service registered is [%s]", desc.ServiceName))
+	return desc.ServiceName, nil
+}
+
+// Start listens on the configured address and serves gRPC
+func (gs *Server) Start() error {
+	// TLS handshake is done by gRPC credentials, do not wrap the listener
+	l, lIP, lPort, err := iputil.StartListener(gs.opts.Address, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start listener: %w", err)
+	}
+	sslFlag := ""
+	if gs.opts.TLSConfig != nil {
+		sslFlag = openTLS
+	}
+	registry.InstanceEndpoints[gs.opts.ProtocolServerName] = net.JoinHostPort(lIP, lPort) + sslFlag
+	go func() {
+		if err := gs.s.Serve(l); err != nil {
+			openlog.Error("gRPC server err: " + err.Error())
+			server.ErrRuntime <- err
+		}
+	}()
+	openlog.Info(fmt.Sprintf("gRPC server is listening at %s", registry.InstanceEndpoints[gs.opts.ProtocolServerName]))
+	return nil
+}
+
+// Stop waits for pending RPCs to finish in drain timeout, then stops the server
+func (gs *Server) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), config.GetDrainTimeout())
+	defer cancel()
+	return gs.Shutdown(ctx)
+}
+
+// Shutdown waits for pending RPCs until ctx is done, then closes all connections
+func (gs *Server) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		gs.s.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		gs.s.Stop()
+		return ctx.Err()
+	}
+}
+
+func (gs *Server) String() string {
+	return Name
+}
+
+// intercept converts a unary call to invocation and runs it through provider chain,
+// the last handler of the chain calls the real service method
+func (gs *Server) intercept(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, h grpc.UnaryHandler) (interface{}, error) {
+	done := server.TrackRequest(gs.opts.ProtocolServerName)
+	defer done()
+	c, err := handler.GetChain(common.Provider, gs.opts.ChainName)
+	if err != nil {
+		openlog.Error("handler chain init err: " + err.Error())
+		return nil, grpcstatus.Error(codes.Internal, err.Error())
+	}
+	inv := Request2Invocation(ctx, req, info.FullMethod)
+	var cancel context.CancelFunc
+	inv.Ctx, cancel = common.WithHeaderDeadline(inv.Ctx, inv.Headers())
+	defer cancel()
+	chain := c.Clone()
+	chain.AddHandler(&invokeHandler{h: h})
+	var resp interface{}
+	chain.Next(inv, func(ir *invocation.Response) {
+		resp = ir.Result
+		err = toStatusError(ir)
+	})
+	return resp, err
+}
+
+// Request2Invocation converts gRPC request to invocation,
+// incoming metadata is saved as invocation headers
+func Request2Invocation(ctx context.Context, req interface{}, fullMethod string) *invocation.Invocation {
+	schemaID, operationID := SplitMethod(fullMethod)
+	m := make(map[string]string)
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for k, v := range md {
+			if len(v) > 0 {
+				m[k] = v[0]
+			}
+		}
+	}
+	inv := invocation.New(context.WithValue(ctx, common.ContextHeaderKey{}, m))
+	inv.MicroServiceName = runtime.ServiceName
+	inv.SourceMicroService = m[common.HeaderSourceName]
+	inv.Protocol = Name
+	inv.SchemaID = schemaID
+	inv.OperationID = operationID
+	inv.URLPath = fullMethod
+	inv.Args = req
+	inv.SetMetadata(common.RestRoutePath, fullMethod)
+	return inv
+}
+
+// SplitMethod splits "/package.Service/Method" into service name and method name
+func SplitMethod(fullMethod string) (string, string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	i := strings.LastIndex(fullMethod, "/")
+	if i < 0 {
+		return fullMethod, ""
+	}
+	return fullMethod[:i], fullMethod[i+1:]
+}
+
+// toStatusError keeps gRPC status error returned by service method,
+// other errors written back by handlers are converted with the response status as code
+func toStatusError(ir *invocation.Response) error {
+	if ir.Err == nil {
+		return nil
+	}
+	if _, ok := grpcstatus.FromError(ir.Err); ok || ir.Status == 0 {
+		return ir.Err
+	}
+	return grpcstatus.Error(toCode(ir.Status), ir.Err.Error())
+}
+
+// toCode converts response status to gRPC code, handlers write back gRPC codes registered in status package,
+// or HTTP statuses, like rate limiter and fault injection do
+func toCode(s int) codes.Code {
+	if s > int(codes.OK) && s <= int(codes.Unauthenticated) {
+		return codes.Code(s)
+	}
+	switch s {
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusConflict, http.StatusPreconditionFailed:
+		return codes.FailedPrecondition
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable, http.StatusBadGateway:
+		return codes.Unavailable
+	case http.StatusInternalServerError:
+		return codes.Internal
+	}
+	if s >= http.StatusBadRequest && s < http.StatusInternalServerError {
+		return codes.InvalidArgument
+	}
+	return codes.Unknown
+}
+
+type invokeHandler struct {
+	h grpc.UnaryHandler
+}
+
+func (ih *invokeHandler) Handle(chain *handler.Chain, i *invocation.Invocation, cb invocation.ResponseCallBack) {
+	resp, err := ih.h(i.Ctx, i.Args)
+	r := &invocation.Response{Result: resp, Err: err}
+	if err != nil {
+		r.Status = int(grpcstatus.Code(err))
+	}
+	cb(r)
+}
+
+func (ih *invokeHandler) Name() string {
+	return "grpc-invoke"
+}
@@ -0,0 +1,126 @@
+package grpc_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-chassis/go-chassis/v2/control"
+	_ "github.com/go-chassis/go-chassis/v2/control/servicecomb"
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/config"
+	"github.com/go-chassis/go-chassis/v2/core/handler"
+	"github.com/go-chassis/go-chassis/v2/core/invocation"
+	"github.com/go-chassis/go-chassis/v2/core/lager"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/go-chassis/v2/core/server"
+	"github.com/go-chassis/go-chassis/v2/core/status"
+	"github.com/go-chassis/go-chassis/v2/examples/schemas"
+	"github.com/go-chassis/go-chassis/v2/middleware/ratelimiter"
+	grpcserver "github.com/go-chassis/go-chassis/v2/server/grpc"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	grpcstatus "google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func init() {
+	lager.Init(&lager.Options{
+		LoggerLevel: "INFO",
+	})
+	archaius.Init(archaius.WithMemorySource())
+	config.ReadGlobalConfigFromArchaius()
+}
+
+type authHandler struct{}
+
+func (h *authHandler) Handle(chain *handler.Chain, i *invocation.Invocation, cb invocation.ResponseCallBack) {
+	if i.Header("token") == "" {
+		handler.WriteBackErr(errors.New("no token"), status.Status(i.Protocol, status.Unauthorized), cb)
+		return
+	}
+	chain.Next(i, cb)
+}
+
+func (h *authHandler) Name() string {
+	return "grpc-test-auth"
+}
+
+func TestServer(t *testing.T) {
+	err := handler.RegisterHandler("grpc-test-auth", func() handler.Handler { return &authHandler{} })
+	assert.NoError(t, err)
+	err = handler.CreateChains(common.Provider, map[string]string{"grpc-test": "grpc-test-auth"})
+	assert.NoError(t, err)
+
+	f, err := server.GetServerFunc("grpc")
+	assert.NoError(t, err)
+	s := f(server.Options{
+		Address:            "127.0.0.1:0",
+		ProtocolServerName: "grpc",
+		ChainName:          "grpc-test",
+	})
+	assert.Equal(t, "grpc", s.String())
+
+	_, err = s.Register(&schemas.GrpcHello{})
+	assert.Equal(t, grpcserver.ErrInvalidDesc, err)
+	id, err := s.Register(&schemas.GrpcHello{}, server.WithRPCServiceDesc(&schemas.GreeterServiceDesc))
+	assert.NoError(t, err)
+	assert.Equal(t, "helloworld.Greeter", id)
+
+	err = s.Start()
+	assert.NoError(t, err)
+	defer s.Stop()
+
+	conn, err := grpc.Dial(registry.InstanceEndpoints["grpc"], grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	t.Run("call without token, should be rejected by provider chain", func(t *testing.T) {
+		reply := &wrapperspb.StringValue{}
+		err := conn.Invoke(context.Background(), "/helloworld.Greeter/SayHello", wrapperspb.String("peter"), reply)
+		assert.Equal(t, codes.Unauthenticated, grpcstatus.Code(err))
+	})
+	t.Run("call with token, should success", func(t *testing.T) {
+		reply := &wrapperspb.StringValue{}
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "token", "abc")
+		err := conn.Invoke(ctx, "/helloworld.Greeter/SayHello", wrapperspb.String("peter"), reply)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello peter", reply.GetValue())
+	})
+}
+
+func TestServer_RateLimited(t *testing.T) {
+	assert.NoError(t, control.Init(control.Options{}))
+	archaius.Set("cse.flowcontrol.Provider.qps.global.limit", 0)
+	defer archaius.Delete("cse.flowcontrol.Provider.qps.global.limit")
+	err := handler.CreateChains(common.Provider, map[string]string{"grpc-limited": ratelimiter.Provider})
+	assert.NoError(t, err)
+
+	f, err := server.GetServerFunc("grpc")
+	assert.NoError(t, err)
+	s := f(server.Options{
+		Address:            "127.0.0.1:0",
+		ProtocolServerName: "grpc",
+		ChainName:          "grpc-limited",
+	})
+	_, err = s.Register(&schemas.GrpcHello{}, server.WithRPCServiceDesc(&schemas.GreeterServiceDesc))
+	assert.NoError(t, err)
+	assert.NoError(t, s.Start())
+	defer s.Stop()
+
+	conn, err := grpc.Dial(registry.InstanceEndpoints["grpc"], grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	defer conn.Close()
+	err = conn.Invoke(context.Background(), "/helloworld.Greeter/SayHello", wrapperspb.String("peter"), &wrapperspb.StringValue{})
+	assert.Equal(t, codes.ResourceExhausted, grpcstatus.Code(err), "http status 429 of rate limiter is converted")
+}
+
+func TestSplitMethod(t *testing.T) {
+	s, o := grpcserver.SplitMethod("/helloworld.Greeter/SayHello")
+	assert.Equal(t, "helloworld.Greeter", s)
+	assert.Equal(t, "SayHello", o)
+}
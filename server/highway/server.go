@@ -0,0 +1,293 @@
+// Package highway is the highway protocol server plugin,
+// requests go through provider handler chain then dispatch to provider
+package highway
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/config"
+	"github.com/go-chassis/go-chassis/v2/core/handler"
+	"github.com/go-chassis/go-chassis/v2/core/invocation"
+	"github.com/go-chassis/go-chassis/v2/core/provider"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/go-chassis/v2/core/server"
+	"github.com/go-chassis/go-chassis/v2/pkg/highway"
+	"github.com/go-chassis/go-chassis/v2/pkg/runtime"
+	"github.com/go-chassis/go-chassis/v2/pkg/util/iputil"
+	"github.com/go-chassis/openlog"
+)
+
+// Name is the protocol name of highway server
+const Name = common.ProtocolHighway
+
+const openTLS = "?sslEnabled=true"
+
+// MaxConcurrentRequests is the max number of requests handled at the same time on one connection,
+// server stops reading the connection until one of them is done
+const MaxConcurrentRequests = 1000
+
+func init() {
+	server.InstallPlugin(Name, New)
+}
+
+// Server is highway server plugin
+type Server struct {
+	opts     server.Options
+	provider provider.Provider
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+}
+
+// New returns a highway protocol server, schemas are registered to the provider of this micro service
+func New(opts server.Options) server.ProtocolServer {
+	p := opts.Provider
+	if p == nil {
+		var err error
+		p, err = provider.GetProvider(runtime.ServiceName)
+		if err != nil {
+			p = provider.RegisterProvider(common.DefaultProvider, runtime.ServiceName)
+		}
+	}
+	return &Server{
+		opts:     opts,
+		provider: p,
+		conns:    make(map[net.Conn]struct{}),
+	}
+}
+
+// Register registers a schema to provider, you can specify schema id with server.WithSchemaID
+func (s *Server) Register(schema interface{}, options ...server.RegisterOption) (string, error) {
+	opts := server.RegisterOptions{}
+	for _, o := range options {
+		o(&opts)
+	}
+	if opts.SchemaID != "" {
+		if err := s.provider.RegisterName(opts.SchemaID, schema); err != nil {
+			return "", err
+		}
+		return opts.SchemaID, nil
+	}
+	return s.provider.Register(schema)
+}
+
+// Start listens on the configured address and serves highway
+func (s *Server) Start() error {
+	l, lIP, lPort, err := iputil.StartListener(s.opts.Address, s.opts.TLSConfig)
+	if err != nil {
+		return fmt.Errorf("failed to start listener: %w", err)
+	}
+	sslFlag := ""
+	if s.opts.TLSConfig != nil {
+		sslFlag = openTLS
+	}
+	s.mu.Lock()
+	s.listener = l
+	s.mu.Unlock()
+	registry.InstanceEndpoints[s.opts.ProtocolServerName] = net.JoinHostPort(lIP, lPort) + sslFlag
+	go s.accept(l)
+	openlog.Info(fmt.Sprintf("highway server is listening at %s", registry.InstanceEndpoints[s.opts.ProtocolServerName]))
+	return nil
+}
+
+func (s *Server) accept(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if !closed {
+				openlog.Error("highway server err: " + err.Error())
+				server.ErrRuntime <- err
+			}
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+// serveConn reads request frames from one connection, every request is handled in its own goroutine,
+// no more than MaxConcurrentRequests at the same time,
+// responses are written back with the same msg id, so that client can multiplex a connection
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+	r := bufio.NewReader(conn)
+	var wmu sync.Mutex
+	sem := make(chan struct{}, MaxConcurrentRequests)
+	for {
+		f, err := highway.ReadFrame(r, s.opts.BodyLimit)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) && !errors.Is(err, io.EOF) {
+				openlog.Debug("highway connection closed: " + err.Error())
+			}
+			return
+		}
+		sem <- struct{}{}
+		done := server.TrackRequest(s.opts.ProtocolServerName)
+		go func() {
+			defer func() {
+				done()
+				<-sem
+			}()
+			rf := s.handle(f)
+			wmu.Lock()
+			defer wmu.Unlock()
+			if err := highway.WriteFrame(conn, rf); err != nil {
+				openlog.Error("write highway response failed: " + err.Error())
+			}
+		}()
+	}
+}
+
+func (s *Server) handle(f *highway.Frame) *highway.Frame {
+	rh := &highway.ResponseHeader{StatusCode: http.StatusOK}
+	rf := &highway.Frame{MsgID: f.MsgID}
+	body, err := s.invoke(f, rh)
+	if err != nil {
+		if rh.StatusCode == http.StatusOK {
+			rh.StatusCode = http.StatusInternalServerError
+		}
+		rh.ReasonPhrase = err.Error()
+	} else {
+		rf.Body = body
+	}
+	rf.Header = rh.Marshal()
+	return rf
+}
+
+func (s *Server) invoke(f *highway.Frame, rh *highway.ResponseHeader) ([]byte, error) {
+	h := &highway.RequestHeader{}
+	if err := h.Unmarshal(f.Header); err != nil {
+		rh.StatusCode = http.StatusBadRequest
+		return nil, err
+	}
+	if h.MsgType != highway.MsgTypeRequest {
+		// login request does not need a response body
+		return nil, nil
+	}
+	op, err := s.provider.GetOperation(h.SchemaID, h.OperationName)
+	if err != nil {
+		rh.StatusCode = http.StatusNotFound
+		return nil, err
+	}
+	arg := reflect.New(op.Args()[1].Elem()).Interface()
+	if err := highway.DecodeBody(f.Body, arg); err != nil {
+		rh.StatusCode = http.StatusBadRequest
+		return nil, fmt.Errorf("decode highway request failed: %w", err)
+	}
+	c, err := handler.GetChain(common.Provider, s.opts.ChainName)
+	if err != nil {
+		openlog.Error("handler chain init err: " + err.Error())
+		return nil, err
+	}
+	inv := Request2Invocation(h, arg)
+	var cancel context.CancelFunc
+	inv.Ctx, cancel = common.WithHeaderDeadline(inv.Ctx, inv.Headers())
+	defer cancel()
+	chain := c.Clone()
+	chain.AddHandler(&invokeHandler{p: s.provider})
+	var body []byte
+	chain.Next(inv, func(ir *invocation.Response) {
+		if ir.Err != nil {
+			err = ir.Err
+			if ir.Status != 0 {
+				rh.StatusCode = int32(ir.Status)
+			}
+			return
+		}
+		body, err = highway.EncodeBody(ir.Result)
+	})
+	return body, err
+}
+
+// Request2Invocation converts highway request to invocation, request context is saved as invocation headers
+func Request2Invocation(h *highway.RequestHeader, arg interface{}) *invocation.Invocation {
+	m := h.Context
+	if m == nil {
+		m = make(map[string]string)
+	}
+	inv := invocation.New(common.NewContext(m))
+	inv.MicroServiceName = runtime.ServiceName
+	inv.SourceMicroService = m[common.HeaderSourceName]
+	inv.Protocol = Name
+	inv.SchemaID = h.SchemaID
+	inv.OperationID = h.OperationName
+	inv.Args = arg
+	inv.SetMetadata(common.RestRoutePath, h.SchemaID+"/"+h.OperationName)
+	return inv
+}
+
+// Stop closes listener, waits for in-flight requests in drain timeout, then closes all connections
+func (s *Server) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), config.GetDrainTimeout())
+	defer cancel()
+	return s.Shutdown(ctx)
+}
+
+// Shutdown closes listener and waits for in-flight requests until ctx is done, then closes all connections
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if s.listener == nil {
+		s.mu.Unlock()
+		openlog.Info("highway server never started")
+		return nil
+	}
+	s.closed = true
+	err := s.listener.Close()
+	s.mu.Unlock()
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for server.InFlight(s.opts.ProtocolServerName) > 0 && ctx.Err() == nil {
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+	}
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	if err == nil {
+		err = ctx.Err()
+	}
+	return err
+}
+
+func (s *Server) String() string {
+	return Name
+}
+
+type invokeHandler struct {
+	p provider.Provider
+}
+
+func (ih *invokeHandler) Handle(chain *handler.Chain, i *invocation.Invocation, cb invocation.ResponseCallBack) {
+	result, err := ih.p.Invoke(i)
+	cb(&invocation.Response{Result: result, Err: err})
+}
+
+func (ih *invokeHandler) Name() string {
+	return "highway-invoke"
+}
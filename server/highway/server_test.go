@@ -0,0 +1,141 @@
+package highway_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/go-archaius"
+	hwclient "github.com/go-chassis/go-chassis/v2/client/highway"
+	"github.com/go-chassis/go-chassis/v2/core/client"
+	"github.com/go-chassis/go-chassis/v2/core/common"
+	"github.com/go-chassis/go-chassis/v2/core/config"
+	"github.com/go-chassis/go-chassis/v2/core/handler"
+	"github.com/go-chassis/go-chassis/v2/core/invocation"
+	"github.com/go-chassis/go-chassis/v2/core/lager"
+	"github.com/go-chassis/go-chassis/v2/core/provider"
+	"github.com/go-chassis/go-chassis/v2/core/registry"
+	"github.com/go-chassis/go-chassis/v2/core/server"
+	"github.com/go-chassis/go-chassis/v2/core/status"
+	"github.com/go-chassis/go-chassis/v2/examples/schemas"
+	"github.com/go-chassis/go-chassis/v2/examples/schemas/helloworld"
+	"github.com/go-chassis/go-chassis/v2/pkg/highway"
+	_ "github.com/go-chassis/go-chassis/v2/server/highway"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	lager.Init(&lager.Options{
+		LoggerLevel: "INFO",
+	})
+	archaius.Init(archaius.WithMemorySource())
+	config.ReadGlobalConfigFromArchaius()
+}
+
+type rejectHandler struct{}
+
+func (h *rejectHandler) Handle(chain *handler.Chain, i *invocation.Invocation, cb invocation.ResponseCallBack) {
+	if i.Header("user") == "" {
+		handler.WriteBackErr(errors.New("no user"), status.Status(i.Protocol, status.Unauthorized), cb)
+		return
+	}
+	if _, ok := i.Ctx.Deadline(); !ok && i.Header("want-deadline") != "" {
+		handler.WriteBackErr(errors.New("no deadline"), http.StatusBadRequest, cb)
+		return
+	}
+	chain.Next(i, cb)
+}
+
+func (h *rejectHandler) Name() string {
+	return "highway-test-reject"
+}
+
+func TestServer(t *testing.T) {
+	err := handler.RegisterHandler("highway-test-reject", func() handler.Handler { return &rejectHandler{} })
+	assert.NoError(t, err)
+	err = handler.CreateChains(common.Provider, map[string]string{"highway-test": "highway-test-reject"})
+	assert.NoError(t, err)
+
+	f, err := server.GetServerFunc("highway")
+	assert.NoError(t, err)
+	s := f(server.Options{
+		Address:            "127.0.0.1:0",
+		ProtocolServerName: "highway",
+		ChainName:          "highway-test",
+		Provider:           provider.NewProvider("Server"),
+	})
+	assert.Equal(t, "highway", s.String())
+	id, err := s.Register(&schemas.HelloServer{})
+	assert.NoError(t, err)
+	assert.Equal(t, "HelloServer", id)
+	err = s.Start()
+	assert.NoError(t, err)
+	addr := registry.InstanceEndpoints["highway"]
+
+	c, err := hwclient.New(client.Options{Timeout: 3 * time.Second})
+	assert.NoError(t, err)
+	defer c.Close()
+	newInv := func(operation string) *invocation.Invocation {
+		inv := invocation.New(context.Background())
+		inv.MicroServiceName = "Server"
+		inv.SchemaID = "HelloServer"
+		inv.OperationID = operation
+		inv.Args = &helloworld.HelloRequest{Name: "peter"}
+		return inv
+	}
+
+	t.Run("concurrent calls on one connection, should success", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for n := 0; n < 20; n++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				inv := newInv("SayHello")
+				inv.SetHeader("user", "peter")
+				reply := &helloworld.HelloReply{}
+				err := c.Call(inv.Ctx, addr, inv, reply)
+				assert.NoError(t, err)
+				assert.Equal(t, "Go Hello  peter", reply.Message)
+			}()
+		}
+		wg.Wait()
+	})
+	t.Run("call without header, should be rejected by provider chain", func(t *testing.T) {
+		inv := newInv("SayHello")
+		err := c.Call(inv.Ctx, addr, inv, &helloworld.HelloReply{})
+		var se highway.StatusError
+		assert.True(t, errors.As(err, &se))
+		assert.Equal(t, http.StatusUnauthorized, se.Code)
+	})
+	t.Run("timeout header becomes provider context deadline", func(t *testing.T) {
+		inv := newInv("SayHello")
+		inv.SetHeader("user", "peter")
+		inv.SetHeader("want-deadline", "true")
+		err := c.Call(inv.Ctx, addr, inv, &helloworld.HelloReply{})
+		var se highway.StatusError
+		assert.True(t, errors.As(err, &se))
+		assert.Equal(t, http.StatusBadRequest, se.Code)
+
+		inv.SetHeader(common.HeaderTimeout, "2000")
+		err = c.Call(inv.Ctx, addr, inv, &helloworld.HelloReply{})
+		assert.NoError(t, err)
+	})
+	t.Run("call unknown operation, should fail", func(t *testing.T) {
+		inv := newInv("SayBye")
+		inv.SetHeader("user", "peter")
+		err := c.Call(inv.Ctx, addr, inv, &helloworld.HelloReply{})
+		var se highway.StatusError
+		assert.True(t, errors.As(err, &se))
+		assert.Equal(t, http.StatusNotFound, se.Code)
+	})
+	t.Run("call after server stopped, should get transport failure", func(t *testing.T) {
+		assert.NoError(t, s.Stop())
+		inv := newInv("SayHello")
+		inv.SetHeader("user", "peter")
+		err := c.Call(inv.Ctx, addr, inv, &helloworld.HelloReply{})
+		assert.Error(t, err)
+	})
+}
@@ -21,6 +21,7 @@ import (
 	"github.com/go-chassis/go-chassis/v2/core/invocation"
 	"github.com/go-chassis/go-chassis/v2/core/registry"
 	"github.com/go-chassis/go-chassis/v2/core/server"
+	"github.com/go-chassis/go-chassis/v2/pkg/compression"
 	"github.com/go-chassis/go-chassis/v2/pkg/profile"
 	"github.com/go-chassis/go-chassis/v2/pkg/runtime"
 	"github.com/go-chassis/go-chassis/v2/pkg/util/iputil"
@@ -130,6 +131,11 @@ func HTTPRequest2Invocation(req *restful.Request, schema, operation string, resp
 	for k := range req.Request.Header {
 		m[k] = req.Request.Header.Get(k)
 	}
+	if _, ok := common.TimeoutFromHeaders(m); ok {
+		// derived from request context, net/http cancels it once handler returns,
+		// which cancels the deadline and releases its timer as well
+		inv.Ctx, _ = common.WithHeaderDeadline(context.WithValue(req.Request.Context(), common.ContextHeaderKey{}, m), m)
+	}
 	return inv, nil
 }
 
@@ -256,7 +262,7 @@ func (r *restfulServer) Start() error {
 	sslFlag := ""
 	r.server = &http.Server{
 		Addr:              config.Address,
-		Handler:           r.container,
+		Handler:           r.track(compression.Handler(r.container, compressionOptions)),
 		ReadTimeout:       r.opts.Timeout,
 		WriteTimeout:      r.opts.Timeout,
 		IdleTimeout:       r.opts.Timeout,
@@ -295,6 +301,17 @@ func (r *restfulServer) Start() error {
 	return nil
 }
 
+// compressionOptions decides response compression by the config of consumer service
+func compressionOptions(req *http.Request) *compression.Options {
+	if !globalconfig.GetCompressionEnabled(common.GetXCSEContext(common.HeaderSourceName, req)) {
+		return nil
+	}
+	return &compression.Options{
+		Encodings: globalconfig.GetCompressionEncodings(),
+		MinSize:   globalconfig.GetCompressionMinSize(),
+	}
+}
+
 // register to swagger ui,Whether to create a schema, you need to refer to the configuration.
 func (r *restfulServer) CreateLocalSchema(opts server.Options) error {
 	var path string
@@ -343,14 +360,42 @@ func (r *restfulServer) Stop() error {
 		openlog.Info("http server never started")
 		return nil
 	}
-	//only golang 1.8 support graceful shutdown.
-	if err := r.server.Shutdown(context.TODO()); err != nil {
+	ctx, cancel := context.WithTimeout(context.Background(), globalconfig.GetDrainTimeout())
+	defer cancel()
+	return r.Shutdown(ctx)
+}
+
+// Shutdown stops accepting new requests and waits for in-flight requests until ctx is done,
+// then closes all connections
+func (r *restfulServer) Shutdown(ctx context.Context) error {
+	if r.server == nil {
+		openlog.Info("http server never started")
+		return nil
+	}
+	if err := r.server.Shutdown(ctx); err != nil {
 		openlog.Warn("http shutdown error: " + err.Error())
+		if closeErr := r.server.Close(); closeErr != nil {
+			openlog.Warn("http close error: " + closeErr.Error())
+		}
 		return err // failure/timeout shutting down the server gracefully
 	}
 	return nil
 }
 
+// track counts in-flight requests, so that drain progress is known when server stops
+func (r *restfulServer) track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		done := server.TrackRequest(r.opts.ProtocolServerName)
+		defer done()
+		next.ServeHTTP(w, req)
+	})
+}
+
+// ServeHTTP serves request with registered routes directly, it is used by local transport
+func (r *restfulServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.container.ServeHTTP(w, req)
+}
+
 func (r *restfulServer) String() string {
 	return Name
 }
@@ -1,13 +1,17 @@
 package restful
 
 import (
+	"context"
 	"github.com/go-chassis/go-archaius"
 	"github.com/go-chassis/go-chassis/v2/core/lager"
 	"log"
 	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	rf "github.com/emicklei/go-restful"
+	"github.com/go-chassis/go-chassis/v2/core/common"
 	"github.com/go-chassis/go-chassis/v2/core/config"
 	"github.com/go-chassis/go-chassis/v2/core/server"
 	"github.com/stretchr/testify/assert"
@@ -159,6 +163,43 @@ func TestFillParam(t *testing.T) {
 
 }
 
+func TestHTTPRequest2Invocation_Deadline(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/sayhello", nil)
+	inv, err := HTTPRequest2Invocation(rf.NewRequest(r), "schema", "op", nil)
+	assert.NoError(t, err)
+	_, ok := inv.Ctx.Deadline()
+	assert.False(t, ok)
+
+	// net/http cancels request context once handler returns
+	ctx, cancel := context.WithCancel(context.Background())
+	r = r.WithContext(ctx)
+	r.Header.Set(common.HeaderTimeout, "2000")
+	inv, err = HTTPRequest2Invocation(rf.NewRequest(r), "schema", "op", nil)
+	assert.NoError(t, err)
+	d, ok := inv.Ctx.Deadline()
+	assert.True(t, ok)
+	assert.True(t, time.Until(d) <= 2*time.Second)
+	assert.Equal(t, "2000", inv.Header("X-Cse-Timeout"))
+	cancel()
+	assert.Equal(t, context.Canceled, inv.Ctx.Err())
+}
+
+func TestCompressionOptions(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/sayhello", nil)
+	assert.Nil(t, compressionOptions(r))
+
+	archaius.Set("servicecomb.transport.compression.enabled", true)
+	defer archaius.Delete("servicecomb.transport.compression.enabled")
+	o := compressionOptions(r)
+	assert.Equal(t, []string{"gzip", "deflate"}, o.Encodings)
+	assert.Equal(t, 1024, o.MinSize)
+
+	archaius.Set("servicecomb.transport.compression.Client.enabled", false)
+	defer archaius.Delete("servicecomb.transport.compression.Client.enabled")
+	r.Header.Set(common.HeaderSourceName, "Client")
+	assert.Nil(t, compressionOptions(r))
+}
+
 var schemaTestProduces = []string{"application/json"}
 var schemaTestConsumes = []string{"application/xml"}
 var schemaTestRoutes = []Route{